@@ -62,6 +62,26 @@ const (
 	BatchPolicyCustom = "custom"
 )
 
+const (
+	// GrpcCompressionNone disables the compression.
+	GrpcCompressionNone = "none"
+	// GrpcCompressionGzip compresses the messages by gzip.
+	GrpcCompressionGzip = gzip.Name
+	// GrpcCompressionSnappy compresses the messages by snappy.
+	GrpcCompressionSnappy = "snappy"
+	// GrpcCompressionZstd compresses the messages by zstd.
+	GrpcCompressionZstd = "zstd"
+)
+
+// IsValidGrpcCompressionType checks whether the compression type is supported.
+func IsValidGrpcCompressionType(tp string) bool {
+	switch tp {
+	case GrpcCompressionNone, GrpcCompressionGzip, GrpcCompressionSnappy, GrpcCompressionZstd:
+		return true
+	}
+	return false
+}
+
 // TiKVClient is the config for tikv client.
 type TiKVClient struct {
 	// GrpcConnectionCount is the max gRPC connections that will be established
@@ -73,8 +93,13 @@ type TiKVClient struct {
 	// After having pinged for keepalive check, the client waits for a duration of Timeout in seconds
 	// and if no activity is seen even after that the connection is closed.
 	GrpcKeepAliveTimeout float64 `toml:"grpc-keepalive-timeout" json:"grpc-keepalive-timeout"`
-	// GrpcCompressionType is the compression type for gRPC channel: none or gzip.
+	// GrpcCompressionType is the compression type for gRPC channel: none, gzip, snappy or zstd.
+	// TiKV decompresses gzip but not snappy or zstd, which are used only if the server advertises
+	// them by the grpc-accept-encoding header, otherwise the messages are sent uncompressed.
 	GrpcCompressionType string `toml:"grpc-compression-type" json:"grpc-compression-type"`
+	// GrpcCompressionPolicies overrides GrpcCompressionType for the requests of the given command
+	// types, e.g. "Prewrite" or "RawBatchPut".
+	GrpcCompressionPolicies map[string]GrpcCompressionPolicy `toml:"grpc-compression-policies" json:"grpc-compression-policies"`
 	// GrpcSharedBufferPool is the flag to control whether to share the buffer pool in the TiKV gRPC clients.
 	GrpcSharedBufferPool bool `toml:"grpc-shared-buffer-pool" json:"grpc-shared-buffer-pool"`
 	// GrpcInitialWindowSize is the value for initial window size on a stream.
@@ -103,6 +128,9 @@ type TiKVClient struct {
 	// If a store has been up to the limit, it will return error for successive request to
 	// prevent the store occupying too much token in dispatching level.
 	StoreLimit int64 `toml:"store-limit" json:"store-limit"`
+	// AdaptiveStoreLimit replaces StoreLimit by a concurrency limit adapted to the latency and
+	// the load of each store if it's enabled.
+	AdaptiveStoreLimit AdaptiveStoreLimit `toml:"adaptive-store-limit" json:"adaptive-store-limit"`
 	// StoreLivenessTimeout is the timeout for store liveness check request.
 	StoreLivenessTimeout string           `toml:"store-liveness-timeout" json:"store-liveness-timeout"`
 	CoprCache            CoprocessorCache `toml:"copr-cache" json:"copr-cache"`
@@ -134,6 +162,34 @@ type AsyncCommit struct {
 	AllowedClockDrift time.Duration `toml:"allowed-clock-drift" json:"allowed-clock-drift"`
 }
 
+// GrpcCompressionPolicy is the compression policy for the requests of a command type.
+type GrpcCompressionPolicy struct {
+	// Type is the compression type: none, gzip, snappy or zstd. Like GrpcCompressionType, snappy
+	// and zstd are used only if the server supports them.
+	Type string `toml:"type" json:"type"`
+	// MinSize is the min size in bytes of the requests to compress, the smaller ones are sent
+	// without compression.
+	MinSize int `toml:"min-size" json:"min-size"`
+}
+
+// AdaptiveStoreLimit is the config for the adaptive concurrency limit of the requests sent to each store.
+type AdaptiveStoreLimit struct {
+	// Enable enables the adaptive limit. StoreLimit is ignored if it's enabled.
+	Enable bool `toml:"enable" json:"enable"`
+	// MinLimit and MaxLimit are the bounds of the concurrency limit of each store, the limit
+	// starts from MinLimit.
+	MinLimit int64 `toml:"min-limit" json:"min-limit"`
+	MaxLimit int64 `toml:"max-limit" json:"max-limit"`
+	// The limit is decreased if the latency of the requests exceeds the minimum latency
+	// observed recently multiplied by LatencyTolerance.
+	LatencyTolerance float64 `toml:"latency-tolerance" json:"latency-tolerance"`
+	// MaxQueueSize is the max number of requests waiting for the slots of a store, the
+	// requests exceeding it fail immediately.
+	MaxQueueSize int64 `toml:"max-queue-size" json:"max-queue-size"`
+	// MaxQueueWait is the max duration a request waits for a slot.
+	MaxQueueWait time.Duration `toml:"max-queue-wait" json:"max-queue-wait"`
+}
+
 // CoprocessorCache is the config for coprocessor cache.
 type CoprocessorCache struct {
 	// The capacity in MB of the cache. Zero means disable coprocessor cache.
@@ -155,7 +211,7 @@ func DefaultTiKVClient() TiKVClient {
 		GrpcConnectionCount:       4,
 		GrpcKeepAliveTime:         10,
 		GrpcKeepAliveTimeout:      3,
-		GrpcCompressionType:       "none",
+		GrpcCompressionType:       GrpcCompressionNone,
 		GrpcSharedBufferPool:      false,
 		GrpcInitialWindowSize:     DefGrpcInitialWindowSize,
 		GrpcInitialConnWindowSize: DefGrpcInitialConnWindowSize,
@@ -180,6 +236,15 @@ func DefaultTiKVClient() TiKVClient {
 		StoreLimit:           0,
 		StoreLivenessTimeout: DefStoreLivenessTimeout,
 
+		AdaptiveStoreLimit: AdaptiveStoreLimit{
+			Enable:           false,
+			MinLimit:         8,
+			MaxLimit:         1024,
+			LatencyTolerance: 2,
+			MaxQueueSize:     1024,
+			MaxQueueWait:     500 * time.Millisecond,
+		},
+
 		TTLRefreshedTxnSize: 32 * 1024 * 1024,
 
 		CoprCache: CoprocessorCache{
@@ -201,12 +266,23 @@ func (config *TiKVClient) Valid() error {
 	if config.GrpcConnectionCount == 0 {
 		return fmt.Errorf("grpc-connection-count should be greater than 0")
 	}
-	if config.GrpcCompressionType != "none" && config.GrpcCompressionType != gzip.Name {
-		return fmt.Errorf("grpc-compression-type should be none or %s, but got %s", gzip.Name, config.GrpcCompressionType)
+	if !IsValidGrpcCompressionType(config.GrpcCompressionType) {
+		return fmt.Errorf("grpc-compression-type should be none, gzip, snappy or zstd, but got %s", config.GrpcCompressionType)
+	}
+	for cmd, policy := range config.GrpcCompressionPolicies {
+		if !IsValidGrpcCompressionType(policy.Type) {
+			return fmt.Errorf("grpc-compression-policies.%s.type should be none, gzip, snappy or zstd, but got %s", cmd, policy.Type)
+		}
+		if policy.MinSize < 0 {
+			return fmt.Errorf("grpc-compression-policies.%s.min-size should not be negative, but got %d", cmd, policy.MinSize)
+		}
 	}
 	if config.GetGrpcKeepAliveTimeout() < time.Millisecond*50 {
 		return fmt.Errorf("grpc-keepalive-timeout should be at least 0.05, but got %f", config.GrpcKeepAliveTimeout)
 	}
+	if limit := config.AdaptiveStoreLimit; limit.Enable && (limit.MinLimit <= 0 || limit.MaxLimit < limit.MinLimit) {
+		return fmt.Errorf("adaptive-store-limit should have 0 < min-limit <= max-limit, but got %d and %d", limit.MinLimit, limit.MaxLimit)
+	}
 	return nil
 }
 
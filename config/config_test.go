@@ -89,3 +89,18 @@ func TestValidateGRPCKeepAliveTimeout(t *testing.T) {
 	assert.NotNil(t, cfg.Valid())
 	assert.Equal(t, "grpc-keepalive-timeout should be at least 0.05, but got 0.040000", cfg.Valid().Error())
 }
+
+func TestValidateGRPCCompression(t *testing.T) {
+	cfg := DefaultTiKVClient()
+	cfg.GrpcCompressionType = GrpcCompressionZstd
+	cfg.GrpcCompressionPolicies = map[string]GrpcCompressionPolicy{
+		"Prewrite": {Type: GrpcCompressionSnappy, MinSize: 4096},
+		"Get":      {Type: GrpcCompressionNone},
+	}
+	assert.Nil(t, cfg.Valid())
+	cfg.GrpcCompressionType = "lz4"
+	assert.Equal(t, "grpc-compression-type should be none, gzip, snappy or zstd, but got lz4", cfg.Valid().Error())
+	cfg.GrpcCompressionType = GrpcCompressionNone
+	cfg.GrpcCompressionPolicies["Prewrite"] = GrpcCompressionPolicy{Type: GrpcCompressionGzip, MinSize: -1}
+	assert.Equal(t, "grpc-compression-policies.Prewrite.min-size should not be negative, but got -1", cfg.Valid().Error())
+}
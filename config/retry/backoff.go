@@ -71,9 +71,18 @@ type Backoffer struct {
 	configs        []*Config
 	backoffSleepMS map[string]int
 	backoffTimes   map[string]int
+	// trace is the retry trace of the request being sent with the backoffer, the
+	// backoffs are recorded to its last attempt.
+	trace *tikverr.RetryTrace
+	// failedAttempts are the latest attempts of the failed requests sent with the
+	// backoffer, which are reported if the deadline budget is exhausted.
+	failedAttempts []tikverr.RetryAttempt
 	parent         *Backoffer
 }
 
+// maxFailedAttempts limits the attempts of the failed requests kept by a Backoffer.
+const maxFailedAttempts = 64
+
 type txnStartCtxKeyType struct{}
 
 // TxnStartKey is a key for transaction start_ts info in context.Context.
@@ -137,6 +146,9 @@ func (b *Backoffer) BackoffWithCfgAndMaxSleep(cfg *Config, maxSleepMs int, err e
 	}
 	select {
 	case <-b.ctx.Done():
+		if b.ctx.Err() == context.DeadlineExceeded {
+			return b.DeadlineBudgetExhausted(cfg.String(), err)
+		}
 		return errors.WithStack(err)
 	default:
 	}
@@ -193,6 +205,12 @@ func (b *Backoffer) BackoffWithCfgAndMaxSleep(cfg *Config, maxSleepMs int, err e
 		b.fn[cfg.name] = f
 	}
 	realSleep := f(b.ctx, maxSleepMs)
+	if realSleep < 0 {
+		return b.DeadlineBudgetExhausted(cfg.String(), err)
+	}
+	if b.trace != nil {
+		b.trace.AddBackoff(cfg.String(), time.Duration(realSleep)*time.Millisecond)
+	}
 	if cfg.metric != nil {
 		(*cfg.metric).Observe(float64(realSleep) / 1000)
 	}
@@ -267,6 +285,7 @@ func (b *Backoffer) Clone() *Backoffer {
 		configs:        append([]*Config{}, b.configs...),
 		backoffSleepMS: copyMapWithoutRecursive(b.backoffSleepMS),
 		backoffTimes:   copyMapWithoutRecursive(b.backoffTimes),
+		failedAttempts: b.failedAttempts[:len(b.failedAttempts):len(b.failedAttempts)],
 		parent:         b.parent,
 	}
 }
@@ -286,6 +305,7 @@ func (b *Backoffer) Fork() (*Backoffer, context.CancelFunc) {
 		configs:        append([]*Config{}, b.configs...),
 		backoffSleepMS: copyMapWithoutRecursive(b.backoffSleepMS),
 		backoffTimes:   copyMapWithoutRecursive(b.backoffTimes),
+		failedAttempts: b.failedAttempts[:len(b.failedAttempts):len(b.failedAttempts)],
 		vars:           b.vars,
 		parent:         b,
 	}, cancel
@@ -304,6 +324,7 @@ func (b *Backoffer) UpdateUsingForked(forked *Backoffer) {
 			b.errors = forked.errors
 			b.backoffSleepMS = forked.backoffSleepMS
 			b.backoffTimes = forked.backoffTimes
+			b.failedAttempts = forked.failedAttempts
 			break
 		}
 	}
@@ -400,6 +421,55 @@ func (b *Backoffer) longestSleepCfg() (*Config, int) {
 	return nil, 0
 }
 
+// RemainingBudget returns the time left before the deadline of the context, ok is false if the
+// context has no deadline.
+func (b *Backoffer) RemainingBudget() (remaining time.Duration, ok bool) {
+	deadline, ok := b.ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// SetRetryTrace sets the retry trace of the request being sent with the
+// backoffer, the backoffs done are recorded to its last attempt. It returns the
+// previous one to be restored after the request is done.
+func (b *Backoffer) SetRetryTrace(trace *tikverr.RetryTrace) (prev *tikverr.RetryTrace) {
+	prev, b.trace = b.trace, trace
+	return prev
+}
+
+// AddFailedAttempts records the attempts of a failed request, only the latest
+// attempts are kept.
+func (b *Backoffer) AddFailedAttempts(attempts []tikverr.RetryAttempt) {
+	b.failedAttempts = append(b.failedAttempts, attempts...)
+	if n := len(b.failedAttempts) - maxFailedAttempts; n > 0 {
+		b.failedAttempts = append([]tikverr.RetryAttempt(nil), b.failedAttempts[n:]...)
+	}
+}
+
+// DeadlineBudgetExhausted returns the ErrDeadlineBudgetExhausted error with the attempts recorded,
+// stage is where the budget is exhausted and lastErr is the last error met by the request.
+func (b *Backoffer) DeadlineBudgetExhausted(stage string, lastErr error) error {
+	deadline, _ := b.ctx.Deadline()
+	return errors.WithStack(&tikverr.ErrDeadlineBudgetExhausted{
+		Deadline: deadline,
+		Stage:    stage,
+		Attempts: b.deadlineAttempts(),
+		LastErr:  lastErr,
+	})
+}
+
+// deadlineAttempts returns the attempts of the failed requests and the ones of
+// the request being sent.
+func (b *Backoffer) deadlineAttempts() []tikverr.RetryAttempt {
+	attempts := append([]tikverr.RetryAttempt(nil), b.failedAttempts...)
+	if b.trace != nil {
+		attempts = append(attempts, b.trace.Attempts...)
+	}
+	return attempts
+}
+
 func (b *Backoffer) CheckKilled() error {
 	if b.vars != nil && b.vars.Killed != nil {
 		killed := atomic.LoadUint32(b.vars.Killed)
@@ -37,9 +37,12 @@ package retry
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	tikverr "github.com/tikv/client-go/v2/error"
 )
 
 func TestBackoffWithMax(t *testing.T) {
@@ -132,3 +135,69 @@ func TestBackoffWithMaxExcludedExceed(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Greater(t, b.excludedSleep, b.maxSleep)
 }
+
+func TestBackoffDeadlineBudget(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	b := NewBackofferWithVars(ctx, 10000, nil)
+	b.AddFailedAttempts([]tikverr.RetryAttempt{{Addr: "store1", Duration: 10 * time.Millisecond, RPCError: "timeout"}})
+	b.SetRetryTrace(&tikverr.RetryTrace{Attempts: []tikverr.RetryAttempt{{Addr: "store2"}}})
+	remaining, ok := b.RemainingBudget()
+	assert.True(t, ok)
+	assert.LessOrEqual(t, remaining, 50*time.Millisecond)
+
+	// The sleep of tikvRPC starts from 100ms, which cannot finish before the deadline.
+	start := time.Now()
+	err := b.Backoff(BoTiKVRPC, errors.New("send failed"))
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+	var budgetErr *tikverr.ErrDeadlineBudgetExhausted
+	assert.True(t, errors.As(err, &budgetErr))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, BoTiKVRPC.String(), budgetErr.Stage)
+	assert.Equal(t, "send failed", budgetErr.LastErr.Error())
+	// The attempts of the failed requests are followed by the ones of the request being sent.
+	assert.Len(t, budgetErr.Attempts, 2)
+	assert.Equal(t, "store1", budgetErr.Attempts[0].Addr)
+	assert.Equal(t, "timeout", budgetErr.Attempts[0].RPCError)
+	assert.Equal(t, "store2", budgetErr.Attempts[1].Addr)
+
+	b = NewBackofferWithVars(context.Background(), 10000, nil)
+	_, ok = b.RemainingBudget()
+	assert.False(t, ok)
+}
+
+func TestBackoffRetryTrace(t *testing.T) {
+	b := NewBackofferWithVars(context.Background(), 10000, nil)
+	// The backoff without a retry trace or before any attempt is not recorded.
+	assert.Nil(t, b.Backoff(BoTxnLockFast, errors.New("locked")))
+	trace := &tikverr.RetryTrace{}
+	assert.Nil(t, b.SetRetryTrace(trace))
+	assert.Nil(t, b.Backoff(BoTxnLockFast, errors.New("locked")))
+	assert.Empty(t, trace.Attempts)
+
+	trace.AddAttempt(tikverr.RetryAttempt{Addr: "store1"})
+	assert.Nil(t, b.Backoff(BoTxnLockFast, errors.New("locked")))
+	assert.Equal(t, []string{BoTxnLockFast.String()}, trace.Attempts[0].BackoffType)
+	assert.Positive(t, trace.Attempts[0].BackoffSleep)
+	assert.Equal(t, trace, b.SetRetryTrace(nil))
+
+	// The forked backoffer doesn't record the backoffs to the trace of its parent,
+	// and the failed attempts are merged back.
+	b.SetRetryTrace(trace)
+	forked, cancel := b.Fork()
+	defer cancel()
+	assert.Nil(t, forked.Backoff(BoTxnLockFast, errors.New("locked")))
+	assert.Len(t, trace.Attempts[0].BackoffType, 1)
+	forked.AddFailedAttempts([]tikverr.RetryAttempt{{Addr: "store2"}})
+	assert.Empty(t, b.failedAttempts)
+	b.UpdateUsingForked(forked)
+	assert.Len(t, b.failedAttempts, 1)
+
+	// Only the latest failed attempts are kept.
+	for i := 0; i < maxFailedAttempts; i++ {
+		b.AddFailedAttempts([]tikverr.RetryAttempt{{Addr: fmt.Sprintf("store%d", i+3)}})
+	}
+	assert.Len(t, b.failedAttempts, maxFailedAttempts)
+	assert.Equal(t, "store3", b.failedAttempts[0].Addr)
+	assert.Equal(t, fmt.Sprintf("store%d", maxFailedAttempts+2), b.failedAttempts[maxFailedAttempts-1].Addr)
+}
@@ -59,6 +59,7 @@ type Config struct {
 }
 
 // backoffFn is the backoff function which compute the sleep time and do sleep.
+// It returns -1 without sleeping if the sleep cannot finish before the deadline of ctx.
 type backoffFn func(ctx context.Context, maxSleepMs int) int
 
 func (c *Config) createBackoffFn(vars *kv.Variables) backoffFn {
@@ -201,6 +202,10 @@ func newBackoffFn(base, cap, jitter int) backoffFn {
 			lastSleep = sleep
 			return realSleep
 		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < time.Duration(realSleep)*time.Millisecond {
+			// The sleep cannot finish before the deadline, skip it.
+			return -1
+		}
 		select {
 		case <-time.After(time.Duration(realSleep) * time.Millisecond):
 			attempts++
@@ -35,6 +35,7 @@
 package error
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -96,6 +97,12 @@ var (
 	ErrRegionFlashbackNotPrepared = errors.New("region is not prepared for the flashback")
 	// ErrIsWitness is the error when a request is send to a witness.
 	ErrIsWitness = errors.New("peer is witness")
+	// ErrStoreCircuitBreakerOpen is the error when a request is rejected by the open circuit breaker of the store.
+	ErrStoreCircuitBreakerOpen = errors.New("store circuit breaker is open")
+	// ErrSavepointNotSupported is the error when savepoints are used in a pipelined transaction.
+	ErrSavepointNotSupported = errors.New("savepoint is not supported in pipelined transaction")
+	// ErrSavepointNotExist is the error when the savepoint to rollback or release does not exist.
+	ErrSavepointNotExist = errors.New("savepoint does not exist")
 	// ErrUnknown is the unknow error.
 	ErrUnknown = errors.New("unknown")
 	// ErrResultUndetermined is the error when execution result is unknown.
@@ -263,6 +270,29 @@ func (e *ErrTokenLimit) Error() string {
 	return fmt.Sprintf("Store token is up to the limit, store id = %d.", e.StoreID)
 }
 
+// ErrDeadlineBudgetExhausted is the error that the deadline of the request is reached, or the
+// time left is not enough for the next attempt. It matches context.DeadlineExceeded by errors.Is.
+type ErrDeadlineBudgetExhausted struct {
+	Deadline time.Time
+	// Stage is where the budget is exhausted, e.g. "rpc", "region reload" or the backoff type.
+	Stage string
+	// Attempts are the attempts made for the request before the budget is exhausted, with the
+	// backoffs done after them. It's the retry trace kept by the backoffer of the request.
+	Attempts []RetryAttempt
+	// LastErr is the last error met by the request.
+	LastErr error
+}
+
+func (e *ErrDeadlineBudgetExhausted) Error() string {
+	return fmt.Sprintf("deadline budget exhausted at %s after %d attempts, deadline: %s, last error: %v",
+		e.Stage, len(e.Attempts), e.Deadline.Format(time.RFC3339Nano), e.LastErr)
+}
+
+// Unwrap makes the error match context.DeadlineExceeded.
+func (e *ErrDeadlineBudgetExhausted) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
 // ErrAssertionFailed is the error that assertion on data failed.
 type ErrAssertionFailed struct {
 	*kvrpcpb.AssertionFailed
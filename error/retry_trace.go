@@ -0,0 +1,129 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package error
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// maxRetryTraceAttempts limits the attempts kept in a RetryTrace, only the
+// latest ones are kept and the earlier ones are counted.
+const maxRetryTraceAttempts = 64
+
+// RetryAttempt is an attempt to send a request to a replica of a region.
+type RetryAttempt struct {
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	RegionID uint64        `json:"region_id"`
+	StoreID  uint64        `json:"store_id"`
+	PeerID   uint64        `json:"peer_id"`
+	Addr     string        `json:"addr,omitempty"`
+	// ProxyAddr is the address of the store forwarding the request, if any.
+	ProxyAddr string `json:"proxy_addr,omitempty"`
+	// Replica is the role of the replica, "leader" or "follower".
+	Replica string `json:"replica,omitempty"`
+	// RPCContext describes the RPC context of the attempt, it's only recorded
+	// for the failed attempts.
+	RPCContext  string `json:"rpc_context,omitempty"`
+	RPCError    string `json:"rpc_error,omitempty"`
+	RegionError string `json:"region_error,omitempty"`
+	// BackoffType and BackoffSleep are the backoffs done after the attempt and
+	// before the next one.
+	BackoffType  []string      `json:"backoff_type,omitempty"`
+	BackoffSleep time.Duration `json:"backoff_sleep,omitempty"`
+}
+
+// MarshalJSON renders the durations in a human readable form.
+func (a RetryAttempt) MarshalJSON() ([]byte, error) {
+	type attempt RetryAttempt
+	return json.Marshal(struct {
+		attempt
+		Duration     string `json:"duration"`
+		BackoffSleep string `json:"backoff_sleep,omitempty"`
+	}{
+		attempt:      attempt(a),
+		Duration:     a.Duration.String(),
+		BackoffSleep: formatOptionalDuration(a.BackoffSleep),
+	})
+}
+
+func formatOptionalDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// RetryTrace records the attempts of a request sent to a region.
+type RetryTrace struct {
+	Attempts []RetryAttempt `json:"attempts"`
+	// DroppedAttempts is the number of the earliest attempts not kept in Attempts.
+	DroppedAttempts int `json:"dropped_attempts,omitempty"`
+}
+
+// AddAttempt appends an attempt to the trace, the earliest attempt is dropped
+// if there are too many.
+func (t *RetryTrace) AddAttempt(a RetryAttempt) {
+	if len(t.Attempts) >= maxRetryTraceAttempts {
+		copy(t.Attempts, t.Attempts[1:])
+		t.Attempts = t.Attempts[:len(t.Attempts)-1]
+		t.DroppedAttempts++
+	}
+	t.Attempts = append(t.Attempts, a)
+}
+
+// AddBackoff records a backoff done after the last attempt. It's ignored if
+// there is no attempt yet.
+func (t *RetryTrace) AddBackoff(tp string, sleep time.Duration) {
+	if len(t.Attempts) == 0 {
+		return
+	}
+	a := &t.Attempts[len(t.Attempts)-1]
+	a.BackoffType = append(a.BackoffType, tp)
+	a.BackoffSleep += sleep
+}
+
+// JSON renders the trace as JSON.
+func (t *RetryTrace) JSON() string {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Sprintf(`{"error":%q}`, err.Error())
+	}
+	return string(data)
+}
+
+// ErrWithRetryTrace is the error of a request failed after retries, which
+// carries the trace of the attempts. Use errors.As to get it from the errors
+// returned by the requests.
+type ErrWithRetryTrace struct {
+	Err   error
+	Trace *RetryTrace
+}
+
+func (e *ErrWithRetryTrace) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error of the request.
+func (e *ErrWithRetryTrace) Unwrap() error {
+	return e.Err
+}
+
+// Cause returns the cause of the error, so errors.Cause sees through the trace.
+func (e *ErrWithRetryTrace) Cause() error {
+	return e.Err
+}
@@ -214,6 +214,11 @@ func (s *testRangeTaskSuite) testRangeTaskImpl(concurrency int) {
 			s.checkRanges(collect(ranges), expectedRanges)
 			s.Equal(runner.CompletedRegions(), len(expectedRanges))
 			s.Equal(runner.FailedRegions(), 0)
+			if len(expectedRanges) > 0 {
+				s.Equal([]kv.KeyRange{r}, runner.CompletedRanges())
+			} else {
+				s.Empty(runner.CompletedRanges())
+			}
 		}
 	}
 }
@@ -254,6 +259,11 @@ func (s *testRangeTaskSuite) testRangeTaskErrorImpl(concurrency int) {
 			s.NotNil(err)
 			s.Less(runner.CompletedRegions(), len(subRanges))
 			s.Equal(runner.FailedRegions(), 1)
+			// The failed task is not in the completed ranges.
+			for _, completed := range runner.CompletedRanges() {
+				s.False(bytes.Compare(completed.StartKey, errKey) <= 0 &&
+					(len(completed.EndKey) == 0 || bytes.Compare(errKey, completed.EndKey) < 0))
+			}
 		}
 	}
 }
@@ -144,6 +144,9 @@ func (c *codecV1) DecodeResponse(req *tikvrpc.Request, resp *tikvrpc.Response) (
 	case tikvrpc.CmdRawChecksum:
 		r := resp.Resp.(*kvrpcpb.RawChecksumResponse)
 		r.RegionError = decodeRegionError
+	case tikvrpc.CmdRawBatchScan:
+		r := resp.Resp.(*kvrpcpb.RawBatchScanResponse)
+		r.RegionError = decodeRegionError
 	}
 	return resp, nil
 }
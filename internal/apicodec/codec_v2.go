@@ -243,6 +243,10 @@ func (c *codecV2) EncodeRequest(req *tikvrpc.Request) (*tikvrpc.Request, error)
 		r := *req.RawChecksum()
 		r.Ranges = c.encodeKeyRanges(r.Ranges)
 		req.Req = &r
+	case tikvrpc.CmdRawBatchScan:
+		r := *req.RawBatchScan()
+		r.Ranges = c.encodeKeyRanges(r.Ranges)
+		req.Req = &r
 
 	// TiFlash Requests
 	case tikvrpc.CmdBatchCop:
@@ -261,6 +265,14 @@ func (c *codecV2) EncodeRequest(req *tikvrpc.Request) (*tikvrpc.Request, error)
 		r := *req.UnsafeDestroyRange()
 		r.StartKey, r.EndKey = c.encodeRange(r.StartKey, r.EndKey, false)
 		req.Req = &r
+	case tikvrpc.CmdPrepareFlashbackToVersion:
+		r := *req.PrepareFlashbackToVersion()
+		r.StartKey, r.EndKey = c.encodeRange(r.StartKey, r.EndKey, false)
+		req.Req = &r
+	case tikvrpc.CmdFlashbackToVersion:
+		r := *req.FlashbackToVersion()
+		r.StartKey, r.EndKey = c.encodeRange(r.StartKey, r.EndKey, false)
+		req.Req = &r
 	case tikvrpc.CmdPhysicalScanLock:
 		r := *req.PhysicalScanLock()
 		r.StartKey = c.EncodeKey(r.StartKey)
@@ -555,6 +567,16 @@ func (c *codecV2) DecodeResponse(req *tikvrpc.Request, resp *tikvrpc.Response) (
 		if err != nil {
 			return nil, err
 		}
+	case tikvrpc.CmdRawBatchScan:
+		r := resp.Resp.(*kvrpcpb.RawBatchScanResponse)
+		r.RegionError, err = c.decodeRegionError(r.RegionError)
+		if err != nil {
+			return nil, err
+		}
+		r.Kvs, err = c.decodePairs(r.Kvs)
+		if err != nil {
+			return nil, err
+		}
 
 	// Other requests.
 	case tikvrpc.CmdUnsafeDestroyRange:
@@ -563,6 +585,18 @@ func (c *codecV2) DecodeResponse(req *tikvrpc.Request, resp *tikvrpc.Response) (
 		if err != nil {
 			return nil, err
 		}
+	case tikvrpc.CmdPrepareFlashbackToVersion:
+		r := resp.Resp.(*kvrpcpb.PrepareFlashbackToVersionResponse)
+		r.RegionError, err = c.decodeRegionError(r.RegionError)
+		if err != nil {
+			return nil, err
+		}
+	case tikvrpc.CmdFlashbackToVersion:
+		r := resp.Resp.(*kvrpcpb.FlashbackToVersionResponse)
+		r.RegionError, err = c.decodeRegionError(r.RegionError)
+		if err != nil {
+			return nil, err
+		}
 	case tikvrpc.CmdPhysicalScanLock:
 		r := resp.Resp.(*kvrpcpb.PhysicalScanLockResponse)
 		r.Locks, err = c.decodeLockInfos(r.Locks)
@@ -69,7 +69,7 @@ import (
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/encoding"
 	"google.golang.org/grpc/experimental"
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
@@ -168,12 +168,17 @@ type connArray struct {
 	done chan struct{}
 
 	monitor *connMonitor
+	// compression negotiates the compressors of the requests with the server.
+	compression *compressorNegotiator
 
 	metrics struct {
 		rpcLatHist        *rpcMetrics
 		rpcSrcLatSum      sync.Map
 		rpcNetLatExternal prometheus.Observer
 		rpcNetLatInternal prometheus.Observer
+
+		uncompressedBytes *prometheus.CounterVec
+		compressedBytes   *prometheus.CounterVec
 	}
 }
 
@@ -191,6 +196,8 @@ func newConnArray(maxSize uint, addr string, ver uint64, security config.Securit
 	a.metrics.rpcLatHist = deriveRPCMetrics(metrics.TiKVSendReqHistogram.MustCurryWith(prometheus.Labels{metrics.LblStore: addr}))
 	a.metrics.rpcNetLatExternal = metrics.TiKVRPCNetLatencyHistogram.WithLabelValues(addr, "false")
 	a.metrics.rpcNetLatInternal = metrics.TiKVRPCNetLatencyHistogram.WithLabelValues(addr, "true")
+	a.metrics.uncompressedBytes = metrics.TiKVGRPCUncompressedBytes.MustCurryWith(prometheus.Labels{metrics.LblStore: addr})
+	a.metrics.compressedBytes = metrics.TiKVGRPCCompressedBytes.MustCurryWith(prometheus.Labels{metrics.LblStore: addr})
 	if err := a.Init(addr, security, idleNotify, enableBatch, eventListener, opts...); err != nil {
 		return nil, err
 	}
@@ -307,6 +314,7 @@ func (a *connArray) Init(addr string, security config.Security, idleNotify *uint
 		streamInterceptor = grpc_opentracing.StreamClientInterceptor()
 	}
 
+	a.compression = newCompressorNegotiator(cfg.TiKVClient.GrpcCompressionType)
 	allowBatch := (cfg.TiKVClient.MaxBatchSize > 0) && enableBatch
 	if allowBatch {
 		a.batchConn = newBatchConn(uint(len(a.v)), cfg.TiKVClient.MaxBatchSize, idleNotify)
@@ -317,9 +325,6 @@ func (a *connArray) Init(addr string, security config.Security, idleNotify *uint
 		ctx, cancel := context.WithTimeout(context.Background(), a.dialTimeout)
 		var callOptions []grpc.CallOption
 		callOptions = append(callOptions, grpc.MaxCallRecvMsgSize(MaxRecvMsgSize))
-		if cfg.TiKVClient.GrpcCompressionType == gzip.Name {
-			callOptions = append(callOptions, grpc.UseCompressor(gzip.Name))
-		}
 
 		opts = append([]grpc.DialOption{
 			opt,
@@ -327,6 +332,10 @@ func (a *connArray) Init(addr string, security config.Security, idleNotify *uint
 			grpc.WithInitialConnWindowSize(cfg.TiKVClient.GrpcInitialConnWindowSize),
 			grpc.WithUnaryInterceptor(unaryInterceptor),
 			grpc.WithStreamInterceptor(streamInterceptor),
+			// The compressors are applied by the interceptors after negotiated with the server.
+			grpc.WithChainUnaryInterceptor(a.compression.unaryInterceptor),
+			grpc.WithChainStreamInterceptor(a.compression.streamInterceptor),
+			grpc.WithStatsHandler(&compressionStatsHandler{conn: a}),
 			grpc.WithDefaultCallOptions(callOptions...),
 			grpc.WithConnectParams(grpc.ConnectParams{
 				Backoff: backoff.Config{
@@ -362,18 +371,19 @@ func (a *connArray) Init(addr string, security config.Security, idleNotify *uint
 
 		if allowBatch {
 			batchClient := &batchCommandsClient{
-				target:           a.target,
-				conn:             conn.ClientConn,
-				forwardedClients: make(map[string]*batchCommandsStream),
-				batched:          sync.Map{},
-				epoch:            0,
-				closed:           0,
-				tikvClientCfg:    cfg.TiKVClient,
-				tikvLoad:         &a.tikvTransportLayerLoad,
-				dialTimeout:      a.dialTimeout,
-				tryLock:          tryLock{sync.NewCond(new(sync.Mutex)), false},
-				eventListener:    eventListener,
-				metrics:          &a.batchConn.metrics,
+				target:            a.target,
+				conn:              conn.ClientConn,
+				forwardedClients:  make(map[string]*batchCommandsStream),
+				compressedClients: make(map[string]*batchCommandsStream),
+				batched:           sync.Map{},
+				epoch:             0,
+				closed:            0,
+				tikvClientCfg:     cfg.TiKVClient,
+				tikvLoad:          &a.tikvTransportLayerLoad,
+				dialTimeout:       a.dialTimeout,
+				tryLock:           tryLock{sync.NewCond(new(sync.Mutex)), false},
+				eventListener:     eventListener,
+				metrics:           &a.batchConn.metrics,
 			}
 			batchClient.maxConcurrencyRequestLimit.Store(cfg.TiKVClient.MaxConcurrencyRequestLimit)
 			a.batchCommandsClients = append(a.batchCommandsClients, batchClient)
@@ -444,6 +454,16 @@ func (a *connArray) updateRPCMetrics(req *tikvrpc.Request, resp *tikvrpc.Respons
 	}
 }
 
+// updateCompressionMetrics counts the bytes of a message sent by the connections
+// before and after compression, messages sent uncompressed are counted as none.
+func (a *connArray) updateCompressionMetrics(reqType, compressor string, size, compressedSize int) {
+	if compressor == "" || compressor == encoding.Identity {
+		compressor = config.GrpcCompressionNone
+	}
+	a.metrics.uncompressedBytes.WithLabelValues(reqType, compressor).Add(float64(size))
+	a.metrics.compressedBytes.WithLabelValues(reqType, compressor).Add(float64(compressedSize))
+}
+
 type option struct {
 	gRPCDialOptions []grpc.DialOption
 	security        config.Security
@@ -629,10 +649,24 @@ func (c *RPCClient) sendRequest(ctx context.Context, addr string, req *tikvrpc.R
 		return nil, err
 	}
 
+	if cb, settings := getStoreCircuitBreaker(addr); cb != nil {
+		if !cb.allow(settings, time.Now()) {
+			return nil, cb.reject()
+		}
+		defer func() { cb.done(ctx, settings, err) }()
+	}
+
 	wrapErrConn := func(resp *tikvrpc.Response, err error) (*tikvrpc.Response, error) {
 		return resp, WrapErrConn(err, connArray)
 	}
 
+	ctx = withRequestType(ctx, req.Type.String())
+	if policies := config.GetGlobalConfig().TiKVClient.GrpcCompressionPolicies; len(policies) > 0 {
+		if compressor, _ := selectCompressor(policies, req); compressor != "" {
+			ctx = withCompressor(ctx, compressor)
+		}
+	}
+
 	start := time.Now()
 	defer func() {
 		elapsed := time.Since(start)
@@ -73,6 +73,9 @@ type batchCommandsEntry struct {
 	// forwardedHost is the address of a store which will handle the request.
 	// It's different from the address the request sent to.
 	forwardedHost string
+	// compressor overrides the default compressor of the connection, the request
+	// is sent by the stream of the compressor. It's ignored if forwardedHost is set.
+	compressor string
 	// canceled indicated the request is canceled or not.
 	canceled int32
 	err      error
@@ -92,6 +95,14 @@ func (b *batchCommandsEntry) priority() uint64 {
 	return b.pri
 }
 
+// streamCompressor returns the compressor of the stream sending the request.
+func (b *batchCommandsEntry) streamCompressor() string {
+	if b.forwardedHost != "" {
+		return ""
+	}
+	return b.compressor
+}
+
 func (b *batchCommandsEntry) async() bool {
 	return b.cb != nil
 }
@@ -124,6 +135,9 @@ type batchCommandsBuilder struct {
 	requestIDs []uint64
 	// In most cases, there isn't any forwardingReq.
 	forwardingReqs map[string]*tikvpb.BatchCommandsRequest
+	// compressedReqs are the requests sent with the compressors other than the
+	// default one, they are empty unless compression policies are configured.
+	compressedReqs map[string]*tikvpb.BatchCommandsRequest
 
 	latestReqStartTime time.Time
 }
@@ -150,8 +164,9 @@ func (b *batchCommandsBuilder) hasHighPriorityTask() bool {
 // so the limit only works for normal tasks.
 // The first return value is the request that doesn't need forwarding.
 // The second is a map that maps forwarded hosts to requests.
+// The third is a map that maps compressors to requests.
 func (b *batchCommandsBuilder) buildWithLimit(limit int64, collect func(id uint64, e *batchCommandsEntry),
-) (*tikvpb.BatchCommandsRequest, map[string]*tikvpb.BatchCommandsRequest) {
+) (*tikvpb.BatchCommandsRequest, map[string]*tikvpb.BatchCommandsRequest, map[string]*tikvpb.BatchCommandsRequest) {
 	count := int64(0)
 	build := func(reqs []Item) {
 		for _, e := range reqs {
@@ -166,17 +181,13 @@ func (b *batchCommandsBuilder) buildWithLimit(limit int64, collect func(id uint6
 			if collect != nil {
 				collect(b.idAlloc, e)
 			}
-			if e.forwardedHost == "" {
+			if e.forwardedHost != "" {
+				appendBatchRequest(b.forwardingReqs, e.forwardedHost, b.idAlloc, e.req)
+			} else if e.compressor != "" {
+				appendBatchRequest(b.compressedReqs, e.compressor, b.idAlloc, e.req)
+			} else {
 				b.requestIDs = append(b.requestIDs, b.idAlloc)
 				b.requests = append(b.requests, e.req)
-			} else {
-				batchReq, ok := b.forwardingReqs[e.forwardedHost]
-				if !ok {
-					batchReq = &tikvpb.BatchCommandsRequest{}
-					b.forwardingReqs[e.forwardedHost] = batchReq
-				}
-				batchReq.RequestIds = append(batchReq.RequestIds, b.idAlloc)
-				batchReq.Requests = append(batchReq.Requests, e.req)
 			}
 			b.idAlloc++
 		}
@@ -199,7 +210,17 @@ func (b *batchCommandsBuilder) buildWithLimit(limit int64, collect func(id uint6
 			RequestIds: b.requestIDs,
 		}
 	}
-	return req, b.forwardingReqs
+	return req, b.forwardingReqs, b.compressedReqs
+}
+
+func appendBatchRequest(reqs map[string]*tikvpb.BatchCommandsRequest, key string, id uint64, req *tikvpb.BatchCommandsRequest_Request) {
+	batchReq, ok := reqs[key]
+	if !ok {
+		batchReq = &tikvpb.BatchCommandsRequest{}
+		reqs[key] = batchReq
+	}
+	batchReq.RequestIds = append(batchReq.RequestIds, id)
+	batchReq.Requests = append(batchReq.Requests, req)
 }
 
 // cancel all requests, only used in test.
@@ -227,6 +248,9 @@ func (b *batchCommandsBuilder) reset() {
 	for k := range b.forwardingReqs {
 		delete(b.forwardingReqs, k)
 	}
+	for k := range b.compressedReqs {
+		delete(b.compressedReqs, k)
+	}
 }
 
 func newBatchCommandsBuilder(maxBatchSize uint) *batchCommandsBuilder {
@@ -236,6 +260,7 @@ func newBatchCommandsBuilder(maxBatchSize uint) *batchCommandsBuilder {
 		requests:       make([]*tikvpb.BatchCommandsRequest_Request, 0, maxBatchSize),
 		requestIDs:     make([]uint64, 0, maxBatchSize),
 		forwardingReqs: make(map[string]*tikvpb.BatchCommandsRequest),
+		compressedReqs: make(map[string]*tikvpb.BatchCommandsRequest),
 	}
 }
 
@@ -647,7 +672,7 @@ func (a *batchConn) getClientAndSend() {
 	available := cli.available()
 	reqSendTime := time.Now()
 	batch := 0
-	req, forwardingReqs := a.reqBuilder.buildWithLimit(available, func(id uint64, e *batchCommandsEntry) {
+	req, forwardingReqs, compressedReqs := a.reqBuilder.buildWithLimit(available, func(id uint64, e *batchCommandsEntry) {
 		cli.batched.Store(id, e)
 		cli.sent.Add(1)
 		atomic.StoreInt64(&e.sendLat, int64(reqSendTime.Sub(e.start)))
@@ -657,11 +682,15 @@ func (a *batchConn) getClientAndSend() {
 	})
 	if req != nil {
 		batch += len(req.RequestIds)
-		cli.send("", req)
+		cli.send("", "", req)
 	}
 	for forwardedHost, req := range forwardingReqs {
 		batch += len(req.RequestIds)
-		cli.send(forwardedHost, req)
+		cli.send(forwardedHost, "", req)
+	}
+	for compressor, req := range compressedReqs {
+		batch += len(req.RequestIds)
+		cli.send("", compressor, req)
 	}
 	if batch > 0 {
 		a.metrics.batchSize.Observe(float64(batch))
@@ -705,6 +734,11 @@ func (l *tryLock) unlockForRecreate() {
 type batchCommandsStream struct {
 	tikvpb.Tikv_BatchCommandsClient
 	forwardedHost string
+	// compressor overrides the default compressor of the connection for the
+	// requests sent by the stream.
+	compressor string
+	// lastPayload is the sizes of the last message sent by the stream.
+	lastPayload payloadSizes
 }
 
 func (s *batchCommandsStream) recv() (resp *tikvpb.BatchCommandsResponse, err error) {
@@ -728,7 +762,13 @@ func (s *batchCommandsStream) recv() (resp *tikvpb.BatchCommandsResponse, err er
 // recreate creates a new BatchCommands stream. The conn should be ready for work.
 func (s *batchCommandsStream) recreate(conn *grpc.ClientConn) error {
 	tikvClient := tikvpb.NewTikvClient(conn)
-	ctx := context.TODO()
+	ctx := withRequestType(context.TODO(), batchCommandsRequestType)
+	ctx = withPayloadSizes(ctx, &s.lastPayload)
+	if s.compressor != "" {
+		// The compressor is negotiated with the server when the stream is
+		// created, and the stream keeps it until it's recreated.
+		ctx = withCompressor(ctx, s.compressor)
+	}
 	// Set metadata for forwarding stream.
 	if s.forwardedHost != "" {
 		ctx = metadata.AppendToOutgoingContext(ctx, forwardMetadataKey, s.forwardedHost)
@@ -756,7 +796,13 @@ type batchCommandsClient struct {
 	//
 	// forwardedClients are clients that need forwarding. It's a map that maps forwarded hosts to streams
 	forwardedClients map[string]*batchCommandsStream
-	batched          sync.Map
+	// gRPC doesn't support compressing the messages of a stream by different compressors either,
+	// so the requests selected compressors by the compression policies are sent by the streams of
+	// the compressors.
+	//
+	// compressedClients is a map that maps compressors to streams.
+	compressedClients map[string]*batchCommandsStream
+	batched           sync.Map
 
 	tikvClientCfg config.TiKVClient
 	tikvLoad      *uint64
@@ -800,38 +846,60 @@ func (c *batchCommandsClient) available() int64 {
 	return limit
 }
 
-func (c *batchCommandsClient) send(forwardedHost string, req *tikvpb.BatchCommandsRequest) {
-	err := c.initBatchClient(forwardedHost)
+func (c *batchCommandsClient) send(forwardedHost, compressor string, req *tikvpb.BatchCommandsRequest) {
+	err := c.initBatchClient(forwardedHost, compressor)
 	if err != nil {
 		logutil.BgLogger().Warn(
 			"init create streaming fail",
 			zap.String("target", c.target),
 			zap.String("forwardedHost", forwardedHost),
+			zap.String("compressor", compressor),
 			zap.Error(err),
 		)
 		c.failRequestsByIDs(err, req.RequestIds) // fast fail requests.
 		return
 	}
 
-	client := c.client
-	if forwardedHost != "" {
-		client = c.forwardedClients[forwardedHost]
-	}
+	client := c.getStream(forwardedHost, compressor)
 	if err := client.Send(req); err != nil {
 		logutil.BgLogger().Info(
 			"sending batch commands meets error",
 			zap.String("target", c.target),
 			zap.String("forwardedHost", forwardedHost),
+			zap.String("compressor", compressor),
 			zap.Uint64s("requestIDs", req.RequestIds),
 			zap.Error(err),
 		)
 		c.failRequestsByIDs(err, req.RequestIds) // fast fail requests.
+		return
+	}
+	c.onCompression(req, client.lastPayload)
+}
+
+// onCompression updates the exec details of the requests sent by a message,
+// each request takes its share of the compressed message by its size.
+func (c *batchCommandsClient) onCompression(req *tikvpb.BatchCommandsRequest, sizes payloadSizes) {
+	if sizes.size == 0 {
+		return
+	}
+	for i, requestID := range req.RequestIds {
+		value, ok := c.batched.Load(requestID)
+		if !ok {
+			continue
+		}
+		stmtExec := value.(*batchCommandsEntry).ctx.Value(util.ExecDetailsKey)
+		if stmtExec == nil {
+			continue
+		}
+		size := req.Requests[i].Size()
+		(&networkCollector{}).onCompression(stmtExec.(*util.ExecDetails), size, size*sizes.compressedSize/sizes.size)
 	}
 }
 
 // `failPendingRequests` must be called in locked contexts in order to avoid double closing channels.
 // when enable-forwarding is true, the `forwardedHost` maybe not empty.
 // failPendingRequests fails all pending requests which req.forwardedHost equals to forwardedHost parameter.
+// The same applies to the compressor, the requests sent by the streams of other compressors are not failed.
 // Why need check `forwardedHost`? Here is an example, when enable-forwarding is true, and this client has network issue with store1:
 //   - some requests are sent to store1 with forwarding, such as forwardedHost is store2, those requests will succeed.
 //   - some requests are sent to store1 without forwarding, and may fail then `failPendingRequests` would be called,
@@ -840,12 +908,12 @@ func (c *batchCommandsClient) send(forwardedHost string, req *tikvpb.BatchComman
 //     2. panic which cause by `send on closed channel`, since failPendingRequests will close the entry.res channel,
 //     but in another batchRecvLoop goroutine,  it may receive the response from forwardedHost store2 and try to send the response to entry.res channel,
 //     then panic by send on closed channel.
-func (c *batchCommandsClient) failPendingRequests(err error, forwardedHost string) {
+func (c *batchCommandsClient) failPendingRequests(err error, forwardedHost, compressor string) {
 	util.EvalFailpoint("panicInFailPendingRequests")
 	c.batched.Range(func(key, value interface{}) bool {
 		id, _ := key.(uint64)
 		entry, _ := value.(*batchCommandsEntry)
-		if entry.forwardedHost == forwardedHost {
+		if entry.forwardedHost == forwardedHost && entry.streamCompressor() == compressor {
 			c.failRequest(err, id, entry)
 		}
 		return true
@@ -1061,7 +1129,7 @@ func (c *batchCommandsClient) recreateStreamingClient(err error, streamClient *b
 	}
 	*epoch++
 
-	c.failPendingRequests(err, streamClient.forwardedHost) // fail all pending requests.
+	c.failPendingRequests(err, streamClient.forwardedHost, streamClient.compressor) // fail all pending requests.
 	b := retry.NewBackofferWithVars(context.Background(), math.MaxInt32, nil)
 	for { // try to re-create the streaming in the loop.
 		if c.isStopped() {
@@ -1080,19 +1148,31 @@ func (c *batchCommandsClient) recreateStreamingClient(err error, streamClient *b
 	return false
 }
 
-func (c *batchCommandsClient) newBatchStream(forwardedHost string) (*batchCommandsStream, error) {
-	batchStream := &batchCommandsStream{forwardedHost: forwardedHost}
+func (c *batchCommandsClient) newBatchStream(forwardedHost, compressor string) (*batchCommandsStream, error) {
+	batchStream := &batchCommandsStream{forwardedHost: forwardedHost, compressor: compressor}
 	if err := batchStream.recreate(c.conn); err != nil {
 		return nil, err
 	}
 	return batchStream, nil
 }
 
-func (c *batchCommandsClient) initBatchClient(forwardedHost string) error {
-	if forwardedHost == "" && c.client != nil {
-		return nil
+// getStream returns the stream to send the requests with the forwarded host and
+// the compressor, the compressor is ignored if the forwarded host is set.
+func (c *batchCommandsClient) getStream(forwardedHost, compressor string) *batchCommandsStream {
+	if forwardedHost != "" {
+		return c.forwardedClients[forwardedHost]
 	}
-	if _, ok := c.forwardedClients[forwardedHost]; ok {
+	if compressor != "" {
+		return c.compressedClients[compressor]
+	}
+	return c.client
+}
+
+func (c *batchCommandsClient) initBatchClient(forwardedHost, compressor string) error {
+	if forwardedHost != "" {
+		compressor = ""
+	}
+	if c.getStream(forwardedHost, compressor) != nil {
 		return nil
 	}
 
@@ -1100,14 +1180,16 @@ func (c *batchCommandsClient) initBatchClient(forwardedHost string) error {
 		return err
 	}
 
-	streamClient, err := c.newBatchStream(forwardedHost)
+	streamClient, err := c.newBatchStream(forwardedHost, compressor)
 	if err != nil {
 		return err
 	}
-	if forwardedHost == "" {
-		c.client = streamClient
-	} else {
+	if forwardedHost != "" {
 		c.forwardedClients[forwardedHost] = streamClient
+	} else if compressor != "" {
+		c.compressedClients[compressor] = streamClient
+	} else {
+		c.client = streamClient
 	}
 	go c.batchRecvLoop(c.tikvClientCfg, c.tikvLoad, c.metrics, streamClient)
 	return nil
@@ -1139,6 +1221,7 @@ func sendBatchRequest(
 		req:           req,
 		res:           make(chan *tikvpb.BatchCommandsResponse_Response, 1),
 		forwardedHost: forwardedHost,
+		compressor:    compressorFromContext(ctx),
 		canceled:      0,
 		err:           nil,
 		pri:           priority,
@@ -35,8 +35,10 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"math"
 	"math/rand"
 	"runtime"
@@ -53,14 +55,20 @@ import (
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/tikvpb"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/tikv/client-go/v2/config"
+	tikverr "github.com/tikv/client-go/v2/error"
 	"github.com/tikv/client-go/v2/internal/client/mockserver"
 	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/metrics"
 	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/util"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/encoding"
 	"google.golang.org/grpc/metadata"
 )
 
@@ -401,7 +409,7 @@ func TestBatchCommandsBuilder(t *testing.T) {
 		assert.Equal(t, builder.len(), i+1)
 	}
 	entryMap := make(map[uint64]*batchCommandsEntry)
-	batchedReq, forwardingReqs := builder.buildWithLimit(math.MaxInt64, func(id uint64, e *batchCommandsEntry) {
+	batchedReq, forwardingReqs, _ := builder.buildWithLimit(math.MaxInt64, func(id uint64, e *batchCommandsEntry) {
 		entryMap[id] = e
 	})
 	assert.Equal(t, len(batchedReq.GetRequests()), 10)
@@ -427,7 +435,7 @@ func TestBatchCommandsBuilder(t *testing.T) {
 		}
 	}
 	entryMap = make(map[uint64]*batchCommandsEntry)
-	batchedReq, forwardingReqs = builder.buildWithLimit(math.MaxInt64, func(id uint64, e *batchCommandsEntry) {
+	batchedReq, forwardingReqs, _ = builder.buildWithLimit(math.MaxInt64, func(id uint64, e *batchCommandsEntry) {
 		entryMap[id] = e
 	})
 	assert.Equal(t, len(batchedReq.GetRequests()), 1)
@@ -446,6 +454,21 @@ func TestBatchCommandsBuilder(t *testing.T) {
 		}
 	}
 
+	// Test collecting compressed requests, the compressor is ignored for forwarding.
+	builder.reset()
+	builder.push(&batchCommandsEntry{req: req})
+	builder.push(&batchCommandsEntry{req: req, compressor: config.GrpcCompressionZstd})
+	builder.push(&batchCommandsEntry{req: req, compressor: config.GrpcCompressionZstd})
+	builder.push(&batchCommandsEntry{req: req, compressor: config.GrpcCompressionSnappy})
+	builder.push(&batchCommandsEntry{req: req, compressor: config.GrpcCompressionSnappy, forwardedHost: "127.0.0.1:6666"})
+	batchedReq, forwardingReqs, compressedReqs := builder.buildWithLimit(math.MaxInt64, nil)
+	assert.Equal(t, len(batchedReq.GetRequests()), 1)
+	assert.Equal(t, len(forwardingReqs), 1)
+	assert.Equal(t, len(forwardingReqs["127.0.0.1:6666"].GetRequests()), 1)
+	assert.Equal(t, len(compressedReqs), 2)
+	assert.Equal(t, len(compressedReqs[config.GrpcCompressionZstd].GetRequestIds()), 2)
+	assert.Equal(t, len(compressedReqs[config.GrpcCompressionSnappy].GetRequestIds()), 1)
+
 	// Test not collecting canceled requests
 	builder.reset()
 	entries := []*batchCommandsEntry{
@@ -459,7 +482,7 @@ func TestBatchCommandsBuilder(t *testing.T) {
 		builder.push(entry)
 	}
 	entryMap = make(map[uint64]*batchCommandsEntry)
-	batchedReq, forwardingReqs = builder.buildWithLimit(math.MaxInt64, func(id uint64, e *batchCommandsEntry) {
+	batchedReq, forwardingReqs, _ = builder.buildWithLimit(math.MaxInt64, func(id uint64, e *batchCommandsEntry) {
 		entryMap[id] = e
 	})
 	assert.Equal(t, len(batchedReq.GetRequests()), 2)
@@ -738,7 +761,7 @@ func TestLimitConcurrency(t *testing.T) {
 	batch := newBatchConn(1, 128, nil)
 	{
 		batch.reqBuilder.push(&batchCommandsEntry{req: &tikvpb.BatchCommandsRequest_Request{}})
-		reqs, _ := batch.reqBuilder.buildWithLimit(1, func(_ uint64, _ *batchCommandsEntry) {})
+		reqs, _, _ := batch.reqBuilder.buildWithLimit(1, func(_ uint64, _ *batchCommandsEntry) {})
 		re.Len(reqs.RequestIds, 1)
 		re.Equal(0, batch.reqBuilder.len())
 		batch.reqBuilder.reset()
@@ -748,7 +771,7 @@ func TestLimitConcurrency(t *testing.T) {
 	{
 		batch.reqBuilder.push(&batchCommandsEntry{req: &tikvpb.BatchCommandsRequest_Request{}, pri: highTaskPriority})
 		batch.reqBuilder.push(&batchCommandsEntry{req: &tikvpb.BatchCommandsRequest_Request{}, pri: highTaskPriority - 1})
-		reqs, _ := batch.reqBuilder.buildWithLimit(0, func(_ uint64, _ *batchCommandsEntry) {})
+		reqs, _, _ := batch.reqBuilder.buildWithLimit(0, func(_ uint64, _ *batchCommandsEntry) {})
 		re.Len(reqs.RequestIds, 1)
 		batch.reqBuilder.reset()
 		re.Equal(1, batch.reqBuilder.len())
@@ -758,7 +781,7 @@ func TestLimitConcurrency(t *testing.T) {
 	{
 		batch.reqBuilder.push(&batchCommandsEntry{req: &tikvpb.BatchCommandsRequest_Request{}})
 		batch.reqBuilder.push(&batchCommandsEntry{req: &tikvpb.BatchCommandsRequest_Request{}})
-		reqs, _ := batch.reqBuilder.buildWithLimit(2, func(_ uint64, _ *batchCommandsEntry) {})
+		reqs, _, _ := batch.reqBuilder.buildWithLimit(2, func(_ uint64, _ *batchCommandsEntry) {})
 		re.Len(reqs.RequestIds, 2)
 		re.Equal(1, batch.reqBuilder.len())
 		batch.reqBuilder.reset()
@@ -1140,3 +1163,248 @@ func TestBatchPolicy(t *testing.T) {
 		}
 	})
 }
+
+func TestStoreCircuitBreaker(t *testing.T) {
+	settings := &StoreCircuitBreakerSettings{
+		ErrorRateThresholdPct:   60,
+		TimeoutRateThresholdPct: 30,
+		MinQPSForOpen:           1,
+		ErrorRateWindow:         10 * time.Second,
+		CoolDownInterval:        time.Second,
+		HalfOpenSuccessCount:    2,
+	}
+	cb := &storeCircuitBreaker{addr: "store1"}
+	now := time.Now()
+	timeoutErr := errors.WithStack(context.DeadlineExceeded)
+
+	// The rates are not evaluated until the qps is met.
+	for i := 0; i < 9; i++ {
+		require.True(t, cb.allow(settings, now))
+		cb.onResult(settings, now, timeoutErr)
+	}
+	require.False(t, cb.isOpen(settings, now))
+	for i := 0; i < 20; i++ {
+		require.True(t, cb.allow(settings, now))
+		cb.onResult(settings, now, nil)
+	}
+	// 10 timeouts in 30 requests reach the timeout rate threshold.
+	require.True(t, cb.allow(settings, now))
+	cb.onResult(settings, now, timeoutErr)
+	require.True(t, cb.isOpen(settings, now))
+	require.False(t, cb.allow(settings, now))
+
+	// The requests out of the window are not counted after closed, and the
+	// circuit breaker is half-open after cooling down.
+	now = now.Add(settings.CoolDownInterval)
+	require.False(t, cb.isOpen(settings, now))
+	require.True(t, cb.allow(settings, now))
+	require.True(t, cb.allow(settings, now))
+	require.False(t, cb.allow(settings, now))
+	cb.onResult(settings, now, errors.New("send failed"))
+	require.True(t, cb.isOpen(settings, now))
+
+	now = now.Add(settings.CoolDownInterval)
+	require.True(t, cb.allow(settings, now))
+	cb.onResult(settings, now, nil)
+	require.Equal(t, circuitBreakerHalfOpen, cb.mu.state)
+	require.True(t, cb.allow(settings, now))
+	cb.onResult(settings, now, nil)
+	require.Equal(t, circuitBreakerClosed, cb.mu.state)
+
+	// The errors in the expired buckets are not counted.
+	for i := 0; i < 9; i++ {
+		cb.onResult(settings, now, errors.New("send failed"))
+	}
+	now = now.Add(settings.ErrorRateWindow)
+	for i := 0; i < 10; i++ {
+		cb.onResult(settings, now, nil)
+	}
+	cb.onResult(settings, now, errors.New("send failed"))
+	require.Equal(t, circuitBreakerClosed, cb.mu.state)
+}
+
+func TestStoreCircuitBreakerFastFail(t *testing.T) {
+	defer ChangeStoreCircuitBreakerSettings(func(settings *StoreCircuitBreakerSettings) {
+		settings.ErrorRateThresholdPct = 0
+		settings.MinQPSForOpen = 10
+	})
+	ChangeStoreCircuitBreakerSettings(func(settings *StoreCircuitBreakerSettings) {
+		settings.ErrorRateThresholdPct = 50
+		settings.MinQPSForOpen = 0
+	})
+	client := NewRPCClient()
+	defer func() {
+		require.NoError(t, client.Close())
+	}()
+	unknownAddr := "127.0.0.1:52028"
+	req := tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("key")})
+	_, err := client.sendRequest(context.Background(), unknownAddr, req, 100*time.Millisecond)
+	require.Error(t, err)
+	require.True(t, IsStoreCircuitBreakerOpen(unknownAddr))
+
+	start := time.Now()
+	_, err = client.sendRequest(context.Background(), unknownAddr, req, time.Second)
+	require.ErrorIs(t, err, tikverr.ErrStoreCircuitBreakerOpen)
+	require.Less(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestCompressors(t *testing.T) {
+	data := []byte(strings.Repeat("tikv-client-go", 1024))
+	for _, name := range []string{config.GrpcCompressionGzip, config.GrpcCompressionSnappy, config.GrpcCompressionZstd} {
+		compressor := encoding.GetCompressor(name)
+		require.NotNil(t, compressor, name)
+		// Run twice to reuse the pooled writers and readers.
+		for i := 0; i < 2; i++ {
+			var buf bytes.Buffer
+			w, err := compressor.Compress(&buf)
+			require.NoError(t, err)
+			_, err = w.Write(data)
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+			require.Less(t, buf.Len(), len(data), name)
+
+			r, err := compressor.Decompress(&buf)
+			require.NoError(t, err)
+			decompressed, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, data, decompressed, name)
+		}
+	}
+}
+
+func TestSelectCompressor(t *testing.T) {
+	policies := map[string]config.GrpcCompressionPolicy{
+		tikvrpc.CmdPrewrite.String(): {Type: config.GrpcCompressionZstd, MinSize: 64},
+		tikvrpc.CmdGet.String():      {Type: config.GrpcCompressionNone},
+	}
+	small := tikvrpc.NewRequest(tikvrpc.CmdPrewrite, &kvrpcpb.PrewriteRequest{PrimaryLock: []byte("k")})
+	compressor, size := selectCompressor(policies, small)
+	require.Equal(t, encoding.Identity, compressor)
+	require.Equal(t, small.Prewrite().Size(), size)
+
+	large := tikvrpc.NewRequest(tikvrpc.CmdPrewrite, &kvrpcpb.PrewriteRequest{PrimaryLock: make([]byte, 64)})
+	compressor, size = selectCompressor(policies, large)
+	require.Equal(t, config.GrpcCompressionZstd, compressor)
+	require.Equal(t, large.Prewrite().Size(), size)
+
+	compressor, _ = selectCompressor(policies, tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: make([]byte, 64)}))
+	require.Equal(t, encoding.Identity, compressor)
+	compressor, _ = selectCompressor(policies, tikvrpc.NewRequest(tikvrpc.CmdCommit, &kvrpcpb.CommitRequest{}))
+	require.Equal(t, "", compressor)
+}
+
+func TestCompressorNegotiator(t *testing.T) {
+	n := newCompressorNegotiator(config.GrpcCompressionSnappy)
+	// The compressors other than gzip are not used until the server advertises them.
+	require.Equal(t, encoding.Identity, n.negotiate(""))
+	require.Equal(t, encoding.Identity, n.negotiate(config.GrpcCompressionZstd))
+	require.Equal(t, encoding.Identity, n.negotiate(config.GrpcCompressionNone))
+	require.Equal(t, config.GrpcCompressionGzip, n.negotiate(config.GrpcCompressionGzip))
+
+	// The headers without grpc-accept-encoding are ignored.
+	n.observe(metadata.Pairs("grpc-accept-encoding", "identity, gzip, snappy"))
+	n.observe(metadata.MD{})
+	require.Equal(t, config.GrpcCompressionSnappy, n.negotiate(""))
+	require.Equal(t, encoding.Identity, n.negotiate(config.GrpcCompressionZstd))
+	require.Equal(t, config.GrpcCompressionGzip, n.negotiate(config.GrpcCompressionGzip))
+
+	// TiKV only decompresses identity, deflate and gzip.
+	n.observe(metadata.Pairs("grpc-accept-encoding", "identity,deflate,gzip"))
+	require.Equal(t, encoding.Identity, n.negotiate(""))
+	require.Equal(t, encoding.Identity, newCompressorNegotiator(config.GrpcCompressionNone).negotiate(""))
+}
+
+func TestCompressionPolicies(t *testing.T) {
+	server, port := mockserver.StartMockTikvService()
+	require.True(t, port > 0)
+	defer server.Stop()
+	server.SetAcceptEncoding("identity,gzip,snappy")
+	handle := func(req *tikvpb.BatchCommandsRequest) (*tikvpb.BatchCommandsResponse, error) {
+		resp := &tikvpb.BatchCommandsResponse{RequestIds: req.GetRequestIds()}
+		for _, r := range req.GetRequests() {
+			if r.GetPrewrite() != nil {
+				resp.Responses = append(resp.Responses, &tikvpb.BatchCommandsResponse_Response{
+					Cmd: &tikvpb.BatchCommandsResponse_Response_Prewrite{Prewrite: &kvrpcpb.PrewriteResponse{}},
+				})
+			} else {
+				resp.Responses = append(resp.Responses, &tikvpb.BatchCommandsResponse_Response{
+					Cmd: &tikvpb.BatchCommandsResponse_Response_Get{Get: &kvrpcpb.GetResponse{}},
+				})
+			}
+		}
+		return resp, nil
+	}
+	server.OnBatchCommandsRequest.Store(&handle)
+	addr := server.Addr()
+
+	// The small prewrite requests are sent uncompressed, and the get requests are
+	// compressed by the default gzip compressor.
+	policies := map[string]config.GrpcCompressionPolicy{
+		tikvrpc.CmdPrewrite.String(): {Type: config.GrpcCompressionSnappy, MinSize: 64},
+	}
+	bytesSent := func(vec *prometheus.CounterVec, reqType, compressor string) float64 {
+		metric := dto.Metric{}
+		require.NoError(t, vec.WithLabelValues(addr, reqType, compressor).Write(&metric))
+		return metric.GetCounter().GetValue()
+	}
+	for _, maxBatchSize := range []uint{0, 128} {
+		restoreFn := config.UpdateGlobal(func(conf *config.Config) {
+			conf.TiKVClient.MaxBatchSize = maxBatchSize
+			conf.TiKVClient.GrpcConnectionCount = 1
+			conf.TiKVClient.GrpcCompressionType = config.GrpcCompressionGzip
+			conf.TiKVClient.GrpcCompressionPolicies = policies
+		})
+		rpcClient := NewRPCClient()
+		// The compressors supported by the server are learned from the response of the get request,
+		// so the large prewrite request is compressed by snappy.
+		details := &util.ExecDetails{}
+		for _, req := range []*tikvrpc.Request{
+			tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("k")}),
+			tikvrpc.NewRequest(tikvrpc.CmdPrewrite, &kvrpcpb.PrewriteRequest{PrimaryLock: make([]byte, 1024)}),
+			tikvrpc.NewRequest(tikvrpc.CmdPrewrite, &kvrpcpb.PrewriteRequest{PrimaryLock: []byte("k")}),
+		} {
+			ctx := context.Background()
+			if req.Type == tikvrpc.CmdPrewrite && len(req.Prewrite().PrimaryLock) > 64 {
+				ctx = context.WithValue(ctx, util.ExecDetailsKey, details)
+			}
+			_, err := rpcClient.SendRequest(ctx, addr, req, 10*time.Second)
+			require.NoError(t, err)
+		}
+		// The exec details of the batched requests are updated after the messages are sent.
+		require.Eventually(t, func() bool {
+			return atomic.LoadInt64(&details.UncompressedBytesSent) >= 1024
+		}, time.Second, 10*time.Millisecond)
+		require.Positive(t, atomic.LoadInt64(&details.CompressedBytesSent))
+		require.Less(t, atomic.LoadInt64(&details.CompressedBytesSent), atomic.LoadInt64(&details.UncompressedBytesSent))
+		if maxBatchSize > 0 {
+			connArray, err := rpcClient.getConnArray(addr, true)
+			require.NoError(t, err)
+			batchClient := connArray.batchConn.batchCommandsClients[0]
+			require.NotNil(t, batchClient.client)
+			require.Len(t, batchClient.compressedClients, 2)
+			require.Equal(t, config.GrpcCompressionSnappy, batchClient.compressedClients[config.GrpcCompressionSnappy].compressor)
+			require.Equal(t, encoding.Identity, batchClient.compressedClients[encoding.Identity].compressor)
+			// The messages of the batch streams are counted by the compressors of the streams.
+			for _, compressor := range []string{config.GrpcCompressionSnappy, config.GrpcCompressionNone, config.GrpcCompressionGzip} {
+				require.Positive(t, bytesSent(metrics.TiKVGRPCUncompressedBytes, batchCommandsRequestType, compressor), compressor)
+				require.Positive(t, bytesSent(metrics.TiKVGRPCCompressedBytes, batchCommandsRequestType, compressor), compressor)
+			}
+			require.Equal(t,
+				bytesSent(metrics.TiKVGRPCUncompressedBytes, batchCommandsRequestType, config.GrpcCompressionNone),
+				bytesSent(metrics.TiKVGRPCCompressedBytes, batchCommandsRequestType, config.GrpcCompressionNone))
+		} else {
+			prewrite, get := tikvrpc.CmdPrewrite.String(), tikvrpc.CmdGet.String()
+			require.GreaterOrEqual(t, bytesSent(metrics.TiKVGRPCUncompressedBytes, prewrite, config.GrpcCompressionSnappy), float64(64))
+			require.Positive(t, bytesSent(metrics.TiKVGRPCCompressedBytes, prewrite, config.GrpcCompressionSnappy))
+			require.Positive(t, bytesSent(metrics.TiKVGRPCUncompressedBytes, prewrite, config.GrpcCompressionNone))
+			require.Equal(t,
+				bytesSent(metrics.TiKVGRPCUncompressedBytes, prewrite, config.GrpcCompressionNone),
+				bytesSent(metrics.TiKVGRPCCompressedBytes, prewrite, config.GrpcCompressionNone))
+			// The requests without policies are counted by the default compressor.
+			require.Positive(t, bytesSent(metrics.TiKVGRPCUncompressedBytes, get, config.GrpcCompressionGzip))
+			require.Positive(t, bytesSent(metrics.TiKVGRPCCompressedBytes, get, config.GrpcCompressionGzip))
+		}
+		rpcClient.Close()
+		restoreFn()
+	}
+}
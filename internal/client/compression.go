@@ -0,0 +1,358 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/tikv/client-go/v2/config"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	// Register the gzip compressor.
+	_ "google.golang.org/grpc/encoding/gzip"
+)
+
+func init() {
+	encoding.RegisterCompressor(newSnappyCompressor())
+	encoding.RegisterCompressor(newZstdCompressor())
+}
+
+type snappyCompressor struct {
+	writers sync.Pool
+	readers sync.Pool
+}
+
+func newSnappyCompressor() *snappyCompressor {
+	c := &snappyCompressor{}
+	c.writers.New = func() any {
+		return &snappyWriter{Writer: snappy.NewBufferedWriter(io.Discard), pool: &c.writers}
+	}
+	return c
+}
+
+func (c *snappyCompressor) Name() string {
+	return config.GrpcCompressionSnappy
+}
+
+func (c *snappyCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	z := c.writers.Get().(*snappyWriter)
+	z.Reset(w)
+	return z, nil
+}
+
+func (c *snappyCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	z, ok := c.readers.Get().(*snappyReader)
+	if !ok {
+		return &snappyReader{Reader: snappy.NewReader(r), pool: &c.readers}, nil
+	}
+	z.Reset(r)
+	return z, nil
+}
+
+type snappyWriter struct {
+	*snappy.Writer
+	pool *sync.Pool
+}
+
+func (z *snappyWriter) Close() error {
+	defer z.pool.Put(z)
+	return z.Writer.Close()
+}
+
+type snappyReader struct {
+	*snappy.Reader
+	pool *sync.Pool
+}
+
+func (z *snappyReader) Read(p []byte) (int, error) {
+	n, err := z.Reader.Read(p)
+	if err == io.EOF {
+		z.pool.Put(z)
+	}
+	return n, err
+}
+
+type zstdCompressor struct {
+	writers sync.Pool
+	readers sync.Pool
+}
+
+func newZstdCompressor() *zstdCompressor {
+	c := &zstdCompressor{}
+	c.writers.New = func() any {
+		// The encoder never fails with the valid options.
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return &zstdWriter{Encoder: w, pool: &c.writers}
+	}
+	return c
+}
+
+func (c *zstdCompressor) Name() string {
+	return config.GrpcCompressionZstd
+}
+
+func (c *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	z := c.writers.Get().(*zstdWriter)
+	z.Reset(w)
+	return z, nil
+}
+
+func (c *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	z, ok := c.readers.Get().(*zstdReader)
+	if !ok {
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return &zstdReader{Decoder: d, pool: &c.readers}, nil
+	}
+	if err := z.Reset(r); err != nil {
+		c.readers.Put(z)
+		return nil, err
+	}
+	return z, nil
+}
+
+type zstdWriter struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+func (z *zstdWriter) Close() error {
+	defer z.pool.Put(z)
+	return z.Encoder.Close()
+}
+
+type zstdReader struct {
+	*zstd.Decoder
+	pool *sync.Pool
+}
+
+func (z *zstdReader) Read(p []byte) (int, error) {
+	n, err := z.Decoder.Read(p)
+	if err == io.EOF {
+		z.pool.Put(z)
+	}
+	return n, err
+}
+
+type compressorCtxKey struct{}
+
+// withCompressor sets the compressor of the request sent with the context.
+func withCompressor(ctx context.Context, compressor string) context.Context {
+	return context.WithValue(ctx, compressorCtxKey{}, compressor)
+}
+
+// compressorFromContext returns the compressor set by withCompressor, an empty
+// string means the default compressor of the connection.
+func compressorFromContext(ctx context.Context) string {
+	compressor, _ := ctx.Value(compressorCtxKey{}).(string)
+	return compressor
+}
+
+type requestTypeCtxKey struct{}
+
+// batchCommandsRequestType is the request type of the messages sent by the
+// BatchCommands streams in the compression metrics.
+const batchCommandsRequestType = "BatchCommands"
+
+// withRequestType sets the request type of the messages sent with the context,
+// which labels the compression metrics.
+func withRequestType(ctx context.Context, reqType string) context.Context {
+	return context.WithValue(ctx, requestTypeCtxKey{}, reqType)
+}
+
+func requestTypeFromContext(ctx context.Context) string {
+	reqType, _ := ctx.Value(requestTypeCtxKey{}).(string)
+	return reqType
+}
+
+// acceptEncodingHeader is the header by which the server advertises the
+// compressors it can decompress.
+const acceptEncodingHeader = "grpc-accept-encoding"
+
+// compressorNegotiator resolves the compressors of the requests sent by a
+// connection to the ones supported by the server. TiKV only decompresses
+// identity, deflate and gzip messages, so gzip is always used as configured,
+// while the other compressors, e.g. snappy and zstd, are used only after the
+// server advertises them by grpc-accept-encoding in the response headers.
+// Until then, or if the server doesn't support them, the messages are sent
+// uncompressed.
+type compressorNegotiator struct {
+	// defaultCompressor is the compressor of the requests that don't set one.
+	defaultCompressor string
+	// acceptEncoding is the last grpc-accept-encoding header received.
+	acceptEncoding atomic.Pointer[string]
+}
+
+func newCompressorNegotiator(defaultCompressor string) *compressorNegotiator {
+	return &compressorNegotiator{defaultCompressor: defaultCompressor}
+}
+
+// observe records the compressors advertised in the response headers.
+func (n *compressorNegotiator) observe(md metadata.MD) {
+	if v := md.Get(acceptEncodingHeader); len(v) > 0 {
+		acceptEncoding := strings.Join(v, ",")
+		n.acceptEncoding.Store(&acceptEncoding)
+	}
+}
+
+func (n *compressorNegotiator) accepts(compressor string) bool {
+	acceptEncoding := n.acceptEncoding.Load()
+	if acceptEncoding == nil {
+		return false
+	}
+	for _, name := range strings.Split(*acceptEncoding, ",") {
+		if strings.TrimSpace(name) == compressor {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiate returns the compressor to send the request with, an empty
+// compressor means the default one of the connection.
+func (n *compressorNegotiator) negotiate(compressor string) string {
+	if compressor == "" {
+		compressor = n.defaultCompressor
+	}
+	switch compressor {
+	case "", config.GrpcCompressionNone, encoding.Identity:
+		return encoding.Identity
+	case config.GrpcCompressionGzip:
+		return compressor
+	}
+	if n.accepts(compressor) {
+		return compressor
+	}
+	return encoding.Identity
+}
+
+// unaryInterceptor applies the negotiated compressor of the request, which
+// overrides the default one of the connection, and observes the compressors
+// advertised by the server.
+func (n *compressorNegotiator) unaryInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	compressor := n.negotiate(compressorFromContext(ctx))
+	var header metadata.MD
+	opts = append(opts, grpc.UseCompressor(compressor), grpc.Header(&header))
+	err := invoker(withCompressor(ctx, compressor), method, req, reply, cc, opts...)
+	n.observe(header)
+	return err
+}
+
+// streamInterceptor applies the negotiated compressor to the messages of the
+// stream, and observes the compressors advertised by the server once the
+// first response is received.
+func (n *compressorNegotiator) streamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	compressor := n.negotiate(compressorFromContext(ctx))
+	opts = append(opts, grpc.UseCompressor(compressor))
+	stream, err := streamer(withCompressor(ctx, compressor), desc, cc, method, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &negotiatingStream{ClientStream: stream, negotiator: n}, nil
+}
+
+type negotiatingStream struct {
+	grpc.ClientStream
+	negotiator *compressorNegotiator
+	observed   bool
+}
+
+func (s *negotiatingStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil && !s.observed {
+		// The headers are received along with the first response.
+		s.observed = true
+		if header, err := s.Header(); err == nil {
+			s.negotiator.observe(header)
+		}
+	}
+	return err
+}
+
+// selectCompressor selects the compressor of the request by the policy of its
+// command type. It returns an empty compressor if there is no policy for the
+// command type, and encoding.Identity if the request should not be compressed.
+// The size of the request is returned if it's computed.
+func selectCompressor(policies map[string]config.GrpcCompressionPolicy, req *tikvrpc.Request) (compressor string, size int) {
+	policy, ok := policies[req.Type.String()]
+	if !ok {
+		return "", 0
+	}
+	if sized, ok := req.Req.(interface{ Size() int }); ok {
+		size = sized.Size()
+	}
+	if policy.Type == config.GrpcCompressionNone || size < policy.MinSize {
+		return encoding.Identity, size
+	}
+	return policy.Type, size
+}
+
+type payloadSizesCtxKey struct{}
+
+// payloadSizes are the sizes of the last message sent by a stream before and
+// after compression.
+type payloadSizes struct {
+	size           int
+	compressedSize int
+}
+
+// withPayloadSizes sets the sizes to record the messages sent with the context.
+func withPayloadSizes(ctx context.Context, sizes *payloadSizes) context.Context {
+	return context.WithValue(ctx, payloadSizesCtxKey{}, sizes)
+}
+
+// compressionStatsHandler collects the sizes of the messages sent by the
+// connections before and after compression, which are reported by gRPC after
+// the messages are encoded.
+type compressionStatsHandler struct {
+	conn *connArray
+}
+
+func (h *compressionStatsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h *compressionStatsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	payload, ok := s.(*stats.OutPayload)
+	if !ok || !payload.Client {
+		return
+	}
+	h.conn.updateCompressionMetrics(requestTypeFromContext(ctx), compressorFromContext(ctx), payload.Length, payload.CompressedLength)
+	// The messages of the batch streams are shared by the requests, whose
+	// exec details are updated by the stream after sending.
+	if sizes, ok := ctx.Value(payloadSizesCtxKey{}).(*payloadSizes); ok {
+		sizes.size, sizes.compressedSize = payload.Length, payload.CompressedLength
+	} else if stmtExec := ctx.Value(util.ExecDetailsKey); stmtExec != nil {
+		(&networkCollector{}).onCompression(stmtExec.(*util.ExecDetails), payload.Length, payload.CompressedLength)
+	}
+}
+
+func (h *compressionStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h *compressionStatsHandler) HandleConn(context.Context, stats.ConnStats) {}
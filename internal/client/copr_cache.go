@@ -0,0 +1,321 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"container/list"
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/coprocessor"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config"
+	"github.com/tikv/client-go/v2/metrics"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
+	"github.com/tikv/client-go/v2/util/async"
+)
+
+// coprCacheEntryOverhead is the estimated memory used by a cache entry besides
+// its key and data.
+const coprCacheEntryOverhead = 64
+
+// CoprCache caches the results of coprocessor requests. A cached result is
+// reused when TiKV reports that the data of the region is not changed since
+// the result is computed, in which case TiKV answers "cache hit" without
+// returning the data again.
+//
+// The cache can be added to a Client by Wrap, or to the requests of a context
+// by Interceptor. A nil *CoprCache is a disabled cache.
+type CoprCache struct {
+	capacity                int64
+	admissionMaxRanges      int
+	admissionMaxResultBytes int
+	admissionMinProcessTime time.Duration
+
+	mu struct {
+		sync.Mutex
+		// lru holds *coprCacheEntry, the most recently used one is at the front.
+		lru      *list.List
+		entries  map[string]*list.Element
+		memUsage int64
+	}
+}
+
+type coprCacheEntry struct {
+	key string
+	// startTS is the start ts of the request which computes the data, the data
+	// can only be reused by requests that are not older than it.
+	startTS uint64
+	// dataVersion is the data version of the region reported by TiKV.
+	dataVersion uint64
+	data        []byte
+}
+
+func (e *coprCacheEntry) memUsage() int64 {
+	return int64(len(e.key) + len(e.data) + coprCacheEntryOverhead)
+}
+
+// NewCoprCache creates a coprocessor cache with the config. It returns nil if
+// the capacity of the config is zero, which means the cache is disabled.
+func NewCoprCache(cfg config.CoprocessorCache) (*CoprCache, error) {
+	if cfg.CapacityMB <= 0 {
+		return nil, nil
+	}
+	capacity := int64(cfg.CapacityMB * 1024 * 1024)
+	maxResultBytes := int64(cfg.AdmissionMaxResultMB * 1024 * 1024)
+	if maxResultBytes <= 0 {
+		return nil, errors.New("coprocessor cache admission max result must be positive")
+	}
+	if maxResultBytes > capacity {
+		return nil, errors.Errorf("coprocessor cache capacity %.2fMB is less than the admission max result %.2fMB",
+			cfg.CapacityMB, cfg.AdmissionMaxResultMB)
+	}
+	c := &CoprCache{
+		capacity:                capacity,
+		admissionMaxRanges:      int(cfg.AdmissionMaxRanges),
+		admissionMaxResultBytes: int(maxResultBytes),
+		admissionMinProcessTime: time.Duration(cfg.AdmissionMinProcessMs) * time.Millisecond,
+	}
+	c.mu.lru = list.New()
+	c.mu.entries = make(map[string]*list.Element)
+	return c, nil
+}
+
+// Wrap returns a Client which looks up the cache before sending coprocessor
+// requests by the given client.
+func (c *CoprCache) Wrap(client Client) Client {
+	if c == nil {
+		return client
+	}
+	return coprCacheClient{Client: client, cache: c}
+}
+
+// Interceptor returns an RPCInterceptor which looks up the cache before sending
+// coprocessor requests.
+func (c *CoprCache) Interceptor() interceptor.RPCInterceptor {
+	return interceptor.NewRPCInterceptor("copr-cache", func(next interceptor.RPCInterceptorFunc) interceptor.RPCInterceptorFunc {
+		return func(target string, req *tikvrpc.Request) (*tikvrpc.Response, error) {
+			req, entry, key := c.prepare(req)
+			resp, err := next(target, req)
+			return c.onResponse(req, entry, key, resp, err)
+		}
+	})
+}
+
+// MemUsage returns the memory used by the cache in bytes.
+func (c *CoprCache) MemUsage() int64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.mu.memUsage
+}
+
+// Len returns the number of cached results.
+func (c *CoprCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.mu.lru.Len()
+}
+
+// prepare returns the request to send. If the request can be cached, the
+// returned request enables the cache of TiKV and the cache key is returned. The
+// returned entry is the cached result which the request asks TiKV to validate.
+func (c *CoprCache) prepare(req *tikvrpc.Request) (*tikvrpc.Request, *coprCacheEntry, string) {
+	if c == nil || req.Type != tikvrpc.CmdCop {
+		return req, nil, ""
+	}
+	copReq := req.Cop()
+	if !c.checkRequestAdmission(copReq) {
+		return req, nil, ""
+	}
+	key := coprCacheKey(req, copReq)
+	entry := c.get(key)
+	if entry != nil && entry.startTS > copReq.StartTs {
+		entry = nil
+	}
+
+	// Copy the request since it may be retried or used by other goroutines.
+	newCopReq := *copReq
+	newCopReq.IsCacheEnabled = true
+	newCopReq.CacheIfMatchVersion = 0
+	if entry != nil {
+		newCopReq.CacheIfMatchVersion = entry.dataVersion
+	}
+	newReq := *req
+	newReq.Req = &newCopReq
+	return &newReq, entry, key
+}
+
+// onResponse fills the data of the cache hit response and caches the admitted
+// results.
+func (c *CoprCache) onResponse(req *tikvrpc.Request, entry *coprCacheEntry, key string, resp *tikvrpc.Response, err error) (*tikvrpc.Response, error) {
+	if len(key) == 0 || err != nil || resp == nil {
+		return resp, err
+	}
+	copResp, ok := resp.Resp.(*coprocessor.Response)
+	if !ok || copResp.GetRegionError() != nil || copResp.GetLocked() != nil || len(copResp.GetOtherError()) > 0 {
+		return resp, err
+	}
+
+	if copResp.IsCacheHit {
+		if entry == nil {
+			return nil, errors.Errorf("unexpected coprocessor cache hit of region %d without cached result", req.RegionId)
+		}
+		metrics.CoprCacheCounterHit.Inc()
+		copResp.Data = entry.data
+		return resp, nil
+	}
+	metrics.CoprCacheCounterMiss.Inc()
+
+	if !copResp.CanBeCached || !c.checkResponseAdmission(copResp) {
+		metrics.CoprCacheCounterReject.Inc()
+		return resp, nil
+	}
+	metrics.CoprCacheCounterAdmit.Inc()
+	// The response data may refer to the receive buffer, copy it.
+	data := make([]byte, len(copResp.Data))
+	copy(data, copResp.Data)
+	c.put(&coprCacheEntry{
+		key:         key,
+		startTS:     req.Cop().StartTs,
+		dataVersion: copResp.CacheLastVersion,
+		data:        data,
+	})
+	return resp, nil
+}
+
+func (c *CoprCache) checkRequestAdmission(copReq *coprocessor.Request) bool {
+	// Paging and batched requests returns partial results, which can not be
+	// reused.
+	if copReq.PagingSize > 0 || len(copReq.Tasks) > 0 {
+		return false
+	}
+	return len(copReq.Ranges) <= c.admissionMaxRanges
+}
+
+func (c *CoprCache) checkResponseAdmission(copResp *coprocessor.Response) bool {
+	if len(copResp.Data) == 0 || len(copResp.Data) > c.admissionMaxResultBytes {
+		return false
+	}
+	return coprProcessTime(copResp) >= c.admissionMinProcessTime
+}
+
+func coprProcessTime(copResp *coprocessor.Response) time.Duration {
+	if details := copResp.GetExecDetailsV2(); details != nil {
+		if timeDetail := details.GetTimeDetailV2(); timeDetail != nil {
+			return time.Duration(timeDetail.GetProcessWallTimeNs())
+		}
+		if timeDetail := details.GetTimeDetail(); timeDetail != nil {
+			return time.Duration(timeDetail.GetProcessWallTimeMs()) * time.Millisecond
+		}
+	}
+	return time.Duration(copResp.GetExecDetails().GetTimeDetail().GetProcessWallTimeMs()) * time.Millisecond
+}
+
+// coprCacheKey builds the cache key from the region, the region version and
+// the content of the request.
+func coprCacheKey(req *tikvrpc.Request, copReq *coprocessor.Request) string {
+	size := 8*3 + len(copReq.Data)
+	for _, r := range copReq.Ranges {
+		size += 8 + len(r.Start) + len(r.End)
+	}
+	key := make([]byte, 0, size)
+	key = binary.BigEndian.AppendUint64(key, req.RegionId)
+	key = binary.BigEndian.AppendUint64(key, req.GetRegionEpoch().GetVersion())
+	key = binary.BigEndian.AppendUint64(key, uint64(copReq.Tp))
+	key = append(key, copReq.Data...)
+	for _, r := range copReq.Ranges {
+		key = binary.BigEndian.AppendUint32(key, uint32(len(r.Start)))
+		key = append(key, r.Start...)
+		key = binary.BigEndian.AppendUint32(key, uint32(len(r.End)))
+		key = append(key, r.End...)
+	}
+	return string(key)
+}
+
+func (c *CoprCache) get(key string) *coprCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.mu.entries[key]
+	if !ok {
+		return nil
+	}
+	c.mu.lru.MoveToFront(elem)
+	return elem.Value.(*coprCacheEntry)
+}
+
+func (c *CoprCache) put(entry *coprCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delta := entry.memUsage()
+	if elem, ok := c.mu.entries[entry.key]; ok {
+		old := elem.Value.(*coprCacheEntry)
+		if old.startTS > entry.startTS {
+			// Keep the result which can be reused by more requests.
+			return
+		}
+		delta -= old.memUsage()
+		elem.Value = entry
+		c.mu.lru.MoveToFront(elem)
+	} else {
+		c.mu.entries[entry.key] = c.mu.lru.PushFront(entry)
+	}
+	c.mu.memUsage += delta
+	for c.mu.memUsage > c.capacity {
+		back := c.mu.lru.Back()
+		evicted := c.mu.lru.Remove(back).(*coprCacheEntry)
+		delete(c.mu.entries, evicted.key)
+		c.mu.memUsage -= evicted.memUsage()
+		delta -= evicted.memUsage()
+		metrics.CoprCacheCounterEvict.Inc()
+	}
+	metrics.TiKVCoprCacheMemoryGauge.Add(float64(delta))
+}
+
+var _ ClientAsync = coprCacheClient{}
+
+type coprCacheClient struct {
+	Client
+	cache *CoprCache
+}
+
+func (r coprCacheClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	req, entry, key := r.cache.prepare(req)
+	resp, err := r.Client.SendRequest(ctx, addr, req, timeout)
+	return r.cache.onResponse(req, entry, key, resp, err)
+}
+
+func (r coprCacheClient) SendRequestAsync(ctx context.Context, addr string, req *tikvrpc.Request, cb async.Callback[*tikvrpc.Response]) {
+	cli, ok := r.Client.(ClientAsync)
+	if !ok {
+		cb.Invoke(nil, errors.Errorf("%T dose not implement ClientAsync interface", r.Client))
+		return
+	}
+	req, entry, key := r.cache.prepare(req)
+	if len(key) > 0 {
+		cb.Inject(func(resp *tikvrpc.Response, err error) (*tikvrpc.Response, error) {
+			return r.cache.onResponse(req, entry, key, resp, err)
+		})
+	}
+	cli.SendRequestAsync(ctx, addr, req, cb)
+}
@@ -0,0 +1,207 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/coprocessor"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/config"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/tikvrpc/interceptor"
+)
+
+// mockCoprClient mocks the coprocessor cache of TiKV, it answers cache hit if
+// the requested version matches the data version.
+type mockCoprClient struct {
+	emptyClient
+	dataVersion uint64
+	data        []byte
+	processTime time.Duration
+	reqs        []*coprocessor.Request
+}
+
+func (c *mockCoprClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+	if req.Type != tikvrpc.CmdCop {
+		return &tikvrpc.Response{}, nil
+	}
+	copReq := req.Cop()
+	c.reqs = append(c.reqs, copReq)
+	resp := &coprocessor.Response{
+		ExecDetailsV2: &kvrpcpb.ExecDetailsV2{
+			TimeDetailV2: &kvrpcpb.TimeDetailV2{ProcessWallTimeNs: uint64(c.processTime)},
+		},
+	}
+	if copReq.IsCacheEnabled {
+		if copReq.CacheIfMatchVersion == c.dataVersion {
+			resp.IsCacheHit = true
+		} else {
+			resp.CanBeCached = true
+			resp.CacheLastVersion = c.dataVersion
+		}
+	}
+	if !resp.IsCacheHit {
+		resp.Data = append([]byte(nil), c.data...)
+	}
+	return &tikvrpc.Response{Resp: resp}, nil
+}
+
+func newCopRequest(regionID, version, startTS uint64, ranges ...string) *tikvrpc.Request {
+	copReq := &coprocessor.Request{Tp: 103, Data: []byte("dag"), StartTs: startTS}
+	for i := 0; i+1 < len(ranges); i += 2 {
+		copReq.Ranges = append(copReq.Ranges, &coprocessor.KeyRange{Start: []byte(ranges[i]), End: []byte(ranges[i+1])})
+	}
+	return tikvrpc.NewRequest(tikvrpc.CmdCop, copReq, kvrpcpb.Context{
+		RegionId:    regionID,
+		RegionEpoch: &metapb.RegionEpoch{Version: version},
+	})
+}
+
+func newTestCoprCache(t *testing.T) *CoprCache {
+	cache, err := NewCoprCache(config.CoprocessorCache{
+		CapacityMB:            1,
+		AdmissionMaxRanges:    2,
+		AdmissionMaxResultMB:  0.5,
+		AdmissionMinProcessMs: 5,
+	})
+	require.Nil(t, err)
+	return cache
+}
+
+func TestCoprCache(t *testing.T) {
+	cache := newTestCoprCache(t)
+	mock := &mockCoprClient{dataVersion: 10, data: []byte("result"), processTime: 10 * time.Millisecond}
+	cli := cache.Wrap(mock)
+	send := func(req *tikvrpc.Request) *coprocessor.Response {
+		resp, err := cli.SendRequest(context.Background(), "", req, time.Second)
+		require.Nil(t, err)
+		return resp.Resp.(*coprocessor.Response)
+	}
+
+	resp := send(newCopRequest(1, 1, 100, "a", "b"))
+	require.False(t, resp.IsCacheHit)
+	require.Equal(t, "result", string(resp.Data))
+	require.Equal(t, uint64(0), mock.reqs[0].CacheIfMatchVersion)
+	require.True(t, mock.reqs[0].IsCacheEnabled)
+	require.Equal(t, 1, cache.Len())
+	require.Greater(t, cache.MemUsage(), int64(0))
+
+	// A newer request is answered by the cache.
+	resp = send(newCopRequest(1, 1, 200, "a", "b"))
+	require.True(t, resp.IsCacheHit)
+	require.Equal(t, "result", string(resp.Data))
+	require.Equal(t, uint64(10), mock.reqs[1].CacheIfMatchVersion)
+
+	// An older request can not use the cached result.
+	send(newCopRequest(1, 1, 50, "a", "b"))
+	require.Equal(t, uint64(0), mock.reqs[2].CacheIfMatchVersion)
+
+	// The data is changed.
+	mock.dataVersion, mock.data = 11, []byte("result2")
+	resp = send(newCopRequest(1, 1, 300, "a", "b"))
+	require.False(t, resp.IsCacheHit)
+	require.Equal(t, "result2", string(resp.Data))
+	resp = send(newCopRequest(1, 1, 300, "a", "b"))
+	require.True(t, resp.IsCacheHit)
+	require.Equal(t, "result2", string(resp.Data))
+
+	// Different region version, ranges or region are different keys.
+	send(newCopRequest(1, 2, 300, "a", "b"))
+	require.Equal(t, uint64(0), mock.reqs[len(mock.reqs)-1].CacheIfMatchVersion)
+	send(newCopRequest(1, 1, 300, "a", "c"))
+	require.Equal(t, uint64(0), mock.reqs[len(mock.reqs)-1].CacheIfMatchVersion)
+	send(newCopRequest(2, 1, 300, "a", "b"))
+	require.Equal(t, uint64(0), mock.reqs[len(mock.reqs)-1].CacheIfMatchVersion)
+	require.Equal(t, 4, cache.Len())
+}
+
+func TestCoprCacheAdmission(t *testing.T) {
+	cache := newTestCoprCache(t)
+	mock := &mockCoprClient{dataVersion: 10, data: []byte("result"), processTime: time.Millisecond}
+	cli := cache.Wrap(mock)
+
+	// The request is processed too fast.
+	_, err := cli.SendRequest(context.Background(), "", newCopRequest(1, 1, 100, "a", "b"), time.Second)
+	require.Nil(t, err)
+	require.Equal(t, 0, cache.Len())
+
+	// Too many ranges.
+	mock.processTime = 10 * time.Millisecond
+	_, err = cli.SendRequest(context.Background(), "", newCopRequest(1, 1, 100, "a", "b", "c", "d", "e", "f"), time.Second)
+	require.Nil(t, err)
+	require.False(t, mock.reqs[1].IsCacheEnabled)
+	require.Equal(t, 0, cache.Len())
+
+	// Paging requests are not cached.
+	req := newCopRequest(1, 1, 100, "a", "b")
+	req.Cop().PagingSize = 64
+	_, err = cli.SendRequest(context.Background(), "", req, time.Second)
+	require.Nil(t, err)
+	require.False(t, mock.reqs[2].IsCacheEnabled)
+
+	// The result is too large.
+	mock.data = make([]byte, 600*1024)
+	_, err = cli.SendRequest(context.Background(), "", newCopRequest(1, 1, 100, "a", "b"), time.Second)
+	require.Nil(t, err)
+	require.Equal(t, 0, cache.Len())
+
+	// The least recently used results are evicted when the cache is full.
+	mock.data = make([]byte, 400*1024)
+	for i := 0; i < 3; i++ {
+		_, err = cli.SendRequest(context.Background(), "", newCopRequest(uint64(i), 1, 100, "a", "b"), time.Second)
+		require.Nil(t, err)
+	}
+	require.Equal(t, 2, cache.Len())
+	require.LessOrEqual(t, cache.MemUsage(), int64(1024*1024))
+	evicted := newCopRequest(0, 1, 100, "a", "b")
+	require.Nil(t, cache.get(coprCacheKey(evicted, evicted.Cop())))
+
+	// Other commands are not affected.
+	n := len(mock.reqs)
+	_, err = cli.SendRequest(context.Background(), "", tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{}), time.Second)
+	require.Nil(t, err)
+	require.Len(t, mock.reqs, n)
+}
+
+func TestCoprCacheInterceptor(t *testing.T) {
+	cache := newTestCoprCache(t)
+	mock := &mockCoprClient{dataVersion: 10, data: []byte("result"), processTime: 10 * time.Millisecond}
+	cli := NewInterceptedClient(mock)
+	ctx := interceptor.WithRPCInterceptor(context.Background(), cache.Interceptor())
+
+	for i := 0; i < 2; i++ {
+		resp, err := cli.SendRequest(ctx, "", newCopRequest(1, 1, 100, "a", "b"), time.Second)
+		require.Nil(t, err)
+		copResp := resp.Resp.(*coprocessor.Response)
+		require.Equal(t, i == 1, copResp.IsCacheHit)
+		require.Equal(t, "result", string(copResp.Data))
+	}
+}
+
+func TestCoprCacheDisabled(t *testing.T) {
+	cache, err := NewCoprCache(config.CoprocessorCache{})
+	require.Nil(t, err)
+	require.Nil(t, cache)
+	mock := &mockCoprClient{}
+	require.Equal(t, Client(mock), cache.Wrap(mock))
+
+	_, err = NewCoprCache(config.CoprocessorCache{CapacityMB: 1, AdmissionMaxResultMB: 2})
+	require.NotNil(t, err)
+}
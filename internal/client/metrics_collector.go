@@ -180,3 +180,9 @@ func (s *networkCollector) onResp(req *tikvrpc.Request, resp *tikvrpc.Response,
 		s.staleReadMetricsCollector.onResp(float64(size), isCrossZoneTraffic)
 	}
 }
+
+// onCompression counts the bytes of a request before and after compression.
+func (s *networkCollector) onCompression(details *util.ExecDetails, size, compressedSize int) {
+	atomic.AddInt64(&details.UncompressedBytesSent, int64(size))
+	atomic.AddInt64(&details.CompressedBytesSent, int64(compressedSize))
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/tikv/client-go/v2/internal/logutil"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 // MockServer is a mock tikv server for testing purpose.
@@ -50,6 +51,9 @@ type MockServer struct {
 	}
 
 	OnBatchCommandsRequest atomic.Pointer[func(*tikvpb.BatchCommandsRequest) (*tikvpb.BatchCommandsResponse, error)]
+
+	// acceptEncoding is sent by the grpc-accept-encoding header of the responses if it's set.
+	acceptEncoding atomic.Pointer[string]
 }
 
 // KvGet implements the TikvServer interface.
@@ -140,6 +144,36 @@ func (s *MockServer) checkMetadata(ctx context.Context) error {
 	return nil
 }
 
+// SetAcceptEncoding sets the compressors advertised by the grpc-accept-encoding header of the responses.
+func (s *MockServer) SetAcceptEncoding(acceptEncoding string) {
+	s.acceptEncoding.Store(&acceptEncoding)
+}
+
+func (s *MockServer) acceptEncodingHeader() metadata.MD {
+	if acceptEncoding := s.acceptEncoding.Load(); acceptEncoding != nil {
+		return metadata.Pairs("grpc-accept-encoding", *acceptEncoding)
+	}
+	return nil
+}
+
+func (s *MockServer) unaryInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if md := s.acceptEncodingHeader(); md != nil {
+		if err := grpc.SetHeader(ctx, md); err != nil {
+			return nil, err
+		}
+	}
+	return handler(ctx, req)
+}
+
+func (s *MockServer) streamInterceptor(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if md := s.acceptEncodingHeader(); md != nil {
+		if err := ss.SetHeader(md); err != nil {
+			return err
+		}
+	}
+	return handler(srv, ss)
+}
+
 // IsRunning returns true is the mock server is running.
 func (s *MockServer) IsRunning() bool {
 	return atomic.LoadInt64(&s.running) == 1
@@ -170,7 +204,11 @@ func (s *MockServer) Start(addr string) int {
 	}
 	port = lis.Addr().(*net.TCPAddr).Port
 
-	grpcServer := grpc.NewServer(grpc.ConnectionTimeout(time.Minute))
+	grpcServer := grpc.NewServer(
+		grpc.ConnectionTimeout(time.Minute),
+		grpc.UnaryInterceptor(s.unaryInterceptor),
+		grpc.StreamInterceptor(s.streamInterceptor),
+	)
 	tikvpb.RegisterTikvServer(grpcServer, s)
 	s.grpcServer = grpcServer
 	go func() {
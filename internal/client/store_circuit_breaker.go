@@ -0,0 +1,279 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/metrics"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StoreCircuitBreakerSettings describes the configuration of the circuit
+// breakers of the stores.
+type StoreCircuitBreakerSettings struct {
+	// ErrorRateThresholdPct trips the circuit breaker if the percentage of the
+	// failed requests in the window reaches it, 0 means never.
+	ErrorRateThresholdPct uint32
+	// TimeoutRateThresholdPct trips the circuit breaker if the percentage of
+	// the timed out requests in the window reaches it, 0 means never.
+	TimeoutRateThresholdPct uint32
+	// MinQPSForOpen is the average qps over the ErrorRateWindow that must be met
+	// before evaluating the rates.
+	MinQPSForOpen uint32
+	// ErrorRateWindow is the sliding window to track the errors and timeouts.
+	ErrorRateWindow time.Duration
+	// CoolDownInterval is how long to wait after the circuit breaker is open
+	// before going to half-open state to send probe requests.
+	CoolDownInterval time.Duration
+	// HalfOpenSuccessCount is how many successful probe requests are needed to
+	// close the circuit breaker.
+	HalfOpenSuccessCount uint32
+}
+
+func (s *StoreCircuitBreakerSettings) enabled() bool {
+	return (s.ErrorRateThresholdPct > 0 || s.TimeoutRateThresholdPct > 0) && s.ErrorRateWindow > 0
+}
+
+var storeCircuitBreakerSettings atomic.Pointer[StoreCircuitBreakerSettings]
+
+// storeCircuitBreakers are the circuit breakers by the address of the stores.
+var storeCircuitBreakers sync.Map
+
+func init() {
+	storeCircuitBreakerSettings.Store(&StoreCircuitBreakerSettings{
+		ErrorRateWindow:      10 * time.Second,
+		MinQPSForOpen:        10,
+		CoolDownInterval:     10 * time.Second,
+		HalfOpenSuccessCount: 1,
+	})
+}
+
+// ChangeStoreCircuitBreakerSettings changes the settings of the circuit breakers
+// of the stores, which are disabled by default.
+func ChangeStoreCircuitBreakerSettings(apply func(settings *StoreCircuitBreakerSettings)) {
+	settings := *storeCircuitBreakerSettings.Load()
+	apply(&settings)
+	storeCircuitBreakerSettings.Store(&settings)
+	logutil.BgLogger().Info("store circuit breaker settings changed", zap.Any("settings", settings))
+}
+
+// IsStoreCircuitBreakerOpen reports whether the circuit breaker of the store
+// is open, the requests sent to the store are rejected until it cools down.
+func IsStoreCircuitBreakerOpen(addr string) bool {
+	settings := storeCircuitBreakerSettings.Load()
+	if !settings.enabled() {
+		return false
+	}
+	v, ok := storeCircuitBreakers.Load(addr)
+	if !ok {
+		return false
+	}
+	return v.(*storeCircuitBreaker).isOpen(settings, time.Now())
+}
+
+func getStoreCircuitBreaker(addr string) (*storeCircuitBreaker, *StoreCircuitBreakerSettings) {
+	settings := storeCircuitBreakerSettings.Load()
+	if !settings.enabled() {
+		return nil, nil
+	}
+	if v, ok := storeCircuitBreakers.Load(addr); ok {
+		return v.(*storeCircuitBreaker), settings
+	}
+	v, _ := storeCircuitBreakers.LoadOrStore(addr, &storeCircuitBreaker{addr: addr})
+	return v.(*storeCircuitBreaker), settings
+}
+
+type circuitBreakerState int
+
+const (
+	circuitBreakerClosed circuitBreakerState = iota
+	circuitBreakerOpen
+	circuitBreakerHalfOpen
+)
+
+func (s circuitBreakerState) String() string {
+	switch s {
+	case circuitBreakerOpen:
+		return "open"
+	case circuitBreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+const circuitBreakerBuckets = 10
+
+// circuitBreakerBucket counts the requests of a slot of the sliding window.
+type circuitBreakerBucket struct {
+	epoch    int64
+	total    uint32
+	errors   uint32
+	timeouts uint32
+}
+
+// storeCircuitBreaker stops sending requests to a store when the rate of the
+// failed or timed out requests to it is too high. It's closed at first and
+// opens when the rates over the sliding window reach the thresholds. After
+// cooling down, it lets a few probe requests through in half-open state, and
+// it's closed if they succeed or opened again if any of them fails.
+type storeCircuitBreaker struct {
+	addr string
+
+	mu struct {
+		sync.Mutex
+		state     circuitBreakerState
+		openedAt  time.Time
+		probes    uint32
+		successes uint32
+		buckets   [circuitBreakerBuckets]circuitBreakerBucket
+	}
+}
+
+func (cb *storeCircuitBreaker) isOpen(settings *StoreCircuitBreakerSettings, now time.Time) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.mu.state == circuitBreakerOpen && now.Sub(cb.mu.openedAt) < settings.CoolDownInterval
+}
+
+// allow reports whether the request can be sent to the store.
+func (cb *storeCircuitBreaker) allow(settings *StoreCircuitBreakerSettings, now time.Time) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.mu.state {
+	case circuitBreakerOpen:
+		if now.Sub(cb.mu.openedAt) < settings.CoolDownInterval {
+			return false
+		}
+		cb.setState(circuitBreakerHalfOpen, now)
+		fallthrough
+	case circuitBreakerHalfOpen:
+		if cb.mu.probes >= max(settings.HalfOpenSuccessCount, 1) {
+			return false
+		}
+		cb.mu.probes++
+	}
+	return true
+}
+
+// onResult records the result of a request allowed by the circuit breaker.
+func (cb *storeCircuitBreaker) onResult(settings *StoreCircuitBreakerSettings, now time.Time, err error) {
+	failed := err != nil
+	timeout := failed && isTimeoutError(err)
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.mu.state {
+	case circuitBreakerHalfOpen:
+		if failed {
+			cb.setState(circuitBreakerOpen, now)
+			return
+		}
+		cb.mu.successes++
+		if cb.mu.successes >= max(settings.HalfOpenSuccessCount, 1) {
+			cb.setState(circuitBreakerClosed, now)
+		}
+	case circuitBreakerClosed:
+		width := settings.ErrorRateWindow / circuitBreakerBuckets
+		if width <= 0 {
+			width = 1
+		}
+		epoch := now.UnixNano() / int64(width)
+		b := &cb.mu.buckets[epoch%circuitBreakerBuckets]
+		if b.epoch != epoch {
+			*b = circuitBreakerBucket{epoch: epoch}
+		}
+		b.total++
+		if failed {
+			b.errors++
+		}
+		if timeout {
+			b.timeouts++
+		}
+		if failed && cb.shouldOpen(settings, epoch) {
+			cb.setState(circuitBreakerOpen, now)
+		}
+	}
+}
+
+// shouldOpen checks the rates over the buckets of the window ending at epoch.
+func (cb *storeCircuitBreaker) shouldOpen(settings *StoreCircuitBreakerSettings, epoch int64) bool {
+	var total, errs, timeouts uint32
+	for i := range cb.mu.buckets {
+		b := &cb.mu.buckets[i]
+		if b.epoch > epoch-circuitBreakerBuckets {
+			total += b.total
+			errs += b.errors
+			timeouts += b.timeouts
+		}
+	}
+	if float64(total) < float64(settings.MinQPSForOpen)*settings.ErrorRateWindow.Seconds() || total == 0 {
+		return false
+	}
+	return (settings.ErrorRateThresholdPct > 0 && errs*100 >= settings.ErrorRateThresholdPct*total) ||
+		(settings.TimeoutRateThresholdPct > 0 && timeouts*100 >= settings.TimeoutRateThresholdPct*total)
+}
+
+func (cb *storeCircuitBreaker) setState(state circuitBreakerState, now time.Time) {
+	if cb.mu.state == state {
+		return
+	}
+	logutil.BgLogger().Info("store circuit breaker state changed",
+		zap.String("store", cb.addr), zap.Stringer("from", cb.mu.state), zap.Stringer("to", state))
+	metrics.TiKVStoreCircuitBreakerCounter.WithLabelValues(cb.addr, state.String()).Inc()
+	cb.mu.state = state
+	cb.mu.probes = 0
+	cb.mu.successes = 0
+	switch state {
+	case circuitBreakerOpen:
+		cb.mu.openedAt = now
+	case circuitBreakerClosed:
+		cb.mu.buckets = [circuitBreakerBuckets]circuitBreakerBucket{}
+	}
+}
+
+func isTimeoutError(err error) bool {
+	cause := errors.Cause(err)
+	return cause == context.DeadlineExceeded || status.Code(cause) == codes.DeadlineExceeded
+}
+
+// reject returns the error of the request rejected by the circuit breaker.
+func (cb *storeCircuitBreaker) reject() error {
+	metrics.TiKVStoreCircuitBreakerCounter.WithLabelValues(cb.addr, "fast_fail").Inc()
+	return errors.WithMessagef(tikverr.ErrStoreCircuitBreakerOpen, "store %s", cb.addr)
+}
+
+// done records the result of the request allowed by the circuit breaker. The
+// failures caused by the context of the caller are not counted.
+func (cb *storeCircuitBreaker) done(ctx context.Context, settings *StoreCircuitBreakerSettings, err error) {
+	if err != nil && ctx.Err() != nil {
+		// Release the probe of the half-open circuit breaker without a result.
+		cb.mu.Lock()
+		if cb.mu.state == circuitBreakerHalfOpen && cb.mu.probes > 0 {
+			cb.mu.probes--
+		}
+		cb.mu.Unlock()
+		return
+	}
+	cb.onResult(settings, time.Now(), err)
+}
@@ -0,0 +1,227 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"context"
+	"time"
+
+	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/metrics"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"go.uber.org/zap"
+)
+
+// HedgePolicy controls the hedged reads of RegionRequestSender. If a Get,
+// BatchGet or Scan request is not answered after the hedge delay, the same
+// request is sent to another eligible replica and the first good answer is
+// taken, the other one is cancelled.
+//
+// Only the first attempt of a request is hedged, so a request is sent at most
+// one more time than without hedging.
+type HedgePolicy struct {
+	// Delay is the time to wait before sending the hedged request. When the
+	// delay is decided by the store latency, it's the lower bound of the delay.
+	Delay time.Duration
+	// LatencyPercentile decides the delay by the latency of the target store if
+	// it's in (0, 1]. The delay is the percentile of the latency of the recent
+	// hedgeable requests to the store kept in its SlowScoreStat, e.g. 0.99
+	// hedges the requests slower than the p99 latency of the store. Delay is
+	// used until enough requests are measured.
+	LatencyPercentile float64
+}
+
+// delay returns the time to wait before hedging the request sent to the store.
+func (p *HedgePolicy) delay(store *Store) time.Duration {
+	delay := p.Delay
+	if p.LatencyPercentile > 0 && p.LatencyPercentile <= 1 && store != nil {
+		if latency := store.healthStatus.clientSideSlowScore.getLatencyPercentile(p.LatencyPercentile); latency > 0 {
+			delay = max(delay, latency)
+		}
+	}
+	return delay
+}
+
+// SetHedgePolicy enables hedged reads of the sender, nil disables it.
+func (s *RegionRequestSender) SetHedgePolicy(policy *HedgePolicy) {
+	s.hedgePolicy = policy
+}
+
+// canHedge checks whether the request being sent to rpcCtx can be hedged.
+func (s *sendReqState) canHedge(req *tikvrpc.Request, rpcCtx *RPCContext) bool {
+	if s.hedgePolicy == nil || s.replicaSelector == nil || s.vars.sendTimes > 0 {
+		return false
+	}
+	if rpcCtx.ProxyStore != nil || s.replicaSelector.target == nil {
+		return false
+	}
+	return isHedgeableCmd(req.Type)
+}
+
+func isHedgeableCmd(tp tikvrpc.CmdType) bool {
+	switch tp {
+	case tikvrpc.CmdGet, tikvrpc.CmdBatchGet, tikvrpc.CmdScan:
+		return true
+	default:
+		return false
+	}
+}
+
+// recordHedgeLatency records the latency of the hedgeable request answered by
+// the store, which decides the hedge delay of the later requests.
+func (s *sendReqState) recordHedgeLatency(req *tikvrpc.Request, rpcCtx *RPCContext, resp *tikvrpc.Response, err error, timecost time.Duration) {
+	if s.hedgePolicy == nil || rpcCtx == nil || rpcCtx.Store == nil || err != nil || resp == nil || !isHedgeableCmd(req.Type) {
+		return
+	}
+	rpcCtx.Store.healthStatus.recordLatency(timecost)
+}
+
+// hedgeReplica returns the replica to send the hedged request to, or nil if
+// there is no eligible one. The replicas matching the store labels and tried
+// fewer times are preferred.
+func (s *replicaSelector) hedgeReplica() *replica {
+	var (
+		candidate      *replica
+		candidateMatch bool
+	)
+	for _, r := range s.replicas {
+		if r == s.target || r.isEpochStale() || r.isExhausted(maxReplicaAttempt, maxReplicaAttemptTime) ||
+			r.hasFlag(deadlineErrUsingConfTimeoutFlag|dataIsNotReadyFlag|serverIsBusyFlag) ||
+			r.store.getLivenessState() != reachable || r.store.healthStatus.IsSlow() {
+			continue
+		}
+		match := len(s.option.labels) == 0 || r.store.IsLabelsMatch(s.option.labels)
+		if candidate == nil || (match && !candidateMatch) || (match == candidateMatch && r.attempts < candidate.attempts) {
+			candidate, candidateMatch = r, match
+		}
+	}
+	return candidate
+}
+
+// copyHedgeRequest copies the request for hedging. The inner request is copied
+// too since its context is attached when sending. Both the primary and the
+// hedged requests are copies, because the loser may be still being sent when
+// sendWithHedge returns and the caller may reuse and modify its request then.
+func copyHedgeRequest(req *tikvrpc.Request) *tikvrpc.Request {
+	hedgeReq := *req
+	switch req.Type {
+	case tikvrpc.CmdGet:
+		inner := *req.Get()
+		hedgeReq.Req = &inner
+	case tikvrpc.CmdBatchGet:
+		inner := *req.BatchGet()
+		hedgeReq.Req = &inner
+	case tikvrpc.CmdScan:
+		inner := *req.Scan()
+		hedgeReq.Req = &inner
+	}
+	return &hedgeReq
+}
+
+type hedgeResult struct {
+	rpcCtx *RPCContext
+	resp   *tikvrpc.Response
+	err    error
+}
+
+func (r *hedgeResult) isGood() bool {
+	if r.err != nil || r.resp == nil {
+		return false
+	}
+	regionErr, err := r.resp.GetRegionError()
+	return err == nil && regionErr == nil
+}
+
+// sendWithHedge sends the request to the selected replica, and sends it to
+// another replica too if it's not answered after the hedge delay. It returns
+// the first good answer, or the answer of the selected replica if neither is
+// good. The RPC context of the replica answering the request is returned.
+func (s *sendReqState) sendWithHedge(
+	bo *retry.Backoffer, ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration,
+) (*RPCContext, *tikvrpc.Response, error) {
+	rpcCtx := s.vars.rpcCtx
+	primaryReq, hedgeReq := copyHedgeRequest(req), copyHedgeRequest(req)
+
+	// The loser is cancelled through the canceller when returning.
+	canceller := NewRPCanceller()
+	defer canceller.CancelAll()
+	results := make(chan hedgeResult, 2)
+	sendOne := func(rpcCtx *RPCContext, addr string, req *tikvrpc.Request) {
+		sendCtx, cancel := canceller.WithCancel(ctx)
+		go func() {
+			defer cancel()
+			start := time.Now()
+			resp, err := s.client.SendRequest(sendCtx, addr, req, timeout)
+			s.recordHedgeLatency(req, rpcCtx, resp, err, time.Since(start))
+			results <- hedgeResult{rpcCtx: rpcCtx, resp: resp, err: err}
+		}()
+	}
+	sendOne(rpcCtx, addr, primaryReq)
+
+	timer := time.NewTimer(s.hedgePolicy.delay(rpcCtx.Store))
+	defer timer.Stop()
+	select {
+	case r := <-results:
+		return r.rpcCtx, r.resp, r.err
+	case <-timer.C:
+	}
+
+	var hedgeCtx *RPCContext
+	if target := s.replicaSelector.hedgeReplica(); target != nil {
+		var err error
+		hedgeCtx, err = s.replicaSelector.buildRPCContext(bo, target, nil)
+		if err != nil {
+			logutil.Logger(ctx).Debug("failed to build the rpc context of hedged request", zap.Error(err))
+			hedgeCtx = nil
+		}
+	}
+	if hedgeCtx == nil {
+		r := <-results
+		return r.rpcCtx, r.resp, r.err
+	}
+	hedgeReq.ForwardedHost = ""
+	if err := tikvrpc.SetContextNoAttach(hedgeReq, hedgeCtx.Meta, hedgeCtx.Peer); err != nil {
+		r := <-results
+		return r.rpcCtx, r.resp, r.err
+	}
+	if !hedgeReq.StaleRead && hedgeCtx.Peer.GetId() != s.replicaSelector.region.GetLeaderPeerID() {
+		hedgeReq.ReplicaRead = true
+	}
+	sendOne(hedgeCtx, hedgeCtx.Addr, hedgeReq)
+	metrics.TiKVHedgedRequestCounter.WithLabelValues(req.Type.String(), "sent").Inc()
+	if s.Stats != nil {
+		s.Stats.HedgeCount++
+	}
+
+	var primary *hedgeResult
+	for i := 0; i < 2; i++ {
+		r := <-results
+		if r.isGood() {
+			if r.rpcCtx == hedgeCtx {
+				metrics.TiKVHedgedRequestCounter.WithLabelValues(req.Type.String(), "won").Inc()
+				if s.Stats != nil {
+					s.Stats.HedgeWinCount++
+				}
+			}
+			return r.rpcCtx, r.resp, r.err
+		}
+		if r.rpcCtx == rpcCtx {
+			primary = &r
+		}
+	}
+	// Neither is good, let the caller handle the error of the selected replica.
+	return primary.rpcCtx, primary.resp, primary.err
+}
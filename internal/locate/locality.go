@@ -0,0 +1,207 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/metrics"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+const (
+	// DefaultZoneLabelKey is the store label key of the zone used by LocalityPolicy.
+	DefaultZoneLabelKey = "zone"
+
+	localityDecisionLocal    = "local_replica"
+	localityDecisionBudget   = "cross_zone_budget"
+	localityDecisionFallback = "leader_fallback"
+)
+
+// LocalityPolicy routes the follower reads and stale reads to the replicas in
+// the same zone as the client.
+//
+// For each attempt of such a read, a fresh replica in the local zone is
+// preferred. If there is none, the request falls back to the leader even if the
+// budget remains. Only if the leader is unavailable either, the request is sent
+// to the other replicas across zones as usual. The cross-zone traffic of the
+// reads, including the leader fallbacks, is charged to the budget of the
+// current second.
+type LocalityPolicy struct {
+	// Zone is the zone of the client.
+	Zone string
+	// ZoneLabelKey is the store label key of the zone, DefaultZoneLabelKey is
+	// used if it's empty.
+	ZoneLabelKey string
+	// CrossZoneBytesPerSecond is the budget of the bytes sent and received
+	// across zones by the reads per second, no limit if it's not positive.
+	CrossZoneBytesPerSecond int64
+
+	budget struct {
+		sync.Mutex
+		windowStart time.Time
+		used        int64
+	}
+}
+
+func (p *LocalityPolicy) zoneLabelKey() string {
+	if p.ZoneLabelKey == "" {
+		return DefaultZoneLabelKey
+	}
+	return p.ZoneLabelKey
+}
+
+// isLocal reports whether the store is in the zone of the client.
+func (p *LocalityPolicy) isLocal(store *Store) bool {
+	zone, ok := store.GetLabelValue(p.zoneLabelKey())
+	return ok && zone == p.Zone
+}
+
+// budgetExhausted reports whether the cross-zone budget of the current second
+// is used up.
+func (p *LocalityPolicy) budgetExhausted() bool {
+	if p.CrossZoneBytesPerSecond <= 0 {
+		return false
+	}
+	p.budget.Lock()
+	defer p.budget.Unlock()
+	p.rotateBudgetWindow(time.Now())
+	return p.budget.used >= p.CrossZoneBytesPerSecond
+}
+
+// consumeBudget charges the cross-zone bytes to the budget.
+func (p *LocalityPolicy) consumeBudget(bytes int) {
+	if p.CrossZoneBytesPerSecond <= 0 || bytes <= 0 {
+		return
+	}
+	p.budget.Lock()
+	p.rotateBudgetWindow(time.Now())
+	p.budget.used += int64(bytes)
+	p.budget.Unlock()
+}
+
+func (p *LocalityPolicy) rotateBudgetWindow(now time.Time) {
+	if now.Sub(p.budget.windowStart) >= time.Second {
+		p.budget.windowStart = now
+		p.budget.used = 0
+	}
+}
+
+// SetLocalityPolicy sets the locality policy of the reads sent through the
+// cache, nil disables it.
+func (c *RegionCache) SetLocalityPolicy(policy *LocalityPolicy) {
+	c.localityPolicy.Store(policy)
+}
+
+// GetLocalityPolicy returns the locality policy of the cache.
+func (c *RegionCache) GetLocalityPolicy() *LocalityPolicy {
+	return c.localityPolicy.Load()
+}
+
+// localityApplicable reports whether the request is routed by the locality
+// policy.
+func (s *replicaSelector) localityApplicable() bool {
+	return s.isReadOnlyReq &&
+		(s.isStaleRead || s.replicaReadType == kv.ReplicaReadFollower || s.replicaReadType == kv.ReplicaReadMixed)
+}
+
+// nextByLocality selects the target by the locality policy. If the target is
+// left nil, the built-in selection is used and the returned decision, if any,
+// is recorded for its target.
+func (s *replicaSelector) nextByLocality(req *tikvrpc.Request, policy *LocalityPolicy) (decision string) {
+	leaderIdx := s.region.getStore().workTiKVIdx
+	var (
+		target   *replica
+		isLeader bool
+	)
+	for i, r := range s.replicas {
+		// Follower reads are not sent to the leader unless the followers fail.
+		if AccessIndex(i) == leaderIdx && !s.isStaleRead && s.replicaReadType == kv.ReplicaReadFollower {
+			continue
+		}
+		if !policy.isLocal(r.store) || !s.isFreshReplica(r) {
+			continue
+		}
+		if target == nil || r.attempts < target.attempts {
+			target, isLeader = r, AccessIndex(i) == leaderIdx
+		}
+	}
+	if target != nil {
+		s.target = target
+		s.setReadFlags(req, isLeader)
+		metrics.TiKVLocalityRoutingCounter.WithLabelValues(localityDecisionLocal, localityScope(true)).Inc()
+		return ""
+	}
+	// Without a fresh local replica, the read falls back to the leader even if
+	// the budget remains, and its cross-zone traffic is charged to the budget
+	// by consumeCrossZoneBudget like the others.
+	leader := s.replicas[leaderIdx]
+	if leader.isEpochStale() || leader.isExhausted(maxReplicaAttempt, maxReplicaAttemptTime) ||
+		leader.store.getLivenessState() == unreachable {
+		if !policy.budgetExhausted() {
+			// Let the built-in selection choose a replica across zones.
+			return localityDecisionBudget
+		}
+		return ""
+	}
+	s.target = leader
+	req.StaleRead = false
+	req.ReplicaRead = false
+	metrics.TiKVLocalityRoutingCounter.WithLabelValues(localityDecisionFallback, localityScope(policy.isLocal(leader.store))).Inc()
+	return ""
+}
+
+// isFreshReplica reports whether the replica is expected to serve the read
+// without being rejected.
+func (s *replicaSelector) isFreshReplica(r *replica) bool {
+	return !r.isEpochStale() && !r.isExhausted(1, 0) &&
+		!r.hasFlag(deadlineErrUsingConfTimeoutFlag|dataIsNotReadyFlag|serverIsBusyFlag) &&
+		r.store.getLivenessState() != unreachable && !r.store.healthStatus.IsSlow() && !r.isCircuitBroken()
+}
+
+// consumeCrossZoneBudget charges the bytes of the read sent across zones to the
+// budget of the locality policy.
+func (s *sendReqState) consumeCrossZoneBudget(req *tikvrpc.Request, rpcCtx *RPCContext) {
+	policy := s.regionCache.GetLocalityPolicy()
+	if policy == nil || rpcCtx == nil || rpcCtx.Store == nil || !s.replicaSelector.localityApplicable() {
+		return
+	}
+	if policy.isLocal(rpcCtx.Store) {
+		return
+	}
+	bytes := requestWireSize(req.Req)
+	if s.vars.resp != nil {
+		bytes += requestWireSize(s.vars.resp.Resp)
+	}
+	policy.consumeBudget(bytes)
+}
+
+func localityScope(local bool) string {
+	if local {
+		return "local"
+	}
+	return "cross_zone"
+}
+
+// requestWireSize returns the size of the request or response message, 0 if
+// it's unknown.
+func requestWireSize(msg interface{}) int {
+	if m, ok := msg.(interface{ Size() int }); ok {
+		return m.Size()
+	}
+	return 0
+}
@@ -443,13 +443,15 @@ func (r *Region) checkRegionCacheTTL(ts int64) bool {
 }
 
 // invalidate invalidates a region, next time it will got null result.
-func (r *Region) invalidate(reason InvalidReason, nocount ...bool) {
+func (r *Region) invalidate(reason InvalidReason, nocount ...bool) bool {
 	if atomic.CompareAndSwapInt32((*int32)(&r.invalidReason), int32(Ok), int32(reason)) {
 		if len(nocount) == 0 || !nocount[0] {
 			metrics.RegionCacheCounterWithInvalidateRegionFromCacheOK.Inc()
 		}
 		atomic.StoreInt64(&r.ttl, expiredTTL)
+		return true
 	}
+	return false
 }
 
 func (r *Region) getSyncFlags() int32 {
@@ -496,6 +498,7 @@ type regionIndexMu struct {
 	regions        map[RegionVerID]*Region // cached regions are organized as regionVerID to region ref mapping
 	latestVersions map[uint64]RegionVerID  // cache the map from regionID to its latest RegionVerID
 	sorted         *SortedRegions          // cache regions are organized as sorted key to region ref mapping
+	events         *regionEventHub
 }
 
 func newRegionIndexMu(rs []*Region) *regionIndexMu {
@@ -511,6 +514,7 @@ func newRegionIndexMu(rs []*Region) *regionIndexMu {
 
 func (mu *regionIndexMu) refresh(r []*Region) {
 	newMu := newRegionIndexMu(r)
+	newMu.events = mu.events
 	mu.Lock()
 	defer mu.Unlock()
 	mu.regions = newMu.regions
@@ -656,6 +660,10 @@ type RegionCache struct {
 
 	stores storeCache
 
+	events *regionEventHub
+
+	localityPolicy atomic.Pointer[LocalityPolicy]
+
 	// runner for background jobs
 	bg *bgRunner
 
@@ -696,7 +704,8 @@ func NewRegionCache(pdClient pd.Client, opt ...RegionCacheOpt) *RegionCache {
 		c.codec = codecPDClient.GetCodec()
 	}
 
-	c.stores = newStoreCache(pdClient)
+	c.events = &regionEventHub{}
+	c.stores = newStoreCache(pdClient, c.events)
 	c.bg = newBackgroundRunner(context.Background())
 	c.enableForwarding = config.GetGlobalConfig().EnableForwarding
 	if c.pdClient != nil {
@@ -715,6 +724,7 @@ func NewRegionCache(pdClient pd.Client, opt ...RegionCacheOpt) *RegionCache {
 	} else {
 		c.mu = *newRegionIndexMu(nil)
 	}
+	c.mu.events = c.events
 
 	var (
 		refreshStoreInterval = config.GetGlobalConfig().StoresRefreshInterval
@@ -792,7 +802,9 @@ func refreshFullStoreList(ctx context.Context, stores storeCache) {
 func newTestRegionCache() *RegionCache {
 	c := &RegionCache{}
 	c.bg = newBackgroundRunner(context.Background())
+	c.events = &regionEventHub{}
 	c.mu = *newRegionIndexMu(nil)
+	c.mu.events = c.events
 	return c
 }
 
@@ -840,7 +852,7 @@ func (c *RegionCache) SetRegionCacheStore(id uint64, addr string, peerAddr strin
 // SetPDClient replaces pd client,for testing only
 func (c *RegionCache) SetPDClient(client pd.Client) {
 	c.pdClient = client
-	c.stores = newStoreCache(client)
+	c.stores = newStoreCache(client, c.events)
 }
 
 // RPCContext contains data that is needed to send RPC to a region.
@@ -877,6 +889,7 @@ type storeSelectorOp struct {
 	preferLeader bool
 	labels       []*metapb.StoreLabel
 	stores       []uint64
+	strategy     ReplicaSelectionStrategy
 }
 
 // StoreSelectorOption configures storeSelectorOp.
@@ -954,13 +967,13 @@ func (c *RegionCache) GetTiKVRPCContext(bo *retry.Backoffer, id RegionVerID, rep
 	}
 	if store == nil || len(addr) == 0 {
 		// Store not found, region must be out of date.
-		cachedRegion.invalidate(StoreNotFound)
+		c.invalidateRegion(cachedRegion, StoreNotFound)
 		return nil, nil
 	}
 
 	storeFailEpoch := atomic.LoadUint32(&store.epoch)
 	if storeFailEpoch != regionStore.storeEpochs[storeIdx] {
-		cachedRegion.invalidate(Other)
+		c.invalidateRegion(cachedRegion, Other)
 		logutil.Logger(bo.GetCtx()).Info("invalidate current region, because others failed on same store",
 			zap.Uint64("region", id.GetID()),
 			zap.String("store", store.addr))
@@ -1075,7 +1088,7 @@ func (c *RegionCache) GetTiFlashRPCContext(bo *retry.Backoffer, id RegionVerID,
 			return nil, err
 		}
 		if len(addr) == 0 {
-			cachedRegion.invalidate(StoreNotFound)
+			c.invalidateRegion(cachedRegion, StoreNotFound)
 			return nil, nil
 		}
 		if store.getResolveState() == needCheck {
@@ -1086,7 +1099,7 @@ func (c *RegionCache) GetTiFlashRPCContext(bo *retry.Backoffer, id RegionVerID,
 		peer := cachedRegion.meta.Peers[storeIdx]
 		storeFailEpoch := atomic.LoadUint32(&store.epoch)
 		if storeFailEpoch != regionStore.storeEpochs[storeIdx] {
-			cachedRegion.invalidate(Other)
+			c.invalidateRegion(cachedRegion, Other)
 			logutil.Logger(bo.GetCtx()).Info("invalidate current region, because others failed on same store",
 				zap.Uint64("region", id.GetID()),
 				zap.String("store", store.addr))
@@ -1106,7 +1119,7 @@ func (c *RegionCache) GetTiFlashRPCContext(bo *retry.Backoffer, id RegionVerID,
 		}, nil
 	}
 
-	cachedRegion.invalidate(Other)
+	c.invalidateRegion(cachedRegion, Other)
 	return nil, nil
 }
 
@@ -1864,7 +1877,7 @@ func (c *RegionCache) InvalidateCachedRegionWithReason(id RegionVerID, reason In
 	if cachedRegion == nil {
 		return
 	}
-	cachedRegion.invalidate(reason)
+	c.invalidateRegion(cachedRegion, reason)
 }
 
 // UpdateLeader update some region cache with newer leader info.
@@ -1885,12 +1898,12 @@ func (c *RegionCache) UpdateLeader(regionID RegionVerID, leader *metapb.Peer, cu
 		return
 	}
 
-	if !r.switchWorkLeaderToPeer(leader) {
+	if !c.switchWorkLeaderToPeer(r, leader) {
 		logutil.BgLogger().Info("invalidate region cache due to cannot find peer when updating leader",
 			zap.Uint64("regionID", regionID.GetID()),
 			zap.Int("currIdx", int(currentPeerIdx)),
 			zap.Uint64("leaderStoreID", leader.GetStoreId()))
-		r.invalidate(StoreNotFound)
+		c.invalidateRegion(r, StoreNotFound)
 	} else {
 		logutil.BgLogger().Info("switch region leader to specific leader due to kv return NotLeader",
 			zap.Uint64("regionID", regionID.GetID()),
@@ -1936,6 +1949,7 @@ func (mu *regionIndexMu) insertRegionToCache(cachedRegion *Region, invalidateOld
 	}
 	// Insert the region (won't replace because of above deletion).
 	mu.sorted.ReplaceOrInsert(cachedRegion)
+	mu.events.onRegionInserted(cachedRegion, intersectedRegions)
 	// Inherit the workTiKVIdx, workTiFlashIdx and buckets from the first intersected region.
 	if len(intersectedRegions) > 0 {
 		oldRegion := intersectedRegions[0].cachedRegion
@@ -2616,7 +2630,7 @@ func (c *RegionCache) OnRegionEpochNotMatch(bo *retry.Backoffer, ctx *RPCContext
 		}
 	}
 	if needInvalidateOld && cachedRegion != nil {
-		cachedRegion.invalidate(EpochNotMatch)
+		c.invalidateRegion(cachedRegion, EpochNotMatch)
 	}
 
 	c.mu.Lock()
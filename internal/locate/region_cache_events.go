@@ -0,0 +1,273 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+)
+
+// RegionEventType is the type of the RegionEvent.
+type RegionEventType int
+
+const (
+	// RegionSplit indicates a cached region is replaced by a region split from it.
+	RegionSplit RegionEventType = iota
+	// RegionMerged indicates cached regions are replaced by a region merged from them.
+	RegionMerged
+	// LeaderChanged indicates the leader of a cached region is switched to another store.
+	LeaderChanged
+	// RegionInvalidated indicates a cached region is invalidated.
+	RegionInvalidated
+	// StoreStateChanged indicates the resolve state or the liveness of a store is changed.
+	StoreStateChanged
+)
+
+func (t RegionEventType) String() string {
+	switch t {
+	case RegionSplit:
+		return "RegionSplit"
+	case RegionMerged:
+		return "RegionMerged"
+	case LeaderChanged:
+		return "LeaderChanged"
+	case RegionInvalidated:
+		return "RegionInvalidated"
+	case StoreStateChanged:
+		return "StoreStateChanged"
+	default:
+		return fmt.Sprintf("RegionEventType(%d)", int(t))
+	}
+}
+
+// RegionEvent is a change of the region cache observed by the client. The
+// fields which are not related to the type of the event are left empty.
+type RegionEvent struct {
+	Type RegionEventType
+	// Region is the region the event is about. For RegionSplit and
+	// RegionMerged, it's the new region inserted to the cache.
+	Region   RegionVerID
+	StartKey []byte
+	EndKey   []byte
+	// Sources are the cached regions replaced by the new region of a
+	// RegionSplit or RegionMerged event.
+	Sources []RegionVerID
+	// LeaderStoreID and OldLeaderStoreID are the stores of the new and the old
+	// leader of a LeaderChanged event.
+	LeaderStoreID    uint64
+	OldLeaderStoreID uint64
+	// Reason is why the region of a RegionInvalidated event is invalidated.
+	Reason InvalidReason
+	// StoreID, StoreAddr, StoreState and StoreLiveness describe the store of a
+	// StoreStateChanged event, the state is one of "resolved", "needCheck",
+	// "deleted" and "tombstone", and the liveness is one of "reachable",
+	// "unreachable" and "unknown".
+	StoreID       uint64
+	StoreAddr     string
+	StoreState    string
+	StoreLiveness string
+}
+
+const defaultRegionEventBufferSize = 256
+
+// RegionEventSubscription receives the events of the region cache. It must be
+// closed when it's not used anymore.
+//
+// The events are delivered without blocking the region cache: if the buffer of
+// the subscription is full, the new event is dropped and counted by Dropped.
+// A subscriber which finds events dropped should treat all its knowledge
+// derived from the events as stale.
+type RegionEventSubscription struct {
+	hub     *regionEventHub
+	ch      chan RegionEvent
+	dropped atomic.Uint64
+	closed  bool // protected by hub.mu
+}
+
+// Events returns the channel to receive the events. It's closed when the
+// subscription is closed.
+func (s *RegionEventSubscription) Events() <-chan RegionEvent {
+	return s.ch
+}
+
+// Dropped returns the number of the events dropped because the buffer is full.
+func (s *RegionEventSubscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close stops the subscription and closes the events channel. It's safe to
+// call it more than once.
+func (s *RegionEventSubscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(s.hub.mu.subs, s)
+	s.hub.subCount.Add(-1)
+	close(s.ch)
+}
+
+// regionEventHub dispatches the events to the subscriptions. A nil hub
+// discards all events.
+type regionEventHub struct {
+	subCount atomic.Int32
+	mu       struct {
+		sync.RWMutex
+		subs map[*RegionEventSubscription]struct{}
+	}
+}
+
+func (h *regionEventHub) subscribe(bufferSize int) *RegionEventSubscription {
+	if bufferSize <= 0 {
+		bufferSize = defaultRegionEventBufferSize
+	}
+	sub := &RegionEventSubscription{hub: h, ch: make(chan RegionEvent, bufferSize)}
+	h.mu.Lock()
+	if h.mu.subs == nil {
+		h.mu.subs = make(map[*RegionEventSubscription]struct{})
+	}
+	h.mu.subs[sub] = struct{}{}
+	h.subCount.Add(1)
+	h.mu.Unlock()
+	return sub
+}
+
+// active reports whether there is any subscription, it's used to avoid
+// building the events which nobody receives.
+func (h *regionEventHub) active() bool {
+	return h != nil && h.subCount.Load() > 0
+}
+
+func (h *regionEventHub) publish(e RegionEvent) {
+	if !h.active() {
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.mu.subs {
+		select {
+		case sub.ch <- e:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// onRegionInserted publishes RegionSplit or RegionMerged if the cached regions
+// replaced by the inserted region are split or merged. Replacing a region by a
+// newer version with the same range, e.g. after a conf change, is not an event.
+func (h *regionEventHub) onRegionInserted(region *Region, replaced []*btreeItem) {
+	if !h.active() || len(replaced) == 0 {
+		return
+	}
+	sources := make([]RegionVerID, 0, len(replaced))
+	split := false
+	for _, item := range replaced {
+		old := item.cachedRegion
+		sources = append(sources, old.VerID())
+		if !rangeContains(region, old) {
+			split = true
+		}
+	}
+	var typ RegionEventType
+	switch {
+	case split:
+		typ = RegionSplit
+	case len(replaced) > 1 || !rangeContains(replaced[0].cachedRegion, region):
+		typ = RegionMerged
+	default:
+		return
+	}
+	h.publish(RegionEvent{
+		Type:     typ,
+		Region:   region.VerID(),
+		StartKey: region.StartKey(),
+		EndKey:   region.EndKey(),
+		Sources:  sources,
+	})
+}
+
+// rangeContains reports whether the range of outer contains the range of inner.
+func rangeContains(outer, inner *Region) bool {
+	if bytes.Compare(outer.StartKey(), inner.StartKey()) > 0 {
+		return false
+	}
+	if len(outer.EndKey()) == 0 {
+		return true
+	}
+	return len(inner.EndKey()) > 0 && bytes.Compare(inner.EndKey(), outer.EndKey()) <= 0
+}
+
+func (h *regionEventHub) onStoreStateChanged(s *Store) {
+	if !h.active() {
+		return
+	}
+	h.publish(RegionEvent{
+		Type:          StoreStateChanged,
+		StoreID:       s.storeID,
+		StoreAddr:     s.addr,
+		StoreState:    s.getResolveState().String(),
+		StoreLiveness: s.getLivenessState().String(),
+	})
+}
+
+// SubscribeRegionEvents subscribes the region split, merge, leader change,
+// invalidation and store state change events of the cache. The events are
+// buffered by a channel of bufferSize, a default size is used if it's not
+// positive. See RegionEventSubscription for the drop policy.
+func (c *RegionCache) SubscribeRegionEvents(bufferSize int) *RegionEventSubscription {
+	return c.events.subscribe(bufferSize)
+}
+
+// invalidateRegion invalidates the cached region and publishes the event if
+// it's not invalidated yet.
+func (c *RegionCache) invalidateRegion(r *Region, reason InvalidReason) {
+	if r.invalidate(reason) && c.events.active() {
+		c.events.publish(RegionEvent{
+			Type:     RegionInvalidated,
+			Region:   r.VerID(),
+			StartKey: r.StartKey(),
+			EndKey:   r.EndKey(),
+			Reason:   reason,
+		})
+	}
+}
+
+// switchWorkLeaderToPeer switches the leader of the cached region to the peer
+// and publishes the event if the leader is changed. It returns false if no
+// peer matches the peer.
+func (c *RegionCache) switchWorkLeaderToPeer(r *Region, peer *metapb.Peer) bool {
+	oldLeaderStoreID := r.GetLeaderStoreID()
+	if !r.switchWorkLeaderToPeer(peer) {
+		return false
+	}
+	if newLeaderStoreID := r.GetLeaderStoreID(); newLeaderStoreID != oldLeaderStoreID && c.events.active() {
+		c.events.publish(RegionEvent{
+			Type:             LeaderChanged,
+			Region:           r.VerID(),
+			StartKey:         r.StartKey(),
+			EndKey:           r.EndKey(),
+			LeaderStoreID:    newLeaderStoreID,
+			OldLeaderStoreID: oldLeaderStoreID,
+		})
+	}
+	return true
+}
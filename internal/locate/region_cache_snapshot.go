@@ -0,0 +1,315 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/pd/client/clients/router"
+	"go.uber.org/zap"
+)
+
+// The snapshot of the region cache is encoded as:
+//
+//	magic | version | api version | keyspace | stores | regions
+//
+// stores:  count, then for each store: id | addr | peer addr | status addr | type | labels
+// regions: count, then for each region: meta | leader peer id | buckets | pending peers | down peers
+//
+// Integers are uvarints, bytes are prefixed by their uvarint length and the
+// protobuf messages are encoded as bytes.
+var regionCacheSnapshotMagic = []byte("TKRC")
+
+const (
+	regionCacheSnapshotVersion = 1
+	// maxRegionCacheSnapshotBytes limits the size of a single field to detect
+	// corrupted snapshots early.
+	maxRegionCacheSnapshotBytes = 64 * 1024 * 1024
+)
+
+// Export writes the valid regions and the resolved stores in the cache to w,
+// which can be loaded by Import to warm up the cache of another process.
+func (c *RegionCache) Export(w io.Writer) error {
+	stores := c.GetAllStores()
+	now := time.Now().Unix()
+	var regions []*Region
+	c.mu.RLock()
+	c.mu.sorted.b.Ascend(func(item *btreeItem) bool {
+		r := item.cachedRegion
+		if !r.isCacheTTLExpired(now) && !r.checkSyncFlags(needReloadOnAccess) {
+			regions = append(regions, r)
+		}
+		return true
+	})
+	c.mu.RUnlock()
+
+	enc := snapshotEncoder{w: bufio.NewWriter(w)}
+	enc.writeBytes(regionCacheSnapshotMagic)
+	enc.writeUvarint(regionCacheSnapshotVersion)
+	enc.writeUvarint(uint64(c.codec.GetAPIVersion()))
+	enc.writeBytes(c.codec.GetKeyspace())
+
+	enc.writeUvarint(uint64(len(stores)))
+	for _, s := range stores {
+		enc.writeUvarint(s.storeID)
+		enc.writeBytes([]byte(s.addr))
+		enc.writeBytes([]byte(s.peerAddr))
+		enc.writeBytes([]byte(s.saddr))
+		enc.writeUvarint(uint64(s.storeType))
+		enc.writeUvarint(uint64(len(s.labels)))
+		for _, label := range s.labels {
+			enc.writeBytes([]byte(label.Key))
+			enc.writeBytes([]byte(label.Value))
+		}
+	}
+
+	enc.writeUvarint(uint64(len(regions)))
+	for _, r := range regions {
+		rs := r.getStore()
+		enc.writeProto(r.meta)
+		enc.writeUvarint(r.GetLeaderPeerID())
+		if rs.buckets != nil {
+			enc.writeProto(rs.buckets)
+		} else {
+			enc.writeBytes(nil)
+		}
+		enc.writePeers(rs.pendingPeers)
+		enc.writePeers(rs.downPeers)
+	}
+	return enc.flush()
+}
+
+// Import loads the regions and stores exported by Export into the cache. The
+// stores and regions which are already cached are kept.
+//
+// The imported regions are not validated against PD. They are used as if they
+// are loaded from PD, so the stale ones are refreshed by the epoch errors
+// returned by TiKV, and all of them expire after the region cache TTL even if
+// they are accessed continuously, to be validated by reloading from PD lazily.
+func (c *RegionCache) Import(r io.Reader) error {
+	dec := snapshotDecoder{r: bufio.NewReader(r)}
+	if magic := dec.readBytes(); dec.err == nil && !bytes.Equal(magic, regionCacheSnapshotMagic) {
+		return errors.New("invalid region cache snapshot")
+	}
+	if version := dec.readUvarint(); dec.err == nil && version != regionCacheSnapshotVersion {
+		return errors.Errorf("unsupported region cache snapshot version %d", version)
+	}
+	apiVersion := kvrpcpb.APIVersion(dec.readUvarint())
+	keyspace := dec.readBytes()
+	if dec.err != nil {
+		return dec.err
+	}
+	if apiVersion != c.codec.GetAPIVersion() || !bytes.Equal(keyspace, c.codec.GetKeyspace()) {
+		return errors.Errorf("region cache snapshot of api version %v keyspace %x does not match the cache", apiVersion, keyspace)
+	}
+
+	storeCount := dec.readUvarint()
+	stores := make([]*Store, 0, min(storeCount, 1024))
+	for i := uint64(0); i < storeCount && dec.err == nil; i++ {
+		id := dec.readUvarint()
+		addr, peerAddr, statusAddr := string(dec.readBytes()), string(dec.readBytes()), string(dec.readBytes())
+		storeType := tikvrpc.EndpointType(dec.readUvarint())
+		labelCount := dec.readUvarint()
+		labels := make([]*metapb.StoreLabel, 0, min(labelCount, 64))
+		for j := uint64(0); j < labelCount && dec.err == nil; j++ {
+			labels = append(labels, &metapb.StoreLabel{Key: string(dec.readBytes()), Value: string(dec.readBytes())})
+		}
+		stores = append(stores, newStore(id, addr, peerAddr, statusAddr, storeType, resolved, labels))
+	}
+
+	regionCount := dec.readUvarint()
+	pdRegions := make([]*router.Region, 0, min(regionCount, 1<<16))
+	for i := uint64(0); i < regionCount && dec.err == nil; i++ {
+		pdRegion := &router.Region{Meta: &metapb.Region{}}
+		dec.readProto(pdRegion.Meta)
+		leaderID := dec.readUvarint()
+		if data := dec.readBytes(); len(data) > 0 && dec.err == nil {
+			pdRegion.Buckets = &metapb.Buckets{}
+			dec.setErr(proto.Unmarshal(data, pdRegion.Buckets))
+		}
+		pdRegion.PendingPeers = dec.readPeers()
+		pdRegion.DownPeers = dec.readPeers()
+		for _, p := range pdRegion.Meta.GetPeers() {
+			if p.GetId() == leaderID {
+				pdRegion.Leader = p
+			}
+		}
+		pdRegions = append(pdRegions, pdRegion)
+	}
+	if dec.err != nil {
+		return dec.err
+	}
+
+	for _, s := range stores {
+		if _, exists := c.stores.get(s.storeID); !exists {
+			c.stores.put(s)
+		}
+	}
+	// All stores are resolved, so the PD is not accessed when creating regions.
+	bo := retry.NewNoopBackoff(context.Background())
+	regions := make([]*Region, 0, len(pdRegions))
+	for _, pdRegion := range pdRegions {
+		if !c.allPeerStoresCached(pdRegion.Meta) {
+			continue
+		}
+		region, err := newRegion(bo, c, pdRegion)
+		if err != nil {
+			continue
+		}
+		region.setSyncFlags(needExpireAfterTTL)
+		regions = append(regions, region)
+	}
+
+	imported := 0
+	c.mu.Lock()
+	for _, region := range regions {
+		if _, ok := c.mu.latestVersions[region.GetID()]; ok {
+			continue
+		}
+		if c.insertRegionToCache(region, false, false) {
+			imported++
+		}
+	}
+	c.mu.Unlock()
+	logutil.BgLogger().Info("import region cache snapshot",
+		zap.Int("stores", len(stores)), zap.Int("regions", len(pdRegions)), zap.Int("imported-regions", imported))
+	return nil
+}
+
+func (c *RegionCache) allPeerStoresCached(meta *metapb.Region) bool {
+	for _, p := range meta.GetPeers() {
+		if _, exists := c.stores.get(p.GetStoreId()); !exists {
+			return false
+		}
+	}
+	return true
+}
+
+type snapshotEncoder struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (e *snapshotEncoder) writeUvarint(v uint64) {
+	if e.err != nil {
+		return
+	}
+	n := binary.PutUvarint(e.buf[:], v)
+	_, e.err = e.w.Write(e.buf[:n])
+}
+
+func (e *snapshotEncoder) writeBytes(b []byte) {
+	e.writeUvarint(uint64(len(b)))
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.Write(b)
+}
+
+func (e *snapshotEncoder) writeProto(m proto.Message) {
+	if e.err != nil {
+		return
+	}
+	data, err := proto.Marshal(m)
+	if err != nil {
+		e.err = errors.WithStack(err)
+		return
+	}
+	e.writeBytes(data)
+}
+
+func (e *snapshotEncoder) writePeers(peers []*metapb.Peer) {
+	e.writeUvarint(uint64(len(peers)))
+	for _, p := range peers {
+		e.writeProto(p)
+	}
+}
+
+func (e *snapshotEncoder) flush() error {
+	if e.err != nil {
+		return errors.WithStack(e.err)
+	}
+	return errors.WithStack(e.w.Flush())
+}
+
+type snapshotDecoder struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (d *snapshotDecoder) setErr(err error) {
+	if d.err == nil && err != nil {
+		d.err = errors.Wrap(err, "corrupted region cache snapshot")
+	}
+}
+
+func (d *snapshotDecoder) readUvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d.r)
+	d.setErr(err)
+	return v
+}
+
+func (d *snapshotDecoder) readBytes() []byte {
+	n := d.readUvarint()
+	if d.err != nil || n == 0 {
+		return nil
+	}
+	if n > maxRegionCacheSnapshotBytes {
+		d.setErr(errors.Errorf("field size %d is too large", n))
+		return nil
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(d.r, b)
+	d.setErr(err)
+	return b
+}
+
+func (d *snapshotDecoder) readProto(m proto.Message) {
+	data := d.readBytes()
+	if d.err != nil {
+		return
+	}
+	d.setErr(proto.Unmarshal(data, m))
+}
+
+func (d *snapshotDecoder) readPeers() []*metapb.Peer {
+	n := d.readUvarint()
+	if d.err != nil || n == 0 {
+		return nil
+	}
+	peers := make([]*metapb.Peer, 0, min(n, 64))
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		p := &metapb.Peer{}
+		d.readProto(p)
+		peers = append(peers, p)
+	}
+	return peers
+}
@@ -51,6 +51,7 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/pingcap/kvproto/pkg/keyspacepb"
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/stretchr/testify/require"
@@ -2996,3 +2997,182 @@ func BenchmarkBatchLocateKeyRangesFromCache(t *testing.B) {
 	}
 	s.TearDownTest()
 }
+
+func (s *testRegionCacheSuite) TestExportImport() {
+	// key range: ['' - 'm' - 'z']
+	region2 := s.cluster.AllocID()
+	newPeers := s.cluster.AllocIDs(2)
+	s.cluster.Split(s.region1, region2, []byte("m"), newPeers, newPeers[0])
+	s.cluster.UpdateStoreLabels(s.store1, []*metapb.StoreLabel{{Key: "zone", Value: "z1"}})
+	loc1 := s.getRegion([]byte("a"))
+	loc2 := s.getRegion([]byte("x"))
+	s.cache.UpdateLeader(loc2.VerID(), loc2.meta.Peers[1], 0)
+
+	var buf bytes.Buffer
+	s.Nil(s.cache.Export(&buf))
+
+	var getRegionCalls atomic.Int32
+	pdCli := &CodecPDClient{&inspectedPDClient{
+		Client: mocktikv.NewPDClient(s.cluster),
+		getRegion: func(ctx context.Context, cli pd.Client, key []byte, opts ...opt.GetRegionOption) (*router.Region, error) {
+			getRegionCalls.Add(1)
+			return cli.GetRegion(ctx, key, opts...)
+		},
+	}, apicodec.NewCodecV1(apicodec.ModeTxn)}
+	cache := NewRegionCache(pdCli)
+	defer cache.Close()
+	s.Nil(cache.Import(bytes.NewReader(buf.Bytes())))
+
+	// The regions are located without accessing PD.
+	for _, expected := range []*Region{loc1, loc2} {
+		loc, err := cache.LocateKey(s.bo, expected.StartKey())
+		s.Nil(err)
+		s.Equal(expected.VerID(), loc.Region)
+		r := cache.GetCachedRegionWithRLock(loc.Region)
+		s.Equal(expected.GetLeaderPeerID(), r.GetLeaderPeerID())
+		s.True(r.checkSyncFlags(needExpireAfterTTL))
+		ctx, err := cache.GetTiKVRPCContext(s.bo, loc.Region, kv.ReplicaReadLeader, 0)
+		s.Nil(err)
+		s.Equal(expected.GetLeaderStoreID(), ctx.Store.StoreID())
+	}
+	s.Equal(int32(0), getRegionCalls.Load())
+	store, exists := cache.stores.get(s.store1)
+	s.True(exists)
+	s.Equal(resolved, store.getResolveState())
+	s.True(store.IsLabelsMatch([]*metapb.StoreLabel{{Key: "zone", Value: "z1"}}))
+
+	// The cached regions are kept.
+	s.Nil(cache.Import(bytes.NewReader(buf.Bytes())))
+	s.Equal(2, len(cache.mu.regions))
+
+	// Corrupted or mismatched snapshots are rejected.
+	s.NotNil(cache.Import(bytes.NewReader(buf.Bytes()[:buf.Len()/2])))
+	s.NotNil(cache.Import(bytes.NewReader([]byte("invalid"))))
+	codecV2, err := apicodec.NewCodecV2(apicodec.ModeTxn, &keyspacepb.KeyspaceMeta{Id: 1})
+	s.Nil(err)
+	cacheV2 := NewRegionCache(&CodecPDClient{mocktikv.NewPDClient(s.cluster), codecV2})
+	defer cacheV2.Close()
+	s.NotNil(cacheV2.Import(bytes.NewReader(buf.Bytes())))
+}
+
+func (s *testRegionCacheSuite) TestImportStaleRegion() {
+	s.getRegion([]byte("a"))
+	var buf bytes.Buffer
+	s.Nil(s.cache.Export(&buf))
+
+	// key range: ['' - 'm' - 'z']
+	region2 := s.cluster.AllocID()
+	newPeers := s.cluster.AllocIDs(2)
+	s.cluster.Split(s.region1, region2, []byte("m"), newPeers, newPeers[0])
+
+	pdCli := &CodecPDClient{mocktikv.NewPDClient(s.cluster), apicodec.NewCodecV1(apicodec.ModeTxn)}
+	cache := NewRegionCache(pdCli)
+	defer cache.Close()
+	s.Nil(cache.Import(bytes.NewReader(buf.Bytes())))
+	loc, err := cache.LocateKey(s.bo, []byte("x"))
+	s.Nil(err)
+	s.Equal(s.region1, loc.Region.GetID())
+
+	// The stale region is refreshed by the epoch error.
+	sender := NewRegionRequestSender(cache, mocktikv.NewRPCClient(s.cluster, s.mvccStore, nil), oracle.NoopReadTSValidator{})
+	req := tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("x"), Version: 1})
+	resp, _, err := sender.SendReq(s.bo, req, loc.Region, time.Second)
+	s.Nil(err)
+	regionErr, err := resp.GetRegionError()
+	s.Nil(err)
+	s.NotNil(regionErr.GetEpochNotMatch())
+	loc, err = cache.LocateKey(s.bo, []byte("x"))
+	s.Nil(err)
+	s.Equal(region2, loc.Region.GetID())
+}
+
+func (s *testRegionCacheSuite) TestRegionEvents() {
+	sub := s.cache.SubscribeRegionEvents(16)
+	defer sub.Close()
+	nextEvent := func() RegionEvent {
+		select {
+		case e := <-sub.Events():
+			return e
+		default:
+			s.FailNow("no region event")
+			return RegionEvent{}
+		}
+	}
+	noEvent := func() {
+		select {
+		case e := <-sub.Events():
+			s.FailNow("unexpected region event", "%v", e.Type)
+		default:
+		}
+	}
+
+	loc1 := s.getRegion([]byte("a"))
+	noEvent()
+
+	// key range: ['' - 'm' - 'z']
+	region2 := s.cluster.AllocID()
+	newPeers := s.cluster.AllocIDs(2)
+	s.cluster.Split(s.region1, region2, []byte("m"), newPeers, newPeers[0])
+	s.cache.InvalidateCachedRegionWithReason(loc1.VerID(), EpochNotMatch)
+	e := nextEvent()
+	s.Equal(RegionInvalidated, e.Type)
+	s.Equal(loc1.VerID(), e.Region)
+	s.Equal(EpochNotMatch, e.Reason)
+	// Invalidating it again is not an event.
+	s.cache.InvalidateCachedRegion(loc1.VerID())
+	noEvent()
+
+	loc1 = s.getRegion([]byte("a"))
+	e = nextEvent()
+	s.Equal(RegionSplit, e.Type)
+	s.Equal(loc1.VerID(), e.Region)
+	s.Equal([]byte("m"), e.EndKey)
+	s.Len(e.Sources, 1)
+	s.Equal(s.region1, e.Sources[0].GetID())
+	s.NotEqual(loc1.VerID(), e.Sources[0])
+	// The new region doesn't replace any cached region.
+	loc2 := s.getRegion([]byte("x"))
+	noEvent()
+
+	s.cluster.ChangeLeader(s.region1, s.peer2)
+	s.cache.UpdateLeader(loc1.VerID(), &metapb.Peer{Id: s.peer2, StoreId: s.store2}, 0)
+	e = nextEvent()
+	s.Equal(LeaderChanged, e.Type)
+	s.Equal(loc1.VerID(), e.Region)
+	s.Equal(s.store1, e.OldLeaderStoreID)
+	s.Equal(s.store2, e.LeaderStoreID)
+	// The leader is not changed.
+	s.cache.UpdateLeader(loc1.VerID(), &metapb.Peer{Id: s.peer2, StoreId: s.store2}, 0)
+	noEvent()
+
+	s.cluster.Merge(s.region1, region2)
+	s.cache.InvalidateCachedRegion(loc1.VerID())
+	s.Equal(RegionInvalidated, nextEvent().Type)
+	loc := s.getRegion([]byte("a"))
+	e = nextEvent()
+	s.Equal(RegionMerged, e.Type)
+	s.Equal(loc.VerID(), e.Region)
+	s.Empty(e.EndKey)
+	s.ElementsMatch([]RegionVerID{loc1.VerID(), loc2.VerID()}, e.Sources)
+
+	store, exists := s.cache.stores.get(s.store1)
+	s.True(exists)
+	s.cache.stores.markStoreNeedCheck(store)
+	e = nextEvent()
+	s.Equal(StoreStateChanged, e.Type)
+	s.Equal(s.store1, e.StoreID)
+	s.Equal(needCheck.String(), e.StoreState)
+	noEvent()
+
+	// The events are dropped if the buffer is full.
+	sub2 := s.cache.SubscribeRegionEvents(1)
+	s.cache.InvalidateCachedRegion(loc.VerID())
+	loc = s.getRegion([]byte("a"))
+	s.cache.InvalidateCachedRegion(loc.VerID())
+	s.Equal(uint64(1), sub2.Dropped())
+	s.Equal(RegionInvalidated, (<-sub2.Events()).Type)
+	sub2.Close()
+	sub2.Close()
+	_, ok := <-sub2.Events()
+	s.False(ok)
+}
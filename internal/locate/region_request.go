@@ -55,6 +55,7 @@ import (
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config"
 	"github.com/tikv/client-go/v2/config/retry"
 	tikverr "github.com/tikv/client-go/v2/error"
 	"github.com/tikv/client-go/v2/internal/client"
@@ -114,6 +115,7 @@ type RegionRequestSender struct {
 	replicaSelector   *replicaSelector
 	failStoreIDs      map[uint64]struct{}
 	failProxyStoreIDs map[uint64]struct{}
+	hedgePolicy       *HedgePolicy
 	Stats             *RegionRequestRuntimeStats
 	AccessStats       *ReplicaAccessStats
 }
@@ -129,6 +131,12 @@ func (s *RegionRequestSender) String() string {
 type RegionRequestRuntimeStats struct {
 	// RPCStatsList uses to record RPC requests stats, since in most cases, only one kind of rpc request is sent at a time, use slice instead of map for performance.
 	RPCStatsList []RPCRuntimeStats
+	// HedgeCount is the count of hedged requests sent, and HedgeWinCount is the count of them answering first.
+	HedgeCount    uint32
+	HedgeWinCount uint32
+	// RetryTraces are the traces of the requests retried or failed.
+	// Attention: only the first 16 traces are recorded.
+	RetryTraces []*tikverr.RetryTrace
 	RequestErrorStats
 }
 
@@ -140,6 +148,9 @@ type RequestErrorStats struct {
 	OtherErrCnt int
 }
 
+// maxRetryTraces limits the retry traces recorded by RegionRequestRuntimeStats.
+const maxRetryTraces = 16
+
 // NewRegionRequestRuntimeStats returns a new RegionRequestRuntimeStats.
 func NewRegionRequestRuntimeStats() *RegionRequestRuntimeStats {
 	return &RegionRequestRuntimeStats{
@@ -171,6 +182,27 @@ func (r *RegionRequestRuntimeStats) RecordRPCRuntimeStats(cmd tikvrpc.CmdType, d
 	})
 }
 
+// RecordRetryTrace records the retry trace of a request.
+func (r *RegionRequestRuntimeStats) RecordRetryTrace(trace *tikverr.RetryTrace) {
+	if len(r.RetryTraces) < maxRetryTraces {
+		r.RetryTraces = append(r.RetryTraces, trace)
+	}
+}
+
+// RetryTracesJSON renders the retry traces as a JSON array.
+func (r *RegionRequestRuntimeStats) RetryTracesJSON() string {
+	var builder strings.Builder
+	builder.WriteByte('[')
+	for i, trace := range r.RetryTraces {
+		if i > 0 {
+			builder.WriteByte(',')
+		}
+		builder.WriteString(trace.JSON())
+	}
+	builder.WriteByte(']')
+	return builder.String()
+}
+
 // GetRPCStatsCount returns the total rpc types count.
 func (r *RegionRequestRuntimeStats) GetRPCStatsCount() int {
 	return len(r.RPCStatsList)
@@ -228,6 +260,13 @@ func (r *RegionRequestRuntimeStats) String() string {
 		builder.WriteString(util.FormatDuration(v.Consume))
 		builder.WriteString("}")
 	}
+	if r.HedgeCount > 0 {
+		builder.WriteString(", hedge:{num:")
+		builder.WriteString(strconv.FormatUint(uint64(r.HedgeCount), 10))
+		builder.WriteString(", win:")
+		builder.WriteString(strconv.FormatUint(uint64(r.HedgeWinCount), 10))
+		builder.WriteString("}")
+	}
 	if errStatsStr := r.RequestErrorStats.String(); errStatsStr != "" {
 		builder.WriteString(", rpc_errors:")
 		builder.WriteString(errStatsStr)
@@ -263,6 +302,9 @@ func (r *RegionRequestRuntimeStats) Clone() *RegionRequestRuntimeStats {
 	newRs := NewRegionRequestRuntimeStats()
 	newRs.RPCStatsList = make([]RPCRuntimeStats, 0, len(r.RPCStatsList))
 	newRs.RPCStatsList = append(newRs.RPCStatsList, r.RPCStatsList...)
+	newRs.HedgeCount = r.HedgeCount
+	newRs.HedgeWinCount = r.HedgeWinCount
+	newRs.RetryTraces = append(newRs.RetryTraces, r.RetryTraces...)
 	if len(r.ErrStats) > 0 {
 		newRs.ErrStats = make(map[string]int)
 		maps.Copy(newRs.ErrStats, r.ErrStats)
@@ -279,6 +321,11 @@ func (r *RegionRequestRuntimeStats) Merge(rs *RegionRequestRuntimeStats) {
 	for i := range rs.RPCStatsList {
 		r.mergeRPCRuntimeStats(rs.RPCStatsList[i])
 	}
+	r.HedgeCount += rs.HedgeCount
+	r.HedgeWinCount += rs.HedgeWinCount
+	for _, trace := range rs.RetryTraces {
+		r.RecordRetryTrace(trace)
+	}
 	if len(rs.ErrStats) > 0 {
 		if r.ErrStats == nil {
 			r.ErrStats = make(map[string]int)
@@ -485,6 +532,12 @@ func (r *replica) isEpochStale() bool {
 	return r.epoch != atomic.LoadUint32(&r.store.epoch)
 }
 
+// isCircuitBroken reports whether the circuit breaker of the store is open, the replica is
+// temporarily unavailable until the circuit breaker cools down.
+func (r *replica) isCircuitBroken() bool {
+	return client.IsStoreCircuitBreakerOpen(r.store.addr)
+}
+
 func (r *replica) isExhausted(maxAttempt int, maxAttemptTime time.Duration) bool {
 	return r.attempts >= maxAttempt || (maxAttemptTime > 0 && r.attemptedTime >= maxAttemptTime)
 }
@@ -667,7 +720,7 @@ func (s *baseReplicaSelector) updateLeader(leader *metapb.Peer) int {
 			}
 			replica.onUpdateLeader()
 			// Update the workTiKVIdx so that following requests can be sent to the leader immediately.
-			if !s.region.switchWorkLeaderToPeer(leader) {
+			if !s.regionCache.switchWorkLeaderToPeer(s.region, leader) {
 				panic("the store must exist")
 			}
 			logutil.BgLogger().Debug(
@@ -679,13 +732,13 @@ func (s *baseReplicaSelector) updateLeader(leader *metapb.Peer) int {
 		}
 	}
 	// Invalidate the region since the new leader is not in the cached version.
-	s.region.invalidate(StoreNotFound)
+	s.regionCache.invalidateRegion(s.region, StoreNotFound)
 	return -1
 }
 
 func (s *baseReplicaSelector) invalidateRegion() {
 	if s.region != nil {
-		s.region.invalidate(Other)
+		s.regionCache.invalidateRegion(s.region, Other)
 	}
 }
 
@@ -745,6 +798,8 @@ type sendReqState struct {
 		msg       string
 		sendTimes int
 	}
+	// trace is the retry trace of the request.
+	trace tikverr.RetryTrace
 }
 
 // next encapsulates one iteration of the retry loop. calling `next` will handle send error (s.vars.err) or region error
@@ -767,6 +822,11 @@ func (s *sendReqState) next(
 
 	// handle send error
 	if s.vars.err != nil {
+		if remaining, ok := bo.RemainingBudget(); ok && remaining <= 0 {
+			s.vars.rpcCtx, s.vars.resp = nil, nil
+			s.vars.err = bo.DeadlineBudgetExhausted("send", s.vars.err)
+			return true
+		}
 		if e := s.onSendFail(bo, s.vars.rpcCtx, req, s.vars.err); e != nil {
 			s.vars.rpcCtx, s.vars.resp = nil, nil
 			s.vars.msg = fmt.Sprintf("failed to handle send error: %v", s.vars.err)
@@ -798,6 +858,9 @@ func (s *sendReqState) next(
 
 	s.vars.rpcCtx, s.vars.err = s.getRPCContext(bo, req, regionID, et, opts...)
 	if s.vars.err != nil {
+		if errors.Is(bo.GetCtx().Err(), context.DeadlineExceeded) && !errors.As(s.vars.err, new(*tikverr.ErrDeadlineBudgetExhausted)) {
+			s.vars.err = bo.DeadlineBudgetExhausted("region reload", s.vars.err)
+		}
 		return true
 	}
 
@@ -831,13 +894,20 @@ func (s *sendReqState) next(
 
 	if s.replicaSelector != nil &&
 		s.replicaSelector.target != nil &&
-		req.AccessLocation == kv.AccessUnknown &&
-		len(s.replicaSelector.option.labels) != 0 {
+		req.AccessLocation == kv.AccessUnknown {
 		// patch the access location if it is not set under region request sender.
-		if s.replicaSelector.target.store.IsLabelsMatch(s.replicaSelector.option.labels) {
-			req.AccessLocation = kv.AccessLocalZone
-		} else {
-			req.AccessLocation = kv.AccessCrossZone
+		if len(s.replicaSelector.option.labels) != 0 {
+			if s.replicaSelector.target.store.IsLabelsMatch(s.replicaSelector.option.labels) {
+				req.AccessLocation = kv.AccessLocalZone
+			} else {
+				req.AccessLocation = kv.AccessCrossZone
+			}
+		} else if policy := s.regionCache.GetLocalityPolicy(); policy != nil {
+			if policy.isLocal(s.replicaSelector.target.store) {
+				req.AccessLocation = kv.AccessLocalZone
+			} else {
+				req.AccessLocation = kv.AccessCrossZone
+			}
 		}
 	}
 
@@ -866,15 +936,53 @@ func (s *sendReqState) next(
 	}
 
 	// judge the store limit switch.
-	if limit := kv.StoreLimit.Load(); limit > 0 {
+	var limiter *storeConcurrencyLimiter
+	if cfg := config.GetGlobalConfig().TiKVClient.AdaptiveStoreLimit; cfg.Enable && s.vars.rpcCtx.Store != nil {
+		limiter = s.vars.rpcCtx.Store.getConcurrencyLimiter(cfg)
+		if s.vars.err = limiter.acquire(bo.GetCtx(), req.GetResourceControlContext().GetResourceGroupName()); s.vars.err != nil {
+			return true
+		}
+	} else if limit := kv.StoreLimit.Load(); limit > 0 {
 		if s.vars.err = s.getStoreToken(s.vars.rpcCtx.Store, limit); s.vars.err != nil {
 			return true
 		}
 		defer s.releaseStoreToken(s.vars.rpcCtx.Store)
 	}
 
+	// Don't let a single attempt outlive the deadline of the request.
+	if remaining, ok := bo.RemainingBudget(); ok {
+		if remaining <= 0 {
+			s.vars.rpcCtx, s.vars.resp = nil, nil
+			s.vars.err = bo.DeadlineBudgetExhausted("send", nil)
+			if limiter != nil {
+				limiter.release(0, storeLimitFailed)
+			}
+			return true
+		}
+		if remaining < timeout {
+			timeout = remaining
+		}
+		if maxExecMs := uint64(timeout.Milliseconds()); maxExecMs > 0 && maxExecMs < req.Context.MaxExecutionDurationMs {
+			req.Context.MaxExecutionDurationMs = maxExecMs
+		}
+	}
+
+	start := time.Now()
 	canceled := s.send(bo, req, timeout)
 	s.vars.sendTimes++
+	s.traceAttempt(start)
+	if limiter != nil {
+		result := storeLimitFailed
+		if s.vars.err == nil && s.vars.resp != nil {
+			if regionErr, err := s.vars.resp.GetRegionError(); err == nil && regionErr == nil {
+				result = storeLimitOK
+			}
+		} else if s.vars.err != nil && !canceled && bo.GetCtx().Err() == nil && isCauseByDeadlineExceeded(s.vars.err) {
+			// The request times out while the caller is still waiting, the store is too busy to answer it.
+			result = storeLimitTimeout
+		}
+		limiter.release(time.Since(start), result)
+	}
 
 	if s.vars.err != nil {
 		// Because in rpc logic, context.Cancel() will be transferred to rpcContext.Cancel error. For rpcContext cancel,
@@ -911,6 +1019,37 @@ func (s *sendReqState) next(
 	return true
 }
 
+// traceAttempt records the attempt sent at start to the retry trace of the request.
+func (s *sendReqState) traceAttempt(start time.Time) {
+	attempt := tikverr.RetryAttempt{Start: start, Duration: time.Since(start)}
+	rpcCtx := s.vars.rpcCtx
+	if rpcCtx != nil {
+		attempt.RegionID = rpcCtx.Region.GetID()
+		attempt.Addr = rpcCtx.Addr
+		attempt.ProxyAddr = rpcCtx.ProxyAddr
+		if rpcCtx.Store != nil {
+			attempt.StoreID = rpcCtx.Store.storeID
+		}
+		if rpcCtx.Peer != nil {
+			attempt.PeerID = rpcCtx.Peer.GetId()
+		}
+	}
+	if s.replicaSelector != nil {
+		attempt.Replica = s.replicaSelector.replicaType()
+	}
+	if s.vars.err != nil {
+		attempt.RPCError = s.vars.err.Error()
+	} else if s.vars.resp != nil {
+		if regionErr, err := s.vars.resp.GetRegionError(); err == nil && regionErr != nil {
+			attempt.RegionError = regionErr.String()
+		}
+	}
+	if rpcCtx != nil && (attempt.RPCError != "" || attempt.RegionError != "") {
+		attempt.RPCContext = rpcCtx.String()
+	}
+	s.trace.AddAttempt(attempt)
+}
+
 func (s *sendReqState) send(bo *retry.Backoffer, req *tikvrpc.Request, timeout time.Duration) (canceled bool) {
 	rpcCtx := s.vars.rpcCtx
 	ctx := bo.GetCtx()
@@ -973,10 +1112,17 @@ func (s *sendReqState) send(bo *retry.Backoffer, req *tikvrpc.Request, timeout t
 
 	if !injectFailOnSend {
 		start := time.Now()
-		s.vars.resp, s.vars.err = s.client.SendRequest(ctx, sendToAddr, req, timeout)
+		if s.canHedge(req, rpcCtx) {
+			s.vars.rpcCtx, s.vars.resp, s.vars.err = s.sendWithHedge(bo, ctx, sendToAddr, req, timeout)
+			s.storeAddr = s.vars.rpcCtx.Addr
+		} else {
+			s.vars.resp, s.vars.err = s.client.SendRequest(ctx, sendToAddr, req, timeout)
+			s.recordHedgeLatency(req, rpcCtx, s.vars.resp, s.vars.err, time.Since(start))
+		}
 		rpcDuration := time.Since(start)
 		if s.replicaSelector != nil {
 			recordAttemptedTime(s.replicaSelector, rpcDuration)
+			s.consumeCrossZoneBudget(req, s.vars.rpcCtx)
 		}
 		// Record timecost of external requests on related Store when `ReplicaReadMode == "PreferLeader"`.
 		if rpcCtx.Store != nil && req.ReplicaReadType == kv.ReplicaReadPreferLeader && !util.IsInternalRequest(req.RequestSource) {
@@ -1099,7 +1245,9 @@ func (s *RegionRequestSender) SendReqCtx(
 	}
 
 	state := &sendReqState{RegionRequestSender: s}
+	prevTrace := bo.SetRetryTrace(&state.trace)
 	defer func() {
+		bo.SetRetryTrace(prevTrace)
 		if retryTimes := state.vars.sendTimes - 1; retryTimes > 0 {
 			metrics.TiKVRequestRetryTimesHistogram.Observe(float64(retryTimes))
 		}
@@ -1122,13 +1270,21 @@ func (s *RegionRequestSender) SendReqCtx(
 		}
 	}
 
+	if state.vars.sendTimes > 1 {
+		retryTimes = state.vars.sendTimes - 1
+	}
+	trace := &state.trace
 	if state.vars.err == nil {
 		resp, rpcCtx = state.vars.resp, state.vars.rpcCtx
 	} else {
 		err = state.vars.err
+		if len(trace.Attempts) > 0 {
+			bo.AddFailedAttempts(trace.Attempts)
+			err = &tikverr.ErrWithRetryTrace{Err: err, Trace: trace}
+		}
 	}
-	if state.vars.sendTimes > 1 {
-		retryTimes = state.vars.sendTimes - 1
+	if s.Stats != nil && (state.vars.err != nil || retryTimes > 0) {
+		s.Stats.RecordRetryTrace(trace)
 	}
 
 	if len(state.vars.msg) > 0 || err != nil {
@@ -1645,6 +1801,9 @@ func (s *RegionRequestSender) onRegionError(
 			zap.String("reason", regionErr.GetServerIsBusy().GetReason()),
 			zap.Stringer("ctx", ctx),
 		)
+		if ctx != nil && ctx.Store != nil {
+			ctx.Store.onServerIsBusy()
+		}
 		if ctx != nil && ctx.Store != nil && ctx.Store.storeType.IsTiFlashRelatedType() {
 			err = bo.Backoff(retry.BoTiFlashServerBusy, errors.Errorf("server is busy, ctx: %v", ctx))
 		} else {
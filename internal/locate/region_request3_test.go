@@ -36,7 +36,9 @@ package locate
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -51,6 +53,7 @@ import (
 	"github.com/pingcap/log"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/suite"
+	"github.com/tikv/client-go/v2/config"
 	"github.com/tikv/client-go/v2/config/retry"
 	tikverr "github.com/tikv/client-go/v2/error"
 	"github.com/tikv/client-go/v2/internal/apicodec"
@@ -121,6 +124,246 @@ func (s *testRegionRequestToThreeStoresSuite) TestStoreTokenLimit() {
 	kv.StoreLimit.Store(oldStoreLimit)
 }
 
+func (s *testRegionRequestToThreeStoresSuite) TestAdaptiveStoreLimit() {
+	store := newStore(1, "store1", "", "", tikvrpc.TiKV, resolved, nil)
+	l := newStoreConcurrencyLimiter(store, config.AdaptiveStoreLimit{
+		MinLimit:         2,
+		MaxLimit:         4,
+		LatencyTolerance: 2,
+		MaxQueueSize:     3,
+		MaxQueueWait:     50 * time.Millisecond,
+	})
+	ctx := context.Background()
+	s.Nil(l.acquire(ctx, "a"))
+	s.Nil(l.acquire(ctx, "a"))
+	// The request waiting for a slot times out.
+	err := l.acquire(ctx, "a")
+	_, ok := errors.Cause(err).(*tikverr.ErrTokenLimit)
+	s.True(ok)
+
+	// The released slots are granted to the resource groups in turn.
+	l.mu.cfg.MaxQueueWait = 10 * time.Second
+	var granted []string
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	enqueue := func(group, name string) {
+		l.mu.Lock()
+		queued := l.mu.queued
+		l.mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Nil(l.acquire(ctx, group))
+			mu.Lock()
+			granted = append(granted, name)
+			mu.Unlock()
+		}()
+		s.Eventually(func() bool {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			return l.mu.queued == queued+1
+		}, time.Second, time.Millisecond)
+	}
+	enqueue("a", "a1")
+	enqueue("a", "a2")
+	enqueue("b", "b1")
+	// The queue is full.
+	err = l.acquire(ctx, "c")
+	_, ok = errors.Cause(err).(*tikverr.ErrTokenLimit)
+	s.True(ok)
+	for i := 0; i < 3; i++ {
+		l.release(time.Millisecond, storeLimitFailed)
+		s.Eventually(func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(granted) == i+1
+		}, time.Second, time.Millisecond)
+	}
+	wg.Wait()
+	s.Equal([]string{"a1", "b1", "a2"}, granted)
+	s.Equal(int64(2), l.mu.inflight)
+	l.release(time.Millisecond, storeLimitFailed)
+	l.release(time.Millisecond, storeLimitFailed)
+
+	// The limit grows when the requests are answered in time.
+	for i := 0; i < 20; i++ {
+		s.Nil(l.acquire(ctx, "a"))
+		s.Nil(l.acquire(ctx, "a"))
+		l.release(time.Millisecond, storeLimitOK)
+		l.release(time.Millisecond, storeLimitOK)
+	}
+	s.Equal(4.0, l.mu.limit)
+	// The limit is cut when the store is busy or slow.
+	l.onServerBusy()
+	s.Equal(2.0, l.mu.limit)
+	s.Nil(l.acquire(ctx, "a"))
+	l.mu.lastDecrease = time.Time{}
+	l.mu.limit = 4
+	l.release(10*time.Millisecond, storeLimitOK)
+	s.Equal(3.6, l.mu.limit)
+	s.Nil(l.acquire(ctx, "a"))
+	l.mu.lastDecrease = time.Time{}
+	l.mu.limit = 4
+	l.release(time.Second, storeLimitTimeout)
+	s.Equal(2.0, l.mu.limit)
+
+	// The requests are limited by the adaptive limiter if it's enabled.
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.AdaptiveStoreLimit.Enable = true
+	})()
+	oldStoreLimit := kv.StoreLimit.Load()
+	kv.StoreLimit.Store(500)
+	defer kv.StoreLimit.Store(oldStoreLimit)
+	leaderStore, _ := s.loadAndGetLeaderStore()
+	leaderStore.tokenCount.Store(500)
+	defer leaderStore.tokenCount.Store(0)
+	req := tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("a")}, kvrpcpb.Context{})
+	region, err := s.cache.LocateRegionByID(s.bo, s.regionID)
+	s.Nil(err)
+	resp, _, err := s.regionRequestSender.SendReq(s.bo, req, region.Region, time.Second)
+	s.Nil(err)
+	s.NotNil(resp)
+	limiter := leaderStore.concurrencyLimiter.Load()
+	s.NotNil(limiter)
+	s.Equal(int64(0), limiter.mu.inflight)
+
+	// ServerIsBusy cuts the limit even if the store doesn't estimate the wait time.
+	limiter.mu.Lock()
+	limiter.mu.limit = float64(limiter.mu.cfg.MinLimit * 4)
+	limiter.mu.lastDecrease = time.Time{}
+	limiter.mu.Unlock()
+	busy := true
+	s.regionRequestSender.client = &fnClient{fn: func(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+		if busy {
+			busy = false
+			return &tikvrpc.Response{Resp: &kvrpcpb.GetResponse{RegionError: &errorpb.Error{ServerIsBusy: &errorpb.ServerIsBusy{}}}}, nil
+		}
+		return &tikvrpc.Response{Resp: &kvrpcpb.GetResponse{}}, nil
+	}}
+	_, _, err = s.regionRequestSender.SendReq(retry.NewBackofferWithVars(context.Background(), 10000, nil), req, region.Region, time.Second)
+	s.Nil(err)
+	limiter.mu.Lock()
+	s.Less(limiter.mu.limit, float64(limiter.mu.cfg.MinLimit*4))
+	limiter.mu.Unlock()
+
+	// The limiter follows the changes of the config.
+	defer config.UpdateGlobal(func(conf *config.Config) {
+		conf.TiKVClient.AdaptiveStoreLimit.MinLimit = 100
+		conf.TiKVClient.AdaptiveStoreLimit.MaxLimit = 200
+		conf.TiKVClient.AdaptiveStoreLimit.MaxQueueSize = 1
+	})()
+	_, _, err = s.regionRequestSender.SendReq(s.bo, req, region.Region, time.Second)
+	s.Nil(err)
+	s.Equal(limiter, leaderStore.concurrencyLimiter.Load())
+	limiter.mu.Lock()
+	s.Equal(100.0, limiter.mu.limit)
+	s.Equal(int64(1), limiter.mu.cfg.MaxQueueSize)
+	limiter.mu.Unlock()
+}
+
+func (s *testRegionRequestToThreeStoresSuite) TestDeadlineBudget() {
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	var timeouts []time.Duration
+	s.regionRequestSender.client = &fnClient{fn: func(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (response *tikvrpc.Response, err error) {
+		timeouts = append(timeouts, timeout)
+		s.LessOrEqual(time.Duration(req.Context.MaxExecutionDurationMs)*time.Millisecond, timeout)
+		// Simulate that the store is stuck until the request times out.
+		time.Sleep(timeout)
+		return nil, errors.New("send failed")
+	}}
+
+	req := tikvrpc.NewRequest(tikvrpc.CmdRawPut, &kvrpcpb.RawPutRequest{
+		Key:   []byte("key"),
+		Value: []byte("value"),
+	})
+	bo := retry.NewBackofferWithVars(ctx, 100000, nil)
+	loc, err := s.cache.LocateKey(s.bo, []byte("key"))
+	s.Nil(err)
+	_, _, err = s.regionRequestSender.SendReq(bo, req, loc.Region, 10*time.Second)
+	s.True(time.Now().Before(deadline.Add(100 * time.Millisecond)))
+
+	var budgetErr *tikverr.ErrDeadlineBudgetExhausted
+	s.True(errors.As(err, &budgetErr), "%v", err)
+	s.True(errors.Is(err, context.DeadlineExceeded))
+	s.Equal(deadline, budgetErr.Deadline)
+	// The timeout of the attempt is shrunk to the remaining budget.
+	s.Len(timeouts, 1)
+	s.LessOrEqual(timeouts[0], 300*time.Millisecond)
+	s.Len(budgetErr.Attempts, len(timeouts))
+	for _, attempt := range budgetErr.Attempts {
+		s.NotEmpty(attempt.Addr)
+		s.Equal("send failed", attempt.RPCError)
+	}
+	// The attempts of the budget error are the ones of the retry trace.
+	var traceErr *tikverr.ErrWithRetryTrace
+	s.True(errors.As(err, &traceErr))
+	s.Equal(traceErr.Trace.Attempts, budgetErr.Attempts)
+}
+
+func (s *testRegionRequestToThreeStoresSuite) TestRetryTrace() {
+	var (
+		addrs     []string
+		succeeded bool
+	)
+	s.regionRequestSender.client = &fnClient{fn: func(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (response *tikvrpc.Response, err error) {
+		if succeeded {
+			return &tikvrpc.Response{Resp: &kvrpcpb.RawPutResponse{}}, nil
+		}
+		addrs = append(addrs, addr)
+		if len(addrs) == 1 {
+			return &tikvrpc.Response{Resp: &kvrpcpb.RawPutResponse{
+				RegionError: &errorpb.Error{MaxTimestampNotSynced: &errorpb.MaxTimestampNotSynced{}},
+			}}, nil
+		}
+		return nil, errors.New("send failed")
+	}}
+	s.regionRequestSender.Stats = NewRegionRequestRuntimeStats()
+
+	req := tikvrpc.NewRequest(tikvrpc.CmdRawPut, &kvrpcpb.RawPutRequest{
+		Key:   []byte("key"),
+		Value: []byte("value"),
+	})
+	// The backoff after the second attempt exceeds the max sleep.
+	bo := retry.NewBackofferWithVars(context.Background(), 1, nil)
+	loc, err := s.cache.LocateKey(s.bo, []byte("key"))
+	s.Nil(err)
+	// The successful requests sent with the same backoffer before don't take the
+	// room of the trace.
+	succeeded = true
+	for i := 0; i < 100; i++ {
+		_, _, err = s.regionRequestSender.SendReq(bo, req, loc.Region, time.Second)
+		s.Nil(err)
+	}
+	succeeded = false
+	_, _, err = s.regionRequestSender.SendReq(bo, req, loc.Region, time.Second)
+	s.NotNil(err)
+
+	var traceErr *tikverr.ErrWithRetryTrace
+	s.True(errors.As(err, &traceErr))
+	attempts := traceErr.Trace.Attempts
+	s.Len(attempts, len(addrs))
+	for i, attempt := range attempts {
+		s.Equal(addrs[i], attempt.Addr)
+		s.Equal(loc.Region.GetID(), attempt.RegionID)
+		s.NotZero(attempt.StoreID)
+		s.NotEmpty(attempt.RPCContext)
+	}
+	s.Equal("leader", attempts[0].Replica)
+	s.Contains(attempts[0].RegionError, "max_timestamp_not_synced")
+	s.Equal([]string{retry.BoMaxTsNotSynced.String()}, attempts[0].BackoffType)
+	s.Positive(attempts[0].BackoffSleep)
+	s.Equal("send failed", attempts[1].RPCError)
+
+	// The trace is also recorded in the runtime stats, which can be rendered as JSON.
+	s.Equal([]*tikverr.RetryTrace{traceErr.Trace}, s.regionRequestSender.Stats.RetryTraces)
+	var traces []map[string]interface{}
+	s.Nil(json.Unmarshal([]byte(s.regionRequestSender.Stats.RetryTracesJSON()), &traces))
+	s.Len(traces, 1)
+	s.Len(traces[0]["attempts"], len(addrs))
+}
+
 func (s *testRegionRequestToThreeStoresSuite) TestSwitchPeerWhenNoLeader() {
 	var leaderAddr string
 	s.regionRequestSender.client = &fnClient{fn: func(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (response *tikvrpc.Response, err error) {
@@ -207,6 +450,40 @@ func (s *testRegionRequestToThreeStoresSuite) loadAndGetLeaderStore() (*Store, s
 	return leaderStore, leaderAddr
 }
 
+func (s *testRegionRequestToThreeStoresSuite) TestStoreCircuitBreaker() {
+	defer client.ChangeStoreCircuitBreakerSettings(func(settings *client.StoreCircuitBreakerSettings) {
+		settings.ErrorRateThresholdPct = 0
+		settings.MinQPSForOpen = 10
+	})
+	client.ChangeStoreCircuitBreakerSettings(func(settings *client.StoreCircuitBreakerSettings) {
+		settings.ErrorRateThresholdPct = 50
+		settings.MinQPSForOpen = 0
+	})
+	_, leaderAddr := s.loadAndGetLeaderStore()
+	// Trip the circuit breaker of the leader by a failed request.
+	rpcClient := client.NewRPCClient()
+	defer rpcClient.Close()
+	req := tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("a")})
+	_, err := rpcClient.SendRequest(context.Background(), leaderAddr, req, 100*time.Millisecond)
+	s.NotNil(err)
+	s.True(client.IsStoreCircuitBreakerOpen(leaderAddr))
+
+	// The leader is skipped while the circuit breaker is open.
+	var addrs []string
+	s.regionRequestSender.client = &fnClient{fn: func(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+		addrs = append(addrs, addr)
+		return &tikvrpc.Response{Resp: &kvrpcpb.GetResponse{Value: []byte("value")}}, nil
+	}}
+	loc, err := s.cache.LocateKey(s.bo, []byte("a"))
+	s.Nil(err)
+	req = tikvrpc.NewReplicaReadRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("a")}, kv.ReplicaReadLeader, nil)
+	resp, _, err := s.regionRequestSender.SendReq(s.bo, req, loc.Region, time.Second)
+	s.Nil(err)
+	s.Equal([]byte("value"), resp.Resp.(*kvrpcpb.GetResponse).Value)
+	s.Len(addrs, 1)
+	s.NotEqual(leaderAddr, addrs[0])
+}
+
 func (s *testRegionRequestToThreeStoresSuite) TestForwarding() {
 	sender := NewRegionRequestSender(s.cache, s.regionRequestSender.client, oracle.NoopReadTSValidator{})
 	sender.regionCache.enableForwarding = true
@@ -1202,7 +1479,9 @@ func (s *testRegionRequestToThreeStoresSuite) TestSendReqFirstTimeout() {
 	bo = retry.NewBackoffer(context.Background(), 1000)
 	resp, _, _, err := s.regionRequestSender.SendReqCtx(bo, req, loc.Region, time.Millisecond, tikvrpc.TiKV)
 	s.Nil(resp)
-	s.Equal(context.DeadlineExceeded, err)
+	s.ErrorIs(err, context.DeadlineExceeded)
+	var traceErr *tikverr.ErrWithRetryTrace
+	s.ErrorAs(err, &traceErr)
 	backoffTimes := bo.GetBackoffTimes()
 	s.True(backoffTimes["tikvRPC"] > 0) // write request timeout won't do fast retry, so backoff times should be more than 0.
 }
@@ -1639,3 +1918,326 @@ func (s *testRegionRequestToThreeStoresSuite) TestTiKVRecoveredFromDown() {
 	}
 	s.Require().Fail("should access recovered peer after region reloading within RegionCacheTTL")
 }
+
+func (s *testRegionRequestToThreeStoresSuite) TestHedgedRead() {
+	_, leaderAddr := s.loadAndGetLeaderStore()
+	var (
+		leaderDelay      atomic.Int64
+		followerBusy     atomic.Bool
+		leaderCancelled  = make(chan struct{}, 10)
+		followerReplicas sync.Map
+		callerReq        atomic.Pointer[tikvrpc.Request]
+		callerReqSent    atomic.Bool
+	)
+	mockClient := &fnClient{fn: func(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+		if req == callerReq.Load() {
+			callerReqSent.Store(true)
+		}
+		if addr == leaderAddr {
+			select {
+			case <-time.After(time.Duration(leaderDelay.Load())):
+				return &tikvrpc.Response{Resp: &kvrpcpb.GetResponse{Value: []byte("leader")}}, nil
+			case <-ctx.Done():
+				leaderCancelled <- struct{}{}
+				return nil, ctx.Err()
+			}
+		}
+		followerReplicas.Store(addr, req.ReplicaRead)
+		if followerBusy.Load() {
+			return &tikvrpc.Response{Resp: &kvrpcpb.GetResponse{RegionError: &errorpb.Error{ServerIsBusy: &errorpb.ServerIsBusy{}}}}, nil
+		}
+		return &tikvrpc.Response{Resp: &kvrpcpb.GetResponse{Value: []byte("follower")}}, nil
+	}}
+	loc, err := s.cache.LocateKey(s.bo, []byte("a"))
+	s.Nil(err)
+	send := func(tp tikvrpc.CmdType, policy *HedgePolicy) (*RegionRequestRuntimeStats, *RPCContext, string) {
+		sender := NewRegionRequestSender(s.cache, mockClient, oracle.NoopReadTSValidator{})
+		sender.Stats = NewRegionRequestRuntimeStats()
+		sender.SetHedgePolicy(policy)
+		var req *tikvrpc.Request
+		if tp == tikvrpc.CmdGet {
+			req = tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("a")}, kvrpcpb.Context{})
+		} else {
+			req = tikvrpc.NewRequest(tp, &kvrpcpb.PrewriteRequest{}, kvrpcpb.Context{})
+		}
+		callerReq.Store(req)
+		resp, rpcCtx, _, err := sender.SendReqCtx(retry.NewBackoffer(context.Background(), 1000), req, loc.Region, time.Second, tikvrpc.TiKV)
+		s.Nil(err)
+		s.False(req.ReplicaRead)
+		if getResp, ok := resp.Resp.(*kvrpcpb.GetResponse); ok {
+			return sender.Stats, rpcCtx, string(getResp.Value)
+		}
+		return sender.Stats, rpcCtx, ""
+	}
+	policy := &HedgePolicy{Delay: 10 * time.Millisecond}
+
+	// The leader is slow, the hedged request to a follower wins and the leader is cancelled.
+	leaderDelay.Store(int64(time.Minute))
+	stats, rpcCtx, value := send(tikvrpc.CmdGet, policy)
+	s.Equal("follower", value)
+	s.NotEqual(leaderAddr, rpcCtx.Addr)
+	s.Equal(uint32(1), stats.HedgeCount)
+	s.Equal(uint32(1), stats.HedgeWinCount)
+	s.Contains(stats.String(), "hedge:{num:1, win:1}")
+	replicaRead, ok := followerReplicas.Load(rpcCtx.Addr)
+	s.True(ok)
+	s.True(replicaRead.(bool))
+	select {
+	case <-leaderCancelled:
+	case <-time.After(time.Second):
+		s.Fail("the leader request is not cancelled")
+	}
+	// Neither of the hedged requests is the caller's one, which may be reused after returning.
+	s.False(callerReqSent.Load())
+
+	// The hedged request fails, the answer of the leader is taken.
+	leaderDelay.Store(int64(50 * time.Millisecond))
+	followerBusy.Store(true)
+	stats, rpcCtx, value = send(tikvrpc.CmdGet, policy)
+	s.Equal("leader", value)
+	s.Equal(leaderAddr, rpcCtx.Addr)
+	s.Equal(uint32(1), stats.HedgeCount)
+	s.Equal(uint32(0), stats.HedgeWinCount)
+	followerBusy.Store(false)
+
+	// The leader answers in time, no hedged request is sent.
+	leaderDelay.Store(0)
+	stats, _, value = send(tikvrpc.CmdGet, &HedgePolicy{Delay: time.Second})
+	s.Equal("leader", value)
+	s.Equal(uint32(0), stats.HedgeCount)
+
+	// Hedging is disabled or the request is not a read request.
+	leaderDelay.Store(int64(50 * time.Millisecond))
+	stats, _, value = send(tikvrpc.CmdGet, nil)
+	s.Equal("leader", value)
+	s.Equal(uint32(0), stats.HedgeCount)
+	stats, _, _ = send(tikvrpc.CmdPrewrite, policy)
+	s.Equal(uint32(0), stats.HedgeCount)
+
+	merged := NewRegionRequestRuntimeStats()
+	merged.Merge(&RegionRequestRuntimeStats{HedgeCount: 2, HedgeWinCount: 1})
+	merged.Merge(merged.Clone())
+	s.Equal(uint32(4), merged.HedgeCount)
+	s.Equal(uint32(2), merged.HedgeWinCount)
+}
+
+func (s *testRegionRequestToThreeStoresSuite) TestHedgePolicyDelay() {
+	leaderStore, _ := s.loadAndGetLeaderStore()
+	policy := &HedgePolicy{Delay: 10 * time.Millisecond, LatencyPercentile: 0.9}
+	slowScore := &leaderStore.healthStatus.clientSideSlowScore
+	slowScore.resetSlowScore()
+	// The store latency is not measured yet.
+	s.Equal(10*time.Millisecond, policy.delay(leaderStore))
+	for i := 1; i <= 10; i++ {
+		leaderStore.healthStatus.recordLatency(time.Duration(i) * 20 * time.Millisecond)
+	}
+	s.Equal(180*time.Millisecond, policy.delay(leaderStore))
+	policy.LatencyPercentile = 0.5
+	s.Equal(100*time.Millisecond, policy.delay(leaderStore))
+	// The latency samples don't affect the slow score.
+	slowScore.updateSlowScore()
+	s.Equal(uint64(slowScoreInitVal), slowScore.getSlowScore())
+	// The delay is not less than the configured one.
+	policy.Delay = time.Second
+	s.Equal(time.Second, policy.delay(leaderStore))
+	policy.LatencyPercentile = 0
+	policy.Delay = 10 * time.Millisecond
+	s.Equal(10*time.Millisecond, policy.delay(leaderStore))
+	slowScore.resetSlowScore()
+}
+
+func (s *testRegionRequestToThreeStoresSuite) TestReplicaSelectionStrategy() {
+	leaderStore, leaderAddr := s.loadAndGetLeaderStore()
+	var (
+		reqAddrs   []string
+		busyAddrs  = make(map[string]bool)
+		calls      [][]ReplicaCandidate
+		chooseFunc func([]ReplicaCandidate) int
+	)
+	mockClient := &fnClient{fn: func(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+		reqAddrs = append(reqAddrs, addr)
+		if addr != leaderAddr && !req.ReplicaRead && !req.StaleRead {
+			return &tikvrpc.Response{Resp: &kvrpcpb.GetResponse{RegionError: &errorpb.Error{NotLeader: &errorpb.NotLeader{}}}}, nil
+		}
+		if busyAddrs[addr] {
+			return &tikvrpc.Response{Resp: &kvrpcpb.GetResponse{RegionError: &errorpb.Error{ServerIsBusy: &errorpb.ServerIsBusy{}}}}, nil
+		}
+		return &tikvrpc.Response{Resp: &kvrpcpb.GetResponse{Value: []byte(addr)}}, nil
+	}}
+	strategy := ReplicaSelectionStrategyFunc(func(req *tikvrpc.Request, candidates []ReplicaCandidate) int {
+		calls = append(calls, candidates)
+		return chooseFunc(candidates)
+	})
+	followerOf := func(candidates []ReplicaCandidate) int {
+		for i, c := range candidates {
+			if !c.IsLeader {
+				return i
+			}
+		}
+		return -1
+	}
+	loc, err := s.cache.LocateKey(s.bo, []byte("a"))
+	s.Nil(err)
+	send := func(req *tikvrpc.Request) (*tikvrpc.Response, *RPCContext) {
+		reqAddrs, calls = nil, nil
+		sender := NewRegionRequestSender(s.cache, mockClient, oracle.NoopReadTSValidator{})
+		resp, rpcCtx, _, err := sender.SendReqCtx(retry.NewBackoffer(context.Background(), 1000), req, loc.Region, time.Second, tikvrpc.TiKV, WithReplicaSelectionStrategy(strategy))
+		s.Nil(err)
+		return resp, rpcCtx
+	}
+	newGetReq := func() *tikvrpc.Request {
+		return tikvrpc.NewRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("a")}, kvrpcpb.Context{})
+	}
+
+	// The strategy routes a leader read to a follower.
+	chooseFunc = followerOf
+	req := newGetReq()
+	resp, rpcCtx := send(req)
+	s.NotEqual(leaderAddr, rpcCtx.Addr)
+	s.Equal(rpcCtx.Addr, string(resp.Resp.(*kvrpcpb.GetResponse).Value))
+	s.True(req.ReplicaRead)
+	s.Len(calls, 1)
+	s.Len(calls[0], 3)
+	leaderCandidates := 0
+	for _, c := range calls[0] {
+		if c.IsLeader {
+			leaderCandidates++
+			s.Equal(leaderStore.StoreID(), c.StoreID)
+			s.Equal(leaderAddr, c.Addr)
+		}
+		s.Equal(0, c.Attempts)
+		s.NotZero(c.PeerID)
+	}
+	s.Equal(1, leaderCandidates)
+
+	// The retry of a failed request is decided by the strategy with the untried replicas.
+	busyAddrs[calls[0][followerOf(calls[0])].Addr] = true
+	resp, rpcCtx = send(newGetReq())
+	s.Len(calls, 2)
+	s.Len(calls[1], 2)
+	s.Len(reqAddrs, 2)
+	s.NotEqual(reqAddrs[0], rpcCtx.Addr)
+	s.NotEqual(leaderAddr, rpcCtx.Addr)
+	s.Equal(rpcCtx.Addr, string(resp.Resp.(*kvrpcpb.GetResponse).Value))
+	busyAddrs = make(map[string]bool)
+
+	// The built-in selection is used if the strategy doesn't choose any one.
+	chooseFunc = func([]ReplicaCandidate) int { return -1 }
+	req = newGetReq()
+	_, rpcCtx = send(req)
+	s.Equal(leaderAddr, rpcCtx.Addr)
+	s.False(req.ReplicaRead)
+	s.Len(calls, 1)
+
+	// The strategy is not used by write requests.
+	chooseFunc = followerOf
+	_, rpcCtx = send(tikvrpc.NewRequest(tikvrpc.CmdPrewrite, &kvrpcpb.PrewriteRequest{}, kvrpcpb.Context{}))
+	s.Equal(leaderAddr, rpcCtx.Addr)
+	s.Empty(calls)
+}
+
+func (s *testRegionRequestToThreeStoresSuite) TestLocalityPolicy() {
+	leaderStore, leaderAddr := s.loadAndGetLeaderStore()
+	var (
+		localAddr    string
+		reqAddrs     []string
+		notReadyAddr string
+	)
+	for i, storeID := range s.storeIDs {
+		store, exists := s.cache.stores.get(storeID)
+		s.True(exists)
+		zone := fmt.Sprintf("z%d", i)
+		if storeID != leaderStore.StoreID() && localAddr == "" {
+			zone, localAddr = "local", store.GetAddr()
+		}
+		store.labels = []*metapb.StoreLabel{{Key: DefaultZoneLabelKey, Value: zone}}
+	}
+	mockClient := &fnClient{fn: func(ctx context.Context, addr string, req *tikvrpc.Request, timeout time.Duration) (*tikvrpc.Response, error) {
+		reqAddrs = append(reqAddrs, addr)
+		if addr != leaderAddr && !req.ReplicaRead && !req.StaleRead {
+			return &tikvrpc.Response{Resp: &kvrpcpb.GetResponse{RegionError: &errorpb.Error{NotLeader: &errorpb.NotLeader{}}}}, nil
+		}
+		if addr == notReadyAddr && req.StaleRead {
+			return &tikvrpc.Response{Resp: &kvrpcpb.GetResponse{RegionError: &errorpb.Error{DataIsNotReady: &errorpb.DataIsNotReady{}}}}, nil
+		}
+		return &tikvrpc.Response{Resp: &kvrpcpb.GetResponse{Value: []byte(addr)}}, nil
+	}}
+	loc, err := s.cache.LocateKey(s.bo, []byte("a"))
+	s.Nil(err)
+	send := func(req *tikvrpc.Request) *RPCContext {
+		reqAddrs = nil
+		sender := NewRegionRequestSender(s.cache, mockClient, oracle.NoopReadTSValidator{})
+		resp, rpcCtx, _, err := sender.SendReqCtx(retry.NewBackoffer(context.Background(), 1000), req, loc.Region, time.Second, tikvrpc.TiKV)
+		s.Nil(err)
+		s.Equal(rpcCtx.Addr, string(resp.Resp.(*kvrpcpb.GetResponse).Value))
+		return rpcCtx
+	}
+	newGetReq := func(replicaReadType kv.ReplicaReadType) *tikvrpc.Request {
+		req := tikvrpc.NewReplicaReadRequest(tikvrpc.CmdGet, &kvrpcpb.GetRequest{Key: []byte("a")}, replicaReadType, nil)
+		return req
+	}
+
+	s.cache.SetLocalityPolicy(&LocalityPolicy{Zone: "local"})
+	defer s.cache.SetLocalityPolicy(nil)
+	// The follower reads go to the local follower.
+	for i := 0; i < 5; i++ {
+		req := newGetReq(kv.ReplicaReadMixed)
+		s.Equal(localAddr, send(req).Addr)
+		s.True(req.ReplicaRead)
+		s.Equal(kv.AccessLocalZone, req.AccessLocation)
+	}
+	// The leader reads are not affected.
+	s.Equal(leaderAddr, send(newGetReq(kv.ReplicaReadLeader)).Addr)
+
+	// The stale read goes to the local replica first, and then to the leader if
+	// the local replica is not ready, even if the budget is unlimited.
+	req := newGetReq(kv.ReplicaReadMixed)
+	req.EnableStaleWithMixedReplicaRead()
+	s.Equal(localAddr, send(req).Addr)
+	s.True(req.StaleRead)
+	notReadyAddr = localAddr
+	req = newGetReq(kv.ReplicaReadMixed)
+	req.EnableStaleWithMixedReplicaRead()
+	s.Equal(leaderAddr, send(req).Addr)
+	s.Equal([]string{localAddr, leaderAddr}, reqAddrs)
+	notReadyAddr = ""
+
+	// Without a fresh local replica, the follower reads fall back to the leader
+	// even if the budget remains, and the leader in another zone is charged to
+	// the budget.
+	budgetUsed := func(policy *LocalityPolicy) int64 {
+		policy.budget.Lock()
+		defer policy.budget.Unlock()
+		return policy.budget.used
+	}
+	policy := &LocalityPolicy{Zone: "other", CrossZoneBytesPerSecond: math.MaxInt32}
+	s.cache.SetLocalityPolicy(policy)
+	req = newGetReq(kv.ReplicaReadFollower)
+	s.Equal(leaderAddr, send(req).Addr)
+	s.False(req.ReplicaRead)
+	s.Equal(kv.AccessCrossZone, req.AccessLocation)
+	s.Len(reqAddrs, 1)
+	used := budgetUsed(policy)
+	s.Positive(used)
+
+	// The leader fallback is charged even if the budget is exhausted.
+	policy.CrossZoneBytesPerSecond = used
+	s.True(policy.budgetExhausted())
+	req = newGetReq(kv.ReplicaReadFollower)
+	s.Equal(leaderAddr, send(req).Addr)
+	s.False(req.ReplicaRead)
+	s.Len(reqAddrs, 1)
+	s.Greater(budgetUsed(policy), used)
+
+	// If the leader is unavailable, the follower reads go across zones within
+	// the budget.
+	policy = &LocalityPolicy{Zone: "other", CrossZoneBytesPerSecond: math.MaxInt32}
+	s.cache.SetLocalityPolicy(policy)
+	atomic.StoreUint32(&leaderStore.livenessState, uint32(unreachable))
+	defer atomic.StoreUint32(&leaderStore.livenessState, uint32(reachable))
+	req = newGetReq(kv.ReplicaReadFollower)
+	s.NotEqual(leaderAddr, send(req).Addr)
+	s.True(req.ReplicaRead)
+	s.Equal(kv.AccessCrossZone, req.AccessLocation)
+	s.Positive(budgetUsed(policy))
+}
@@ -0,0 +1,125 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+// ReplicaCandidate describes a replica which a read request can be sent to.
+type ReplicaCandidate struct {
+	StoreID  uint64
+	PeerID   uint64
+	Addr     string
+	Role     metapb.PeerRole
+	IsLeader bool
+	Labels   []*metapb.StoreLabel
+	// Reachable is false if the liveness of the store is unknown yet. The
+	// unreachable stores are never candidates.
+	Reachable bool
+	// EstimatedWaitTime is the wait time of the store estimated by the
+	// feedback of TiKV.
+	EstimatedWaitTime time.Duration
+	// SlowScore is the client side slow score of the store, ranging from 1 to
+	// 100. IsSlow reports whether the store is slow in either client side or
+	// TiKV side.
+	SlowScore uint64
+	IsSlow    bool
+	// FlowsToLeader and FlowsToFollower are the counts of the requests sent to
+	// the store as the leader or a follower under the PreferLeader mode.
+	FlowsToLeader   uint64
+	FlowsToFollower uint64
+	// Attempts is the times the request has been sent to the replica.
+	Attempts int
+}
+
+// ReplicaSelectionStrategy decides the replica to send a read request to. It's
+// called on each attempt of the request with the replicas not tried yet, so
+// the retries go to the other replicas.
+type ReplicaSelectionStrategy interface {
+	// Select returns the index of the chosen candidate. The built-in selection
+	// is used if the index is out of range, e.g. -1.
+	Select(req *tikvrpc.Request, candidates []ReplicaCandidate) int
+}
+
+// ReplicaSelectionStrategyFunc is a function that implements ReplicaSelectionStrategy.
+type ReplicaSelectionStrategyFunc func(req *tikvrpc.Request, candidates []ReplicaCandidate) int
+
+// Select implements ReplicaSelectionStrategy.
+func (f ReplicaSelectionStrategyFunc) Select(req *tikvrpc.Request, candidates []ReplicaCandidate) int {
+	return f(req, candidates)
+}
+
+// WithReplicaSelectionStrategy indicates selecting the replica of read requests by the strategy.
+func WithReplicaSelectionStrategy(strategy ReplicaSelectionStrategy) StoreSelectorOption {
+	return func(op *storeSelectorOp) {
+		op.strategy = strategy
+	}
+}
+
+// nextByStrategy selects the target by the custom strategy, the target is nil
+// if there is no candidate or the strategy doesn't choose any one.
+func (s *replicaSelector) nextByStrategy(req *tikvrpc.Request) {
+	leaderIdx := s.region.getStore().workTiKVIdx
+	candidates := make([]ReplicaCandidate, 0, len(s.replicas))
+	replicas := make([]*replica, 0, len(s.replicas))
+	for i, r := range s.replicas {
+		isLeader := AccessIndex(i) == leaderIdx
+		liveness := r.store.getLivenessState()
+		if r.isEpochStale() || liveness == unreachable || r.isExhausted(1, 0) || r.isCircuitBroken() {
+			continue
+		}
+		candidates = append(candidates, ReplicaCandidate{
+			StoreID:           r.store.storeID,
+			PeerID:            r.peer.GetId(),
+			Addr:              r.store.GetAddr(),
+			Role:              r.peer.GetRole(),
+			IsLeader:          isLeader,
+			Labels:            r.store.labels,
+			Reachable:         liveness == reachable,
+			EstimatedWaitTime: r.store.EstimatedWaitTime(),
+			SlowScore:         r.store.healthStatus.clientSideSlowScore.getSlowScore(),
+			IsSlow:            r.store.healthStatus.IsSlow(),
+			FlowsToLeader:     r.store.getReplicaFlowsStats(toLeader),
+			FlowsToFollower:   r.store.getReplicaFlowsStats(toFollower),
+			Attempts:          r.attempts,
+		})
+		replicas = append(replicas, r)
+	}
+	if len(candidates) == 0 {
+		return
+	}
+	idx := s.option.strategy.Select(req, candidates)
+	if idx < 0 || idx >= len(candidates) {
+		return
+	}
+	s.target = replicas[idx]
+	s.setReadFlags(req, candidates[idx].IsLeader)
+}
+
+// setReadFlags sets the read flags of the request sent to the target chosen
+// out of the built-in selection.
+func (s *replicaSelector) setReadFlags(req *tikvrpc.Request, isLeader bool) {
+	if s.isStaleRead && s.attempts == 1 {
+		req.StaleRead = true
+		req.ReplicaRead = false
+	} else {
+		req.StaleRead = false
+		req.ReplicaRead = !isLeader
+	}
+}
@@ -106,11 +106,29 @@ func (s *replicaSelector) next(bo *retry.Backoffer, req *tikvrpc.Request) (rpcCt
 	s.attempts++
 	s.target = nil
 	s.proxy = nil
-	switch s.replicaReadType {
-	case kv.ReplicaReadLeader:
-		s.nextForReplicaReadLeader(req)
-	default:
-		s.nextForReplicaReadMixed(req)
+	if s.option.strategy != nil && s.isReadOnlyReq {
+		s.nextByStrategy(req)
+	}
+	var (
+		localityPolicy   *LocalityPolicy
+		localityDecision string
+	)
+	if s.target == nil && s.localityApplicable() {
+		if localityPolicy = s.regionCache.GetLocalityPolicy(); localityPolicy != nil {
+			localityDecision = s.nextByLocality(req, localityPolicy)
+		}
+	}
+	if s.target == nil {
+		switch s.replicaReadType {
+		case kv.ReplicaReadLeader:
+			s.nextForReplicaReadLeader(req)
+		default:
+			s.nextForReplicaReadMixed(req)
+		}
+		if s.target != nil && localityDecision != "" {
+			local := localityPolicy.isLocal(s.target.store)
+			metrics.TiKVLocalityRoutingCounter.WithLabelValues(localityDecision, localityScope(local)).Inc()
+		}
 	}
 	if s.target == nil {
 		return nil, nil
@@ -238,6 +256,7 @@ func isLeaderCandidate(leader *replica) bool {
 		leader.isExhausted(maxReplicaAttempt, maxReplicaAttemptTime) ||
 		leader.hasFlag(deadlineErrUsingConfTimeoutFlag) ||
 		leader.hasFlag(notLeaderFlag) ||
+		leader.isCircuitBroken() ||
 		leader.isEpochStale() { // check leader epoch here, if leader.epoch staled, we can try other replicas. instead of buildRPCContext failed and invalidate region then retry.
 		return false
 	}
@@ -325,7 +344,7 @@ func hasDeadlineExceededError(replicas []*replica) bool {
 }
 
 func (s *ReplicaSelectMixedStrategy) isCandidate(r *replica, isLeader bool, epochStale bool, liveness livenessState) bool {
-	if epochStale || liveness == unreachable {
+	if epochStale || liveness == unreachable || r.isCircuitBroken() {
 		// the replica is not available, skip it.
 		return false
 	}
@@ -471,6 +490,7 @@ func (s ReplicaSelectLeaderWithProxyStrategy) isCandidate(r *replica, isLeader b
 	if isLeader ||
 		r.isExhausted(1, 0) ||
 		r.store.getLivenessState() != reachable ||
+		r.isCircuitBroken() ||
 		r.isEpochStale() {
 		// check epoch here, if epoch staled, we can try other replicas. instead of buildRPCContext failed and invalidate region then retry.
 		return false
@@ -523,6 +543,7 @@ func (s *replicaSelector) onServerIsBusy(
 	var store *Store
 	if ctx != nil && ctx.Store != nil {
 		store = ctx.Store
+		store.onServerIsBusy()
 		if serverIsBusy.EstimatedWaitMs != 0 {
 			ctx.Store.updateServerLoadStats(serverIsBusy.EstimatedWaitMs)
 			if s.busyThreshold != 0 && isReadReq(req.Type) {
@@ -623,7 +644,7 @@ func (s *replicaSelector) onSendSuccess(req *tikvrpc.Request) {
 		}
 	}
 	if s.target != nil && s.target.peer.Id != s.region.GetLeaderPeerID() && req != nil && !req.StaleRead && !req.ReplicaRead {
-		s.region.switchWorkLeaderToPeer(s.target.peer)
+		s.regionCache.switchWorkLeaderToPeer(s.region, s.target.peer)
 	}
 }
 
@@ -17,6 +17,8 @@ package locate
 import (
 	"fmt"
 	"math"
+	"slices"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -28,6 +30,8 @@ const (
 	slowScoreInitTimeoutInUs = 500000   // unit: us
 	slowScoreMaxTimeoutInUs  = 30000000 // max timeout of one txn, unit: us
 	slidingWindowSize        = 10       // default size of sliding window
+	latencySampleSize        = 128      // size of the latency samples to compute the percentiles
+	latencyMinSampleCount    = 10       // min count of the latency samples to compute the percentiles
 )
 
 // CountSlidingWindow represents the statistics on a bunch of sliding windows.
@@ -65,6 +69,37 @@ func (cnt *CountSlidingWindow) Append(value uint64) (gradient float64) {
 	return gradient
 }
 
+// latencySamples keeps the latency of the recent requests in a ring buffer.
+type latencySamples struct {
+	mu      sync.Mutex
+	samples [latencySampleSize]time.Duration
+	next    int
+	count   int
+}
+
+func (l *latencySamples) record(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.samples[l.next] = d
+	l.next = (l.next + 1) % latencySampleSize
+	if l.count < latencySampleSize {
+		l.count++
+	}
+}
+
+func (l *latencySamples) percentile(p float64) time.Duration {
+	l.mu.Lock()
+	if l.count < latencyMinSampleCount {
+		l.mu.Unlock()
+		return 0
+	}
+	samples := slices.Clone(l.samples[:l.count])
+	l.mu.Unlock()
+	slices.Sort(samples)
+	idx := int(math.Ceil(p*float64(len(samples)))) - 1
+	return samples[max(0, min(idx, len(samples)-1))]
+}
+
 // SlowScoreStat represents the statistics on business of Store.
 type SlowScoreStat struct {
 	avgScore            uint64
@@ -73,6 +108,7 @@ type SlowScoreStat struct {
 	intervalUpdCount    uint64             // count of update in one counting interval.
 	tsCntSlidingWindow  CountSlidingWindow // sliding window on timecost
 	updCntSlidingWindow CountSlidingWindow // sliding window on update count
+	latency             latencySamples     // recent latency samples, which don't affect the slow score
 }
 
 func (ss *SlowScoreStat) getSlowScore() uint64 {
@@ -149,6 +185,18 @@ func (ss *SlowScoreStat) recordSlowScoreStat(timecost time.Duration) {
 	atomic.AddUint64(&ss.intervalTimecost, curTimecost)
 }
 
+// recordLatency records the timecost of a request as a latency sample. Unlike
+// recordSlowScoreStat, it doesn't affect the slow score.
+func (ss *SlowScoreStat) recordLatency(timecost time.Duration) {
+	ss.latency.record(timecost)
+}
+
+// getLatencyPercentile returns the p-th percentile (0 < p <= 1) of the recent
+// latency samples, 0 means there are not enough samples yet.
+func (ss *SlowScoreStat) getLatencyPercentile(p float64) time.Duration {
+	return ss.latency.percentile(p)
+}
+
 func (ss *SlowScoreStat) markAlreadySlow() {
 	atomic.StoreUint64(&ss.avgScore, slowScoreMax)
 }
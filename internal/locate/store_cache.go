@@ -68,10 +68,11 @@ type storeCache interface {
 	markTiflashComputeStoresNeedReload()
 	markStoreNeedCheck(store *Store)
 	getCheckStoreEvents() <-chan struct{}
+	onStoreStateChanged(store *Store)
 }
 
-func newStoreCache(pdClient pd.Client) *storeCacheImpl {
-	c := &storeCacheImpl{pdClient: pdClient.WithCallerComponent("store-cache")}
+func newStoreCache(pdClient pd.Client, events *regionEventHub) *storeCacheImpl {
+	c := &storeCacheImpl{pdClient: pdClient.WithCallerComponent("store-cache"), events: events}
 	c.notifyCheckCh = make(chan struct{}, 1)
 	c.storeMu.stores = make(map[uint64]*Store)
 	c.tiflashComputeStoreMu.needReload = true
@@ -81,6 +82,7 @@ func newStoreCache(pdClient pd.Client) *storeCacheImpl {
 
 type storeCacheImpl struct {
 	pdClient pd.Client
+	events   *regionEventHub
 
 	testingKnobs struct {
 		// Replace the requestLiveness function for test purpose. Note that in unit tests, if this is not set,
@@ -192,7 +194,11 @@ func (c *storeCacheImpl) markTiflashComputeStoresNeedReload() {
 }
 
 func (c *storeCacheImpl) markStoreNeedCheck(store *Store) {
+	wasResolved := store.getResolveState() == resolved
 	if store.changeResolveStateTo(resolved, needCheck) {
+		if wasResolved {
+			c.onStoreStateChanged(store)
+		}
 		select {
 		case c.notifyCheckCh <- struct{}{}:
 		default:
@@ -204,6 +210,10 @@ func (c *storeCacheImpl) getCheckStoreEvents() <-chan struct{} {
 	return c.notifyCheckCh
 }
 
+func (c *storeCacheImpl) onStoreStateChanged(store *Store) {
+	c.events.onStoreStateChanged(store)
+}
+
 // Store contains a kv process's address.
 type Store struct {
 	addr         string               // loaded store address
@@ -217,6 +227,8 @@ type Store struct {
 	storeType    tikvrpc.EndpointType // type of the store
 	tokenCount   atomic.Int64         // used store token count
 
+	concurrencyLimiter atomic.Pointer[storeConcurrencyLimiter]
+
 	loadStats atomic.Pointer[storeLoadStats]
 
 	// whether the store is unreachable due to some reason, therefore requests to the store needs to be
@@ -458,6 +470,7 @@ func (s *Store) reResolve(c storeCache, scheduler *bgRunner) (bool, error) {
 			zap.Uint64("store", s.storeID), zap.String("addr", s.addr))
 		atomic.AddUint32(&s.epoch, 1)
 		s.setResolveState(tombstone)
+		c.onStoreStateChanged(s)
 		metrics.RegionCacheCounterWithInvalidateStoreRegionsOK.Inc()
 		return false, nil
 	}
@@ -484,6 +497,8 @@ func (s *Store) reResolve(c storeCache, scheduler *bgRunner) (bool, error) {
 		}
 		c.put(newStore)
 		s.setResolveState(deleted)
+		c.onStoreStateChanged(s)
+		c.onStoreStateChanged(newStore)
 		logutil.BgLogger().Info("store address or labels changed, add new store and mark old store deleted",
 			zap.Uint64("store", s.storeID),
 			zap.String("old-addr", s.addr),
@@ -494,7 +509,9 @@ func (s *Store) reResolve(c storeCache, scheduler *bgRunner) (bool, error) {
 			zap.String("new-liveness", newStore.getLivenessState().String()))
 		return false, nil
 	}
-	s.changeResolveStateTo(needCheck, resolved)
+	if s.getResolveState() == needCheck && s.changeResolveStateTo(needCheck, resolved) {
+		c.onStoreStateChanged(s)
+	}
 	return true, nil
 }
 
@@ -608,6 +625,7 @@ func (s *Store) requestLivenessAndStartHealthCheckLoopIfNeeded(bo *retry.Backoff
 	// It may be already started by another thread.
 	if atomic.CompareAndSwapUint32(&s.livenessState, uint32(reachable), uint32(liveness)) {
 		s.unreachableSince = time.Now()
+		c.onStoreStateChanged(s)
 		reResolveInterval := storeReResolveInterval
 		if val, err := util.EvalFailpoint("injectReResolveInterval"); err == nil {
 			if dur, err := time.ParseDuration(val.(string)); err == nil {
@@ -643,7 +661,9 @@ func startHealthCheckLoop(scheduler *bgRunner, c storeCache, s *Store, liveness
 		}
 
 		liveness = requestLiveness(ctx, s, c)
-		atomic.StoreUint32(&s.livenessState, uint32(liveness))
+		if old := atomic.SwapUint32(&s.livenessState, uint32(liveness)); old != uint32(liveness) {
+			c.onStoreStateChanged(s)
+		}
 		if liveness == reachable {
 			logutil.BgLogger().Info("[health check] store became reachable", zap.Uint64("storeID", s.storeID))
 			return true
@@ -907,6 +927,11 @@ func (s *StoreHealthStatus) recordClientSideSlowScoreStat(timecost time.Duration
 	s.updateSlowFlag()
 }
 
+// recordLatency records timecost of a request as a latency sample of the store.
+func (s *StoreHealthStatus) recordLatency(timecost time.Duration) {
+	s.clientSideSlowScore.recordLatency(timecost)
+}
+
 // markAlreadySlow marks the related store already slow.
 func (s *StoreHealthStatus) markAlreadySlow() {
 	s.clientSideSlowScore.markAlreadySlow()
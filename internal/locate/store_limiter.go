@@ -0,0 +1,295 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/metrics"
+)
+
+const (
+	// storeLimitMinLatencyWindow is the window of the minimum latency, the
+	// baseline of the latency is refreshed after it.
+	storeLimitMinLatencyWindow = 10 * time.Second
+	// storeLimitDecreaseInterval avoids decreasing the limit repeatedly for
+	// the requests sent at the same time.
+	storeLimitDecreaseInterval = 100 * time.Millisecond
+	storeLimitBusyFactor       = 0.5
+	storeLimitSlowFactor       = 0.9
+)
+
+// storeLimitResult is the result of a request sent with a slot of the
+// concurrency limiter.
+type storeLimitResult int
+
+const (
+	// storeLimitFailed means the request failed without a hint of the load of
+	// the store, the limit is kept.
+	storeLimitFailed storeLimitResult = iota
+	// storeLimitOK means the request is answered, the limit is adapted by the
+	// latency.
+	storeLimitOK
+	// storeLimitTimeout means the request timed out, the limit is cut like
+	// ServerIsBusy.
+	storeLimitTimeout
+)
+
+// storeConcurrencyLimiter limits the concurrency of the requests sent to a
+// store by AIMD: the limit grows by one for every limit requests answered in
+// time, and it's cut when the store reports ServerIsBusy, the request times
+// out or the latency rises beyond the tolerance. It doesn't grow while the store estimates its requests
+// have to wait.
+//
+// The requests exceeding the limit wait in queues by resource group, and the
+// released slots are granted to the groups in turn, so a busy group doesn't
+// starve the others.
+type storeConcurrencyLimiter struct {
+	store *Store
+	label string
+
+	mu struct {
+		sync.Mutex
+		// cfg is updated by the config of the latest request.
+		cfg             config.AdaptiveStoreLimit
+		limit           float64
+		inflight        int64
+		minLatency      time.Duration
+		minLatencySince time.Time
+		lastDecrease    time.Time
+		queued          int64
+		queues          map[string][]*storeLimitWaiter
+		// groups are the resource groups with waiters in the order of turns.
+		groups []string
+		turn   int
+	}
+}
+
+type storeLimitWaiter struct {
+	ch chan struct{}
+	// granted is protected by the mutex of the limiter.
+	granted bool
+}
+
+func newStoreConcurrencyLimiter(store *Store, cfg config.AdaptiveStoreLimit) *storeConcurrencyLimiter {
+	l := &storeConcurrencyLimiter{
+		store: store,
+		label: strconv.FormatUint(store.storeID, 10),
+	}
+	l.mu.cfg = cfg
+	l.mu.limit = float64(cfg.MinLimit)
+	l.mu.queues = make(map[string][]*storeLimitWaiter)
+	metrics.TiKVStoreConcurrencyLimit.WithLabelValues(l.label).Set(l.mu.limit)
+	return l
+}
+
+// getConcurrencyLimiter returns the adaptive concurrency limiter of the store,
+// the limiter is created by the config when it's used for the first time, and
+// follows the changes of the config later.
+func (s *Store) getConcurrencyLimiter(cfg config.AdaptiveStoreLimit) *storeConcurrencyLimiter {
+	if l := s.concurrencyLimiter.Load(); l != nil {
+		l.updateConfig(cfg)
+		return l
+	}
+	s.concurrencyLimiter.CompareAndSwap(nil, newStoreConcurrencyLimiter(s, cfg))
+	return s.concurrencyLimiter.Load()
+}
+
+// updateConfig applies the config if it's changed. The limit is moved into the
+// new bounds and the slots freed by a larger limit are granted to the waiters.
+func (l *storeConcurrencyLimiter) updateConfig(cfg config.AdaptiveStoreLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.mu.cfg == cfg {
+		return
+	}
+	l.mu.cfg = cfg
+	l.setLimit(l.mu.limit)
+	l.grant()
+}
+
+// acquire takes a slot to send a request of the resource group. It waits
+// until a slot is released if the limit is reached, and fails if the queue is
+// full or the wait times out.
+func (l *storeConcurrencyLimiter) acquire(ctx context.Context, group string) error {
+	l.mu.Lock()
+	if l.mu.queued == 0 && float64(l.mu.inflight) < l.mu.limit {
+		l.mu.inflight++
+		l.mu.Unlock()
+		return nil
+	}
+	if l.mu.queued >= l.mu.cfg.MaxQueueSize {
+		l.mu.Unlock()
+		return l.limitError()
+	}
+	w := &storeLimitWaiter{ch: make(chan struct{})}
+	if len(l.mu.queues[group]) == 0 {
+		l.mu.groups = append(l.mu.groups, group)
+	}
+	l.mu.queues[group] = append(l.mu.queues[group], w)
+	l.mu.queued++
+	l.updateQueueDepth()
+	maxWait := l.mu.cfg.MaxQueueWait
+	l.mu.Unlock()
+
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-w.ch:
+		return nil
+	case <-timer.C:
+		err = l.limitError()
+	case <-ctx.Done():
+		err = errors.WithStack(ctx.Err())
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.granted {
+		return nil
+	}
+	l.removeWaiter(group, w)
+	return err
+}
+
+// release returns the slot taken by acquire and adapts the limit by the
+// result and the latency of the request.
+func (l *storeConcurrencyLimiter) release(latency time.Duration, result storeLimitResult) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.mu.inflight--
+	switch result {
+	case storeLimitOK:
+		l.onLatency(latency)
+	case storeLimitTimeout:
+		l.decrease(storeLimitBusyFactor)
+	}
+	l.grant()
+}
+
+// onServerBusy cuts the limit since the store rejects the requests.
+func (l *storeConcurrencyLimiter) onServerBusy() {
+	l.mu.Lock()
+	l.decrease(storeLimitBusyFactor)
+	l.mu.Unlock()
+}
+
+// onServerIsBusy cuts the concurrency limit of the store if the adaptive limiter
+// is used, no matter whether the store estimates the wait time or not.
+func (s *Store) onServerIsBusy() {
+	if l := s.concurrencyLimiter.Load(); l != nil {
+		l.onServerBusy()
+	}
+}
+
+func (l *storeConcurrencyLimiter) onLatency(latency time.Duration) {
+	now := time.Now()
+	if l.mu.minLatency == 0 || latency < l.mu.minLatency || now.Sub(l.mu.minLatencySince) > storeLimitMinLatencyWindow {
+		l.mu.minLatency = latency
+		l.mu.minLatencySince = now
+	}
+	if l.mu.cfg.LatencyTolerance > 0 && float64(latency) > float64(l.mu.minLatency)*l.mu.cfg.LatencyTolerance {
+		l.decrease(storeLimitSlowFactor)
+		return
+	}
+	// Only grow the limit if it's nearly used up and the store is not queuing.
+	if float64(l.mu.inflight+1) >= l.mu.limit/2 && l.store.EstimatedWaitTime() == 0 {
+		l.setLimit(l.mu.limit + 1/l.mu.limit)
+	}
+}
+
+func (l *storeConcurrencyLimiter) decrease(factor float64) {
+	now := time.Now()
+	if now.Sub(l.mu.lastDecrease) < storeLimitDecreaseInterval {
+		return
+	}
+	l.mu.lastDecrease = now
+	l.setLimit(l.mu.limit * factor)
+}
+
+func (l *storeConcurrencyLimiter) setLimit(limit float64) {
+	limit = max(float64(l.mu.cfg.MinLimit), min(float64(l.mu.cfg.MaxLimit), limit))
+	if int64(limit) != int64(l.mu.limit) {
+		metrics.TiKVStoreConcurrencyLimit.WithLabelValues(l.label).Set(float64(int64(limit)))
+	}
+	l.mu.limit = limit
+}
+
+// grant hands the free slots to the waiters, one resource group per turn.
+func (l *storeConcurrencyLimiter) grant() {
+	granted := false
+	for l.mu.queued > 0 && float64(l.mu.inflight) < l.mu.limit {
+		l.mu.turn %= len(l.mu.groups)
+		group := l.mu.groups[l.mu.turn]
+		queue := l.mu.queues[group]
+		w := queue[0]
+		if len(queue) == 1 {
+			delete(l.mu.queues, group)
+			l.mu.groups = append(l.mu.groups[:l.mu.turn], l.mu.groups[l.mu.turn+1:]...)
+		} else {
+			l.mu.queues[group] = queue[1:]
+			l.mu.turn++
+		}
+		w.granted = true
+		close(w.ch)
+		l.mu.inflight++
+		l.mu.queued--
+		granted = true
+	}
+	if granted {
+		l.updateQueueDepth()
+	}
+}
+
+func (l *storeConcurrencyLimiter) removeWaiter(group string, w *storeLimitWaiter) {
+	queue := l.mu.queues[group]
+	for i, waiter := range queue {
+		if waiter == w {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) > 0 {
+		l.mu.queues[group] = queue
+	} else {
+		delete(l.mu.queues, group)
+		for i, g := range l.mu.groups {
+			if g == group {
+				l.mu.groups = append(l.mu.groups[:i], l.mu.groups[i+1:]...)
+				if i < l.mu.turn {
+					l.mu.turn--
+				}
+				break
+			}
+		}
+	}
+	l.mu.queued--
+	l.updateQueueDepth()
+}
+
+func (l *storeConcurrencyLimiter) updateQueueDepth() {
+	metrics.TiKVStoreConcurrencyQueueDepth.WithLabelValues(l.label).Set(float64(l.mu.queued))
+}
+
+func (l *storeConcurrencyLimiter) limitError() error {
+	metrics.TiKVStoreLimitErrorCounter.WithLabelValues(l.store.addr, l.label).Inc()
+	return errors.WithStack(&tikverr.ErrTokenLimit{StoreID: l.store.storeID})
+}
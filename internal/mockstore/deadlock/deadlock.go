@@ -149,3 +149,23 @@ func (d *Detector) Expire(minTS uint64) {
 	}
 	d.lock.Unlock()
 }
+
+// WaitForEntry is a wait-for relationship registered in the detector.
+type WaitForEntry struct {
+	Txn        uint64
+	WaitForTxn uint64
+	KeyHash    uint64
+}
+
+// WaitForEntries returns all the registered wait-for relationships.
+func (d *Detector) WaitForEntries() []WaitForEntry {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	var entries []WaitForEntry
+	for txn, list := range d.waitForMap {
+		for _, pair := range list.txns {
+			entries = append(entries, WaitForEntry{Txn: txn, WaitForTxn: pair.txn, KeyHash: pair.keyHash})
+		}
+	}
+	return entries
+}
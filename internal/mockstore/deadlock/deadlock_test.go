@@ -56,6 +56,7 @@ func TestDeadlock(t *testing.T) {
 	// After cycle is broken, no deadlock now.
 	err = detector.Detect(3, 1, 300)
 	assert.Nil(err)
+	assert.ElementsMatch([]WaitForEntry{{1, 2, 100}, {3, 1, 300}}, detector.WaitForEntries())
 	list3 := detector.waitForMap[3]
 	assert.Len(list3.txns, 1)
 
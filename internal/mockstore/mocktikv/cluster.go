@@ -130,6 +130,23 @@ func (c *Cluster) GetAllRegions() []*Region {
 	return regions
 }
 
+// getStoreRegions returns the metas of the regions which have peers on the store.
+func (c *Cluster) getStoreRegions(storeID uint64) []*metapb.Region {
+	c.RLock()
+	defer c.RUnlock()
+
+	var regions []*metapb.Region
+	for _, r := range c.regions {
+		for _, peer := range r.Meta.Peers {
+			if peer.GetStoreId() == storeID {
+				regions = append(regions, proto.Clone(r.Meta).(*metapb.Region))
+				break
+			}
+		}
+	}
+	return regions
+}
+
 // GetStore returns a Store's meta.
 func (c *Cluster) GetStore(storeID uint64) *metapb.Store {
 	c.RLock()
@@ -512,6 +529,25 @@ func (c *Cluster) Merge(regionID1, regionID2 uint64) {
 	delete(c.regions, regionID2)
 }
 
+// PrepareFlashback marks the Region as in flashback progress. Until
+// FinishFlashback is called, the Region only accepts flashback requests.
+func (c *Cluster) PrepareFlashback(regionID, startTS uint64) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.regions[regionID].Meta.IsInFlashback = true
+	c.regions[regionID].Meta.FlashbackStartTs = startTS
+}
+
+// FinishFlashback clears the flashback progress of the Region.
+func (c *Cluster) FinishFlashback(regionID uint64) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.regions[regionID].Meta.IsInFlashback = false
+	c.regions[regionID].Meta.FlashbackStartTs = 0
+}
+
 // SplitKeys evenly splits the start, end key into "count" regions.
 // Only works for single store.
 func (c *Cluster) SplitKeys(start, end []byte, count int) {
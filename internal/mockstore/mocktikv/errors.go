@@ -51,6 +51,10 @@ type ErrLocked struct {
 	TTL         uint64
 	TxnSize     uint64
 	LockType    kvrpcpb.Op
+	MinCommitTS uint64
+
+	UseAsyncCommit bool
+	Secondaries    [][]byte
 }
 
 // Error formats the lock to a string.
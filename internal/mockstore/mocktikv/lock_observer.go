@@ -0,0 +1,169 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocktikv
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/pingcap/goleveldb/leveldb"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+)
+
+// lockObserver records the locks written after it is registered on a store.
+type lockObserver struct {
+	maxTS uint64
+	locks map[lockObserverKey]*kvrpcpb.LockInfo
+}
+
+type lockObserverKey struct {
+	key     string
+	startTS uint64
+}
+
+func lockInfoFromMvccLock(key []byte, lock *mvccLock) *kvrpcpb.LockInfo {
+	return &kvrpcpb.LockInfo{
+		PrimaryLock:     lock.primary,
+		LockVersion:     lock.startTS,
+		Key:             key,
+		LockTtl:         lock.ttl,
+		TxnSize:         lock.txnSize,
+		LockType:        lock.op,
+		LockForUpdateTs: lock.forUpdateTS,
+		MinCommitTs:     lock.minCommitTS,
+		UseAsyncCommit:  lock.useAsyncCommit,
+		Secondaries:     lock.secondaries,
+	}
+}
+
+// RegisterLockObserver implements the LockObserver interface.
+func (mvcc *MVCCLevelDB) RegisterLockObserver(storeID, maxTS uint64) error {
+	mvcc.mu.Lock()
+	defer mvcc.mu.Unlock()
+
+	if observer, ok := mvcc.lockObservers[storeID]; ok {
+		if observer.maxTS > maxTS {
+			return errors.Errorf("lock observer on store %d is registered with a greater max ts %d", storeID, observer.maxTS)
+		}
+		if observer.maxTS == maxTS {
+			return nil
+		}
+	}
+	mvcc.lockObservers[storeID] = &lockObserver{
+		maxTS: maxTS,
+		locks: make(map[lockObserverKey]*kvrpcpb.LockInfo),
+	}
+	return nil
+}
+
+// CheckLockObserver implements the LockObserver interface.
+func (mvcc *MVCCLevelDB) CheckLockObserver(storeID, maxTS uint64) ([]*kvrpcpb.LockInfo, error) {
+	mvcc.mu.RLock()
+	defer mvcc.mu.RUnlock()
+
+	observer, ok := mvcc.lockObservers[storeID]
+	if !ok || observer.maxTS != maxTS {
+		return nil, errors.Errorf("lock observer with max ts %d is not registered on store %d", maxTS, storeID)
+	}
+	locks := make([]*kvrpcpb.LockInfo, 0, len(observer.locks))
+	for _, lock := range observer.locks {
+		locks = append(locks, lock)
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		if c := bytes.Compare(locks[i].Key, locks[j].Key); c != 0 {
+			return c < 0
+		}
+		return locks[i].LockVersion < locks[j].LockVersion
+	})
+	return locks, nil
+}
+
+// RemoveLockObserver implements the LockObserver interface.
+func (mvcc *MVCCLevelDB) RemoveLockObserver(storeID, maxTS uint64) error {
+	mvcc.mu.Lock()
+	defer mvcc.mu.Unlock()
+
+	observer, ok := mvcc.lockObservers[storeID]
+	if !ok || observer.maxTS != maxTS {
+		return errors.Errorf("lock observer with max ts %d is not registered on store %d", maxTS, storeID)
+	}
+	delete(mvcc.lockObservers, storeID)
+	return nil
+}
+
+// PhysicalScanLock implements the LockObserver interface.
+func (mvcc *MVCCLevelDB) PhysicalScanLock(startKey []byte, maxTS uint64, limit int, keyFilter func(key []byte) bool) ([]*kvrpcpb.LockInfo, error) {
+	mvcc.mu.RLock()
+	defer mvcc.mu.RUnlock()
+
+	iter, currKey, err := newScanIterator(mvcc.getDB(""), startKey, nil)
+	defer iter.Release()
+	if err != nil {
+		return nil, err
+	}
+
+	var locks []*kvrpcpb.LockInfo
+	for iter.Valid() && (limit <= 0 || len(locks) < limit) {
+		dec := lockDecoder{expectKey: currKey}
+		ok, err := dec.Decode(iter)
+		if err != nil {
+			return nil, err
+		}
+		if ok && dec.lock.startTS <= maxTS && (keyFilter == nil || keyFilter(currKey)) {
+			locks = append(locks, lockInfoFromMvccLock(currKey, &dec.lock))
+		}
+
+		skip := skipDecoder{currKey: currKey}
+		_, err = skip.Decode(iter)
+		if err != nil {
+			return nil, err
+		}
+		currKey = skip.currKey
+	}
+	return locks, nil
+}
+
+// observeLocks records the locks in the written batch to the registered lock
+// observers. mvcc.mu must be held.
+func (mvcc *MVCCLevelDB) observeLocks(batch *leveldb.Batch) {
+	if len(mvcc.lockObservers) == 0 {
+		return
+	}
+	_ = batch.Replay(lockObserverReplay{mvcc.lockObservers})
+}
+
+type lockObserverReplay struct {
+	observers map[uint64]*lockObserver
+}
+
+func (r lockObserverReplay) Put(encodedKey, value []byte) {
+	key, ver, err := mvccDecode(encodedKey)
+	if err != nil || ver != lockVer {
+		return
+	}
+	var lock mvccLock
+	if err = lock.UnmarshalBinary(value); err != nil {
+		return
+	}
+	for _, observer := range r.observers {
+		if lock.startTS > observer.maxTS {
+			continue
+		}
+		observer.locks[lockObserverKey{key: string(key), startTS: lock.startTS}] = lockInfoFromMvccLock(key, &lock)
+	}
+}
+
+func (r lockObserverReplay) Delete([]byte) {}
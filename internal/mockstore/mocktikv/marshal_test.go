@@ -50,6 +50,10 @@ func TestMarshalmvccLock(t *testing.T) {
 		op:          kvrpcpb.Op_Put,
 		ttl:         444,
 		minCommitTS: 666,
+		generation:  3,
+
+		useAsyncCommit: true,
+		secondaries:    [][]byte{{'f'}, {'g', 'h'}},
 	}
 	bin, err := l.MarshalBinary()
 	assert.Nil(err)
@@ -64,6 +68,9 @@ func TestMarshalmvccLock(t *testing.T) {
 	assert.Equal(string(l.primary), string(l1.primary))
 	assert.Equal(string(l.value), string(l1.value))
 	assert.Equal(l.minCommitTS, l1.minCommitTS)
+	assert.Equal(l.generation, l1.generation)
+	assert.Equal(l.useAsyncCommit, l1.useAsyncCommit)
+	assert.Equal(l.secondaries, l1.secondaries)
 }
 
 func TestMarshalmvccValue(t *testing.T) {
@@ -35,13 +35,16 @@
 package mocktikv
 
 import (
+	"context"
 	"math"
 	"testing"
+	"time"
 
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/tikvrpc"
 )
 
 func lock(key, primary string, ts uint64) *kvrpcpb.LockInfo {
@@ -82,7 +85,7 @@ func mustPutOK(t *testing.T, store MVCCStore, key, value string, startTS, commit
 		PrimaryLock:  []byte(key),
 		StartVersion: startTS,
 	}
-	errs := store.Prewrite(req)
+	errs, _, _ := store.Prewrite(req)
 	for _, err := range errs {
 		assert.Nil(t, err)
 	}
@@ -102,7 +105,7 @@ func mustDeleteOK(t *testing.T, store MVCCStore, key string, startTS, commitTS u
 		PrimaryLock:  []byte(key),
 		StartVersion: startTS,
 	}
-	errs := store.Prewrite(req)
+	errs, _, _ := store.Prewrite(req)
 	for _, err := range errs {
 		assert.Nil(t, err)
 	}
@@ -475,7 +478,7 @@ func TestCommitConflict(t *testing.T) {
 		PrimaryLock:  []byte("x"),
 		StartVersion: 10,
 	}
-	errs := store.Prewrite(req)
+	errs, _, _ := store.Prewrite(req)
 	assert.NotNil(errs[0])
 	// B find rollback A because A exist too long.
 	mustRollbackOK(t, store, [][]byte{[]byte("x")}, 5)
@@ -598,7 +601,7 @@ func TestRollbackAndWriteConflict(t *testing.T) {
 		StartVersion: 2,
 		LockTtl:      2,
 	}
-	errs := store.Prewrite(req)
+	errs, _, _ := store.Prewrite(req)
 	mustWriteWriteConflict(t, errs, 1)
 
 	mustPutOK(t, store, "test", "test2", 5, 8)
@@ -611,7 +614,7 @@ func TestRollbackAndWriteConflict(t *testing.T) {
 		StartVersion: 6,
 		LockTtl:      1,
 	}
-	errs = store.Prewrite(req)
+	errs, _, _ = store.Prewrite(req)
 	mustWriteWriteConflict(t, errs, 0)
 }
 
@@ -671,21 +674,21 @@ func TestCheckTxnStatus(t *testing.T) {
 	startTS := uint64(5 << 18)
 	mustPrewriteWithTTLOK(t, store, putMutations("pk", "val"), "pk", startTS, 666)
 
-	ttl, commitTS, action, err := store.CheckTxnStatus([]byte("pk"), startTS, startTS+100, 666, false, false)
+	ttl, commitTS, action, _, err := store.CheckTxnStatus([]byte("pk"), startTS, startTS+100, 666, false, false)
 	assert.Nil(err)
 	assert.Equal(ttl, uint64(666))
 	assert.Equal(commitTS, uint64(0))
 	assert.Equal(action, kvrpcpb.Action_MinCommitTSPushed)
 
 	// MaxUint64 as callerStartTS shouldn't update minCommitTS but return Action_MinCommitTSPushed.
-	ttl, commitTS, action, err = store.CheckTxnStatus([]byte("pk"), startTS, math.MaxUint64, 666, false, false)
+	ttl, commitTS, action, _, err = store.CheckTxnStatus([]byte("pk"), startTS, math.MaxUint64, 666, false, false)
 	assert.Nil(err)
 	assert.Equal(ttl, uint64(666))
 	assert.Equal(commitTS, uint64(0))
 	assert.Equal(action, kvrpcpb.Action_MinCommitTSPushed)
 	mustCommitOK(t, store, [][]byte{[]byte("pk")}, startTS, startTS+101)
 
-	ttl, commitTS, _, err = store.CheckTxnStatus([]byte("pk"), startTS, 0, 666, false, false)
+	ttl, commitTS, _, _, err = store.CheckTxnStatus([]byte("pk"), startTS, 0, 666, false, false)
 	assert.Nil(err)
 	assert.Equal(ttl, uint64(0))
 	assert.Equal(commitTS, startTS+101)
@@ -693,7 +696,7 @@ func TestCheckTxnStatus(t *testing.T) {
 	mustPrewriteWithTTLOK(t, store, putMutations("pk1", "val"), "pk1", startTS, 666)
 	mustRollbackOK(t, store, [][]byte{[]byte("pk1")}, startTS)
 
-	ttl, commitTS, action, err = store.CheckTxnStatus([]byte("pk1"), startTS, 0, 666, false, false)
+	ttl, commitTS, action, _, err = store.CheckTxnStatus([]byte("pk1"), startTS, 0, 666, false, false)
 	assert.Nil(err)
 	assert.Equal(ttl, uint64(0))
 	assert.Equal(commitTS, uint64(0))
@@ -701,21 +704,21 @@ func TestCheckTxnStatus(t *testing.T) {
 
 	mustPrewriteWithTTLOK(t, store, putMutations("pk2", "val"), "pk2", startTS, 666)
 	currentTS := uint64(777 << 18)
-	ttl, commitTS, action, err = store.CheckTxnStatus([]byte("pk2"), startTS, 0, currentTS, false, false)
+	ttl, commitTS, action, _, err = store.CheckTxnStatus([]byte("pk2"), startTS, 0, currentTS, false, false)
 	assert.Nil(err)
 	assert.Equal(ttl, uint64(0))
 	assert.Equal(commitTS, uint64(0))
 	assert.Equal(action, kvrpcpb.Action_TTLExpireRollback)
 
 	// Cover the TxnNotFound case.
-	_, _, _, err = store.CheckTxnStatus([]byte("txnNotFound"), 5, 0, 666, false, false)
+	_, _, _, _, err = store.CheckTxnStatus([]byte("txnNotFound"), 5, 0, 666, false, false)
 	assert.NotNil(err)
 	notFound, ok := errors.Cause(err).(*ErrTxnNotFound)
 	assert.True(ok)
 	assert.Equal(notFound.StartTs, uint64(5))
 	assert.Equal(string(notFound.PrimaryKey), "txnNotFound")
 
-	ttl, commitTS, action, err = store.CheckTxnStatus([]byte("txnNotFound"), 5, 0, 666, true, false)
+	ttl, commitTS, action, _, err = store.CheckTxnStatus([]byte("txnNotFound"), 5, 0, 666, true, false)
 	assert.Nil(err)
 	assert.Equal(ttl, uint64(0))
 	assert.Equal(commitTS, uint64(0))
@@ -728,7 +731,7 @@ func TestCheckTxnStatus(t *testing.T) {
 		StartVersion: 4,
 		MinCommitTs:  6,
 	}
-	errs := store.Prewrite(req)
+	errs, _, _ := store.Prewrite(req)
 	assert.NotNil(errs)
 }
 
@@ -738,7 +741,7 @@ func TestRejectCommitTS(t *testing.T) {
 	defer store.Close()
 	mustPrewriteOK(t, store, putMutations("x", "A"), "x", 5)
 	// Push the minCommitTS
-	_, _, _, err = store.CheckTxnStatus([]byte("x"), 5, 100, 100, false, false)
+	_, _, _, _, err = store.CheckTxnStatus([]byte("x"), 5, 100, 100, false, false)
 	assert.Nil(t, err)
 	err = store.Commit([][]byte{[]byte("x")}, 5, 10)
 	e, ok := errors.Cause(err).(*ErrCommitTSExpired)
@@ -790,3 +793,353 @@ func TestTxnHeartBeat(t *testing.T) {
 	_, err = store.TxnHeartBeat([]byte("pk"), 5, 1000)
 	assert.NotNil(err)
 }
+
+func lockKeys(locks []*kvrpcpb.LockInfo) []string {
+	keys := make([]string, 0, len(locks))
+	for _, l := range locks {
+		keys = append(keys, string(l.Key))
+	}
+	return keys
+}
+
+func TestLockObserver(t *testing.T) {
+	assert := assert.New(t)
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	defer store.Close()
+
+	mustPrewriteOK(t, store, putMutations("k1", "v1"), "k1", 5)
+
+	_, err = store.CheckLockObserver(1, 10)
+	assert.NotNil(err)
+	assert.Nil(store.RegisterLockObserver(1, 10))
+	assert.Nil(store.RegisterLockObserver(2, 10))
+
+	// Only the locks written after registration whose ts is not greater than
+	// max ts are observed.
+	mustPrewriteOK(t, store, putMutations("k2", "v2", "k3", "v3"), "k2", 8)
+	mustPrewriteOK(t, store, putMutations("k4", "v4"), "k4", 20)
+	locks, err := store.CheckLockObserver(1, 10)
+	assert.Nil(err)
+	assert.Equal([]string{"k2", "k3"}, lockKeys(locks))
+	assert.Equal(uint64(8), locks[0].LockVersion)
+	assert.Equal([]byte("k2"), locks[1].PrimaryLock)
+	assert.Equal(kvrpcpb.Op_Put, locks[1].LockType)
+
+	// The observed locks are kept even if they are resolved.
+	mustCommitOK(t, store, [][]byte{[]byte("k2"), []byte("k3")}, 8, 9)
+	locks, err = store.CheckLockObserver(2, 10)
+	assert.Nil(err)
+	assert.Equal([]string{"k2", "k3"}, lockKeys(locks))
+
+	// Registering with a greater max ts resets the observer.
+	assert.NotNil(store.RegisterLockObserver(1, 9))
+	assert.Nil(store.RegisterLockObserver(1, 30))
+	_, err = store.CheckLockObserver(1, 10)
+	assert.NotNil(err)
+	locks, err = store.CheckLockObserver(1, 30)
+	assert.Nil(err)
+	assert.Empty(locks)
+
+	assert.NotNil(store.RemoveLockObserver(1, 10))
+	assert.Nil(store.RemoveLockObserver(1, 30))
+	assert.Nil(store.RemoveLockObserver(2, 10))
+	_, err = store.CheckLockObserver(2, 10)
+	assert.NotNil(err)
+}
+
+func TestPhysicalScanLock(t *testing.T) {
+	assert := assert.New(t)
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	defer store.Close()
+
+	mustPutOK(t, store, "k1", "v1", 1, 2)
+	mustPrewriteOK(t, store, putMutations("p1", "v5", "s1", "v5"), "p1", 5)
+	mustPrewriteOK(t, store, putMutations("p2", "v10", "s2", "v10"), "p2", 10)
+	mustPrewriteOK(t, store, putMutations("p3", "v20", "s3", "v20"), "p3", 20)
+
+	locks, err := store.PhysicalScanLock(nil, 10, 0, nil)
+	assert.Nil(err)
+	assert.Equal([]string{"p1", "p2", "s1", "s2"}, lockKeys(locks))
+
+	locks, err = store.PhysicalScanLock([]byte("p2"), 20, 3, nil)
+	assert.Nil(err)
+	assert.Equal([]string{"p2", "p3", "s1"}, lockKeys(locks))
+}
+
+func TestLockObserverOnStores(t *testing.T) {
+	assert := assert.New(t)
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	defer store.Close()
+
+	// The region [, "m") is only on the first store, and the region ["m", ) is only
+	// on the second one.
+	cluster := NewCluster(store)
+	storeIDs, peerIDs, regionID, _ := BootstrapWithMultiStores(cluster, 2)
+	newRegionID, newPeerIDs := cluster.AllocID(), cluster.AllocIDs(2)
+	cluster.Split(regionID, newRegionID, []byte("m"), newPeerIDs, newPeerIDs[1])
+	cluster.RemovePeer(regionID, peerIDs[1])
+	cluster.RemovePeer(newRegionID, newPeerIDs[0])
+	client := NewRPCClient(cluster, store, nil)
+	defer client.Close()
+
+	send := func(storeID uint64, req *tikvrpc.Request) *tikvrpc.Response {
+		resp, err := client.SendRequest(context.Background(), cluster.GetStore(storeID).GetAddress(), req, time.Second)
+		require.Nil(t, err)
+		return resp
+	}
+	for _, storeID := range storeIDs {
+		resp := send(storeID, tikvrpc.NewRequest(tikvrpc.CmdRegisterLockObserver, &kvrpcpb.RegisterLockObserverRequest{MaxTs: 10}))
+		assert.Empty(resp.Resp.(*kvrpcpb.RegisterLockObserverResponse).GetError())
+	}
+	mustPrewriteOK(t, store, putMutations("a", "v", "b", "v", "x", "v"), "a", 5)
+
+	// The stores share the MVCCStore, but each one only returns the locks in its
+	// regions.
+	for i, expected := range [][]string{{"a", "b"}, {"x"}} {
+		resp := send(storeIDs[i], tikvrpc.NewRequest(tikvrpc.CmdCheckLockObserver, &kvrpcpb.CheckLockObserverRequest{MaxTs: 10}))
+		checkResp := resp.Resp.(*kvrpcpb.CheckLockObserverResponse)
+		assert.Empty(checkResp.GetError())
+		assert.Equal(expected, lockKeys(checkResp.GetLocks()))
+
+		resp = send(storeIDs[i], tikvrpc.NewRequest(tikvrpc.CmdPhysicalScanLock, &kvrpcpb.PhysicalScanLockRequest{MaxTs: 10, Limit: 10}))
+		scanResp := resp.Resp.(*kvrpcpb.PhysicalScanLockResponse)
+		assert.Empty(scanResp.GetError())
+		assert.Equal(expected, lockKeys(scanResp.GetLocks()))
+	}
+
+	// The limit applies to the locks in the regions of the store.
+	resp := send(storeIDs[1], tikvrpc.NewRequest(tikvrpc.CmdPhysicalScanLock, &kvrpcpb.PhysicalScanLockRequest{MaxTs: 10, Limit: 1}))
+	assert.Equal([]string{"x"}, lockKeys(resp.Resp.(*kvrpcpb.PhysicalScanLockResponse).GetLocks()))
+}
+
+func mustFlushOK(t *testing.T, store MVCCStore, mutations []*kvrpcpb.Mutation, primary string, startTS, generation uint64) {
+	errs := store.Flush(&kvrpcpb.FlushRequest{
+		Mutations:   mutations,
+		PrimaryKey:  []byte(primary),
+		StartTs:     startTS,
+		MinCommitTs: startTS + 1,
+		Generation:  generation,
+		LockTtl:     3000,
+	})
+	for _, err := range errs {
+		assert.Nil(t, err)
+	}
+}
+
+func mustBufferBatchGet(t *testing.T, store MVCCStore, keys []string, startTS uint64, expect ...string) {
+	ks := make([][]byte, 0, len(keys))
+	for _, k := range keys {
+		ks = append(ks, []byte(k))
+	}
+	pairs := store.BufferBatchGet(ks, startTS)
+	assert.Equal(t, len(expect)/2, len(pairs))
+	for i := range pairs {
+		assert.Nil(t, pairs[i].Err)
+		assert.Equal(t, expect[i*2], string(pairs[i].Key))
+		assert.Equal(t, expect[i*2+1], string(pairs[i].Value))
+	}
+}
+
+func TestFlush(t *testing.T) {
+	assert := assert.New(t)
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	defer store.Close()
+
+	mustPutOK(t, store, "k3", "v3", 1, 2)
+	mustFlushOK(t, store, putMutations("k1", "v1", "k2", "v2"), "k1", 5, 1)
+	mustFlushOK(t, store, append(putMutations("k1", "v11"), &kvrpcpb.Mutation{Op: kvrpcpb.Op_Del, Key: []byte("k3")}), "k1", 5, 2)
+	// The stale flush of an older generation is ignored.
+	mustFlushOK(t, store, putMutations("k1", "v1"), "k1", 5, 1)
+	mustBufferBatchGet(t, store, []string{"k1", "k2", "k3", "k4"}, 5, "k1", "v11", "k2", "v2", "k3", "")
+	mustBufferBatchGet(t, store, []string{"k1"}, 6)
+	mustGetErr(t, store, "k1", 10)
+
+	// The flushed locks block other transactions.
+	errs := store.Flush(&kvrpcpb.FlushRequest{
+		Mutations:  putMutations("k2", "v"),
+		PrimaryKey: []byte("k2"),
+		StartTs:    7,
+		Generation: 1,
+	})
+	_, isLocked := errs[0].(*ErrLocked)
+	assert.True(isLocked)
+
+	mustCommitOK(t, store, [][]byte{[]byte("k1")}, 5, 10)
+	mustResolveLock(t, store, 5, 10)
+	mustGetOK(t, store, "k1", 11, "v11")
+	mustGetOK(t, store, "k2", 11, "v2")
+	mustGetNone(t, store, "k3", 11)
+	mustScanLock(t, store, 20, nil)
+
+	// Rollback the flushed locks.
+	mustFlushOK(t, store, putMutations("k1", "v12", "k2", "v22"), "k1", 15, 1)
+	mustResolveLock(t, store, 15, 0)
+	mustGetOK(t, store, "k1", 20, "v11")
+	mustGetOK(t, store, "k2", 20, "v2")
+	mustScanLock(t, store, 20, nil)
+}
+
+func mustAsyncPrewrite(t *testing.T, store MVCCStore, req *kvrpcpb.PrewriteRequest) (uint64, uint64) {
+	errs, minCommitTS, onePCCommitTS := store.Prewrite(req)
+	for _, err := range errs {
+		assert.Nil(t, err)
+	}
+	return minCommitTS, onePCCommitTS
+}
+
+func TestAsyncCommitPrewrite(t *testing.T) {
+	assert := assert.New(t)
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	defer store.Close()
+
+	// The read pushes the max ts.
+	mustGetNone(t, store, "k1", 20)
+	minCommitTS, onePCCommitTS := mustAsyncPrewrite(t, store, &kvrpcpb.PrewriteRequest{
+		Mutations:      putMutations("k1", "v1", "k2", "v2"),
+		PrimaryLock:    []byte("k1"),
+		StartVersion:   10,
+		LockTtl:        3000,
+		MinCommitTs:    11,
+		UseAsyncCommit: true,
+		Secondaries:    [][]byte{[]byte("k2")},
+	})
+	assert.Equal(uint64(21), minCommitTS)
+	assert.Zero(onePCCommitTS)
+
+	locks, err := store.PhysicalScanLock(nil, 10, 0, nil)
+	assert.Nil(err)
+	assert.Len(locks, 2)
+	for _, lock := range locks {
+		assert.True(lock.UseAsyncCommit)
+		assert.Equal(uint64(21), lock.MinCommitTs)
+	}
+	assert.Equal([][]byte{[]byte("k2")}, locks[0].Secondaries)
+	assert.Empty(locks[1].Secondaries)
+
+	// The retried prewrite returns the same min commit ts.
+	mustGetNone(t, store, "k3", 30)
+	minCommitTS, _ = mustAsyncPrewrite(t, store, &kvrpcpb.PrewriteRequest{
+		Mutations:      putMutations("k2", "v2"),
+		PrimaryLock:    []byte("k1"),
+		StartVersion:   10,
+		UseAsyncCommit: true,
+	})
+	assert.Equal(uint64(31), minCommitTS)
+
+	mustCommitErr(t, store, [][]byte{[]byte("k1"), []byte("k2")}, 10, 20)
+	mustCommitOK(t, store, [][]byte{[]byte("k1"), []byte("k2")}, 10, 31)
+	mustGetOK(t, store, "k1", 40, "v1")
+	mustGetOK(t, store, "k2", 40, "v2")
+
+	// Fallback to 2PC if the min commit ts exceeds the max commit ts.
+	minCommitTS, _ = mustAsyncPrewrite(t, store, &kvrpcpb.PrewriteRequest{
+		Mutations:      putMutations("k3", "v3"),
+		PrimaryLock:    []byte("k3"),
+		StartVersion:   50,
+		UseAsyncCommit: true,
+		MaxCommitTs:    45,
+	})
+	assert.Zero(minCommitTS)
+	locks, err = store.PhysicalScanLock(nil, 50, 0, nil)
+	assert.Nil(err)
+	assert.Len(locks, 1)
+	assert.False(locks[0].UseAsyncCommit)
+}
+
+func TestOnePC(t *testing.T) {
+	assert := assert.New(t)
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	defer store.Close()
+
+	mustGetNone(t, store, "k1", 20)
+	minCommitTS, onePCCommitTS := mustAsyncPrewrite(t, store, &kvrpcpb.PrewriteRequest{
+		Mutations:      putMutations("k1", "v1", "k2", "v2"),
+		PrimaryLock:    []byte("k1"),
+		StartVersion:   10,
+		UseAsyncCommit: true,
+		TryOnePc:       true,
+	})
+	assert.Zero(minCommitTS)
+	assert.Equal(uint64(21), onePCCommitTS)
+	mustScanLock(t, store, 100, nil)
+	mustGetNone(t, store, "k1", 20)
+	mustGetOK(t, store, "k1", 21, "v1")
+	mustGetOK(t, store, "k2", 21, "v2")
+
+	// Fallback to 2PC if the commit ts exceeds the max commit ts.
+	minCommitTS, onePCCommitTS = mustAsyncPrewrite(t, store, &kvrpcpb.PrewriteRequest{
+		Mutations:      putMutations("k1", "v11"),
+		PrimaryLock:    []byte("k1"),
+		StartVersion:   30,
+		UseAsyncCommit: true,
+		TryOnePc:       true,
+		MaxCommitTs:    25,
+	})
+	assert.Zero(minCommitTS)
+	assert.Zero(onePCCommitTS)
+	mustScanLock(t, store, 100, []*kvrpcpb.LockInfo{lock("k1", "k1", 30)})
+}
+
+func TestCheckSecondaryLocks(t *testing.T) {
+	assert := assert.New(t)
+	store, err := NewMVCCLevelDB("")
+	require.Nil(t, err)
+	defer store.Close()
+
+	keys := [][]byte{[]byte("k2"), []byte("k3")}
+	mustAsyncPrewrite(t, store, &kvrpcpb.PrewriteRequest{
+		Mutations:      putMutations("k1", "v1", "k2", "v2", "k3", "v3"),
+		PrimaryLock:    []byte("k1"),
+		StartVersion:   10,
+		LockTtl:        1,
+		UseAsyncCommit: true,
+		Secondaries:    keys,
+	})
+
+	// The expired async commit lock is not rolled back by CheckTxnStatus.
+	ttl, commitTS, _, lockInfo, err := store.CheckTxnStatus([]byte("k1"), 10, 0, 100<<18, true, false)
+	assert.Nil(err)
+	assert.Equal(uint64(1), ttl)
+	assert.Zero(commitTS)
+	assert.True(lockInfo.UseAsyncCommit)
+	assert.Equal(keys, lockInfo.Secondaries)
+
+	locks, commitTS, err := store.CheckSecondaryLocks(keys, 10)
+	assert.Nil(err)
+	assert.Len(locks, 2)
+	assert.Zero(commitTS)
+
+	mustCommitOK(t, store, [][]byte{[]byte("k1"), []byte("k2")}, 10, 11)
+	locks, commitTS, err = store.CheckSecondaryLocks(keys, 10)
+	assert.Nil(err)
+	assert.Empty(locks)
+	assert.Equal(uint64(11), commitTS)
+
+	// The missing secondary lock is rolled back, so the transaction can't be
+	// committed.
+	mustAsyncPrewrite(t, store, &kvrpcpb.PrewriteRequest{
+		Mutations:      putMutations("k1", "v1"),
+		PrimaryLock:    []byte("k1"),
+		StartVersion:   20,
+		UseAsyncCommit: true,
+		Secondaries:    keys,
+	})
+	locks, commitTS, err = store.CheckSecondaryLocks(keys, 20)
+	assert.Nil(err)
+	assert.Empty(locks)
+	assert.Zero(commitTS)
+	errs, _, _ := store.Prewrite(&kvrpcpb.PrewriteRequest{
+		Mutations:      putMutations("k2", "v2"),
+		PrimaryLock:    []byte("k1"),
+		StartVersion:   20,
+		UseAsyncCommit: true,
+	})
+	_, ok := errs[0].(*ErrAlreadyRollbacked)
+	assert.True(ok)
+}
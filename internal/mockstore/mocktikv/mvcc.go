@@ -41,6 +41,7 @@ import (
 	"math"
 
 	"github.com/google/btree"
+	deadlockpb "github.com/pingcap/kvproto/pkg/deadlock"
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pkg/errors"
 	"github.com/tikv/client-go/v2/util/codec"
@@ -71,6 +72,13 @@ type mvccLock struct {
 	forUpdateTS uint64
 	txnSize     uint64
 	minCommitTS uint64
+	// generation is the generation of the pipelined flush which writes the
+	// lock, it is 0 for the locks written by prewrite.
+	generation uint64
+	// useAsyncCommit and secondaries are set by the async commit prewrite,
+	// secondaries are only recorded on the primary lock.
+	useAsyncCommit bool
+	secondaries    [][]byte
 }
 
 type mvccEntry struct {
@@ -93,6 +101,12 @@ func (l *mvccLock) MarshalBinary() ([]byte, error) {
 	mh.WriteNumber(&buf, l.forUpdateTS)
 	mh.WriteNumber(&buf, l.txnSize)
 	mh.WriteNumber(&buf, l.minCommitTS)
+	mh.WriteNumber(&buf, l.generation)
+	mh.WriteNumber(&buf, l.useAsyncCommit)
+	mh.WriteNumber(&buf, uint64(len(l.secondaries)))
+	for _, secondary := range l.secondaries {
+		mh.WriteSlice(&buf, secondary)
+	}
 	return buf.Bytes(), mh.err
 }
 
@@ -108,6 +122,16 @@ func (l *mvccLock) UnmarshalBinary(data []byte) error {
 	mh.ReadNumber(buf, &l.forUpdateTS)
 	mh.ReadNumber(buf, &l.txnSize)
 	mh.ReadNumber(buf, &l.minCommitTS)
+	mh.ReadNumber(buf, &l.generation)
+	mh.ReadNumber(buf, &l.useAsyncCommit)
+	var secondaries uint64
+	mh.ReadNumber(buf, &secondaries)
+	if mh.err == nil && secondaries > 0 {
+		l.secondaries = make([][]byte, secondaries)
+		for i := range l.secondaries {
+			mh.ReadSlice(buf, &l.secondaries[i])
+		}
+	}
 	return mh.err
 }
 
@@ -220,6 +244,10 @@ func (l *mvccLock) lockErr(key []byte) error {
 		TTL:         l.ttl,
 		TxnSize:     l.txnSize,
 		LockType:    l.op,
+		MinCommitTS: l.minCommitTS,
+
+		UseAsyncCommit: l.useAsyncCommit,
+		Secondaries:    l.secondaries,
 	}
 }
 
@@ -270,7 +298,11 @@ type MVCCStore interface {
 	BatchGet(ks [][]byte, startTS uint64, isoLevel kvrpcpb.IsolationLevel, resolvedLocks []uint64) []Pair
 	PessimisticLock(req *kvrpcpb.PessimisticLockRequest) *kvrpcpb.PessimisticLockResponse
 	PessimisticRollback(startKey []byte, endKey []byte, keys [][]byte, startTS, forUpdateTS uint64) []error
-	Prewrite(req *kvrpcpb.PrewriteRequest) []error
+	// Prewrite returns the min commit ts if the request uses async commit, and
+	// the commit ts if the transaction is committed by 1PC.
+	Prewrite(req *kvrpcpb.PrewriteRequest) (errs []error, minCommitTS uint64, onePCCommitTS uint64)
+	Flush(req *kvrpcpb.FlushRequest) []error
+	BufferBatchGet(ks [][]byte, startTS uint64) []Pair
 	Commit(keys [][]byte, startTS, commitTS uint64) error
 	Rollback(keys [][]byte, startTS uint64) error
 	Cleanup(key []byte, startTS, currentTS uint64) error
@@ -280,7 +312,9 @@ type MVCCStore interface {
 	BatchResolveLock(startKey, endKey []byte, txnInfos map[uint64]uint64) error
 	GC(startKey, endKey []byte, safePoint uint64) error
 	DeleteRange(startKey, endKey []byte) error
-	CheckTxnStatus(primaryKey []byte, lockTS uint64, startTS, currentTS uint64, rollbackIfNotFound bool, resolvingPessimisticLock bool) (uint64, uint64, kvrpcpb.Action, error)
+	FlashbackToVersion(startKey, endKey []byte, version, startTS, commitTS uint64) error
+	CheckTxnStatus(primaryKey []byte, lockTS uint64, startTS, currentTS uint64, rollbackIfNotFound bool, resolvingPessimisticLock bool) (uint64, uint64, kvrpcpb.Action, *kvrpcpb.LockInfo, error)
+	CheckSecondaryLocks(keys [][]byte, startTS uint64) ([]*kvrpcpb.LockInfo, uint64, error)
 	Close() error
 }
 
@@ -299,6 +333,32 @@ type RawKV interface {
 	RawChecksum(cf string, startKey, endKey []byte) (uint64, uint64, uint64, error)
 }
 
+// RawTTL is used to put raw keys with TTL and get the remaining TTL of the
+// keys. The expired keys are reported as not found by RawGetKeyTTL only, they
+// are not cleaned up.
+type RawTTL interface {
+	RawPutWithTTL(cf string, key, value []byte, ttl uint64)
+	RawBatchPutWithTTL(cf string, keys, values [][]byte, ttls []uint64)
+	// RawGetKeyTTL returns the remaining TTL in seconds of the key, 0 means the
+	// key never expires.
+	RawGetKeyTTL(cf string, key []byte) (ttl uint64, found bool)
+}
+
+// LockObserver is used by the GC worker to find the locks written during GC.
+type LockObserver interface {
+	RegisterLockObserver(storeID, maxTS uint64) error
+	CheckLockObserver(storeID, maxTS uint64) ([]*kvrpcpb.LockInfo, error)
+	RemoveLockObserver(storeID, maxTS uint64) error
+	// PhysicalScanLock scans the locks from startKey regardless of the regions.
+	// Only the locks whose keys pass keyFilter are returned if it's not nil.
+	PhysicalScanLock(startKey []byte, maxTS uint64, limit int, keyFilter func(key []byte) bool) ([]*kvrpcpb.LockInfo, error)
+}
+
+// LockWaiter is used to get the pessimistic lock waits in the store.
+type LockWaiter interface {
+	GetLockWaitInfo() []*deadlockpb.WaitForEntry
+}
+
 // MVCCDebugger is for debugging.
 type MVCCDebugger interface {
 	MvccGetByStartTS(starTS uint64) (*kvrpcpb.MvccInfo, []byte)
@@ -39,6 +39,8 @@ import (
 	"hash/crc64"
 	"math"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/dgryski/go-farm"
 	"github.com/pingcap/goleveldb/leveldb"
@@ -46,6 +48,7 @@ import (
 	"github.com/pingcap/goleveldb/leveldb/opt"
 	"github.com/pingcap/goleveldb/leveldb/storage"
 	"github.com/pingcap/goleveldb/leveldb/util"
+	deadlockpb "github.com/pingcap/kvproto/pkg/deadlock"
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pkg/errors"
 	tikverr "github.com/tikv/client-go/v2/error"
@@ -95,6 +98,18 @@ type MVCCLevelDB struct {
 	// then write, another write may happen during it, so this lock is necessory.
 	mu               sync.RWMutex
 	deadlockDetector *deadlock.Detector
+	// lockObservers are the lock observers registered on each store.
+	lockObservers map[uint64]*lockObserver
+	// maxTS is the max timestamp of the reads, the commit ts of async commit
+	// and 1PC transactions must be greater than it.
+	maxTS atomic.Uint64
+	// rawTTLs records the expire time of the raw keys put with TTL.
+	rawTTLs map[rawTTLKey]time.Time
+}
+
+type rawTTLKey struct {
+	cf  string
+	key string
 }
 
 const lockVer uint64 = math.MaxUint64
@@ -161,6 +176,8 @@ func NewMVCCLevelDB(path string) (*MVCCLevelDB, error) {
 	mvccLevelDBs := &MVCCLevelDB{
 		dbs:              make(map[string]*leveldb.DB),
 		deadlockDetector: deadlock.NewDetector(),
+		lockObservers:    make(map[uint64]*lockObserver),
+		rawTTLs:          make(map[rawTTLKey]time.Time),
 	}
 	mvccLevelDBs.dbs[defaultCf] = d
 	return mvccLevelDBs, nil
@@ -305,10 +322,26 @@ func (dec *skipDecoder) Decode(iter *Iterator) (bool, error) {
 func (mvcc *MVCCLevelDB) Get(key []byte, startTS uint64, isoLevel kvrpcpb.IsolationLevel, resolvedLocks []uint64) ([]byte, error) {
 	mvcc.mu.RLock()
 	defer mvcc.mu.RUnlock()
+	mvcc.updateMaxTS(startTS)
 
 	return mvcc.getValue(key, startTS, isoLevel, resolvedLocks)
 }
 
+// updateMaxTS updates the max ts with the timestamp of a read. The latest
+// reads of point get in autocommit transactions use math.MaxUint64 and are
+// ignored.
+func (mvcc *MVCCLevelDB) updateMaxTS(ts uint64) {
+	if ts == math.MaxUint64 {
+		return
+	}
+	for {
+		maxTS := mvcc.maxTS.Load()
+		if ts <= maxTS || mvcc.maxTS.CompareAndSwap(maxTS, ts) {
+			return
+		}
+	}
+}
+
 func (mvcc *MVCCLevelDB) getDB(cf string) *leveldb.DB {
 	if cf == "" {
 		cf = defaultCf
@@ -378,6 +411,7 @@ func getValue(iter *Iterator, key []byte, startTS uint64, isoLevel kvrpcpb.Isola
 func (mvcc *MVCCLevelDB) BatchGet(ks [][]byte, startTS uint64, isoLevel kvrpcpb.IsolationLevel, resolvedLocks []uint64) []Pair {
 	mvcc.mu.RLock()
 	defer mvcc.mu.RUnlock()
+	mvcc.updateMaxTS(startTS)
 
 	pairs := make([]Pair, 0, len(ks))
 	for _, k := range ks {
@@ -398,6 +432,7 @@ func (mvcc *MVCCLevelDB) BatchGet(ks [][]byte, startTS uint64, isoLevel kvrpcpb.
 func (mvcc *MVCCLevelDB) Scan(startKey, endKey []byte, limit int, startTS uint64, isoLevel kvrpcpb.IsolationLevel, resolvedLock []uint64) []Pair {
 	mvcc.mu.RLock()
 	defer mvcc.mu.RUnlock()
+	mvcc.updateMaxTS(startTS)
 
 	iter, currKey, err := newScanIterator(mvcc.getDB(""), startKey, endKey)
 	defer iter.Release()
@@ -438,6 +473,7 @@ func (mvcc *MVCCLevelDB) Scan(startKey, endKey []byte, limit int, startTS uint64
 func (mvcc *MVCCLevelDB) ReverseScan(startKey, endKey []byte, limit int, startTS uint64, isoLevel kvrpcpb.IsolationLevel, resolvedLocks []uint64) []Pair {
 	mvcc.mu.RLock()
 	defer mvcc.mu.RUnlock()
+	mvcc.updateMaxTS(startTS)
 
 	var mvccEnd []byte
 	if len(endKey) != 0 {
@@ -547,6 +583,7 @@ func (mvcc *MVCCLevelDB) PessimisticLock(req *kvrpcpb.PessimisticLockRequest) *k
 	resp := &kvrpcpb.PessimisticLockResponse{}
 	mvcc.mu.Lock()
 	defer mvcc.mu.Unlock()
+	mvcc.updateMaxTS(req.ForUpdateTs)
 	mutations := req.Mutations
 	lCtx := &lockCtx{
 		startTS:          req.StartVersion,
@@ -601,6 +638,7 @@ func (mvcc *MVCCLevelDB) PessimisticLock(req *kvrpcpb.PessimisticLockRequest) *k
 		resp.Errors = convertToKeyErrors([]error{err})
 		return resp
 	}
+	mvcc.observeLocks(batch)
 	if lCtx.WakeUpMode == kvrpcpb.PessimisticLockWakeUpMode_WakeUpModeNormal {
 		if req.ReturnValues {
 			resp.Values = make([][]byte, 0, len(lCtx.results))
@@ -805,7 +843,7 @@ func pessimisticRollbackKey(db *leveldb.DB, batch *leveldb.Batch, key []byte, st
 }
 
 // Prewrite implements the MVCCStore interface.
-func (mvcc *MVCCLevelDB) Prewrite(req *kvrpcpb.PrewriteRequest) []error {
+func (mvcc *MVCCLevelDB) Prewrite(req *kvrpcpb.PrewriteRequest) ([]error, uint64, uint64) {
 	mutations := req.Mutations
 	primary := req.PrimaryLock
 	startTS := req.StartVersion
@@ -816,8 +854,13 @@ func (mvcc *MVCCLevelDB) Prewrite(req *kvrpcpb.PrewriteRequest) []error {
 	defer mvcc.mu.Unlock()
 
 	anyError := false
-	batch := &leveldb.Batch{}
 	errs := make([]error, 0, len(mutations))
+	locks := make([]*mvccLock, len(mutations))
+	useAsyncCommit, tryOnePC := req.UseAsyncCommit, req.TryOnePc
+	var commitTS uint64
+	if useAsyncCommit || tryOnePC {
+		commitTS = mvcc.calculateMinCommitTS(req)
+	}
 	txnSize := req.TxnSize
 	for i, m := range mutations {
 		// If the operation is Insert, check if key is exists at first.
@@ -848,7 +891,103 @@ func (mvcc *MVCCLevelDB) Prewrite(req *kvrpcpb.PrewriteRequest) []error {
 		if len(req.PessimisticActions) > 0 {
 			pessimisticAction = req.PessimisticActions[i]
 		}
-		err = prewriteMutation(mvcc.getDB(""), batch, m, startTS, primary, ttl, txnSize, pessimisticAction, minCommitTS, req.AssertionLevel)
+		lock, prewritten, err := prewriteMutation(mvcc.getDB(""), m, startTS, primary, ttl, txnSize, pessimisticAction, minCommitTS, req.AssertionLevel)
+		errs = append(errs, err)
+		if err != nil {
+			anyError = true
+			continue
+		}
+		if prewritten {
+			// The key is prewritten by a previous request of the transaction,
+			// it can't be committed by 1PC any more.
+			tryOnePC = false
+			if lock.minCommitTS > commitTS {
+				commitTS = lock.minCommitTS
+			}
+			continue
+		}
+		locks[i] = lock
+	}
+	if anyError {
+		return errs, 0, 0
+	}
+	if req.MaxCommitTs > 0 && commitTS > req.MaxCommitTs {
+		// Fallback to the normal 2PC.
+		useAsyncCommit, tryOnePC = false, false
+	}
+	if req.TryOnePc && !tryOnePC {
+		// The client requires the min commit ts to be 0 when 1PC falls back.
+		useAsyncCommit = false
+	}
+
+	batch := &leveldb.Batch{}
+	for i, lock := range locks {
+		if lock == nil {
+			continue
+		}
+		key := mutations[i].Key
+		if tryOnePC {
+			if err := commitLock(batch, *lock, key, startTS, commitTS); err != nil {
+				return []error{err}, 0, 0
+			}
+			continue
+		}
+		if useAsyncCommit {
+			lock.useAsyncCommit = true
+			lock.minCommitTS = commitTS
+			if bytes.Equal(key, primary) {
+				lock.secondaries = req.Secondaries
+			}
+		}
+		writeValue, err := lock.MarshalBinary()
+		if err != nil {
+			return []error{err}, 0, 0
+		}
+		batch.Put(mvccEncode(key, lockVer), writeValue)
+	}
+	if err := mvcc.getDB("").Write(batch, nil); err != nil {
+		return []error{err}, 0, 0
+	}
+	mvcc.observeLocks(batch)
+
+	if tryOnePC {
+		mvcc.deadlockDetector.CleanUp(startTS)
+		return errs, 0, commitTS
+	}
+	if useAsyncCommit {
+		return errs, commitTS, 0
+	}
+	return errs, 0, 0
+}
+
+// calculateMinCommitTS calculates the min commit ts of an async commit or 1PC
+// prewrite. The commit ts must be greater than the ts of all reads finished
+// before the prewrite, so the reads won't miss the data of the transaction.
+// mvcc.mu must be held.
+func (mvcc *MVCCLevelDB) calculateMinCommitTS(req *kvrpcpb.PrewriteRequest) uint64 {
+	minCommitTS := mvcc.maxTS.Load() + 1
+	if req.StartVersion >= minCommitTS {
+		minCommitTS = req.StartVersion + 1
+	}
+	if req.ForUpdateTs >= minCommitTS {
+		minCommitTS = req.ForUpdateTs + 1
+	}
+	if req.MinCommitTs > minCommitTS {
+		minCommitTS = req.MinCommitTs
+	}
+	return minCommitTS
+}
+
+// Flush implements the MVCCStore interface.
+func (mvcc *MVCCLevelDB) Flush(req *kvrpcpb.FlushRequest) []error {
+	mvcc.mu.Lock()
+	defer mvcc.mu.Unlock()
+
+	anyError := false
+	batch := &leveldb.Batch{}
+	errs := make([]error, 0, len(req.Mutations))
+	for _, m := range req.Mutations {
+		err := flushMutation(mvcc.getDB(""), batch, m, req)
 		errs = append(errs, err)
 		if err != nil {
 			anyError = true
@@ -860,10 +999,117 @@ func (mvcc *MVCCLevelDB) Prewrite(req *kvrpcpb.PrewriteRequest) []error {
 	if err := mvcc.getDB("").Write(batch, nil); err != nil {
 		return []error{err}
 	}
+	mvcc.observeLocks(batch)
 
 	return errs
 }
 
+// flushMutation writes the lock of a pipelined flush. Unlike prewrite, the
+// lock of the same transaction is overwritten unless it is written by a newer
+// generation.
+func flushMutation(db *leveldb.DB, batch *leveldb.Batch, mutation *kvrpcpb.Mutation, req *kvrpcpb.FlushRequest) error {
+	startTS := req.StartTs
+	startKey := mvccEncode(mutation.Key, lockVer)
+	iter := newIterator(db, &util.Range{
+		Start: startKey,
+	})
+	defer iter.Release()
+
+	dec := lockDecoder{
+		expectKey: mutation.Key,
+	}
+	ok, err := dec.Decode(iter)
+	if err != nil {
+		return err
+	}
+	op := mutation.GetOp()
+	if ok {
+		if dec.lock.startTS != startTS {
+			return dec.lock.lockErr(mutation.Key)
+		}
+		if dec.lock.generation > req.Generation {
+			// The lock is overwritten by a later flush, ignore the stale one.
+			return nil
+		}
+	} else {
+		if op == kvrpcpb.Op_Insert || op == kvrpcpb.Op_CheckNotExists {
+			valIter := newIterator(db, &util.Range{
+				Start: startKey,
+			})
+			v, err := getValue(valIter, mutation.Key, startTS, kvrpcpb.IsolationLevel_SI, nil)
+			valIter.Release()
+			if err != nil {
+				return err
+			}
+			if v != nil {
+				return &ErrKeyAlreadyExist{
+					Key: mutation.Key,
+				}
+			}
+		}
+		_, err = checkConflictValue(iter, mutation, startTS, startTS, false, req.AssertionLevel, false, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	if op == kvrpcpb.Op_CheckNotExists {
+		return nil
+	}
+	if op == kvrpcpb.Op_Insert {
+		op = kvrpcpb.Op_Put
+	}
+	lock := mvccLock{
+		startTS:    startTS,
+		primary:    req.PrimaryKey,
+		value:      mutation.Value,
+		op:         op,
+		ttl:        req.LockTtl,
+		generation: req.Generation,
+	}
+	if bytes.Equal(req.PrimaryKey, mutation.GetKey()) {
+		lock.minCommitTS = req.MinCommitTs
+	}
+	writeValue, err := lock.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	batch.Put(startKey, writeValue)
+	return nil
+}
+
+// BufferBatchGet implements the MVCCStore interface.
+func (mvcc *MVCCLevelDB) BufferBatchGet(ks [][]byte, startTS uint64) []Pair {
+	mvcc.mu.RLock()
+	defer mvcc.mu.RUnlock()
+
+	pairs := make([]Pair, 0, len(ks))
+	for _, k := range ks {
+		iter := newIterator(mvcc.getDB(""), &util.Range{
+			Start: mvccEncode(k, lockVer),
+		})
+		dec := lockDecoder{expectKey: k}
+		ok, err := dec.Decode(iter)
+		iter.Release()
+		if err != nil {
+			pairs = append(pairs, Pair{Key: k, Err: err})
+			continue
+		}
+		// Only the flushed data of the transaction itself is readable. The
+		// deleted keys are returned with empty values.
+		if !ok || dec.lock.startTS != startTS {
+			continue
+		}
+		switch dec.lock.op {
+		case kvrpcpb.Op_Put:
+			pairs = append(pairs, Pair{Key: k, Value: dec.lock.value})
+		case kvrpcpb.Op_Del:
+			pairs = append(pairs, Pair{Key: k, Value: []byte{}})
+		}
+	}
+	return pairs
+}
+
 func checkConflictValue(iter *Iterator, m *kvrpcpb.Mutation, forUpdateTS uint64, startTS uint64, getVal bool, assertionLevel kvrpcpb.AssertionLevel, lockOnlyIfExists bool, allowLockWithConflict bool) ([]byte, error) {
 	dec := &valueDecoder{
 		expectKey: m.Key,
@@ -990,11 +1236,14 @@ func checkConflictValue(iter *Iterator, m *kvrpcpb.Mutation, forUpdateTS uint64,
 	return nil, writeConflictErr
 }
 
-func prewriteMutation(db *leveldb.DB, batch *leveldb.Batch,
+// prewriteMutation checks the mutation and returns the lock to write. If the
+// key is already prewritten by the transaction, the existing lock is returned
+// with prewritten set.
+func prewriteMutation(db *leveldb.DB,
 	mutation *kvrpcpb.Mutation, startTS uint64,
 	primary []byte, ttl uint64, txnSize uint64,
 	pessimisticAction kvrpcpb.PrewriteRequest_PessimisticAction, minCommitTS uint64,
-	assertionLevel kvrpcpb.AssertionLevel) error {
+	assertionLevel kvrpcpb.AssertionLevel) (lock *mvccLock, prewritten bool, err error) {
 	startKey := mvccEncode(mutation.Key, lockVer)
 	iter := newIterator(db, &util.Range{
 		Start: startKey,
@@ -1006,7 +1255,7 @@ func prewriteMutation(db *leveldb.DB, batch *leveldb.Batch,
 	}
 	ok, err := dec.Decode(iter)
 	if err != nil {
-		return err
+		return nil, false, err
 	}
 	if ok {
 		if dec.lock.startTS != startTS {
@@ -1016,10 +1265,10 @@ func prewriteMutation(db *leveldb.DB, batch *leveldb.Batch,
 				// telling TiDB to rollback the transaction **unconditionly**.
 				dec.lock.ttl = 0
 			}
-			return dec.lock.lockErr(mutation.Key)
+			return nil, false, dec.lock.lockErr(mutation.Key)
 		}
 		if dec.lock.op != kvrpcpb.Op_PessimisticLock {
-			return nil
+			return &dec.lock, true, nil
 		}
 		// Overwrite the pessimistic lock.
 		if ttl < dec.lock.ttl {
@@ -1032,15 +1281,15 @@ func prewriteMutation(db *leveldb.DB, batch *leveldb.Batch,
 		}
 		_, err = checkConflictValue(iter, mutation, startTS, startTS, false, assertionLevel, false, false)
 		if err != nil {
-			return err
+			return nil, false, err
 		}
 	} else {
 		if pessimisticAction == kvrpcpb.PrewriteRequest_DO_PESSIMISTIC_CHECK {
-			return ErrAbort("pessimistic lock not found")
+			return nil, false, ErrAbort("pessimistic lock not found")
 		}
 		_, err = checkConflictValue(iter, mutation, startTS, startTS, false, assertionLevel, false, false)
 		if err != nil {
-			return err
+			return nil, false, err
 		}
 	}
 
@@ -1048,7 +1297,7 @@ func prewriteMutation(db *leveldb.DB, batch *leveldb.Batch,
 	if op == kvrpcpb.Op_Insert {
 		op = kvrpcpb.Op_Put
 	}
-	lock := mvccLock{
+	lock = &mvccLock{
 		startTS: startTS,
 		primary: primary,
 		value:   mutation.Value,
@@ -1060,15 +1309,7 @@ func prewriteMutation(db *leveldb.DB, batch *leveldb.Batch,
 	if bytes.Equal(primary, mutation.GetKey()) {
 		lock.minCommitTS = minCommitTS
 	}
-
-	writeKey := mvccEncode(mutation.Key, lockVer)
-	writeValue, err := lock.MarshalBinary()
-	if err != nil {
-		return err
-	}
-
-	batch.Put(writeKey, writeValue)
-	return nil
+	return lock, false, nil
 }
 
 // Commit implements the MVCCStore interface.
@@ -1352,9 +1593,10 @@ func (mvcc *MVCCLevelDB) Cleanup(key []byte, startTS, currentTS uint64) error {
 // callerStartTS is the start ts of reader transaction.
 // currentTS is the current ts, but it may be inaccurate. Just use it to check TTL.
 func (mvcc *MVCCLevelDB) CheckTxnStatus(primaryKey []byte, lockTS, callerStartTS, currentTS uint64,
-	rollbackIfNotExist bool, resolvingPessimisticLock bool) (ttl uint64, commitTS uint64, action kvrpcpb.Action, err error) {
+	rollbackIfNotExist bool, resolvingPessimisticLock bool) (ttl uint64, commitTS uint64, action kvrpcpb.Action, lockInfo *kvrpcpb.LockInfo, err error) {
 	mvcc.mu.Lock()
 	defer mvcc.mu.Unlock()
+	mvcc.updateMaxTS(callerStartTS)
 
 	action = kvrpcpb.Action_NoAction
 
@@ -1378,6 +1620,12 @@ func (mvcc *MVCCLevelDB) CheckTxnStatus(primaryKey []byte, lockTS, callerStartTS
 			lock := dec.lock
 			batch := &leveldb.Batch{}
 
+			// The status of an async commit transaction is decided by all its
+			// locks, the caller should check the secondary locks.
+			if lock.useAsyncCommit {
+				return lock.ttl, 0, action, lockInfoFromMvccLock(primaryKey, &lock), nil
+			}
+
 			// If the lock has already outdated, clean up it.
 			if uint64(oracle.ExtractPhysical(lock.startTS))+lock.ttl < uint64(oracle.ExtractPhysical(currentTS)) {
 				if resolvingPessimisticLock && lock.op == kvrpcpb.Op_PessimisticLock {
@@ -1395,7 +1643,7 @@ func (mvcc *MVCCLevelDB) CheckTxnStatus(primaryKey []byte, lockTS, callerStartTS
 					err = errors.WithStack(err)
 					return
 				}
-				return 0, 0, action, nil
+				return 0, 0, action, nil, nil
 			}
 
 			// If the caller_start_ts is MaxUint64, it's a point get in the autocommit transaction.
@@ -1433,7 +1681,7 @@ func (mvcc *MVCCLevelDB) CheckTxnStatus(primaryKey []byte, lockTS, callerStartTS
 				}
 			}
 
-			return lock.ttl, 0, action, nil
+			return lock.ttl, 0, action, lockInfoFromMvccLock(primaryKey, &lock), nil
 		}
 
 		// If current transaction's lock does not exist.
@@ -1446,10 +1694,10 @@ func (mvcc *MVCCLevelDB) CheckTxnStatus(primaryKey []byte, lockTS, callerStartTS
 		if ok {
 			// If current transaction is already committed.
 			if c.valueType != typeRollback {
-				return 0, c.commitTS, action, nil
+				return 0, c.commitTS, action, nil, nil
 			}
 			// If current transaction is already rollback.
-			return 0, 0, kvrpcpb.Action_NoAction, nil
+			return 0, 0, kvrpcpb.Action_NoAction, nil, nil
 		}
 	}
 
@@ -1462,7 +1710,7 @@ func (mvcc *MVCCLevelDB) CheckTxnStatus(primaryKey []byte, lockTS, callerStartTS
 
 	if rollbackIfNotExist {
 		if resolvingPessimisticLock {
-			return 0, 0, kvrpcpb.Action_LockNotExistDoNothing, nil
+			return 0, 0, kvrpcpb.Action_LockNotExistDoNothing, nil, nil
 		}
 		// Write rollback record, but not delete the lock on the primary key. There may exist lock which has
 		// different lock.startTS with input lockTS, for example the primary key could be already
@@ -1477,15 +1725,84 @@ func (mvcc *MVCCLevelDB) CheckTxnStatus(primaryKey []byte, lockTS, callerStartTS
 			err = errors.WithStack(err1)
 			return
 		}
-		return 0, 0, kvrpcpb.Action_LockNotExistRollback, nil
+		return 0, 0, kvrpcpb.Action_LockNotExistRollback, nil, nil
 	}
 
-	return 0, 0, action, &ErrTxnNotFound{kvrpcpb.TxnNotFound{
+	return 0, 0, action, nil, &ErrTxnNotFound{kvrpcpb.TxnNotFound{
 		StartTs:    lockTS,
 		PrimaryKey: primaryKey,
 	}}
 }
 
+// CheckSecondaryLocks implements the MVCCStore interface.
+// If all the keys are locked by the async commit transaction, the locks are
+// returned. Otherwise the transaction is committed or rolled back, the commit
+// ts is returned and it's 0 if the transaction is rolled back. The keys which
+// are not locked are rolled back to prevent the transaction from committing
+// them later.
+func (mvcc *MVCCLevelDB) CheckSecondaryLocks(keys [][]byte, startTS uint64) ([]*kvrpcpb.LockInfo, uint64, error) {
+	mvcc.mu.Lock()
+	defer mvcc.mu.Unlock()
+
+	batch := &leveldb.Batch{}
+	locks := make([]*kvrpcpb.LockInfo, 0, len(keys))
+	for _, key := range keys {
+		lock, committed, commitTS, err := checkSecondaryLock(mvcc.getDB(""), batch, key, startTS)
+		if err != nil {
+			return nil, 0, err
+		}
+		if lock != nil {
+			locks = append(locks, lock)
+			continue
+		}
+		if err = mvcc.getDB("").Write(batch, nil); err != nil {
+			return nil, 0, errors.WithStack(err)
+		}
+		if committed {
+			return nil, commitTS, nil
+		}
+		return nil, 0, nil
+	}
+	return locks, 0, nil
+}
+
+func checkSecondaryLock(db *leveldb.DB, batch *leveldb.Batch, key []byte, startTS uint64) (*kvrpcpb.LockInfo, bool, uint64, error) {
+	iter := newIterator(db, &util.Range{
+		Start: mvccEncode(key, lockVer),
+	})
+	defer iter.Release()
+
+	dec := lockDecoder{
+		expectKey: key,
+	}
+	ok, err := dec.Decode(iter)
+	if err != nil {
+		return nil, false, 0, err
+	}
+	if ok && dec.lock.startTS == startTS {
+		if dec.lock.op != kvrpcpb.Op_PessimisticLock {
+			return lockInfoFromMvccLock(key, &dec.lock), false, 0, nil
+		}
+		// The pessimistic lock is not prewritten yet, so the transaction can't
+		// be committed.
+		return nil, false, 0, rollbackLock(batch, key, startTS)
+	}
+
+	c, ok, err := getTxnCommitInfo(iter, key, startTS)
+	if err != nil {
+		return nil, false, 0, err
+	}
+	if ok {
+		if c.valueType != typeRollback {
+			return nil, true, c.commitTS, nil
+		}
+		return nil, false, 0, nil
+	}
+	// The lock is not written yet, leave a rollback record so it won't be
+	// prewritten afterwards.
+	return nil, false, 0, writeRollback(batch, key, startTS)
+}
+
 // TxnHeartBeat implements the MVCCStore interface.
 func (mvcc *MVCCLevelDB) TxnHeartBeat(key []byte, startTS uint64, adviseTTL uint64) (uint64, error) {
 	mvcc.mu.Lock()
@@ -1722,6 +2039,81 @@ func (mvcc *MVCCLevelDB) DeleteRange(startKey, endKey []byte) error {
 	return mvcc.doRawDeleteRange("", codec.EncodeBytes(nil, startKey), end)
 }
 
+// FlashbackToVersion implements the MVCCStore interface.
+func (mvcc *MVCCLevelDB) FlashbackToVersion(startKey, endKey []byte, version, startTS, commitTS uint64) error {
+	mvcc.mu.Lock()
+	defer mvcc.mu.Unlock()
+
+	iter, currKey, err := newScanIterator(mvcc.getDB(""), startKey, endKey)
+	defer iter.Release()
+	if err != nil {
+		return err
+	}
+
+	batch := &leveldb.Batch{}
+	for iter.Valid() {
+		key := currKey
+		lockDec := lockDecoder{expectKey: key}
+		ok, err := lockDec.Decode(iter)
+		if err != nil {
+			return err
+		}
+		// Like TiKV, the locks left in the range are rolled back.
+		if ok {
+			if err = rollbackLock(batch, key, lockDec.lock.startTS); err != nil {
+				return err
+			}
+		}
+
+		var latest, old *mvccValue
+		dec := valueDecoder{expectKey: key}
+		for iter.Valid() {
+			ok, err := dec.Decode(iter)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				currKey, _, err = mvccDecode(iter.Key())
+				if err != nil {
+					return err
+				}
+				break
+			}
+			if dec.value.valueType != typePut && dec.value.valueType != typeDelete {
+				continue
+			}
+			value := dec.value
+			if latest == nil {
+				latest = &value
+			}
+			if old == nil && value.commitTS <= version {
+				old = &value
+			}
+		}
+
+		latestExists := latest != nil && latest.valueType == typePut
+		oldExists := old != nil && old.valueType == typePut
+		if latestExists == oldExists && (!oldExists || bytes.Equal(latest.value, old.value)) {
+			continue
+		}
+		value := mvccValue{
+			valueType: typeDelete,
+			startTS:   startTS,
+			commitTS:  commitTS,
+		}
+		if oldExists {
+			value.valueType = typePut
+			value.value = old.value
+		}
+		writeValue, err := value.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		batch.Put(mvccEncode(key, commitTS), writeValue)
+	}
+	return mvcc.getDB("").Write(batch, nil)
+}
+
 // Close calls leveldb's Close to free resources.
 func (mvcc *MVCCLevelDB) Close() error {
 	return mvcc.getDB("").Close()
@@ -1729,6 +2121,11 @@ func (mvcc *MVCCLevelDB) Close() error {
 
 // RawPut implements the RawKV interface.
 func (mvcc *MVCCLevelDB) RawPut(cf string, key, value []byte) {
+	mvcc.RawPutWithTTL(cf, key, value, 0)
+}
+
+// RawPutWithTTL implements the RawTTL interface.
+func (mvcc *MVCCLevelDB) RawPutWithTTL(cf string, key, value []byte, ttl uint64) {
 	mvcc.mu.Lock()
 	defer mvcc.mu.Unlock()
 
@@ -1743,10 +2140,17 @@ func (mvcc *MVCCLevelDB) RawPut(cf string, key, value []byte) {
 	}
 
 	tikverr.Log(db.Put(key, value, nil))
+	mvcc.setRawTTL(cf, key, ttl)
 }
 
 // RawBatchPut implements the RawKV interface
 func (mvcc *MVCCLevelDB) RawBatchPut(cf string, keys, values [][]byte) {
+	mvcc.RawBatchPutWithTTL(cf, keys, values, nil)
+}
+
+// RawBatchPutWithTTL implements the RawTTL interface. The ttls can be empty
+// when none of the keys has TTL.
+func (mvcc *MVCCLevelDB) RawBatchPutWithTTL(cf string, keys, values [][]byte, ttls []uint64) {
 	mvcc.mu.Lock()
 	defer mvcc.mu.Unlock()
 
@@ -1769,6 +2173,47 @@ func (mvcc *MVCCLevelDB) RawBatchPut(cf string, keys, values [][]byte) {
 		batch.Put(key, value)
 	}
 	tikverr.Log(db.Write(batch, nil))
+	for i, key := range keys {
+		var ttl uint64
+		if len(ttls) > 0 {
+			ttl = ttls[i]
+		}
+		mvcc.setRawTTL(cf, key, ttl)
+	}
+}
+
+// setRawTTL records the TTL of a raw key, mvcc.mu must be held.
+func (mvcc *MVCCLevelDB) setRawTTL(cf string, key []byte, ttl uint64) {
+	k := rawTTLKey{cf: cf, key: string(key)}
+	if ttl == 0 {
+		delete(mvcc.rawTTLs, k)
+		return
+	}
+	mvcc.rawTTLs[k] = time.Now().Add(time.Duration(ttl) * time.Second)
+}
+
+// RawGetKeyTTL implements the RawTTL interface.
+func (mvcc *MVCCLevelDB) RawGetKeyTTL(cf string, key []byte) (uint64, bool) {
+	mvcc.mu.Lock()
+	defer mvcc.mu.Unlock()
+
+	db := mvcc.getDB(cf)
+	if db == nil {
+		return 0, false
+	}
+	if _, err := db.Get(key, nil); err != nil {
+		return 0, false
+	}
+	expireAt, ok := mvcc.rawTTLs[rawTTLKey{cf: cf, key: string(key)}]
+	if !ok {
+		return 0, true
+	}
+	remaining := time.Until(expireAt)
+	if remaining <= 0 {
+		return 0, false
+	}
+	// Round up so that a key with TTL never reports 0.
+	return uint64((remaining + time.Second - 1) / time.Second), true
 }
 
 // RawGet implements the RawKV interface.
@@ -1820,6 +2265,7 @@ func (mvcc *MVCCLevelDB) RawDelete(cf string, key []byte) {
 		return
 	}
 	tikverr.Log(db.Delete(key, nil))
+	mvcc.setRawTTL(cf, key, 0)
 }
 
 // RawBatchDelete implements the RawKV interface.
@@ -1981,6 +2427,7 @@ func (mvcc *MVCCLevelDB) RawCompareAndSwap(cf string, key, expectedValue, newVal
 		tikverr.Log(err)
 		return oldValue, false, errors.WithStack(err)
 	}
+	mvcc.setRawTTL(cf, key, 0)
 
 	return oldValue, true, nil
 }
@@ -2039,6 +2486,20 @@ var valueTypeOpMap = [...]kvrpcpb.Op{
 	typeLock:     kvrpcpb.Op_Lock,
 }
 
+// GetLockWaitInfo implements the LockWaiter interface.
+func (mvcc *MVCCLevelDB) GetLockWaitInfo() []*deadlockpb.WaitForEntry {
+	waits := mvcc.deadlockDetector.WaitForEntries()
+	entries := make([]*deadlockpb.WaitForEntry, 0, len(waits))
+	for _, wait := range waits {
+		entries = append(entries, &deadlockpb.WaitForEntry{
+			Txn:        wait.Txn,
+			WaitForTxn: wait.WaitForTxn,
+			KeyHash:    wait.KeyHash,
+		})
+	}
+	return entries
+}
+
 // MvccGetByKey implements the MVCCDebugger interface.
 func (mvcc *MVCCLevelDB) MvccGetByKey(key []byte) *kvrpcpb.MvccInfo {
 	mvcc.mu.RLock()
@@ -76,6 +76,9 @@ func convertToKeyError(err error) *kvrpcpb.KeyError {
 				TxnSize:         locked.TxnSize,
 				LockType:        locked.LockType,
 				LockForUpdateTs: locked.ForUpdateTS,
+				MinCommitTs:     locked.MinCommitTS,
+				UseAsyncCommit:  locked.UseAsyncCommit,
+				Secondaries:     locked.Secondaries,
 			},
 		}
 	}
@@ -228,7 +231,7 @@ func (h kvHandler) handleKvPrewrite(req *kvrpcpb.PrewriteRequest) *kvrpcpb.Prewr
 			panic("KvPrewrite: key not in region")
 		}
 	}
-	errs := h.mvccStore.Prewrite(req)
+	errs, minCommitTS, onePCCommitTS := h.mvccStore.Prewrite(req)
 	for i, e := range errs {
 		if e != nil {
 			if _, isLocked := errors.Cause(e).(*ErrLocked); !isLocked {
@@ -239,6 +242,29 @@ func (h kvHandler) handleKvPrewrite(req *kvrpcpb.PrewriteRequest) *kvrpcpb.Prewr
 		}
 	}
 	return &kvrpcpb.PrewriteResponse{
+		Errors:        convertToKeyErrors(errs),
+		MinCommitTs:   minCommitTS,
+		OnePcCommitTs: onePCCommitTS,
+	}
+}
+
+func (h kvHandler) handleKvFlush(req *kvrpcpb.FlushRequest) *kvrpcpb.FlushResponse {
+	for _, m := range req.Mutations {
+		if !h.checkKeyInRegion(m.Key) {
+			panic("KvFlush: key not in region")
+		}
+	}
+	errs := h.mvccStore.Flush(req)
+	for i, e := range errs {
+		if e != nil {
+			if _, isLocked := errors.Cause(e).(*ErrLocked); !isLocked {
+				// Keep only one error if it's not a KeyIsLocked error.
+				errs = errs[i : i+1]
+				break
+			}
+		}
+	}
+	return &kvrpcpb.FlushResponse{
 		Errors: convertToKeyErrors(errs),
 	}
 }
@@ -311,11 +337,27 @@ func (h kvHandler) handleKvCheckTxnStatus(req *kvrpcpb.CheckTxnStatusRequest) *k
 		panic("KvCheckTxnStatus: key not in region")
 	}
 	var resp kvrpcpb.CheckTxnStatusResponse
-	ttl, commitTS, action, err := h.mvccStore.CheckTxnStatus(req.GetPrimaryKey(), req.GetLockTs(), req.GetCallerStartTs(), req.GetCurrentTs(), req.GetRollbackIfNotExist(), req.ResolvingPessimisticLock)
+	ttl, commitTS, action, lockInfo, err := h.mvccStore.CheckTxnStatus(req.GetPrimaryKey(), req.GetLockTs(), req.GetCallerStartTs(), req.GetCurrentTs(), req.GetRollbackIfNotExist(), req.ResolvingPessimisticLock)
 	if err != nil {
 		resp.Error = convertToKeyError(err)
 	} else {
-		resp.LockTtl, resp.CommitVersion, resp.Action = ttl, commitTS, action
+		resp.LockTtl, resp.CommitVersion, resp.Action, resp.LockInfo = ttl, commitTS, action, lockInfo
+	}
+	return &resp
+}
+
+func (h kvHandler) handleKvCheckSecondaryLocks(req *kvrpcpb.CheckSecondaryLocksRequest) *kvrpcpb.CheckSecondaryLocksResponse {
+	for _, k := range req.Keys {
+		if !h.checkKeyInRegion(k) {
+			panic("KvCheckSecondaryLocks: key not in region")
+		}
+	}
+	var resp kvrpcpb.CheckSecondaryLocksResponse
+	locks, commitTS, err := h.mvccStore.CheckSecondaryLocks(req.GetKeys(), req.GetStartVersion())
+	if err != nil {
+		resp.Error = convertToKeyError(err)
+	} else {
+		resp.Locks, resp.CommitTs = locks, commitTS
 	}
 	return &resp
 }
@@ -345,6 +387,18 @@ func (h kvHandler) handleKvBatchGet(req *kvrpcpb.BatchGetRequest) *kvrpcpb.Batch
 	}
 }
 
+func (h kvHandler) handleKvBufferBatchGet(req *kvrpcpb.BufferBatchGetRequest) *kvrpcpb.BufferBatchGetResponse {
+	for _, k := range req.Keys {
+		if !h.checkKeyInRegion(k) {
+			panic("KvBufferBatchGet: key not in region")
+		}
+	}
+	pairs := h.mvccStore.BufferBatchGet(req.Keys, req.GetVersion())
+	return &kvrpcpb.BufferBatchGetResponse{
+		Pairs: convertToPbPairs(pairs),
+	}
+}
+
 func (h kvHandler) handleMvccGetByKey(req *kvrpcpb.MvccGetByKeyRequest) *kvrpcpb.MvccGetByKeyResponse {
 	debugger, ok := h.mvccStore.(MVCCDebugger)
 	if !ok {
@@ -433,6 +487,135 @@ func (h kvHandler) handleKvDeleteRange(req *kvrpcpb.DeleteRangeRequest) *kvrpcpb
 	return &resp
 }
 
+func (h kvHandler) handleKvPrepareFlashbackToVersion(req *kvrpcpb.PrepareFlashbackToVersionRequest) *kvrpcpb.PrepareFlashbackToVersionResponse {
+	if !h.checkKeyInRegion(req.StartKey) {
+		panic("KvPrepareFlashbackToVersion: key not in region")
+	}
+	h.cluster.PrepareFlashback(req.Context.GetRegionId(), req.GetStartTs())
+	return &kvrpcpb.PrepareFlashbackToVersionResponse{}
+}
+
+func (h kvHandler) handleKvFlashbackToVersion(req *kvrpcpb.FlashbackToVersionRequest) *kvrpcpb.FlashbackToVersionResponse {
+	if !h.checkKeyInRegion(req.StartKey) {
+		panic("KvFlashbackToVersion: key not in region")
+	}
+	regionID := req.Context.GetRegionId()
+	region, _ := h.cluster.GetRegion(regionID)
+	if !region.GetIsInFlashback() || region.GetFlashbackStartTs() != req.GetStartTs() {
+		return &kvrpcpb.FlashbackToVersionResponse{
+			RegionError: &errorpb.Error{
+				Message:              "region is not prepared for the flashback",
+				FlashbackNotPrepared: &errorpb.FlashbackNotPrepared{RegionId: regionID},
+			},
+		}
+	}
+	var resp kvrpcpb.FlashbackToVersionResponse
+	err := h.mvccStore.FlashbackToVersion(req.StartKey, req.EndKey, req.GetVersion(), req.GetStartTs(), req.GetCommitTs())
+	if err != nil {
+		resp.Error = err.Error()
+		return &resp
+	}
+	h.cluster.FinishFlashback(regionID)
+	return &resp
+}
+
+func (h kvHandler) handleKvUnsafeDestroyRange(req *kvrpcpb.UnsafeDestroyRangeRequest) *kvrpcpb.UnsafeDestroyRangeResponse {
+	var resp kvrpcpb.UnsafeDestroyRangeResponse
+	err := h.mvccStore.DeleteRange(req.StartKey, req.EndKey)
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return &resp
+}
+
+func (h kvHandler) handleRegisterLockObserver(req *kvrpcpb.RegisterLockObserverRequest) *kvrpcpb.RegisterLockObserverResponse {
+	observer, ok := h.mvccStore.(LockObserver)
+	if !ok {
+		return &kvrpcpb.RegisterLockObserverResponse{
+			Error: "not implemented",
+		}
+	}
+	var resp kvrpcpb.RegisterLockObserverResponse
+	if err := observer.RegisterLockObserver(h.storeID, req.GetMaxTs()); err != nil {
+		resp.Error = err.Error()
+	}
+	return &resp
+}
+
+func (h kvHandler) handleCheckLockObserver(req *kvrpcpb.CheckLockObserverRequest) *kvrpcpb.CheckLockObserverResponse {
+	observer, ok := h.mvccStore.(LockObserver)
+	if !ok {
+		return &kvrpcpb.CheckLockObserverResponse{
+			Error: "not implemented",
+		}
+	}
+	locks, err := observer.CheckLockObserver(h.storeID, req.GetMaxTs())
+	if err != nil {
+		return &kvrpcpb.CheckLockObserverResponse{
+			Error: err.Error(),
+		}
+	}
+	// All the stores share the same MVCCStore, only the locks in the regions of
+	// the store are observed by it.
+	inStore := h.storeKeyFilter()
+	observed := locks[:0]
+	for _, lock := range locks {
+		if inStore(lock.Key) {
+			observed = append(observed, lock)
+		}
+	}
+	locks = observed
+	// The observer of mocktikv never drops locks, so it is always clean.
+	return &kvrpcpb.CheckLockObserverResponse{
+		IsClean: true,
+		Locks:   locks,
+	}
+}
+
+func (h kvHandler) handleRemoveLockObserver(req *kvrpcpb.RemoveLockObserverRequest) *kvrpcpb.RemoveLockObserverResponse {
+	observer, ok := h.mvccStore.(LockObserver)
+	if !ok {
+		return &kvrpcpb.RemoveLockObserverResponse{
+			Error: "not implemented",
+		}
+	}
+	var resp kvrpcpb.RemoveLockObserverResponse
+	if err := observer.RemoveLockObserver(h.storeID, req.GetMaxTs()); err != nil {
+		resp.Error = err.Error()
+	}
+	return &resp
+}
+
+func (h kvHandler) handlePhysicalScanLock(req *kvrpcpb.PhysicalScanLockRequest) *kvrpcpb.PhysicalScanLockResponse {
+	observer, ok := h.mvccStore.(LockObserver)
+	if !ok {
+		return &kvrpcpb.PhysicalScanLockResponse{
+			Error: "not implemented",
+		}
+	}
+	locks, err := observer.PhysicalScanLock(req.GetStartKey(), req.GetMaxTs(), int(req.GetLimit()), h.storeKeyFilter())
+	if err != nil {
+		return &kvrpcpb.PhysicalScanLockResponse{
+			Error: err.Error(),
+		}
+	}
+	return &kvrpcpb.PhysicalScanLockResponse{
+		Locks: locks,
+	}
+}
+
+func (h kvHandler) handleGetLockWaitInfo(req *kvrpcpb.GetLockWaitInfoRequest) *kvrpcpb.GetLockWaitInfoResponse {
+	waiter, ok := h.mvccStore.(LockWaiter)
+	if !ok {
+		return &kvrpcpb.GetLockWaitInfoResponse{
+			Error: "not implemented",
+		}
+	}
+	return &kvrpcpb.GetLockWaitInfoResponse{
+		Entries: waiter.GetLockWaitInfo(),
+	}
+}
+
 func (h kvHandler) handleKvRawGet(req *kvrpcpb.RawGetRequest) *kvrpcpb.RawGetResponse {
 	rawKV, ok := h.mvccStore.(RawKV)
 	if !ok {
@@ -477,6 +660,16 @@ func (h kvHandler) handleKvRawPut(req *kvrpcpb.RawPutRequest) *kvrpcpb.RawPutRes
 			Error: "not implemented",
 		}
 	}
+	if req.GetTtl() > 0 {
+		rawTTL, ok := h.mvccStore.(RawTTL)
+		if !ok {
+			return &kvrpcpb.RawPutResponse{
+				Error: "ttl not implemented",
+			}
+		}
+		rawTTL.RawPutWithTTL(req.GetCf(), req.GetKey(), req.GetValue(), req.GetTtl())
+		return &kvrpcpb.RawPutResponse{}
+	}
 	rawKV.RawPut(req.GetCf(), req.GetKey(), req.GetValue())
 	return &kvrpcpb.RawPutResponse{}
 }
@@ -494,10 +687,50 @@ func (h kvHandler) handleKvRawBatchPut(req *kvrpcpb.RawBatchPutRequest) *kvrpcpb
 		keys = append(keys, pair.Key)
 		values = append(values, pair.Value)
 	}
+	ttls := req.GetTtls()
+	if len(ttls) == 0 && req.GetTtl() > 0 {
+		ttls = []uint64{req.GetTtl()}
+	}
+	if len(ttls) == 1 && len(keys) > 1 {
+		ttl := ttls[0]
+		ttls = make([]uint64, len(keys))
+		for i := range ttls {
+			ttls[i] = ttl
+		}
+	}
+	if len(ttls) > 0 {
+		if len(ttls) != len(keys) {
+			return &kvrpcpb.RawBatchPutResponse{
+				Error: "the number of ttls mismatches the number of pairs",
+			}
+		}
+		rawTTL, ok := h.mvccStore.(RawTTL)
+		if !ok {
+			return &kvrpcpb.RawBatchPutResponse{
+				Error: "ttl not implemented",
+			}
+		}
+		rawTTL.RawBatchPutWithTTL(req.GetCf(), keys, values, ttls)
+		return &kvrpcpb.RawBatchPutResponse{}
+	}
 	rawKV.RawBatchPut(req.GetCf(), keys, values)
 	return &kvrpcpb.RawBatchPutResponse{}
 }
 
+func (h kvHandler) handleKvRawGetKeyTTL(req *kvrpcpb.RawGetKeyTTLRequest) *kvrpcpb.RawGetKeyTTLResponse {
+	rawTTL, ok := h.mvccStore.(RawTTL)
+	if !ok {
+		return &kvrpcpb.RawGetKeyTTLResponse{
+			Error: "not implemented",
+		}
+	}
+	ttl, found := rawTTL.RawGetKeyTTL(req.GetCf(), req.GetKey())
+	return &kvrpcpb.RawGetKeyTTLResponse{
+		Ttl:      ttl,
+		NotFound: !found,
+	}
+}
+
 func (h kvHandler) handleKvRawDelete(req *kvrpcpb.RawDeleteRequest) *kvrpcpb.RawDeleteResponse {
 	rawKV, ok := h.mvccStore.(RawKV)
 	if !ok {
@@ -599,6 +832,36 @@ func (h kvHandler) handleKvRawScan(req *kvrpcpb.RawScanRequest) *kvrpcpb.RawScan
 	}
 }
 
+func (h kvHandler) handleKvRawBatchScan(req *kvrpcpb.RawBatchScanRequest) *kvrpcpb.RawBatchScanResponse {
+	rawKV, ok := h.mvccStore.(RawKV)
+	if !ok {
+		errStr := "not implemented"
+		return &kvrpcpb.RawBatchScanResponse{
+			RegionError: &errorpb.Error{
+				Message: errStr,
+			},
+		}
+	}
+
+	var pairs []Pair
+	for _, r := range req.Ranges {
+		upperBound := h.endKey
+		if len(r.EndKey) > 0 && (len(upperBound) == 0 || bytes.Compare(r.EndKey, upperBound) < 0) {
+			upperBound = r.EndKey
+		}
+		pairs = append(pairs, rawKV.RawScan(
+			req.GetCf(),
+			r.StartKey,
+			upperBound,
+			int(req.GetEachLimit()),
+		)...)
+	}
+
+	return &kvrpcpb.RawBatchScanResponse{
+		Kvs: convertToPbPairs(pairs),
+	}
+}
+
 func (h kvHandler) handleKvRawChecksum(req *kvrpcpb.RawChecksumRequest) *kvrpcpb.RawChecksumResponse {
 	rawKV, ok := h.mvccStore.(RawKV)
 	if !ok {
@@ -752,6 +1015,9 @@ func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.R
 	if err != nil {
 		return nil, err
 	}
+	if err := session.checkFlashback(req); err != nil {
+		return tikvrpc.GenRegionErrorResp(req, err)
+	}
 	switch req.Type {
 	case tikvrpc.CmdGet:
 		r := req.Get()
@@ -869,6 +1135,13 @@ func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.R
 			return resp, nil
 		}
 		resp.Resp = kvHandler{session}.handleKvCheckTxnStatus(r)
+	case tikvrpc.CmdCheckSecondaryLocks:
+		r := req.CheckSecondaryLocks()
+		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
+			resp.Resp = &kvrpcpb.CheckSecondaryLocksResponse{RegionError: err}
+			return resp, nil
+		}
+		resp.Resp = kvHandler{session}.handleKvCheckSecondaryLocks(r)
 	case tikvrpc.CmdTxnHeartBeat:
 		r := req.TxnHeartBeat()
 		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
@@ -883,6 +1156,23 @@ func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.R
 			return resp, nil
 		}
 		resp.Resp = kvHandler{session}.handleKvBatchGet(r)
+	case tikvrpc.CmdFlush:
+		r := req.Flush()
+		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
+			resp.Resp = &kvrpcpb.FlushResponse{RegionError: err}
+			return resp, nil
+		}
+		resp.Resp = kvHandler{session}.handleKvFlush(r)
+	case tikvrpc.CmdBufferBatchGet:
+		r := req.BufferBatchGet()
+		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
+			resp.Resp = &kvrpcpb.BufferBatchGetResponse{RegionError: err}
+			return resp, nil
+		}
+		resp.Resp = kvHandler{session}.handleKvBufferBatchGet(r)
+	case tikvrpc.CmdBroadcastTxnStatus:
+		// mocktikv has no txn status cache, the broadcast is accepted and ignored.
+		resp.Resp = &kvrpcpb.BroadcastTxnStatusResponse{}
 	case tikvrpc.CmdBatchRollback:
 		r := req.BatchRollback()
 		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
@@ -918,6 +1208,20 @@ func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.R
 			return resp, nil
 		}
 		resp.Resp = kvHandler{session}.handleKvDeleteRange(r)
+	case tikvrpc.CmdPrepareFlashbackToVersion:
+		r := req.PrepareFlashbackToVersion()
+		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
+			resp.Resp = &kvrpcpb.PrepareFlashbackToVersionResponse{RegionError: err}
+			return resp, nil
+		}
+		resp.Resp = kvHandler{session}.handleKvPrepareFlashbackToVersion(r)
+	case tikvrpc.CmdFlashbackToVersion:
+		r := req.FlashbackToVersion()
+		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
+			resp.Resp = &kvrpcpb.FlashbackToVersionResponse{RegionError: err}
+			return resp, nil
+		}
+		resp.Resp = kvHandler{session}.handleKvFlashbackToVersion(r)
 	case tikvrpc.CmdRawGet:
 		r := req.RawGet()
 		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
@@ -945,7 +1249,20 @@ func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.R
 			resp.Resp = &kvrpcpb.RawBatchPutResponse{RegionError: err}
 			return resp, nil
 		}
+		if val, err := util.EvalFailpoint("rpcRawBatchPutError"); err == nil {
+			if uint64(val.(int)) == reqCtx.GetRegionId() {
+				resp.Resp = &kvrpcpb.RawBatchPutResponse{Error: "injected raw batch put error"}
+				return resp, nil
+			}
+		}
 		resp.Resp = kvHandler{session}.handleKvRawBatchPut(r)
+	case tikvrpc.CmdRawGetKeyTTL:
+		r := req.RawGetKeyTTL()
+		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
+			resp.Resp = &kvrpcpb.RawGetKeyTTLResponse{RegionError: err}
+			return resp, nil
+		}
+		resp.Resp = kvHandler{session}.handleKvRawGetKeyTTL(r)
 	case tikvrpc.CmdRawDelete:
 		r := req.RawDelete()
 		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
@@ -973,6 +1290,13 @@ func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.R
 			return resp, nil
 		}
 		resp.Resp = kvHandler{session}.handleKvRawScan(r)
+	case tikvrpc.CmdRawBatchScan:
+		r := req.RawBatchScan()
+		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
+			resp.Resp = &kvrpcpb.RawBatchScanResponse{RegionError: err}
+			return resp, nil
+		}
+		resp.Resp = kvHandler{session}.handleKvRawBatchScan(r)
 	case tikvrpc.CmdRawCompareAndSwap:
 		r := req.RawCompareAndSwap()
 		if err := session.checkRequest(reqCtx, r.Size()); err != nil {
@@ -988,15 +1312,41 @@ func (c *RPCClient) SendRequest(ctx context.Context, addr string, req *tikvrpc.R
 		}
 		resp.Resp = kvHandler{session}.handleKvRawChecksum(r)
 	case tikvrpc.CmdUnsafeDestroyRange:
-		panic("unimplemented")
+		if val, err := util.EvalFailpoint("rpcUnsafeDestroyRangeError"); err == nil {
+			resp.Resp = &kvrpcpb.UnsafeDestroyRangeResponse{Error: val.(string)}
+			return resp, nil
+		}
+		resp.Resp = kvHandler{session}.handleKvUnsafeDestroyRange(req.UnsafeDestroyRange())
 	case tikvrpc.CmdRegisterLockObserver:
-		return nil, errors.New("unimplemented")
+		if val, err := util.EvalFailpoint("rpcRegisterLockObserverError"); err == nil {
+			resp.Resp = &kvrpcpb.RegisterLockObserverResponse{Error: val.(string)}
+			return resp, nil
+		}
+		resp.Resp = kvHandler{session}.handleRegisterLockObserver(req.RegisterLockObserver())
 	case tikvrpc.CmdCheckLockObserver:
-		return nil, errors.New("unimplemented")
+		if val, err := util.EvalFailpoint("rpcCheckLockObserverError"); err == nil {
+			resp.Resp = &kvrpcpb.CheckLockObserverResponse{Error: val.(string)}
+			return resp, nil
+		}
+		checkResp := kvHandler{session}.handleCheckLockObserver(req.CheckLockObserver())
+		if val, err := util.EvalFailpoint("rpcCheckLockObserverDirty"); err == nil && val.(bool) {
+			checkResp.IsClean = false
+		}
+		resp.Resp = checkResp
 	case tikvrpc.CmdRemoveLockObserver:
-		return nil, errors.New("unimplemented")
+		if val, err := util.EvalFailpoint("rpcRemoveLockObserverError"); err == nil {
+			resp.Resp = &kvrpcpb.RemoveLockObserverResponse{Error: val.(string)}
+			return resp, nil
+		}
+		resp.Resp = kvHandler{session}.handleRemoveLockObserver(req.RemoveLockObserver())
 	case tikvrpc.CmdPhysicalScanLock:
-		return nil, errors.New("unimplemented")
+		if val, err := util.EvalFailpoint("rpcPhysicalScanLockError"); err == nil {
+			resp.Resp = &kvrpcpb.PhysicalScanLockResponse{Error: val.(string)}
+			return resp, nil
+		}
+		resp.Resp = kvHandler{session}.handlePhysicalScanLock(req.PhysicalScanLock())
+	case tikvrpc.CmdLockWaitInfo:
+		resp.Resp = kvHandler{session}.handleGetLockWaitInfo(req.LockWaitInfo())
 	case tikvrpc.CmdCop:
 		if c.coprHandler == nil {
 			return nil, errors.New("unimplemented")
@@ -39,6 +39,7 @@ import (
 	"github.com/pingcap/kvproto/pkg/errorpb"
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/client-go/v2/tikvrpc"
 )
 
 // Session stores session scope rpc data.
@@ -178,10 +179,48 @@ func (s *Session) checkRequest(ctx *kvrpcpb.Context, size int) *errorpb.Error {
 	return s.checkRequestSize(size)
 }
 
+// checkFlashback rejects the requests to a Region in flashback progress, except
+// the flashback requests themselves.
+func (s *Session) checkFlashback(req *tikvrpc.Request) *errorpb.Error {
+	if req.Type == tikvrpc.CmdPrepareFlashbackToVersion || req.Type == tikvrpc.CmdFlashbackToVersion {
+		return nil
+	}
+	regionID := req.Context.GetRegionId()
+	if regionID == 0 {
+		return nil
+	}
+	region, _ := s.cluster.GetRegion(regionID)
+	if !region.GetIsInFlashback() {
+		return nil
+	}
+	return &errorpb.Error{
+		Message: *proto.String("region is in flashback progress"),
+		FlashbackInProgress: &errorpb.FlashbackInProgress{
+			RegionId:         regionID,
+			FlashbackStartTs: region.GetFlashbackStartTs(),
+		},
+	}
+}
+
 func (s *Session) checkKeyInRegion(key []byte) bool {
 	return regionContains(s.startKey, s.endKey, NewMvccKey(key))
 }
 
+// storeKeyFilter returns a filter of the keys in the regions which have peers on
+// the store of the session.
+func (s *Session) storeKeyFilter() func(key []byte) bool {
+	regions := s.cluster.getStoreRegions(s.storeID)
+	return func(key []byte) bool {
+		mvccKey := NewMvccKey(key)
+		for _, region := range regions {
+			if regionContains(region.GetStartKey(), region.GetEndKey(), mvccKey) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 func isTiFlashRelatedStore(store *metapb.Store) bool {
 	for _, l := range store.GetLabels() {
 		if l.GetKey() == "engine" && (l.GetValue() == "tiflash" || l.GetValue() == "tiflash_mpp") {
@@ -64,7 +64,7 @@ func mustPrewriteWithTTL(store MVCCStore, mutations []*kvrpcpb.Mutation, primary
 		LockTtl:      ttl,
 		MinCommitTs:  startTS + 1,
 	}
-	errs := store.Prewrite(req)
+	errs, _, _ := store.Prewrite(req)
 	for _, err := range errs {
 		if err != nil {
 			return false
@@ -90,6 +90,13 @@ var (
 	TiKVGRPCConnTransientFailureCounter            *prometheus.CounterVec
 	TiKVPanicCounter                               *prometheus.CounterVec
 	TiKVForwardRequestCounter                      *prometheus.CounterVec
+	TiKVHedgedRequestCounter                       *prometheus.CounterVec
+	TiKVLocalityRoutingCounter                     *prometheus.CounterVec
+	TiKVStoreConcurrencyLimit                      *prometheus.GaugeVec
+	TiKVStoreConcurrencyQueueDepth                 *prometheus.GaugeVec
+	TiKVStoreCircuitBreakerCounter                 *prometheus.CounterVec
+	TiKVGRPCUncompressedBytes                      *prometheus.CounterVec
+	TiKVGRPCCompressedBytes                        *prometheus.CounterVec
 	TiKVTSFutureWaitDuration                       prometheus.Histogram
 	TiKVSafeTSUpdateCounter                        *prometheus.CounterVec
 	TiKVMinSafeTSGapSeconds                        *prometheus.GaugeVec
@@ -117,6 +124,8 @@ var (
 	TiKVLowResolutionTSOUpdateIntervalSecondsGauge prometheus.Gauge
 	TiKVStaleRegionFromPDCounter                   prometheus.Counter
 	TiKVPipelinedFlushThrottleSecondsHistogram     prometheus.Histogram
+	TiKVCoprCacheCounter                           *prometheus.CounterVec
+	TiKVCoprCacheMemoryGauge                       prometheus.Gauge
 )
 
 // Label constants.
@@ -142,6 +151,7 @@ const (
 	LblGeneral         = "general"
 	LblDirection       = "direction"
 	LblReason          = "reason"
+	LblCompressor      = "compressor"
 )
 
 func initMetrics(namespace, subsystem string, constLabels prometheus.Labels) {
@@ -598,6 +608,69 @@ func initMetrics(namespace, subsystem string, constLabels prometheus.Labels) {
 			ConstLabels: constLabels,
 		}, []string{LblFromStore, LblToStore, LblType, LblResult})
 
+	TiKVHedgedRequestCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "hedged_request_counter",
+			Help:        "Counter of hedged read requests being sent and winning",
+			ConstLabels: constLabels,
+		}, []string{LblType, LblResult})
+
+	TiKVLocalityRoutingCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "locality_routing_counter",
+			Help:        "Counter of read requests routed by the locality policy, by the decision and the zone of the target",
+			ConstLabels: constLabels,
+		}, []string{LblType, LblScope})
+
+	TiKVStoreConcurrencyLimit = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "store_concurrency_limit",
+			Help:        "The adaptive concurrency limit of the requests sent to each store",
+			ConstLabels: constLabels,
+		}, []string{LblStore})
+
+	TiKVStoreConcurrencyQueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "store_concurrency_queue_depth",
+			Help:        "The number of requests waiting for the concurrency limit of each store",
+			ConstLabels: constLabels,
+		}, []string{LblStore})
+
+	TiKVStoreCircuitBreakerCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "store_circuit_breaker_counter",
+			Help:        "Counter of the state changes and the fast failed requests of the circuit breaker of each store",
+			ConstLabels: constLabels,
+		}, []string{LblStore, LblType})
+
+	TiKVGRPCUncompressedBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "grpc_uncompressed_bytes",
+			Help:        "Counter of the bytes of the gRPC messages sent to TiKV before compression",
+			ConstLabels: constLabels,
+		}, []string{LblStore, LblType, LblCompressor})
+
+	TiKVGRPCCompressedBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "grpc_compressed_bytes",
+			Help:        "Counter of the bytes of the gRPC messages sent to TiKV after compression",
+			ConstLabels: constLabels,
+		}, []string{LblStore, LblType, LblCompressor})
+
 	TiKVTSFutureWaitDuration = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
 			Namespace:   namespace,
@@ -838,6 +911,24 @@ func initMetrics(namespace, subsystem string, constLabels prometheus.Labels) {
 			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 28), // 0.5ms ~ 18h
 		})
 
+	TiKVCoprCacheCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "copr_cache_counter",
+			Help:        "Counter of coprocessor cache lookups and admissions.",
+			ConstLabels: constLabels,
+		}, []string{LblType})
+
+	TiKVCoprCacheMemoryGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "copr_cache_memory_bytes",
+			Help:        "Memory used by the coprocessor cache.",
+			ConstLabels: constLabels,
+		})
+
 	initShortcuts()
 }
 
@@ -906,6 +997,13 @@ func RegisterMetrics() {
 	prometheus.MustRegister(TiKVGRPCConnTransientFailureCounter)
 	prometheus.MustRegister(TiKVPanicCounter)
 	prometheus.MustRegister(TiKVForwardRequestCounter)
+	prometheus.MustRegister(TiKVHedgedRequestCounter)
+	prometheus.MustRegister(TiKVLocalityRoutingCounter)
+	prometheus.MustRegister(TiKVStoreConcurrencyLimit)
+	prometheus.MustRegister(TiKVStoreConcurrencyQueueDepth)
+	prometheus.MustRegister(TiKVStoreCircuitBreakerCounter)
+	prometheus.MustRegister(TiKVGRPCUncompressedBytes)
+	prometheus.MustRegister(TiKVGRPCCompressedBytes)
 	prometheus.MustRegister(TiKVTSFutureWaitDuration)
 	prometheus.MustRegister(TiKVSafeTSUpdateCounter)
 	prometheus.MustRegister(TiKVMinSafeTSGapSeconds)
@@ -933,6 +1031,8 @@ func RegisterMetrics() {
 	prometheus.MustRegister(TiKVLowResolutionTSOUpdateIntervalSecondsGauge)
 	prometheus.MustRegister(TiKVStaleRegionFromPDCounter)
 	prometheus.MustRegister(TiKVPipelinedFlushThrottleSecondsHistogram)
+	prometheus.MustRegister(TiKVCoprCacheCounter)
+	prometheus.MustRegister(TiKVCoprCacheMemoryGauge)
 }
 
 // readCounter reads the value of a prometheus.Counter.
@@ -52,10 +52,14 @@ var (
 	RawkvCmdHistogramWithGet           prometheus.Observer
 	RawkvCmdHistogramWithBatchGet      prometheus.Observer
 	RawkvCmdHistogramWithBatchPut      prometheus.Observer
+	RawkvCmdHistogramWithBatchWrite    prometheus.Observer
 	RawkvCmdHistogramWithDelete        prometheus.Observer
 	RawkvCmdHistogramWithBatchDelete   prometheus.Observer
 	RawkvCmdHistogramWithRawScan       prometheus.Observer
 	RawkvCmdHistogramWithRawReversScan prometheus.Observer
+	RawkvCmdHistogramWithRawBatchScan  prometheus.Observer
+	RawkvCmdHistogramWithBatchGetTTL   prometheus.Observer
+	RawkvCmdHistogramWithRawScanTTL    prometheus.Observer
 	RawkvSizeHistogramWithKey          prometheus.Observer
 	RawkvSizeHistogramWithValue        prometheus.Observer
 	RawkvCmdHistogramWithRawChecksum   prometheus.Observer
@@ -177,6 +181,12 @@ var (
 	BatchRequestDurationSend prometheus.Observer
 	BatchRequestDurationRecv prometheus.Observer
 	BatchRequestDurationDone prometheus.Observer
+
+	CoprCacheCounterHit    prometheus.Counter
+	CoprCacheCounterMiss   prometheus.Counter
+	CoprCacheCounterAdmit  prometheus.Counter
+	CoprCacheCounterReject prometheus.Counter
+	CoprCacheCounterEvict  prometheus.Counter
 )
 
 func initShortcuts() {
@@ -194,10 +204,14 @@ func initShortcuts() {
 	RawkvCmdHistogramWithGet = TiKVRawkvCmdHistogram.WithLabelValues("get")
 	RawkvCmdHistogramWithBatchGet = TiKVRawkvCmdHistogram.WithLabelValues("batch_get")
 	RawkvCmdHistogramWithBatchPut = TiKVRawkvCmdHistogram.WithLabelValues("batch_put")
+	RawkvCmdHistogramWithBatchWrite = TiKVRawkvCmdHistogram.WithLabelValues("batch_write")
 	RawkvCmdHistogramWithDelete = TiKVRawkvCmdHistogram.WithLabelValues("delete")
 	RawkvCmdHistogramWithBatchDelete = TiKVRawkvCmdHistogram.WithLabelValues("batch_delete")
 	RawkvCmdHistogramWithRawScan = TiKVRawkvCmdHistogram.WithLabelValues("raw_scan")
 	RawkvCmdHistogramWithRawReversScan = TiKVRawkvCmdHistogram.WithLabelValues("raw_reverse_scan")
+	RawkvCmdHistogramWithRawBatchScan = TiKVRawkvCmdHistogram.WithLabelValues("raw_batch_scan")
+	RawkvCmdHistogramWithBatchGetTTL = TiKVRawkvCmdHistogram.WithLabelValues("batch_get_key_ttl")
+	RawkvCmdHistogramWithRawScanTTL = TiKVRawkvCmdHistogram.WithLabelValues("raw_scan_with_ttl")
 	RawkvSizeHistogramWithKey = TiKVRawkvSizeHistogram.WithLabelValues("key")
 	RawkvSizeHistogramWithValue = TiKVRawkvSizeHistogram.WithLabelValues("value")
 	RawkvCmdHistogramWithRawChecksum = TiKVRawkvSizeHistogram.WithLabelValues("raw_checksum")
@@ -323,4 +337,10 @@ func initShortcuts() {
 	StaleReadLocalOutBytes = TiKVStaleReadBytes.WithLabelValues("local", "out")
 	StaleReadRemoteInBytes = TiKVStaleReadBytes.WithLabelValues("cross-zone", "in")
 	StaleReadRemoteOutBytes = TiKVStaleReadBytes.WithLabelValues("cross-zone", "out")
+
+	CoprCacheCounterHit = TiKVCoprCacheCounter.WithLabelValues("hit")
+	CoprCacheCounterMiss = TiKVCoprCacheCounter.WithLabelValues("miss")
+	CoprCacheCounterAdmit = TiKVCoprCacheCounter.WithLabelValues("admit")
+	CoprCacheCounterReject = TiKVCoprCacheCounter.WithLabelValues("reject")
+	CoprCacheCounterEvict = TiKVCoprCacheCounter.WithLabelValues("evict")
 }
@@ -0,0 +1,204 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rawkv
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+var (
+	// rawIterBatchSize is the maximum number of pairs fetched by a single
+	// RawScan request of the iterator. A batch never spans over regions.
+	rawIterBatchSize = 1024
+	// rawIterPrefetchBatches is the number of batches the iterator fetches
+	// ahead of the consumer.
+	rawIterPrefetchBatches = 2
+)
+
+type rawIterBatch struct {
+	pairs []*kvrpcpb.KvPair
+	err   error
+}
+
+// Iterator iterates over the kv pairs of a range. It is returned by
+// Client.Iter and must be closed after use.
+type Iterator struct {
+	cancel  context.CancelFunc
+	batches chan rawIterBatch
+	pairs   []*kvrpcpb.KvPair
+	idx     int
+	err     error
+	closed  bool
+}
+
+// Iter creates an Iterator over the kv pairs in range [startKey, endKey).
+// If endKey is empty, it means unbounded.
+//
+// With the IterReverse option the range is [endKey, startKey) and pairs are
+// returned from startKey(upperBound) to endKey(lowerBound), in the same way as
+// ReverseScan. Scanning from "" in reverse is not supported.
+//
+// The iterator prefetches pairs region by region in the background, so the
+// keys do not need to be paged manually by the caller.
+func (c *Client) Iter(ctx context.Context, startKey, endKey []byte, options ...RawOption) (*Iterator, error) {
+	opts := c.getRawKVOptions(options...)
+	ctx, cancel := context.WithCancel(ctx)
+	it := &Iterator{
+		cancel:  cancel,
+		batches: make(chan rawIterBatch, rawIterPrefetchBatches),
+	}
+	go c.prefetchIterBatches(ctx, it.batches, startKey, endKey, opts)
+
+	if err := it.fetchNext(); err != nil {
+		it.Close()
+		return nil, err
+	}
+	return it, nil
+}
+
+// Valid returns true if the current iterator is valid.
+func (it *Iterator) Valid() bool {
+	return !it.closed && it.err == nil && it.idx < len(it.pairs)
+}
+
+// Key returns the current key.
+func (it *Iterator) Key() []byte {
+	if !it.Valid() {
+		return nil
+	}
+	return it.pairs[it.idx].Key
+}
+
+// Value returns the current value.
+func (it *Iterator) Value() []byte {
+	if !it.Valid() {
+		return nil
+	}
+	return convertNilToEmptySlice(it.pairs[it.idx].Value)
+}
+
+// Next goes the next position.
+func (it *Iterator) Next() error {
+	if it.closed {
+		return errors.New("iterator is closed")
+	}
+	if it.err != nil {
+		return it.err
+	}
+	it.idx++
+	if it.idx < len(it.pairs) {
+		return nil
+	}
+	return it.fetchNext()
+}
+
+// Close releases the resources of the iterator and stops prefetching.
+func (it *Iterator) Close() {
+	if it.closed {
+		return
+	}
+	it.closed = true
+	it.cancel()
+	// Drain the channel so that the prefetch goroutine can exit.
+	for range it.batches {
+	}
+	it.pairs = nil
+}
+
+func (it *Iterator) fetchNext() error {
+	batch, ok := <-it.batches
+	if !ok {
+		it.pairs, it.idx = nil, 0
+		return nil
+	}
+	if batch.err != nil {
+		it.err = batch.err
+		it.pairs, it.idx = nil, 0
+		return batch.err
+	}
+	it.pairs, it.idx = batch.pairs, 0
+	return nil
+}
+
+func (c *Client) prefetchIterBatches(ctx context.Context, ch chan<- rawIterBatch, startKey, endKey []byte, opts *rawOptions) {
+	defer close(ch)
+
+	send := func(batch rawIterBatch) bool {
+		select {
+		case ch <- batch:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		if opts.Reverse {
+			if bytes.Compare(startKey, endKey) <= 0 {
+				return
+			}
+		} else if len(endKey) > 0 && bytes.Compare(startKey, endKey) >= 0 {
+			return
+		}
+
+		req := tikvrpc.NewRequest(tikvrpc.CmdRawScan, &kvrpcpb.RawScanRequest{
+			StartKey: startKey,
+			EndKey:   endKey,
+			Limit:    uint32(rawIterBatchSize),
+			Reverse:  opts.Reverse,
+			KeyOnly:  opts.KeyOnly,
+			Cf:       c.getColumnFamily(opts),
+		})
+		resp, loc, err := c.sendReq(ctx, startKey, req, opts.Reverse)
+		if err != nil {
+			send(rawIterBatch{err: err})
+			return
+		}
+		if resp.Resp == nil {
+			send(rawIterBatch{err: errors.WithStack(tikverr.ErrBodyMissing)})
+			return
+		}
+		pairs := resp.Resp.(*kvrpcpb.RawScanResponse).Kvs
+		if len(pairs) > 0 && !send(rawIterBatch{pairs: pairs}) {
+			return
+		}
+
+		if len(pairs) >= rawIterBatchSize {
+			// The region has more pairs, continue from the last returned key.
+			lastKey := pairs[len(pairs)-1].Key
+			if opts.Reverse {
+				startKey = lastKey
+			} else {
+				startKey = append(append(make([]byte, 0, len(lastKey)+1), lastKey...), 0)
+			}
+			continue
+		}
+
+		if opts.Reverse {
+			startKey = loc.StartKey
+		} else {
+			startKey = loc.EndKey
+		}
+		if len(startKey) == 0 {
+			return
+		}
+	}
+}
@@ -48,6 +48,7 @@ import (
 	"github.com/tikv/client-go/v2/internal/client"
 	"github.com/tikv/client-go/v2/internal/kvrpc"
 	"github.com/tikv/client-go/v2/internal/locate"
+	"github.com/tikv/client-go/v2/kv"
 	"github.com/tikv/client-go/v2/metrics"
 	"github.com/tikv/client-go/v2/oracle"
 	"github.com/tikv/client-go/v2/tikv"
@@ -70,15 +71,21 @@ const (
 	rawBatchPutSize = 16 * 1024
 	// rawBatchPairCount is the maximum limit for rawkv each batch get/delete request.
 	rawBatchPairCount = 512
-	componentName     = caller.Component("rawkv-client-go")
+	// rawGetKeyTTLConcurrency is the max number of concurrent RawGetKeyTTL requests
+	// sent for a batch of keys in the same region.
+	rawGetKeyTTLConcurrency = 16
+	componentName           = caller.Component("rawkv-client-go")
 )
 
 type rawOptions struct {
 	// ColumnFamily filed is used for manipulate kv in specified column family
 	ColumnFamily string
 
-	// This field is used for Scan()/ReverseScan().
+	// This field is used for Scan()/ReverseScan()/Iter().
 	KeyOnly bool
+
+	// This field is used for Iter().
+	Reverse bool
 }
 
 // RawChecksum represents the checksum result of raw kv pairs in TiKV cluster.
@@ -97,6 +104,7 @@ type RawChecksum struct {
 // Available options are:
 // - ScanColumnFamily
 // - ScanKeyOnly
+// - IterReverse
 type RawOption interface {
 	apply(opts *rawOptions)
 }
@@ -123,6 +131,15 @@ func ScanKeyOnly() RawOption {
 	})
 }
 
+// IterReverse is a rawkvOptions that tells the iterator to walk the range
+// in reversed lexicographical order.
+// It can work only in API Iter().
+func IterReverse() RawOption {
+	return rawOptionFunc(func(opts *rawOptions) {
+		opts.Reverse = true
+	})
+}
+
 // Client is a client of TiKV server which is used as a key-value storage,
 // only GET/PUT/DELETE commands are supported.
 type Client struct {
@@ -393,6 +410,40 @@ func (c *Client) GetKeyTTL(ctx context.Context, key []byte, options ...RawOption
 	return &ttl, nil
 }
 
+// BatchGetKeyTTL gets the TTLs of the raw keys from TiKV. The TTL of a key is
+// nil if the key does not exist or is expired, and 0 if the key never expires.
+//
+// TiKV has no batch command for TTLs, so a RawGetKeyTTL request is sent for
+// each key, at most 16 of them concurrently in a region, which costs much more
+// than BatchGet for many keys. The TTLs are not read from a consistent
+// snapshot, and each one reflects the key at the time its request is handled.
+func (c *Client) BatchGetKeyTTL(ctx context.Context, keys [][]byte, options ...RawOption) ([]*uint64, error) {
+	start := time.Now()
+	defer func() { metrics.RawkvCmdHistogramWithBatchGetTTL.Observe(time.Since(start).Seconds()) }()
+
+	opts := c.getRawKVOptions(options...)
+	bo := retry.NewBackofferWithVars(ctx, rawkvMaxBackoff, nil)
+	keyToTTL, err := c.batchGetKeyTTL(bo, keys, opts)
+	if err != nil {
+		return nil, err
+	}
+	ttls := make([]*uint64, len(keys))
+	for i, key := range keys {
+		if ttl, ok := keyToTTL[string(key)]; ok {
+			ttls[i] = &ttl
+		}
+	}
+	return ttls, nil
+}
+
+func (c *Client) batchGetKeyTTL(bo *retry.Backoffer, keys [][]byte, opts *rawOptions) (map[string]uint64, error) {
+	resp, err := c.sendBatchReq(bo, keys, opts, tikvrpc.CmdRawGetKeyTTL)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Resp.(*rawBatchGetKeyTTLResponse).ttls, nil
+}
+
 // GetPDClient returns the PD client.
 func (c *Client) GetPDClient() pd.Client {
 	return c.pdClient
@@ -555,6 +606,41 @@ func (c *Client) Scan(ctx context.Context, startKey, endKey []byte, limit int, o
 	return
 }
 
+// ScanWithTTL queries continuous kv pairs in range [startKey, endKey) like Scan,
+// and returns the remaining TTL of each pair, 0 means the pair never expires.
+//
+// The TTLs are got like BatchGetKeyTTL after the scan, so it sends a Scan plus a
+// RawGetKeyTTL request for each scanned pair. The pairs and TTLs are not read
+// from a consistent snapshot: the pairs which are expired or deleted after they
+// are scanned are skipped, and a pair overwritten in between is returned with
+// the scanned value and the TTL of the new one.
+func (c *Client) ScanWithTTL(ctx context.Context, startKey, endKey []byte, limit int, options ...RawOption,
+) (keys [][]byte, values [][]byte, ttls []uint64, err error) {
+	start := time.Now()
+	defer func() { metrics.RawkvCmdHistogramWithRawScanTTL.Observe(time.Since(start).Seconds()) }()
+
+	scannedKeys, scannedValues, err := c.Scan(ctx, startKey, endKey, limit, options...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	opts := c.getRawKVOptions(options...)
+	bo := retry.NewBackofferWithVars(ctx, rawkvMaxBackoff, nil)
+	keyToTTL, err := c.batchGetKeyTTL(bo, scannedKeys, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for i, key := range scannedKeys {
+		ttl, ok := keyToTTL[string(key)]
+		if !ok {
+			continue
+		}
+		keys = append(keys, key)
+		values = append(values, scannedValues[i])
+		ttls = append(ttls, ttl)
+	}
+	return keys, values, ttls, nil
+}
+
 // ReverseScan queries continuous kv pairs in range [endKey, startKey),
 // from startKey(upperBound) to endKey(lowerBound), up to limit pairs.
 // The returned keys are in reversed lexicographical order.
@@ -604,6 +690,58 @@ func (c *Client) ReverseScan(ctx context.Context, startKey, endKey []byte, limit
 	return
 }
 
+// BatchScan queries the first eachLimit kv pairs of every range in ranges, the
+// groups of the ranges are empty if eachLimit is not positive.
+// The ranges must be sorted and must not overlap each other. An empty EndKey
+// means unbounded, so only the last range may have an empty EndKey.
+// The returned keys and values are grouped by range and listed in the same
+// order as ranges, the pairs of each range are in lexicographical order.
+// Ranges that belong to the same region are sent in a single RawBatchScan request.
+func (c *Client) BatchScan(ctx context.Context, ranges []kv.KeyRange, eachLimit int, options ...RawOption,
+) (keys [][][]byte, values [][][]byte, err error) {
+	start := time.Now()
+	defer func() { metrics.RawkvCmdHistogramWithRawBatchScan.Observe(time.Since(start).Seconds()) }()
+
+	if eachLimit > MaxRawKVScanLimit {
+		return nil, nil, errors.WithStack(ErrMaxScanLimitExceeded)
+	}
+	for i := range ranges {
+		if len(ranges[i].EndKey) > 0 && bytes.Compare(ranges[i].StartKey, ranges[i].EndKey) > 0 {
+			return nil, nil, errors.Errorf("invalid range [%q, %q)", ranges[i].StartKey, ranges[i].EndKey)
+		}
+		if i > 0 && (len(ranges[i-1].EndKey) == 0 || bytes.Compare(ranges[i-1].EndKey, ranges[i].StartKey) > 0) {
+			return nil, nil, errors.New("ranges should be sorted and should not overlap")
+		}
+	}
+	// Like Scan, no pair is returned if the limit is not positive.
+	if eachLimit <= 0 {
+		keys = make([][][]byte, len(ranges))
+		values = make([][][]byte, len(ranges))
+		for i := range ranges {
+			keys[i], values[i] = [][]byte{}, [][]byte{}
+		}
+		return keys, values, nil
+	}
+
+	opts := c.getRawKVOptions(options...)
+	bo := retry.NewBackofferWithVars(ctx, rawkvMaxBackoff, nil)
+	pairs, err := c.sendBatchScan(bo, ranges, eachLimit, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys = make([][][]byte, len(ranges))
+	values = make([][][]byte, len(ranges))
+	for i, rangePairs := range pairs {
+		keys[i] = make([][]byte, 0, len(rangePairs))
+		values[i] = make([][]byte, 0, len(rangePairs))
+		for _, pair := range rangePairs {
+			keys[i] = append(keys[i], pair.Key)
+			values[i] = append(values[i], convertNilToEmptySlice(pair.Value))
+		}
+	}
+	return keys, values, nil
+}
+
 // Checksum do checksum of continuous kv pairs in range [startKey, endKey).
 // If endKey is empty, it means unbounded.
 // If you want to exclude the startKey or include the endKey, push a '\0' to the key. For example, to scan
@@ -754,6 +892,8 @@ func (c *Client) sendBatchReq(bo *retry.Backoffer, keys [][]byte, options *rawOp
 		resp = &tikvrpc.Response{Resp: &kvrpcpb.RawBatchGetResponse{}}
 	case tikvrpc.CmdRawBatchDelete:
 		resp = &tikvrpc.Response{Resp: &kvrpcpb.RawBatchDeleteResponse{}}
+	case tikvrpc.CmdRawGetKeyTTL:
+		resp = &tikvrpc.Response{Resp: &rawBatchGetKeyTTLResponse{ttls: make(map[string]uint64, len(keys))}}
 	}
 	for range batches {
 		if singleResp, ok := <-ches; ok {
@@ -765,6 +905,11 @@ func (c *Client) sendBatchReq(bo *retry.Backoffer, keys [][]byte, options *rawOp
 			} else if cmdType == tikvrpc.CmdRawBatchGet {
 				cmdResp := singleResp.Resp.(*kvrpcpb.RawBatchGetResponse)
 				resp.Resp.(*kvrpcpb.RawBatchGetResponse).Pairs = append(resp.Resp.(*kvrpcpb.RawBatchGetResponse).Pairs, cmdResp.Pairs...)
+			} else if cmdType == tikvrpc.CmdRawGetKeyTTL {
+				merged := resp.Resp.(*rawBatchGetKeyTTLResponse).ttls
+				for key, ttl := range singleResp.Resp.(*rawBatchGetKeyTTLResponse).ttls {
+					merged[key] = ttl
+				}
 			}
 		}
 	}
@@ -777,6 +922,10 @@ func (c *Client) sendBatchReq(bo *retry.Backoffer, keys [][]byte, options *rawOp
 }
 
 func (c *Client) doBatchReq(bo *retry.Backoffer, batch kvrpc.Batch, options *rawOptions, cmdType tikvrpc.CmdType) kvrpc.BatchResult {
+	if cmdType == tikvrpc.CmdRawGetKeyTTL {
+		return c.doBatchGetKeyTTL(bo, batch, options)
+	}
+
 	var req *tikvrpc.Request
 	switch cmdType {
 	case tikvrpc.CmdRawBatchGet:
@@ -836,6 +985,261 @@ func (c *Client) doBatchReq(bo *retry.Backoffer, batch kvrpc.Batch, options *raw
 	return batchResp
 }
 
+// rawBatchGetKeyTTLResponse is the merged result of the RawGetKeyTTL requests
+// sent by sendBatchReq. The keys not found are absent from ttls.
+type rawBatchGetKeyTTLResponse struct {
+	ttls map[string]uint64
+}
+
+// doBatchGetKeyTTL gets the TTLs of the keys in a batch. There is no batch
+// command for TTLs, so a RawGetKeyTTL request is sent for each key, and at most
+// rawGetKeyTTLConcurrency requests of the batch are sent concurrently.
+func (c *Client) doBatchGetKeyTTL(bo *retry.Backoffer, batch kvrpc.Batch, options *rawOptions) kvrpc.BatchResult {
+	type keyTTLResult struct {
+		ttls map[string]uint64
+		err  error
+	}
+	forkedBo, cancel := bo.Fork()
+	defer cancel()
+	ches := make(chan keyTTLResult, len(batch.Keys))
+	tokens := make(chan struct{}, rawGetKeyTTLConcurrency)
+	var lastForkedBo atomic.Pointer[retry.Backoffer]
+	for _, key := range batch.Keys {
+		key1 := key
+		tokens <- struct{}{}
+		go func() {
+			defer func() { <-tokens }()
+			singleKeyBackoffer, singleKeyCancel := forkedBo.Fork()
+			defer singleKeyCancel()
+			ttls, err := c.getKeyTTLInRegion(singleKeyBackoffer, batch.RegionID, key1, options)
+			lastForkedBo.Store(singleKeyBackoffer)
+			ches <- keyTTLResult{ttls: ttls, err: err}
+		}()
+	}
+
+	batchResp := kvrpc.BatchResult{}
+	cmdResp := &rawBatchGetKeyTTLResponse{ttls: make(map[string]uint64, len(batch.Keys))}
+	for range batch.Keys {
+		res := <-ches
+		if res.err != nil {
+			if batchResp.Error == nil {
+				batchResp.Error = res.err
+				cancel()
+			}
+			continue
+		}
+		for k, ttl := range res.ttls {
+			cmdResp.ttls[k] = ttl
+		}
+	}
+	bo.UpdateUsingForked(lastForkedBo.Load())
+	if batchResp.Error == nil {
+		batchResp.Response = &tikvrpc.Response{Resp: cmdResp}
+	}
+	return batchResp
+}
+
+// getKeyTTLInRegion gets the TTL of a key located in the region. The key is
+// absent from the returned TTLs if it's not found.
+func (c *Client) getKeyTTLInRegion(bo *retry.Backoffer, regionID locate.RegionVerID, key []byte, options *rawOptions) (map[string]uint64, error) {
+	sender := locate.NewRegionRequestSender(c.regionCache, c.rpcClient, oracle.NoopReadTSValidator{})
+	req := tikvrpc.NewRequest(tikvrpc.CmdRawGetKeyTTL, &kvrpcpb.RawGetKeyTTLRequest{
+		Key: key,
+		Cf:  c.getColumnFamily(options),
+	})
+	resp, _, err := sender.SendReq(bo, req, regionID, client.ReadTimeoutShort)
+	if err != nil {
+		return nil, err
+	}
+	regionErr, err := resp.GetRegionError()
+	if err != nil {
+		return nil, err
+	}
+	if regionErr != nil {
+		err := bo.Backoff(retry.BoRegionMiss, errors.New(regionErr.String()))
+		if err != nil {
+			return nil, err
+		}
+		resp, err = c.sendBatchReq(bo, [][]byte{key}, options, tikvrpc.CmdRawGetKeyTTL)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Resp.(*rawBatchGetKeyTTLResponse).ttls, nil
+	}
+	if resp.Resp == nil {
+		return nil, errors.WithStack(tikverr.ErrBodyMissing)
+	}
+	ttlResp := resp.Resp.(*kvrpcpb.RawGetKeyTTLResponse)
+	if ttlResp.GetError() != "" {
+		return nil, errors.New(ttlResp.GetError())
+	}
+	if ttlResp.GetNotFound() {
+		return nil, nil
+	}
+	return map[string]uint64{string(key): ttlResp.GetTtl()}, nil
+}
+
+// rawScanBatch is a group of ranges located in the same region.
+type rawScanBatch struct {
+	regionID locate.RegionVerID
+	ranges   []kv.KeyRange
+}
+
+// rawScanRangeRef refers to a sub-range inside a rawScanBatch.
+type rawScanRangeRef struct {
+	batch int
+	pos   int
+}
+
+// sendBatchScan scans the sorted and non-overlapping ranges, and returns the
+// pairs of each range in the order of ranges.
+func (c *Client) sendBatchScan(bo *retry.Backoffer, ranges []kv.KeyRange, eachLimit int, opts *rawOptions) ([][]*kvrpcpb.KvPair, error) {
+	if len(ranges) == 0 {
+		return nil, nil
+	}
+	locs, err := c.regionCache.BatchLocateKeyRanges(bo, ranges)
+	if err != nil {
+		return nil, err
+	}
+
+	// split the ranges by region, a range may be split into several sub-ranges.
+	var batches []rawScanBatch
+	batchIdx := make(map[locate.RegionVerID]int, len(locs))
+	refs := make([][]rawScanRangeRef, len(ranges))
+	j := 0
+	for i, r := range ranges {
+		for j < len(locs) && len(locs[j].EndKey) > 0 && bytes.Compare(locs[j].EndKey, r.StartKey) <= 0 {
+			j++
+		}
+		for k := j; k < len(locs); k++ {
+			loc := locs[k]
+			if len(r.EndKey) > 0 && bytes.Compare(loc.StartKey, r.EndKey) >= 0 {
+				break
+			}
+			subRange := kv.KeyRange{StartKey: r.StartKey, EndKey: r.EndKey}
+			if bytes.Compare(loc.StartKey, subRange.StartKey) > 0 {
+				subRange.StartKey = loc.StartKey
+			}
+			if len(loc.EndKey) > 0 && (len(subRange.EndKey) == 0 || bytes.Compare(loc.EndKey, subRange.EndKey) < 0) {
+				subRange.EndKey = loc.EndKey
+			}
+			idx, ok := batchIdx[loc.Region]
+			if !ok || len(batches[idx].ranges) >= rawBatchPairCount {
+				idx = len(batches)
+				batchIdx[loc.Region] = idx
+				batches = append(batches, rawScanBatch{regionID: loc.Region})
+			}
+			refs[i] = append(refs[i], rawScanRangeRef{batch: idx, pos: len(batches[idx].ranges)})
+			batches[idx].ranges = append(batches[idx].ranges, subRange)
+		}
+	}
+
+	type batchScanResult struct {
+		idx   int
+		pairs [][]*kvrpcpb.KvPair
+		err   error
+	}
+	forkedBo, cancel := bo.Fork()
+	ches := make(chan batchScanResult, len(batches))
+	var lastForkedBo atomic.Pointer[retry.Backoffer]
+	for i, batch := range batches {
+		i, batch1 := i, batch
+		go func() {
+			singleBatchBackoffer, singleBatchCancel := forkedBo.Fork()
+			defer singleBatchCancel()
+			pairs, err := c.doBatchScan(singleBatchBackoffer, batch1, eachLimit, opts)
+			lastForkedBo.Store(singleBatchBackoffer)
+			ches <- batchScanResult{idx: i, pairs: pairs, err: err}
+		}()
+	}
+
+	batchPairs := make([][][]*kvrpcpb.KvPair, len(batches))
+	for range batches {
+		res := <-ches
+		if res.err != nil {
+			if err == nil {
+				err = errors.WithStack(res.err)
+				cancel()
+			}
+			continue
+		}
+		batchPairs[res.idx] = res.pairs
+	}
+	bo.UpdateUsingForked(lastForkedBo.Load())
+	if err != nil {
+		return nil, err
+	}
+	cancel()
+
+	result := make([][]*kvrpcpb.KvPair, len(ranges))
+	for i := range ranges {
+		for _, ref := range refs[i] {
+			result[i] = append(result[i], batchPairs[ref.batch][ref.pos]...)
+			if len(result[i]) >= eachLimit {
+				result[i] = result[i][:eachLimit]
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// doBatchScan sends a RawBatchScan request for the ranges of a single region,
+// and returns the pairs of each range.
+func (c *Client) doBatchScan(bo *retry.Backoffer, batch rawScanBatch, eachLimit int, opts *rawOptions) ([][]*kvrpcpb.KvPair, error) {
+	pbRanges := make([]*kvrpcpb.KeyRange, 0, len(batch.ranges))
+	for _, r := range batch.ranges {
+		pbRanges = append(pbRanges, &kvrpcpb.KeyRange{StartKey: r.StartKey, EndKey: r.EndKey})
+	}
+	req := tikvrpc.NewRequest(tikvrpc.CmdRawBatchScan, &kvrpcpb.RawBatchScanRequest{
+		Ranges:    pbRanges,
+		EachLimit: uint32(eachLimit),
+		KeyOnly:   opts.KeyOnly,
+		Cf:        c.getColumnFamily(opts),
+	})
+
+	sender := locate.NewRegionRequestSender(c.regionCache, c.rpcClient, oracle.NoopReadTSValidator{})
+	resp, _, err := sender.SendReq(bo, req, batch.regionID, client.ReadTimeoutShort)
+	if err != nil {
+		return nil, err
+	}
+	regionErr, err := resp.GetRegionError()
+	if err != nil {
+		return nil, err
+	}
+	if regionErr != nil {
+		err := bo.Backoff(retry.BoRegionMiss, errors.New(regionErr.String()))
+		if err != nil {
+			return nil, err
+		}
+		// recursive call
+		return c.sendBatchScan(bo, batch.ranges, eachLimit, opts)
+	}
+	if resp.Resp == nil {
+		return nil, errors.WithStack(tikverr.ErrBodyMissing)
+	}
+
+	// The pairs of all the ranges are returned in a flat list, assign them
+	// back to the ranges they belong to.
+	kvs := resp.Resp.(*kvrpcpb.RawBatchScanResponse).Kvs
+	pairs := make([][]*kvrpcpb.KvPair, len(batch.ranges))
+	pos := 0
+	for _, pair := range kvs {
+		for pos < len(batch.ranges) && !rangeContainsKey(batch.ranges[pos], pair.Key) {
+			pos++
+		}
+		if pos == len(batch.ranges) {
+			return nil, errors.Errorf("unexpected key %q in RawBatchScan response", pair.Key)
+		}
+		pairs[pos] = append(pairs[pos], pair)
+	}
+	return pairs, nil
+}
+
+func rangeContainsKey(r kv.KeyRange, key []byte) bool {
+	return bytes.Compare(key, r.StartKey) >= 0 && (len(r.EndKey) == 0 || bytes.Compare(key, r.EndKey) < 0)
+}
+
 // sendDeleteRangeReq sends a raw delete range request and returns the response and the actual endKey.
 // If the given range spans over more than one regions, the actual endKey is the end of the first region.
 // We can't use sendReq directly, because we need to know the end of the region before we send the request
@@ -657,3 +657,317 @@ func (s *testRawkvSuite) TestRawChecksum() {
 	s.Equal(expectTotalKvs, check.TotalKvs)
 	s.Equal(expectTotalBytes, check.TotalBytes)
 }
+
+func (s *testRawkvSuite) TestIter() {
+	mvccStore := mocktikv.MustNewMVCCStore()
+	defer mvccStore.Close()
+
+	client := &Client{
+		clusterID:   0,
+		regionCache: locate.NewRegionCache(mocktikv.NewPDClient(s.cluster)),
+		rpcClient:   mocktikv.NewRPCClient(s.cluster, mvccStore, nil),
+	}
+	defer client.Close()
+
+	// use a small batch size so that a region is fetched in several batches.
+	defer func(size int) { rawIterBatchSize = size }(rawIterBatchSize)
+	rawIterBatchSize = 3
+
+	cf := "test_cf"
+	keys := make([]key, 0)
+	values := make([]value, 0)
+	for i := 0; i < 20; i++ {
+		keys = append(keys, []byte(fmt.Sprintf("key%02d", i)))
+		values = append(values, []byte(fmt.Sprintf("value%02d", i)))
+	}
+	err := client.BatchPut(context.Background(), keys, values, SetColumnFamily(cf))
+	s.Nil(err)
+
+	// split the keys into 3 regions.
+	for _, splitKey := range []string{"key05", "key12"} {
+		loc, err := client.regionCache.LocateKey(s.bo, []byte(splitKey))
+		s.Nil(err)
+		newRegionID, peerIDs := s.cluster.AllocID(), s.cluster.AllocIDs(2)
+		s.cluster.SplitRaw(loc.Region.GetID(), newRegionID, []byte(splitKey), peerIDs, peerIDs[0])
+	}
+
+	collect := func(startKey, endKey []byte, options ...RawOption) (keys []key, values []value) {
+		it, err := client.Iter(context.Background(), startKey, endKey, options...)
+		s.Nil(err)
+		defer it.Close()
+		for it.Valid() {
+			keys = append(keys, it.Key())
+			values = append(values, it.Value())
+			s.Nil(it.Next())
+		}
+		return
+	}
+
+	returnKeys, returnValues := collect([]byte("key"), nil, SetColumnFamily(cf))
+	s.Equal(keys, returnKeys)
+	s.Equal(values, returnValues)
+
+	returnKeys, _ = collect([]byte("key03"), []byte("key15"), SetColumnFamily(cf), ScanKeyOnly())
+	s.Equal(keys[3:15], returnKeys)
+
+	returnKeys, returnValues = collect([]byte("key17"), []byte("key02"), SetColumnFamily(cf), IterReverse())
+	s.Len(returnKeys, 15)
+	for i := range returnKeys {
+		s.Equal(keys[16-i], returnKeys[i])
+		s.Equal(values[16-i], returnValues[i])
+	}
+
+	// close an iterator before consuming all the pairs.
+	it, err := client.Iter(context.Background(), []byte("key"), nil, SetColumnFamily(cf))
+	s.Nil(err)
+	s.True(it.Valid())
+	s.Equal(keys[0], it.Key())
+	it.Close()
+	s.False(it.Valid())
+	s.NotNil(it.Next())
+}
+
+func (s *testRawkvSuite) TestBatchScan() {
+	mvccStore := mocktikv.MustNewMVCCStore()
+	defer mvccStore.Close()
+
+	client := &Client{
+		clusterID:   0,
+		regionCache: locate.NewRegionCache(mocktikv.NewPDClient(s.cluster)),
+		rpcClient:   mocktikv.NewRPCClient(s.cluster, mvccStore, nil),
+	}
+	defer client.Close()
+
+	keys := make([]key, 0)
+	values := make([]value, 0)
+	for _, prefix := range []string{"a", "b", "c", "d"} {
+		for i := 0; i < 5; i++ {
+			keys = append(keys, []byte(fmt.Sprintf("%s%d", prefix, i)))
+			values = append(values, []byte(fmt.Sprintf("v%s%d", prefix, i)))
+		}
+	}
+	err := client.BatchPut(context.Background(), keys, values)
+	s.Nil(err)
+
+	// split the keys into 3 regions, range "b" spans over 2 regions.
+	for _, splitKey := range []string{"b2", "c"} {
+		loc, err := client.regionCache.LocateKey(s.bo, []byte(splitKey))
+		s.Nil(err)
+		newRegionID, peerIDs := s.cluster.AllocID(), s.cluster.AllocIDs(2)
+		s.cluster.SplitRaw(loc.Region.GetID(), newRegionID, []byte(splitKey), peerIDs, peerIDs[0])
+	}
+
+	ranges := []kv.KeyRange{
+		{StartKey: []byte("a"), EndKey: []byte("a2")},
+		{StartKey: []byte("b"), EndKey: []byte("c")},
+		{StartKey: []byte("c3"), EndKey: []byte("d")},
+		{StartKey: []byte("d"), EndKey: nil},
+	}
+	returnKeys, returnValues, err := client.BatchScan(context.Background(), ranges, 4)
+	s.Nil(err)
+	s.Len(returnKeys, len(ranges))
+	s.Len(returnValues, len(ranges))
+
+	expected := [][]string{
+		{"a0", "a1"},
+		{"b0", "b1", "b2", "b3"},
+		{"c3", "c4"},
+		{"d0", "d1", "d2", "d3"},
+	}
+	for i, expectedKeys := range expected {
+		s.Len(returnKeys[i], len(expectedKeys))
+		for j, k := range expectedKeys {
+			s.Equal(k, string(returnKeys[i][j]))
+			s.Equal("v"+k, string(returnValues[i][j]))
+		}
+	}
+
+	// ranges that are not sorted are rejected.
+	_, _, err = client.BatchScan(context.Background(), []kv.KeyRange{ranges[1], ranges[0]}, 4)
+	s.NotNil(err)
+
+	_, _, err = client.BatchScan(context.Background(), ranges, MaxRawKVScanLimit+1)
+	s.NotNil(err)
+
+	// No pair is returned if the limit is not positive.
+	for _, limit := range []int{0, -1} {
+		returnKeys, returnValues, err = client.BatchScan(context.Background(), ranges, limit)
+		s.Nil(err)
+		s.Len(returnKeys, len(ranges))
+		s.Len(returnValues, len(ranges))
+		for i := range ranges {
+			s.Empty(returnKeys[i])
+			s.Empty(returnValues[i])
+		}
+	}
+}
+
+func (s *testRawkvSuite) TestWriteBatch() {
+	mvccStore := mocktikv.MustNewMVCCStore()
+	defer mvccStore.Close()
+
+	client := &Client{
+		clusterID:   0,
+		regionCache: locate.NewRegionCache(mocktikv.NewPDClient(s.cluster)),
+		rpcClient:   mocktikv.NewRPCClient(s.cluster, mvccStore, nil),
+	}
+	defer client.Close()
+
+	err := client.BatchPut(context.Background(),
+		[][]byte{[]byte("a1"), []byte("a2"), []byte("b1"), []byte("b2")},
+		[][]byte{[]byte("va1"), []byte("va2"), []byte("vb1"), []byte("vb2")})
+	s.Nil(err)
+
+	// single region batch.
+	wb := NewWriteBatch().
+		Put([]byte("a1"), []byte("va1x")).
+		Put([]byte("a3"), []byte("va3x")).
+		Put([]byte("a3"), []byte("va3")).
+		Put([]byte("b3"), []byte("vb3"))
+	s.Equal(3, wb.Len())
+	s.Nil(client.Write(context.Background(), wb))
+
+	keys, values, err := client.Scan(context.Background(), []byte(""), nil, 10)
+	s.Nil(err)
+	s.Equal([][]byte{[]byte("a1"), []byte("a2"), []byte("a3"), []byte("b1"), []byte("b2"), []byte("b3")}, keys)
+	s.Equal([][]byte{[]byte("va1x"), []byte("va2"), []byte("va3"), []byte("vb1"), []byte("vb2"), []byte("vb3")}, values)
+
+	s.Nil(client.Write(context.Background(), NewWriteBatch().Delete([]byte("a1")).Delete([]byte("a3"))))
+	s.Nil(client.Write(context.Background(), NewWriteBatch().DeleteRange([]byte("b"), []byte("c"))))
+	keys, _, err = client.Scan(context.Background(), []byte(""), nil, 10)
+	s.Nil(err)
+	s.Equal([][]byte{[]byte("a2")}, keys)
+
+	// a mixed single region batch can't be applied atomically, so it's
+	// rejected before anything is written.
+	loc, err := client.regionCache.LocateKey(s.bo, []byte("b"))
+	s.Nil(err)
+	for _, wb := range []*WriteBatch{
+		NewWriteBatch().Delete([]byte("a2")).Put([]byte("a3"), []byte("va3")),
+		NewWriteBatch().DeleteRange([]byte("b"), []byte("c")).Put([]byte("a3"), []byte("va3")),
+		NewWriteBatch().DeleteRange([]byte("a"), []byte("b")).DeleteRange([]byte("b"), []byte("c")),
+	} {
+		var mixedErr *MixedWriteBatchError
+		s.ErrorAs(client.Write(context.Background(), wb), &mixedErr)
+		s.Equal(loc.Region.GetID(), mixedErr.RegionID)
+	}
+	keys, _, err = client.Scan(context.Background(), []byte(""), nil, 10)
+	s.Nil(err)
+	s.Equal([][]byte{[]byte("a2")}, keys)
+
+	newRegionID, peerIDs := s.cluster.AllocID(), s.cluster.AllocIDs(2)
+	s.cluster.SplitRaw(loc.Region.GetID(), newRegionID, []byte("b"), peerIDs, peerIDs[0])
+
+	// the put to the second region fails, the first region is still applied.
+	s.Nil(failpoint.Enable("tikvclient/rpcRawBatchPutError", fmt.Sprintf("return(%d)", newRegionID)))
+	wb = NewWriteBatch().
+		PutWithTTL([]byte("a4"), []byte("va4"), 100).
+		Put([]byte("b4"), []byte("vb4"))
+	err = client.Write(context.Background(), wb)
+	s.Nil(failpoint.Disable("tikvclient/rpcRawBatchPutError"))
+
+	var writeErr *WriteBatchError
+	s.ErrorAs(err, &writeErr)
+	s.Len(writeErr.Results, 2)
+	failed := writeErr.Failed()
+	s.Len(failed, 1)
+	s.Equal(newRegionID, failed[0].RegionID)
+	s.Equal([][]byte{[]byte("b4")}, failed[0].Keys)
+
+	v, err := client.Get(context.Background(), []byte("a4"))
+	s.Nil(err)
+	s.Equal([]byte("va4"), v)
+	v, err = client.Get(context.Background(), []byte("b4"))
+	s.Nil(err)
+	s.Nil(v)
+
+	// the regions are checked separately.
+	wb = NewWriteBatch().DeleteRange([]byte("a"), []byte("b")).Put([]byte("b5"), []byte("vb5"))
+	s.Nil(client.Write(context.Background(), wb))
+	keys, _, err = client.Scan(context.Background(), []byte(""), nil, 10)
+	s.Nil(err)
+	s.Equal([][]byte{[]byte("b5")}, keys)
+
+	// a delete range spans over both regions.
+	s.Nil(client.Write(context.Background(), NewWriteBatch().DeleteRange([]byte("a"), nil)))
+	keys, _, err = client.Scan(context.Background(), []byte(""), nil, 10)
+	s.Nil(err)
+	s.Empty(keys)
+}
+
+func (s *testRawkvSuite) TestKeyTTL() {
+	mvccStore := mocktikv.MustNewMVCCStore()
+	defer mvccStore.Close()
+
+	client := &Client{
+		clusterID:   0,
+		regionCache: locate.NewRegionCache(mocktikv.NewPDClient(s.cluster)),
+		rpcClient:   mocktikv.NewRPCClient(s.cluster, mvccStore, nil),
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	keys := []key{[]byte("a"), []byte("b"), []byte("c")}
+	values := []value{[]byte("va"), []byte("vb"), []byte("vc")}
+	s.Nil(client.BatchPutWithTTL(ctx, keys, values, []uint64{100, 200, 0}))
+
+	// split the keys into 2 regions.
+	loc, err := client.regionCache.LocateKey(s.bo, []byte("b"))
+	s.Nil(err)
+	newRegionID, peerIDs := s.cluster.AllocID(), s.cluster.AllocIDs(2)
+	s.cluster.SplitRaw(loc.Region.GetID(), newRegionID, []byte("b"), peerIDs, peerIDs[0])
+
+	ttl, err := client.GetKeyTTL(ctx, []byte("a"))
+	s.Nil(err)
+	s.NotNil(ttl)
+	s.LessOrEqual(*ttl, uint64(100))
+	s.Greater(*ttl, uint64(0))
+
+	ttls, err := client.BatchGetKeyTTL(ctx, [][]byte{[]byte("c"), []byte("x"), []byte("b"), []byte("a")})
+	s.Nil(err)
+	s.Len(ttls, 4)
+	s.Equal(uint64(0), *ttls[0])
+	s.Nil(ttls[1])
+	s.LessOrEqual(*ttls[2], uint64(200))
+	s.Greater(*ttls[2], uint64(100))
+	s.LessOrEqual(*ttls[3], uint64(100))
+
+	// Overwriting without TTL clears the TTL.
+	s.Nil(client.Put(ctx, []byte("a"), []byte("va2")))
+	returnKeys, returnValues, returnTTLs, err := client.ScanWithTTL(ctx, []byte("a"), nil, 10)
+	s.Nil(err)
+	s.Equal(keys, returnKeys)
+	s.Equal([]value{[]byte("va2"), []byte("vb"), []byte("vc")}, returnValues)
+	s.Len(returnTTLs, 3)
+	s.Equal(uint64(0), returnTTLs[0])
+	s.Greater(returnTTLs[1], uint64(100))
+	s.Equal(uint64(0), returnTTLs[2])
+
+	ttls, err = client.BatchGetKeyTTL(ctx, nil)
+	s.Nil(err)
+	s.Empty(ttls)
+
+	// The keys of a region are requested concurrently, and the ones moved to a new
+	// region are retried.
+	manyKeys := make([][]byte, 0, 3*rawGetKeyTTLConcurrency)
+	manyValues := make([][]byte, 0, 3*rawGetKeyTTLConcurrency)
+	manyTTLs := make([]uint64, 0, 3*rawGetKeyTTLConcurrency)
+	for i := 0; i < 3*rawGetKeyTTLConcurrency; i++ {
+		manyKeys = append(manyKeys, []byte(fmt.Sprintf("k%03d", i)))
+		manyValues = append(manyValues, []byte("v"))
+		manyTTLs = append(manyTTLs, 300)
+	}
+	s.Nil(client.BatchPutWithTTL(ctx, manyKeys, manyValues, manyTTLs))
+	loc, err = client.regionCache.LocateKey(s.bo, manyKeys[rawGetKeyTTLConcurrency])
+	s.Nil(err)
+	newRegionID, peerIDs = s.cluster.AllocID(), s.cluster.AllocIDs(2)
+	s.cluster.SplitRaw(loc.Region.GetID(), newRegionID, manyKeys[rawGetKeyTTLConcurrency], peerIDs, peerIDs[0])
+	ttls, err = client.BatchGetKeyTTL(ctx, manyKeys)
+	s.Nil(err)
+	s.Len(ttls, len(manyKeys))
+	for _, ttl := range ttls {
+		s.NotNil(ttl)
+		s.LessOrEqual(*ttl, uint64(300))
+		s.Greater(*ttl, uint64(200))
+	}
+}
@@ -0,0 +1,385 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rawkv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/client"
+	"github.com/tikv/client-go/v2/internal/locate"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/metrics"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+type writeOp struct {
+	key    []byte
+	value  []byte
+	ttl    uint64
+	delete bool
+}
+
+// WriteBatch buffers a set of mutations which are written to TiKV by Client.Write.
+//
+// If a key is mutated more than once, the last mutation wins. A DeleteRange
+// overrides the previous mutations of the keys in the range, but not the
+// mutations added after it.
+type WriteBatch struct {
+	ops    map[string]*writeOp
+	ranges []kv.KeyRange
+}
+
+// NewWriteBatch creates an empty WriteBatch.
+func NewWriteBatch() *WriteBatch {
+	return &WriteBatch{ops: make(map[string]*writeOp)}
+}
+
+// Put adds a put mutation to the batch.
+func (b *WriteBatch) Put(key, value []byte) *WriteBatch {
+	return b.PutWithTTL(key, value, 0)
+}
+
+// PutWithTTL adds a put mutation with time-to-live duration to the batch.
+func (b *WriteBatch) PutWithTTL(key, value []byte, ttl uint64) *WriteBatch {
+	b.ops[string(key)] = &writeOp{key: key, value: value, ttl: ttl}
+	return b
+}
+
+// Delete adds a delete mutation to the batch.
+func (b *WriteBatch) Delete(key []byte) *WriteBatch {
+	b.ops[string(key)] = &writeOp{key: key, delete: true}
+	return b
+}
+
+// DeleteRange adds a mutation to delete all keys in range [startKey, endKey) to the batch.
+// If endKey is empty, it means unbounded.
+func (b *WriteBatch) DeleteRange(startKey, endKey []byte) *WriteBatch {
+	r := kv.KeyRange{StartKey: startKey, EndKey: endKey}
+	for k, op := range b.ops {
+		if rangeContainsKey(r, op.key) {
+			delete(b.ops, k)
+		}
+	}
+	b.ranges = append(b.ranges, r)
+	return b
+}
+
+// Len returns the number of mutations in the batch.
+func (b *WriteBatch) Len() int {
+	return len(b.ops) + len(b.ranges)
+}
+
+// RegionWriteResult is the result of the mutations of a WriteBatch located in a single region.
+type RegionWriteResult struct {
+	RegionID uint64
+	// Keys are the keys of the put and delete mutations.
+	Keys [][]byte
+	// Ranges are the ranges of the DeleteRange mutations, clipped by the region boundary.
+	Ranges []kv.KeyRange
+	// Err is nil if all the mutations are applied.
+	Err error
+}
+
+// WriteBatchError is returned by Client.Write when the mutations of some
+// regions fail to apply while the others may have been applied. The mutations
+// of the results without error are applied.
+type WriteBatchError struct {
+	Results []RegionWriteResult
+}
+
+// Failed returns the results of the regions whose mutations fail to apply.
+func (e *WriteBatchError) Failed() []RegionWriteResult {
+	var failed []RegionWriteResult
+	for _, r := range e.Results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+func (e *WriteBatchError) Error() string {
+	failed := e.Failed()
+	msgs := make([]string, 0, len(failed))
+	for _, r := range failed {
+		msgs = append(msgs, fmt.Sprintf("region %d: %v", r.RegionID, r.Err))
+	}
+	return fmt.Sprintf("write batch failed in %d of %d regions: %s", len(failed), len(e.Results), strings.Join(msgs, "; "))
+}
+
+// MixedWriteBatchError is returned by Client.Write when the mutations of a
+// region can't be applied atomically by a single request. Nothing of the batch
+// is written.
+type MixedWriteBatchError struct {
+	RegionID uint64
+}
+
+func (e *MixedWriteBatchError) Error() string {
+	return fmt.Sprintf("write batch mixes puts, deletes or delete ranges in region %d, which can't be applied atomically", e.RegionID)
+}
+
+// Write applies the mutations of the WriteBatch to TiKV.
+//
+// The mutations are grouped by region, and the mutations of a region are
+// applied atomically by a single request. So the mutations of a region must be
+// all puts, all deletes, or a single DeleteRange; otherwise a
+// *MixedWriteBatchError is returned before anything is written.
+//
+// If the batch spans over several regions, a *WriteBatchError is returned when
+// the mutations of some regions fail. It reports the result of each region
+// separately.
+func (c *Client) Write(ctx context.Context, b *WriteBatch, options ...RawOption) error {
+	start := time.Now()
+	defer func() { metrics.RawkvCmdHistogramWithBatchWrite.Observe(time.Since(start).Seconds()) }()
+
+	if b.Len() == 0 {
+		return nil
+	}
+	bo := retry.NewBackofferWithVars(ctx, rawkvMaxBackoff, nil)
+	opts := c.getRawKVOptions(options...)
+
+	ops := make([]*writeOp, 0, len(b.ops))
+	for _, op := range b.ops {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return bytes.Compare(ops[i].key, ops[j].key) < 0 })
+
+	results := c.sendWriteBatch(bo, ops, b.ranges, opts)
+	if len(results) == 1 {
+		return results[0].Err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return &WriteBatchError{Results: results}
+		}
+	}
+	return nil
+}
+
+// regionMutations are the mutations of a WriteBatch located in a single region.
+type regionMutations struct {
+	region locate.RegionVerID
+	ops    []*writeOp
+	ranges []kv.KeyRange
+}
+
+func (m *regionMutations) result(err error) RegionWriteResult {
+	return RegionWriteResult{RegionID: m.region.GetID(), Keys: writeOpKeys(m.ops), Ranges: m.ranges, Err: err}
+}
+
+// mixed reports whether the mutations can't be carried by a single request.
+func (m *regionMutations) mixed() bool {
+	if len(m.ranges) > 0 {
+		return len(m.ranges) > 1 || len(m.ops) > 0
+	}
+	for _, op := range m.ops[1:] {
+		if op.delete != m.ops[0].delete {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) sendWriteBatch(bo *retry.Backoffer, ops []*writeOp, ranges []kv.KeyRange, opts *rawOptions) []RegionWriteResult {
+	var groups []*regionMutations
+	groupIdx := make(map[locate.RegionVerID]int)
+	getGroup := func(region locate.RegionVerID) *regionMutations {
+		idx, ok := groupIdx[region]
+		if !ok {
+			idx = len(groups)
+			groupIdx[region] = idx
+			groups = append(groups, &regionMutations{region: region})
+		}
+		return groups[idx]
+	}
+
+	var lastLoc *locate.KeyLocation
+	for _, op := range ops {
+		if lastLoc == nil || !lastLoc.Contains(op.key) {
+			loc, err := c.regionCache.LocateKey(bo, op.key)
+			if err != nil {
+				return []RegionWriteResult{{Keys: writeOpKeys(ops), Ranges: ranges, Err: err}}
+			}
+			lastLoc = loc
+		}
+		g := getGroup(lastLoc.Region)
+		g.ops = append(g.ops, op)
+	}
+	for _, r := range ranges {
+		startKey := r.StartKey
+		for len(r.EndKey) == 0 || bytes.Compare(startKey, r.EndKey) < 0 {
+			loc, err := c.regionCache.LocateKey(bo, startKey)
+			if err != nil {
+				return []RegionWriteResult{{Keys: writeOpKeys(ops), Ranges: ranges, Err: err}}
+			}
+			actualEndKey := r.EndKey
+			if len(loc.EndKey) > 0 && (len(actualEndKey) == 0 || bytes.Compare(loc.EndKey, actualEndKey) < 0) {
+				actualEndKey = loc.EndKey
+			}
+			g := getGroup(loc.Region)
+			g.ranges = append(g.ranges, kv.KeyRange{StartKey: startKey, EndKey: actualEndKey})
+			startKey = loc.EndKey
+			if len(startKey) == 0 {
+				break
+			}
+		}
+	}
+
+	for _, g := range groups {
+		if g.mixed() {
+			return []RegionWriteResult{{Keys: writeOpKeys(ops), Ranges: ranges, Err: errors.WithStack(&MixedWriteBatchError{RegionID: g.region.GetID()})}}
+		}
+	}
+
+	// Unlike the other batch APIs, an error doesn't cancel the other regions,
+	// so that the result of every region can be reported.
+	forkedBo, cancel := bo.Fork()
+	defer cancel()
+	var (
+		mu           sync.Mutex
+		wg           sync.WaitGroup
+		results      = make([][]RegionWriteResult, len(groups))
+		lastForkedBo *retry.Backoffer
+	)
+	for i, g := range groups {
+		i, g := i, g
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			singleBatchBackoffer, singleBatchCancel := forkedBo.Fork()
+			defer singleBatchCancel()
+			res := c.doWriteBatch(singleBatchBackoffer, g, opts)
+			mu.Lock()
+			results[i] = res
+			lastForkedBo = singleBatchBackoffer
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	bo.UpdateUsingForked(lastForkedBo)
+
+	var merged []RegionWriteResult
+	for _, res := range results {
+		merged = append(merged, res...)
+	}
+	return merged
+}
+
+// doWriteBatch applies the mutations of a single region by a single request.
+// If the region is stale, the mutations are regrouped and applied recursively.
+func (c *Client) doWriteBatch(bo *retry.Backoffer, m *regionMutations, opts *rawOptions) []RegionWriteResult {
+	var req *tikvrpc.Request
+	switch {
+	case len(m.ranges) > 0:
+		req = tikvrpc.NewRequest(tikvrpc.CmdRawDeleteRange, &kvrpcpb.RawDeleteRangeRequest{
+			StartKey: m.ranges[0].StartKey,
+			EndKey:   m.ranges[0].EndKey,
+			Cf:       c.getColumnFamily(opts),
+		})
+	case m.ops[0].delete:
+		req = tikvrpc.NewRequest(tikvrpc.CmdRawBatchDelete, &kvrpcpb.RawBatchDeleteRequest{
+			Keys:   writeOpKeys(m.ops),
+			Cf:     c.getColumnFamily(opts),
+			ForCas: c.atomic,
+		})
+	default:
+		req = c.newWriteBatchPutRequest(m.ops, opts)
+	}
+
+	sender := locate.NewRegionRequestSender(c.regionCache, c.rpcClient, oracle.NoopReadTSValidator{})
+	req.MaxExecutionDurationMs = uint64(client.MaxWriteExecutionTime.Milliseconds())
+	resp, _, err := sender.SendReq(bo, req, m.region, client.ReadTimeoutShort)
+	if err != nil {
+		return []RegionWriteResult{m.result(err)}
+	}
+	regionErr, err := resp.GetRegionError()
+	if err != nil {
+		return []RegionWriteResult{m.result(err)}
+	}
+	if regionErr != nil {
+		if regionErr.GetRaftEntryTooLarge() != nil {
+			return []RegionWriteResult{m.result(errors.New(regionErr.String()))}
+		}
+		err := bo.Backoff(retry.BoRegionMiss, errors.New(regionErr.String()))
+		if err != nil {
+			return []RegionWriteResult{m.result(err)}
+		}
+		// recursive call
+		return c.sendWriteBatch(bo, m.ops, m.ranges, opts)
+	}
+	if resp.Resp == nil {
+		return []RegionWriteResult{m.result(errors.WithStack(tikverr.ErrBodyMissing))}
+	}
+
+	var respErr string
+	switch cmdResp := resp.Resp.(type) {
+	case *kvrpcpb.RawDeleteRangeResponse:
+		respErr = cmdResp.GetError()
+	case *kvrpcpb.RawBatchDeleteResponse:
+		respErr = cmdResp.GetError()
+	case *kvrpcpb.RawBatchPutResponse:
+		respErr = cmdResp.GetError()
+	}
+	if respErr != "" {
+		return []RegionWriteResult{m.result(errors.New(respErr))}
+	}
+	return []RegionWriteResult{m.result(nil)}
+}
+
+func (c *Client) newWriteBatchPutRequest(ops []*writeOp, opts *rawOptions) *tikvrpc.Request {
+	pairs := make([]*kvrpcpb.KvPair, 0, len(ops))
+	ttls := make([]uint64, 0, len(ops))
+	hasTTL := false
+	for _, op := range ops {
+		pairs = append(pairs, &kvrpcpb.KvPair{Key: op.key, Value: op.value})
+		ttls = append(ttls, op.ttl)
+		hasTTL = hasTTL || op.ttl > 0
+	}
+	if !hasTTL {
+		ttls = nil
+	}
+	var ttl uint64
+	if len(ttls) > 0 {
+		ttl = ttls[0]
+	}
+	req := tikvrpc.NewRequest(tikvrpc.CmdRawBatchPut, &kvrpcpb.RawBatchPutRequest{
+		Pairs:  pairs,
+		Cf:     c.getColumnFamily(opts),
+		ForCas: c.atomic,
+		Ttls:   ttls,
+		Ttl:    ttl,
+	})
+	req.ApiVersion = c.apiVersion
+	return req
+}
+
+func writeOpKeys(ops []*writeOp) [][]byte {
+	keys := make([][]byte, 0, len(ops))
+	for _, op := range ops {
+		keys = append(keys, op.key)
+	}
+	return keys
+}
@@ -0,0 +1,120 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pingcap/failpoint"
+	"github.com/stretchr/testify/suite"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/mockstore/mocktikv"
+	"github.com/tikv/client-go/v2/testutils"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+	"github.com/tikv/client-go/v2/util"
+)
+
+func TestAsyncCommitMockTiKV(t *testing.T) {
+	util.EnableFailpoints()
+	suite.Run(t, new(testAsyncCommitMockTiKVSuite))
+}
+
+type testAsyncCommitMockTiKVSuite struct {
+	suite.Suite
+	store *KVStore
+}
+
+func (s *testAsyncCommitMockTiKVSuite) SetupTest() {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	s.Require().Nil(err)
+	_, _, regionID := mocktikv.BootstrapWithSingleStore(cluster)
+	peerID := cluster.AllocID()
+	cluster.Split(regionID, cluster.AllocID(), []byte("k2"), []uint64{peerID}, peerID)
+
+	s.store, err = NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	s.Require().Nil(err)
+}
+
+func (s *testAsyncCommitMockTiKVSuite) TearDownTest() {
+	s.Require().Nil(s.store.Close())
+}
+
+func (s *testAsyncCommitMockTiKVSuite) TestResolveAsyncCommitLock() {
+	ctx := context.Background()
+	s.Require().Nil(failpoint.Enable("tikvclient/twoPCShortLockTTL", "return"))
+	s.Require().Nil(failpoint.Enable("tikvclient/asyncCommitDoNothing", "return"))
+	defer func() {
+		s.Require().Nil(failpoint.Disable("tikvclient/twoPCShortLockTTL"))
+		s.Require().Nil(failpoint.Disable("tikvclient/asyncCommitDoNothing"))
+	}()
+
+	txn, err := s.store.Begin()
+	s.Require().Nil(err)
+	txn.SetEnableAsyncCommit(true)
+	txn.SetSessionID(1)
+	s.Require().Nil(txn.Set([]byte("k1"), []byte("v1")))
+	s.Require().Nil(txn.Set([]byte("k3"), []byte("v3")))
+	s.Require().Nil(txn.Commit(ctx))
+	s.Require().True(transaction.TxnProbe{KVTxn: txn}.IsAsyncCommit())
+	commitTS := transaction.TxnProbe{KVTxn: txn}.GetCommitter().GetCommitTS()
+	s.Less(txn.StartTS(), commitTS)
+
+	// The locks are left as the secondaries are not committed, the writer
+	// resolves them by checking all secondary locks.
+	txn1, err := s.store.Begin()
+	s.Require().Nil(err)
+	s.Require().Nil(txn1.Set([]byte("k3"), []byte("v33")))
+	s.Require().Nil(txn1.Commit(ctx))
+
+	// The data is committed with the min commit ts.
+	snapshot := s.store.GetSnapshot(commitTS - 1)
+	_, err = snapshot.Get(ctx, []byte("k1"))
+	s.True(tikverr.IsErrNotFound(err))
+	snapshot = s.store.GetSnapshot(commitTS)
+	val, err := snapshot.Get(ctx, []byte("k1"))
+	s.Require().Nil(err)
+	s.Equal([]byte("v1"), val)
+	val, err = snapshot.Get(ctx, []byte("k3"))
+	s.Require().Nil(err)
+	s.Equal([]byte("v3"), val)
+}
+
+func (s *testAsyncCommitMockTiKVSuite) TestOnePC() {
+	ctx := context.Background()
+	txn, err := s.store.Begin()
+	s.Require().Nil(err)
+	txn.SetEnable1PC(true)
+	s.Require().Nil(txn.Set([]byte("k1"), []byte("v1")))
+	s.Require().Nil(txn.Set([]byte("k11"), []byte("v11")))
+	s.Require().Nil(txn.Commit(ctx))
+	committer := transaction.TxnProbe{KVTxn: txn}.GetCommitter()
+	s.True(committer.IsOnePC())
+	s.Equal(committer.GetOnePCCommitTS(), committer.GetCommitTS())
+
+	snapshot := s.store.GetSnapshot(committer.GetCommitTS())
+	val, err := snapshot.Get(ctx, []byte("k11"))
+	s.Require().Nil(err)
+	s.Equal([]byte("v11"), val)
+
+	// 1PC is not used across regions.
+	txn, err = s.store.Begin()
+	s.Require().Nil(err)
+	txn.SetEnable1PC(true)
+	s.Require().Nil(txn.Set([]byte("k1"), []byte("v2")))
+	s.Require().Nil(txn.Set([]byte("k3"), []byte("v3")))
+	s.Require().Nil(txn.Commit(ctx))
+	s.False(transaction.TxnProbe{KVTxn: txn}.GetCommitter().IsOnePC())
+}
@@ -74,3 +74,21 @@ const (
 func NewRPCClient(opts ...ClientOpt) *client.RPCClient {
 	return client.NewRPCClient(opts...)
 }
+
+// StoreCircuitBreakerSettings describes the configuration of the circuit breakers of the stores.
+type StoreCircuitBreakerSettings = client.StoreCircuitBreakerSettings
+
+// ChangeStoreCircuitBreakerSettings changes the settings of the circuit breakers of the stores.
+func ChangeStoreCircuitBreakerSettings(apply func(settings *StoreCircuitBreakerSettings)) {
+	client.ChangeStoreCircuitBreakerSettings(apply)
+}
+
+// CoprCache caches the results of coprocessor requests.
+type CoprCache = client.CoprCache
+
+// NewCoprCache creates a coprocessor cache with the config, it can be added to a
+// Client by CoprCache.Wrap or to a context by CoprCache.Interceptor. It returns
+// nil if the cache is disabled by the config.
+func NewCoprCache(cfg config.CoprocessorCache) (*CoprCache, error) {
+	return client.NewCoprCache(cfg)
+}
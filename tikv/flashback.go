@@ -0,0 +1,383 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/txnkv/rangetask"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+	"github.com/tikv/client-go/v2/util"
+	zap "go.uber.org/zap"
+)
+
+const (
+	flashbackOneRegionMaxBackoff = 100000
+	// defaultFlashbackRegionsPerTask is the default number of regions of each
+	// range task of a flashback phase.
+	defaultFlashbackRegionsPerTask = 16
+)
+
+// FlashbackPhase is the phase of a flashback.
+type FlashbackPhase int
+
+// Flashback phases.
+const (
+	// FlashbackPhasePrepare stops the writes and reads of the regions in the range.
+	FlashbackPhasePrepare FlashbackPhase = iota
+	// FlashbackPhaseApply rewrites the data in the range to the target version
+	// and resumes the regions.
+	FlashbackPhaseApply
+	// FlashbackPhaseDone means the flashback is finished.
+	FlashbackPhaseDone
+)
+
+// String implements fmt.Stringer interface.
+func (p FlashbackPhase) String() string {
+	switch p {
+	case FlashbackPhasePrepare:
+		return "prepare"
+	case FlashbackPhaseApply:
+		return "apply"
+	case FlashbackPhaseDone:
+		return "done"
+	}
+	return fmt.Sprintf("unknown(%d)", int(p))
+}
+
+// FlashbackProgress records the progress of a flashback. The last progress
+// reported to the callback set by WithFlashbackProgressCallback can be passed
+// to WithFlashbackResume to continue an interrupted flashback.
+type FlashbackProgress struct {
+	Version  uint64
+	StartKey []byte
+	EndKey   []byte
+	// StartTS identifies the flashback. The regions in the range are locked
+	// with it during the prepare phase.
+	StartTS uint64
+	// CommitTS is the timestamp the flashback data is written with. It is
+	// allocated after the prepare phase is finished.
+	CommitTS uint64
+	Phase    FlashbackPhase
+	// PrepareDoneKey and ApplyDoneKey are the keys before which the phases
+	// are finished on all regions of the range. An interrupted phase is
+	// continued from its key, or from StartKey if it's nil.
+	PrepareDoneKey []byte
+	ApplyDoneKey   []byte
+	// CompletedRegions is the number of regions finished by the last run of
+	// the phase.
+	CompletedRegions int
+}
+
+type flashbackOption struct {
+	concurrency    int
+	regionsPerTask int
+	resume         *FlashbackProgress
+	onProgress     func(FlashbackProgress)
+}
+
+// FlashbackOpt is the option for FlashbackToVersion.
+type FlashbackOpt func(*flashbackOption)
+
+// WithFlashbackConcurrency sets the RangeTaskRunner concurrency of each phase.
+func WithFlashbackConcurrency(concurrency int) FlashbackOpt {
+	return func(opt *flashbackOption) {
+		opt.concurrency = concurrency
+	}
+}
+
+// WithFlashbackRegionsPerTask sets the number of regions of each range task of
+// the phases. The finished key of a phase only moves forward by whole tasks, so
+// it's also the granularity of the progress to resume from.
+func WithFlashbackRegionsPerTask(regionsPerTask int) FlashbackOpt {
+	return func(opt *flashbackOption) {
+		opt.regionsPerTask = regionsPerTask
+	}
+}
+
+// WithFlashbackResume continues the flashback from the given progress. The
+// version and key range of the progress must match the flashback.
+func WithFlashbackResume(progress FlashbackProgress) FlashbackOpt {
+	return func(opt *flashbackOption) {
+		opt.resume = &progress
+	}
+}
+
+// WithFlashbackProgressCallback sets the callback which is called every time
+// the flashback moves to the next phase, or when a phase fails after finishing
+// part of the range.
+func WithFlashbackProgressCallback(f func(FlashbackProgress)) FlashbackOpt {
+	return func(opt *flashbackOption) {
+		opt.onProgress = f
+	}
+}
+
+// FlashbackToVersion rewrites the data in range [startKey, endKey) to the
+// state at `version`. Empty keys means the range is unbounded.
+//
+// The flashback is performed by:
+// 1. preparing all regions in the range, which rejects other reads and writes
+// to the regions until the flashback is finished
+// 2. writing the data of `version` with a new commit ts and resuming the regions
+//
+// The workload on the range is expected to be stopped during the flashback.
+// `version` must not be less than the GC safe point, it is checked before each
+// phase.
+//
+// If the flashback fails, it can be continued by passing the last reported
+// progress with WithFlashbackResume. Each phase is continued from the key
+// before which it's finished, and the flashback is finished with the same
+// timestamps. The prepare phase is skipped if it's finished, as the regions
+// applied are resumed and must not be prepared again.
+func (s *KVStore) FlashbackToVersion(ctx context.Context, version uint64, startKey, endKey []byte, opts ...FlashbackOpt) error {
+	// default concurrency 8
+	opt := &flashbackOption{concurrency: 8, regionsPerTask: defaultFlashbackRegionsPerTask}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	progress := FlashbackProgress{
+		Version:  version,
+		StartKey: startKey,
+		EndKey:   endKey,
+		Phase:    FlashbackPhasePrepare,
+	}
+	if opt.resume != nil {
+		if opt.resume.Version != version || !bytes.Equal(opt.resume.StartKey, startKey) || !bytes.Equal(opt.resume.EndKey, endKey) {
+			return errors.Errorf("flashback progress of version %d range [%q, %q) does not match the flashback",
+				opt.resume.Version, opt.resume.StartKey, opt.resume.EndKey)
+		}
+		progress = *opt.resume
+	}
+	if progress.Phase == FlashbackPhaseDone {
+		return nil
+	}
+	report := func() {
+		if opt.onProgress != nil {
+			opt.onProgress(progress)
+		}
+	}
+
+	if err := s.checkFlashbackVersion(ctx, version); err != nil {
+		return err
+	}
+	if progress.StartTS == 0 {
+		bo := retry.NewBackofferWithVars(ctx, transaction.TsoMaxBackoff, nil)
+		startTS, err := s.getTimestampWithRetry(bo, oracle.GlobalTxnScope)
+		if err != nil {
+			return err
+		}
+		if version >= startTS {
+			return errors.Errorf("flashback version %d is not less than the current ts %d", version, startTS)
+		}
+		progress.StartTS = startTS
+		report()
+	}
+
+	if progress.Phase == FlashbackPhasePrepare {
+		prepared, doneKey, err := s.runFlashbackPhase(ctx, "flashback-prepare", progress.PrepareDoneKey, startKey, endKey, opt,
+			func(startKey, endKey []byte) *tikvrpc.Request {
+				return tikvrpc.NewRequest(tikvrpc.CmdPrepareFlashbackToVersion, &kvrpcpb.PrepareFlashbackToVersionRequest{
+					StartKey: startKey,
+					EndKey:   endKey,
+					StartTs:  progress.StartTS,
+					Version:  version,
+				})
+			})
+		if err != nil {
+			if doneKey != nil {
+				progress.PrepareDoneKey = doneKey
+				progress.CompletedRegions = prepared
+				report()
+			}
+			return err
+		}
+
+		if err = s.checkFlashbackVersion(ctx, version); err != nil {
+			return err
+		}
+		if progress.CommitTS == 0 {
+			bo := retry.NewBackofferWithVars(ctx, transaction.TsoMaxBackoff, nil)
+			progress.CommitTS, err = s.getTimestampWithRetry(bo, oracle.GlobalTxnScope)
+			if err != nil {
+				return err
+			}
+		}
+		progress.Phase = FlashbackPhaseApply
+		progress.CompletedRegions = prepared
+		report()
+	} else if err := s.checkFlashbackVersion(ctx, version); err != nil {
+		return err
+	}
+
+	applied, doneKey, err := s.runFlashbackPhase(ctx, "flashback-apply", progress.ApplyDoneKey, startKey, endKey, opt,
+		func(startKey, endKey []byte) *tikvrpc.Request {
+			return tikvrpc.NewRequest(tikvrpc.CmdFlashbackToVersion, &kvrpcpb.FlashbackToVersionRequest{
+				Version:  version,
+				StartKey: startKey,
+				EndKey:   endKey,
+				StartTs:  progress.StartTS,
+				CommitTs: progress.CommitTS,
+			})
+		})
+	if err != nil {
+		if doneKey != nil {
+			progress.ApplyDoneKey = doneKey
+			progress.CompletedRegions = applied
+			report()
+		}
+		return err
+	}
+	progress.Phase = FlashbackPhaseDone
+	progress.CompletedRegions = applied
+	report()
+
+	logutil.Logger(ctx).Info("flashback finished",
+		zap.Uint64("version", version),
+		zap.Uint64("startTS", progress.StartTS),
+		zap.Uint64("commitTS", progress.CommitTS),
+		zap.Int("regions", applied))
+	return nil
+}
+
+// checkFlashbackVersion makes sure the data of the version is not garbage
+// collected.
+func (s *KVStore) checkFlashbackVersion(ctx context.Context, version uint64) error {
+	// Updating with 0 never moves the safe point forward, it just returns the
+	// current one.
+	safePoint, err := s.pdClient.UpdateGCSafePoint(ctx, 0)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if version < safePoint {
+		return errors.Errorf("flashback version %d is less than the GC safe point %d", version, safePoint)
+	}
+	return nil
+}
+
+// runFlashbackPhase runs the phase on the range from doneKey, or from startKey if
+// doneKey is nil. If the phase fails, the key before which it's finished is
+// returned, which is nil if it doesn't move forward.
+func (s *KVStore) runFlashbackPhase(
+	ctx context.Context,
+	name string,
+	doneKey, startKey, endKey []byte,
+	opt *flashbackOption,
+	newReq func(startKey, endKey []byte) *tikvrpc.Request,
+) (int, []byte, error) {
+	if doneKey != nil {
+		startKey = doneKey
+	}
+	handler := func(ctx context.Context, r kv.KeyRange) (rangetask.TaskStat, error) {
+		return s.flashbackOnRange(ctx, r, newReq)
+	}
+	runner := rangetask.NewRangeTaskRunner(name, s, opt.concurrency, handler)
+	if opt.regionsPerTask > 0 {
+		runner.SetRegionsPerTask(opt.regionsPerTask)
+	}
+	err := runner.RunOnRange(ctx, startKey, endKey)
+	if err == nil {
+		// The runner stops dispatching tasks silently once ctx is done, so the
+		// phase is not finished on all regions in that case.
+		err = errors.WithStack(ctx.Err())
+	}
+	if err == nil {
+		return runner.CompletedRegions(), nil, nil
+	}
+	// The phase is finished before the end of the first completed range if it
+	// starts from startKey, as the later ones follow unfinished tasks.
+	completed := runner.CompletedRanges()
+	if len(completed) == 0 || !bytes.Equal(completed[0].StartKey, startKey) {
+		return runner.CompletedRegions(), nil, err
+	}
+	if bytes.Equal(completed[0].EndKey, endKey) {
+		// All the tasks are finished before ctx is done.
+		return runner.CompletedRegions(), nil, nil
+	}
+	return runner.CompletedRegions(), completed[0].EndKey, err
+}
+
+// flashbackOnRange sends the flashback requests built by newReq to each region
+// in the range.
+func (s *KVStore) flashbackOnRange(ctx context.Context, r kv.KeyRange, newReq func(startKey, endKey []byte) *tikvrpc.Request) (rangetask.TaskStat, error) {
+	startKey, rangeEndKey := r.StartKey, r.EndKey
+	var stat rangetask.TaskStat
+	for {
+		select {
+		case <-ctx.Done():
+			return stat, errors.WithStack(ctx.Err())
+		default:
+		}
+
+		if len(rangeEndKey) > 0 && bytes.Compare(startKey, rangeEndKey) >= 0 {
+			break
+		}
+
+		bo := retry.NewBackofferWithVars(ctx, flashbackOneRegionMaxBackoff, nil)
+		loc, err := s.GetRegionCache().LocateKey(bo, startKey)
+		if err != nil {
+			return stat, err
+		}
+
+		endKey := loc.EndKey
+		isLast := len(endKey) == 0 || (len(rangeEndKey) > 0 && bytes.Compare(endKey, rangeEndKey) >= 0)
+		if isLast {
+			endKey = rangeEndKey
+		}
+
+		req := newReq(startKey, endKey)
+		if val, e := util.EvalFailpoint("mockFlashbackRegionError"); e == nil && string(startKey) == val.(string) {
+			return stat, errors.Errorf("injected %s error on region %d", req.Type, loc.Region.GetID())
+		}
+		resp, err := s.SendReq(bo, req, loc.Region, ReadTimeoutMedium)
+		if err != nil {
+			return stat, err
+		}
+		regionErr, err := resp.GetRegionError()
+		if err != nil {
+			return stat, err
+		}
+		if regionErr != nil {
+			err = bo.Backoff(retry.BoRegionMiss, errors.New(regionErr.String()))
+			if err != nil {
+				return stat, err
+			}
+			continue
+		}
+		if resp.Resp == nil {
+			return stat, errors.WithStack(tikverr.ErrBodyMissing)
+		}
+		if errStr := resp.Resp.(interface{ GetError() string }).GetError(); errStr != "" {
+			return stat, errors.Errorf("unexpected %s err: %v", req.Type, errStr)
+		}
+		stat.CompletedRegions++
+		if isLast {
+			break
+		}
+		startKey = endKey
+	}
+
+	return stat, nil
+}
@@ -0,0 +1,216 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pingcap/failpoint"
+	"github.com/stretchr/testify/suite"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/mockstore/mocktikv"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/testutils"
+	"github.com/tikv/client-go/v2/util"
+)
+
+func TestFlashback(t *testing.T) {
+	util.EnableFailpoints()
+	suite.Run(t, new(testFlashbackSuite))
+}
+
+type testFlashbackSuite struct {
+	suite.Suite
+	store   *KVStore
+	cluster *mocktikv.Cluster
+}
+
+func (s *testFlashbackSuite) SetupTest() {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	s.Require().Nil(err)
+	_, _, regionID := mocktikv.BootstrapWithSingleStore(cluster)
+	// Split the range into regions ["", "b") and ["b", "").
+	peerID := cluster.AllocID()
+	cluster.Split(regionID, cluster.AllocID(), []byte("b"), []uint64{peerID}, peerID)
+
+	store, err := NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	s.Require().Nil(err)
+	s.store = store
+	s.cluster = cluster
+}
+
+func (s *testFlashbackSuite) TearDownTest() {
+	s.Require().Nil(s.store.Close())
+}
+
+func (s *testFlashbackSuite) mustCommit(puts map[string]string, deletes ...string) {
+	txn, err := s.store.Begin()
+	s.Require().Nil(err)
+	for k, v := range puts {
+		s.Require().Nil(txn.Set([]byte(k), []byte(v)))
+	}
+	for _, k := range deletes {
+		s.Require().Nil(txn.Delete([]byte(k)))
+	}
+	s.Require().Nil(txn.Commit(context.Background()))
+}
+
+func (s *testFlashbackSuite) mustGetTS() uint64 {
+	ts, err := s.store.CurrentTimestamp(oracle.GlobalTxnScope)
+	s.Require().Nil(err)
+	return ts
+}
+
+func (s *testFlashbackSuite) checkData(expected map[string]string, missing ...string) {
+	snapshot := s.store.GetSnapshot(s.mustGetTS())
+	for k, v := range expected {
+		val, err := snapshot.Get(context.Background(), []byte(k))
+		s.Require().Nil(err)
+		s.Equal(v, string(val))
+	}
+	for _, k := range missing {
+		_, err := snapshot.Get(context.Background(), []byte(k))
+		s.True(tikverr.IsErrNotFound(err), "key %s", k)
+	}
+}
+
+func (s *testFlashbackSuite) TestFlashback() {
+	ctx := context.Background()
+	s.mustCommit(map[string]string{"a": "a1", "b": "b1", "c": "c1"})
+	version := s.mustGetTS()
+	s.mustCommit(map[string]string{"a": "a2", "d": "d2"}, "b")
+
+	var progresses []FlashbackProgress
+	err := s.store.FlashbackToVersion(ctx, version, nil, nil, WithFlashbackProgressCallback(func(p FlashbackProgress) {
+		progresses = append(progresses, p)
+	}))
+	s.Require().Nil(err)
+	s.checkData(map[string]string{"a": "a1", "b": "b1", "c": "c1"}, "d")
+
+	s.Require().Len(progresses, 3)
+	s.Equal(FlashbackPhasePrepare, progresses[0].Phase)
+	s.Equal(FlashbackPhaseApply, progresses[1].Phase)
+	s.Equal(2, progresses[1].CompletedRegions)
+	last := progresses[2]
+	s.Equal(FlashbackPhaseDone, last.Phase)
+	s.Equal(2, last.CompletedRegions)
+	s.Less(version, last.StartTS)
+	s.Less(last.StartTS, last.CommitTS)
+
+	// The data can be written after the flashback.
+	s.mustCommit(map[string]string{"d": "d3"})
+	s.checkData(map[string]string{"d": "d3"})
+
+	// A finished flashback is not executed again.
+	s.Nil(s.store.FlashbackToVersion(ctx, version, nil, nil, WithFlashbackResume(last)))
+	s.checkData(map[string]string{"d": "d3"})
+}
+
+func (s *testFlashbackSuite) TestFlashbackPartialRange() {
+	s.mustCommit(map[string]string{"a": "a1", "c": "c1"})
+	version := s.mustGetTS()
+	s.mustCommit(map[string]string{"a": "a2", "c": "c2"})
+
+	s.Require().Nil(s.store.FlashbackToVersion(context.Background(), version, []byte("b"), nil))
+	s.checkData(map[string]string{"a": "a2", "c": "c1"})
+}
+
+func (s *testFlashbackSuite) TestResumeFlashback() {
+	s.mustCommit(map[string]string{"a": "a1", "c": "c1"})
+	version := s.mustGetTS()
+	s.mustCommit(map[string]string{"c": "c2"}, "a")
+
+	// Interrupt the flashback after all regions are prepared.
+	ctx, cancel := context.WithCancel(context.Background())
+	var progress FlashbackProgress
+	err := s.store.FlashbackToVersion(ctx, version, nil, nil, WithFlashbackProgressCallback(func(p FlashbackProgress) {
+		progress = p
+		if p.Phase == FlashbackPhaseApply {
+			cancel()
+		}
+	}))
+	s.Require().NotNil(err)
+	s.Equal(FlashbackPhaseApply, progress.Phase)
+
+	// The regions reject other requests until the flashback is finished.
+	_, err = s.store.GetSnapshot(s.mustGetTS()).Get(context.Background(), []byte("a"))
+	s.Require().NotNil(err)
+	s.Contains(err.Error(), "flashback progress")
+
+	s.Require().NotNil(s.store.FlashbackToVersion(context.Background(), version, []byte("a"), nil, WithFlashbackResume(progress)))
+	s.Require().Nil(s.store.FlashbackToVersion(context.Background(), version, nil, nil, WithFlashbackResume(progress)))
+	s.checkData(map[string]string{"a": "a1", "c": "c1"})
+}
+
+func (s *testFlashbackSuite) TestResumeFlashbackFromDoneKey() {
+	s.mustCommit(map[string]string{"a": "a1", "c": "c1"})
+	version := s.mustGetTS()
+	s.mustCommit(map[string]string{"a": "a2", "c": "c2"})
+
+	var progress FlashbackProgress
+	opts := []FlashbackOpt{
+		WithFlashbackConcurrency(1),
+		WithFlashbackRegionsPerTask(1),
+		WithFlashbackProgressCallback(func(p FlashbackProgress) {
+			progress = p
+			if p.Phase == FlashbackPhaseApply {
+				s.Require().Nil(failpoint.Enable("tikvclient/mockFlashbackRegionError", `return("b")`))
+			}
+		}),
+	}
+	// The region ["b", "") fails to prepare, the region before it is finished.
+	s.Require().Nil(failpoint.Enable("tikvclient/mockFlashbackRegionError", `return("b")`))
+	s.Require().NotNil(s.store.FlashbackToVersion(context.Background(), version, nil, nil, opts...))
+	s.Equal(FlashbackPhasePrepare, progress.Phase)
+	s.Equal([]byte("b"), progress.PrepareDoneKey)
+	s.Equal(1, progress.CompletedRegions)
+
+	// The prepare phase is continued from "b", and then the region ["b", "")
+	// fails to apply.
+	s.Require().Nil(failpoint.Disable("tikvclient/mockFlashbackRegionError"))
+	s.Require().NotNil(s.store.FlashbackToVersion(context.Background(), version, nil, nil,
+		append(opts, WithFlashbackResume(progress))...))
+	s.Equal(FlashbackPhaseApply, progress.Phase)
+	s.Equal([]byte("b"), progress.ApplyDoneKey)
+	s.Equal(1, progress.CompletedRegions)
+
+	// The applied region is resumed and can be written.
+	s.checkData(map[string]string{"a": "a1"})
+	s.mustCommit(map[string]string{"a": "a3"})
+
+	// The apply phase is continued from "b" without preparing or applying the
+	// finished region again.
+	s.Require().Nil(failpoint.Disable("tikvclient/mockFlashbackRegionError"))
+	s.Require().Nil(s.store.FlashbackToVersion(context.Background(), version, nil, nil,
+		WithFlashbackRegionsPerTask(1), WithFlashbackResume(progress)))
+	s.checkData(map[string]string{"a": "a3", "c": "c1"})
+}
+
+func (s *testFlashbackSuite) TestFlashbackGCSafePoint() {
+	s.mustCommit(map[string]string{"a": "a1"})
+	version := s.mustGetTS()
+	s.mustCommit(map[string]string{"a": "a2"})
+
+	_, err := s.store.GetPDClient().UpdateGCSafePoint(context.Background(), version+1)
+	s.Require().Nil(err)
+	err = s.store.FlashbackToVersion(context.Background(), version, nil, nil)
+	s.Require().NotNil(err)
+	s.Contains(err.Error(), "GC safe point")
+	s.checkData(map[string]string{"a": "a2"})
+
+	err = s.store.FlashbackToVersion(context.Background(), s.mustGetTS()+1<<20, nil, nil)
+	s.Require().NotNil(err)
+}
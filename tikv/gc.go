@@ -54,6 +54,7 @@ const GCScanLockLimit = txnlock.ResolvedCacheSize / 2
 //
 // GC is a simplified version of [GC in TiDB](https://docs.pingcap.com/tidb/stable/garbage-collection-overview).
 // We skip the second step "delete ranges" which is an optimization for TiDB.
+// The package tikv/gcworker runs the full GC periodically, including the delete ranges step.
 func (s *KVStore) GC(ctx context.Context, safepoint uint64, opts ...GCOpt) (newSafePoint uint64, err error) {
 	// default concurrency 8
 	opt := &gcOption{concurrency: 8}
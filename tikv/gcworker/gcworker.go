@@ -0,0 +1,425 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gcworker implements the garbage collection (GC) of a TiKV cluster for
+// the applications using client-go without TiDB.
+//
+// A GC round is performed by:
+//  1. choosing the safe point by the life time of the MVCC versions, the min
+//     start TS of the active transactions and the service safe points in PD.
+//  2. resolving all locks with timestamp <= safe point and updating PD's GC
+//     safe point, so TiKV can clean up the stale MVCC versions.
+//  3. deleting the ranges recorded by AddDeleteRange whose TS <= safe point,
+//     first by DeleteRange and then by UnsafeDestroyRange to free the disk space.
+//
+// The progress of a round is recorded in SafePointKV, a round interrupted by
+// errors or restarts is resumed by the next run with the same safe point.
+//
+// Only one GCWorker should run for a cluster at the same time.
+package gcworker
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/tikv"
+	"go.uber.org/zap"
+)
+
+// Keys of the GC worker in SafePointKV.
+const (
+	gcJobKey            = "/tidb/store/gcworker/client_go_job"
+	gcDeleteRangePrefix = "/tidb/store/gcworker/client_go_delete_range/"
+)
+
+// ServiceID is the ID of the service safe point the GC worker sets in PD.
+const ServiceID = "gc_worker"
+
+// Config is the config of GCWorker.
+type Config struct {
+	// RunInterval is the interval to run GC.
+	RunInterval time.Duration
+	// LifeTime is how long the MVCC versions are kept, the safe point is never
+	// later than now - LifeTime.
+	LifeTime time.Duration
+	// Concurrency is the concurrency of resolving locks and deleting ranges.
+	Concurrency int
+}
+
+// DefaultConfig returns the default config of GCWorker.
+func DefaultConfig() Config {
+	return Config{
+		RunInterval: 10 * time.Minute,
+		LifeTime:    10 * time.Minute,
+		Concurrency: 8,
+	}
+}
+
+// Phase is the phase of a GC round.
+type Phase string
+
+// Phases of a GC round.
+const (
+	PhaseResolveLocks Phase = "resolve_locks"
+	PhaseDeleteRanges Phase = "delete_ranges"
+	PhaseDone         Phase = "done"
+)
+
+// Job is the progress of a GC round.
+type Job struct {
+	SafePoint uint64 `json:"safe_point"`
+	Phase     Phase  `json:"phase"`
+}
+
+// DeleteRangeState is the state of a range to delete.
+type DeleteRangeState string
+
+// States of a range to delete.
+const (
+	// DeleteRangePending means the range is waiting for the safe point to pass its TS.
+	DeleteRangePending DeleteRangeState = "pending"
+	// DeleteRangeDeleted means the data of the range is deleted by DeleteRange,
+	// and the range is waiting for UnsafeDestroyRange to free the disk space.
+	DeleteRangeDeleted DeleteRangeState = "deleted"
+)
+
+// DeleteRange is a range whose data is deleted by GC once the safe point
+// passes its TS.
+type DeleteRange struct {
+	ID       string           `json:"id"`
+	StartKey []byte           `json:"start_key"`
+	EndKey   []byte           `json:"end_key"`
+	TS       uint64           `json:"ts"`
+	State    DeleteRangeState `json:"state"`
+}
+
+// Option is the option of GCWorker.
+type Option func(*GCWorker)
+
+// WithMinStartTSProvider sets the function returning the min start TS of the
+// active transactions of the application, the safe point never passes it. It
+// returns 0 if there is no active transaction.
+func WithMinStartTSProvider(provider func(ctx context.Context) (uint64, error)) Option {
+	return func(w *GCWorker) {
+		w.minStartTS = provider
+	}
+}
+
+// GCWorker runs GC of the cluster periodically.
+type GCWorker struct {
+	store      *tikv.KVStore
+	kv         tikv.SafePointKV
+	cfg        Config
+	minStartTS func(ctx context.Context) (uint64, error)
+
+	// mu serializes the GC rounds.
+	mu sync.Mutex
+	// rangesMu protects the updates of the ranges to delete.
+	rangesMu sync.Mutex
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewGCWorker creates a GCWorker of the store, the zero fields of cfg are set
+// to the default values.
+func NewGCWorker(store *tikv.KVStore, cfg Config, opts ...Option) *GCWorker {
+	def := DefaultConfig()
+	if cfg.RunInterval <= 0 {
+		cfg.RunInterval = def.RunInterval
+	}
+	if cfg.LifeTime <= 0 {
+		cfg.LifeTime = def.LifeTime
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = def.Concurrency
+	}
+	w := &GCWorker{
+		store: store,
+		kv:    store.GetSafePointKV(),
+		cfg:   cfg,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Start starts running GC every RunInterval in the background until Close is
+// called.
+func (w *GCWorker) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.wg.Add(1)
+	go w.run(ctx)
+}
+
+// Close stops the GC worker and waits for the running round to exit.
+func (w *GCWorker) Close() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.wg.Wait()
+}
+
+func (w *GCWorker) run(ctx context.Context) {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.cfg.RunInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.RunOnce(ctx); err != nil && ctx.Err() == nil {
+				logutil.BgLogger().Warn("[gc worker] run GC failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// AddDeleteRange records a range to delete once the safe point passes ts, the
+// range must never be accessed by the transactions with start TS > ts. Adding
+// the same range with the same ts again is a no-op.
+func (w *GCWorker) AddDeleteRange(startKey, endKey []byte, ts uint64) (*DeleteRange, error) {
+	if len(endKey) > 0 && bytes.Compare(startKey, endKey) >= 0 {
+		return nil, errors.Errorf("invalid delete range [%x, %x)", startKey, endKey)
+	}
+	r := &DeleteRange{
+		ID:       fmt.Sprintf("%020d_%s_%s", ts, hex.EncodeToString(startKey), hex.EncodeToString(endKey)),
+		StartKey: startKey,
+		EndKey:   endKey,
+		TS:       ts,
+		State:    DeleteRangePending,
+	}
+	w.rangesMu.Lock()
+	defer w.rangesMu.Unlock()
+	val, err := w.kv.Get(gcDeleteRangePrefix + r.ID)
+	if err != nil {
+		return nil, err
+	}
+	if val != "" {
+		if err := json.Unmarshal([]byte(val), r); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return r, nil
+	}
+	if err := w.saveDeleteRange(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// DeleteRanges returns the ranges not destroyed yet, ordered by TS.
+func (w *GCWorker) DeleteRanges() ([]*DeleteRange, error) {
+	kvs, err := w.kv.GetWithPrefix(gcDeleteRangePrefix)
+	if err != nil {
+		return nil, err
+	}
+	ranges := make([]*DeleteRange, 0, len(kvs))
+	for _, kv := range kvs {
+		// The destroyed ranges are cleared since SafePointKV can't delete keys.
+		if len(kv.Value) == 0 {
+			continue
+		}
+		r := &DeleteRange{}
+		if err := json.Unmarshal(kv.Value, r); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		ranges = append(ranges, r)
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].ID < ranges[j].ID })
+	return ranges, nil
+}
+
+// Job returns the progress of the last GC round, nil if GC has never run.
+func (w *GCWorker) Job() (*Job, error) {
+	val, err := w.kv.Get(gcJobKey)
+	if err != nil || val == "" {
+		return nil, err
+	}
+	job := &Job{}
+	if err := json.Unmarshal([]byte(val), job); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return job, nil
+}
+
+// RunOnce runs a GC round, or resumes the last one if it's not done.
+func (w *GCWorker) RunOnce(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	job, err := w.Job()
+	if err != nil {
+		return err
+	}
+	if job == nil || job.Phase == PhaseDone {
+		var lastSafePoint uint64
+		if job != nil {
+			lastSafePoint = job.SafePoint
+		}
+		safePoint, err := w.calcSafePoint(ctx)
+		if err != nil {
+			return err
+		}
+		if safePoint <= lastSafePoint {
+			logutil.BgLogger().Info("[gc worker] safe point is not advanced, skip GC",
+				zap.Uint64("safePoint", safePoint), zap.Uint64("lastSafePoint", lastSafePoint))
+			return nil
+		}
+		job = &Job{SafePoint: safePoint, Phase: PhaseResolveLocks}
+		if err := w.saveJob(job); err != nil {
+			return err
+		}
+		// Make the reads before the safe point fail by CheckVisibility.
+		if err := w.kv.Put(tikv.GcSavedSafePoint, strconv.FormatUint(safePoint, 10)); err != nil {
+			return err
+		}
+		logutil.BgLogger().Info("[gc worker] start GC", zap.Uint64("safePoint", safePoint))
+	} else {
+		logutil.BgLogger().Info("[gc worker] resume GC",
+			zap.Uint64("safePoint", job.SafePoint), zap.String("phase", string(job.Phase)))
+	}
+
+	if job.Phase == PhaseResolveLocks {
+		start := time.Now()
+		if _, err := w.store.GC(ctx, job.SafePoint, tikv.WithConcurrency(w.cfg.Concurrency)); err != nil {
+			return errors.WithMessage(err, "[gc worker] resolve locks")
+		}
+		logutil.BgLogger().Info("[gc worker] finish resolving locks",
+			zap.Uint64("safePoint", job.SafePoint), zap.Duration("cost", time.Since(start)))
+		job.Phase = PhaseDeleteRanges
+		if err := w.saveJob(job); err != nil {
+			return err
+		}
+	}
+
+	if job.Phase == PhaseDeleteRanges {
+		if err := w.deleteRanges(ctx, job.SafePoint); err != nil {
+			return err
+		}
+		job.Phase = PhaseDone
+		if err := w.saveJob(job); err != nil {
+			return err
+		}
+	}
+	logutil.BgLogger().Info("[gc worker] finish GC", zap.Uint64("safePoint", job.SafePoint))
+	return nil
+}
+
+// calcSafePoint chooses the safe point by the life time, the min start TS of
+// the active transactions and the min service safe point.
+func (w *GCWorker) calcSafePoint(ctx context.Context) (uint64, error) {
+	now, err := w.store.CurrentTimestamp(oracle.GlobalTxnScope)
+	if err != nil {
+		return 0, err
+	}
+	safePoint := oracle.GoTimeToTS(oracle.GetTimeFromTS(now).Add(-w.cfg.LifeTime))
+	if w.minStartTS != nil {
+		minStartTS, err := w.minStartTS(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if minStartTS != 0 && minStartTS < safePoint {
+			safePoint = minStartTS
+		}
+	}
+	// Set the service safe point of the GC worker to get the min service safe point.
+	minServiceSafePoint, err := w.store.GetPDClient().UpdateServiceGCSafePoint(ctx, ServiceID, math.MaxInt64, safePoint)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if minServiceSafePoint < safePoint {
+		safePoint = minServiceSafePoint
+	}
+	return safePoint, nil
+}
+
+// deleteRanges deletes the ranges whose TS <= safePoint. The state of each
+// range is saved after each step, so a failed range is retried by the next
+// round from the step it fails.
+func (w *GCWorker) deleteRanges(ctx context.Context, safePoint uint64) error {
+	ranges, err := w.DeleteRanges()
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, r := range ranges {
+		if r.TS > safePoint {
+			continue
+		}
+		if err := w.deleteRange(ctx, r); err != nil {
+			if ctx.Err() != nil {
+				return errors.WithStack(ctx.Err())
+			}
+			logutil.BgLogger().Warn("[gc worker] delete range failed",
+				zap.String("id", r.ID), zap.String("state", string(r.State)), zap.Error(err))
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Errorf("[gc worker] delete ranges finished with errors: %v", errs)
+	}
+	return nil
+}
+
+func (w *GCWorker) deleteRange(ctx context.Context, r *DeleteRange) error {
+	if r.State == DeleteRangePending {
+		if _, err := w.store.DeleteRange(ctx, r.StartKey, r.EndKey, w.cfg.Concurrency); err != nil {
+			return err
+		}
+		r.State = DeleteRangeDeleted
+		w.rangesMu.Lock()
+		err := w.saveDeleteRange(r)
+		w.rangesMu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	if err := w.store.UnsafeDestroyRange(ctx, r.StartKey, r.EndKey); err != nil {
+		return err
+	}
+	logutil.BgLogger().Info("[gc worker] finish deleting range",
+		zap.String("id", r.ID), zap.Uint64("ts", r.TS))
+	w.rangesMu.Lock()
+	defer w.rangesMu.Unlock()
+	return w.kv.Put(gcDeleteRangePrefix+r.ID, "")
+}
+
+func (w *GCWorker) saveDeleteRange(r *DeleteRange) error {
+	val, err := json.Marshal(r)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return w.kv.Put(gcDeleteRangePrefix+r.ID, string(val))
+}
+
+func (w *GCWorker) saveJob(job *Job) error {
+	val, err := json.Marshal(job)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return w.kv.Put(gcJobKey, string(val))
+}
@@ -0,0 +1,241 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcworker
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pingcap/failpoint"
+	"github.com/stretchr/testify/suite"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/testutils"
+	"github.com/tikv/client-go/v2/tikv"
+	"github.com/tikv/client-go/v2/util"
+	pd "github.com/tikv/pd/client"
+)
+
+func TestGCWorker(t *testing.T) {
+	util.EnableFailpoints()
+	suite.Run(t, new(testGCWorkerSuite))
+}
+
+type testGCWorkerSuite struct {
+	suite.Suite
+	store    *tikv.KVStore
+	pdClient pd.Client
+}
+
+func (s *testGCWorkerSuite) SetupTest() {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	s.Require().Nil(err)
+	testutils.BootstrapWithMultiRegions(cluster, []byte("b"), []byte("d"))
+	store, err := tikv.NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	s.Require().Nil(err)
+	s.store = store
+	s.pdClient = pdClient
+}
+
+func (s *testGCWorkerSuite) TearDownTest() {
+	s.Require().Nil(s.store.Close())
+}
+
+func (s *testGCWorkerSuite) currentTS() uint64 {
+	ts, err := s.store.CurrentTimestamp(oracle.GlobalTxnScope)
+	s.Require().Nil(err)
+	return ts
+}
+
+func (s *testGCWorkerSuite) mustPut(keys ...string) {
+	txn, err := s.store.Begin()
+	s.Require().Nil(err)
+	for _, k := range keys {
+		s.Require().Nil(txn.Set([]byte(k), []byte(k)))
+	}
+	s.Require().Nil(txn.Commit(context.Background()))
+}
+
+func (s *testGCWorkerSuite) mustExist(key string, exist bool) {
+	_, err := s.store.GetSnapshot(math.MaxUint64).Get(context.Background(), []byte(key))
+	if exist {
+		s.Nil(err, key)
+	} else {
+		s.True(tikverr.IsErrNotFound(err), key)
+	}
+}
+
+func (s *testGCWorkerSuite) mustJob(w *GCWorker, safePoint uint64, phase Phase) {
+	job, err := w.Job()
+	s.Require().Nil(err)
+	s.Require().NotNil(job)
+	s.Equal(safePoint, job.SafePoint)
+	s.Equal(phase, job.Phase)
+}
+
+func (s *testGCWorkerSuite) TestSafePoint() {
+	ctx := context.Background()
+	var minStartTS uint64
+	w := NewGCWorker(s.store, Config{LifeTime: time.Hour}, WithMinStartTSProvider(func(ctx context.Context) (uint64, error) {
+		return minStartTS, nil
+	}))
+
+	// The safe point is limited by the min start TS of the active transactions.
+	now := s.currentTS()
+	minStartTS = oracle.GoTimeToTS(oracle.GetTimeFromTS(now).Add(-2 * time.Hour))
+	s.Require().Nil(w.RunOnce(ctx))
+	s.mustJob(w, minStartTS, PhaseDone)
+	saved, err := s.store.GetSafePointKV().Get(tikv.GcSavedSafePoint)
+	s.Require().Nil(err)
+	s.Equal(strconv.FormatUint(minStartTS, 10), saved)
+	pdSafePoint, err := s.pdClient.UpdateGCSafePoint(ctx, 0)
+	s.Require().Nil(err)
+	s.Equal(minStartTS, pdSafePoint)
+
+	// The safe point is limited by the life time without active transactions.
+	minStartTS = 0
+	s.Require().Nil(w.RunOnce(ctx))
+	job, err := w.Job()
+	s.Require().Nil(err)
+	s.Equal(PhaseDone, job.Phase)
+	s.LessOrEqual(job.SafePoint, oracle.GoTimeToTS(oracle.GetTimeFromTS(s.currentTS()).Add(-time.Hour)))
+	s.GreaterOrEqual(job.SafePoint, oracle.GoTimeToTS(oracle.GetTimeFromTS(now).Add(-time.Hour)))
+	lastSafePoint := job.SafePoint
+
+	// GC is skipped if the safe point is not advanced.
+	minStartTS = oracle.GoTimeToTS(oracle.GetTimeFromTS(now).Add(-3 * time.Hour))
+	s.Require().Nil(w.RunOnce(ctx))
+	s.mustJob(w, lastSafePoint, PhaseDone)
+}
+
+func (s *testGCWorkerSuite) TestServiceSafePoint() {
+	ctx := context.Background()
+	serviceSafePoint := oracle.GoTimeToTS(oracle.GetTimeFromTS(s.currentTS()).Add(-time.Hour))
+	_, err := s.pdClient.UpdateServiceGCSafePoint(ctx, "br", math.MaxInt64, serviceSafePoint)
+	s.Require().Nil(err)
+
+	w := NewGCWorker(s.store, Config{LifeTime: time.Millisecond})
+	s.Require().Nil(w.RunOnce(ctx))
+	s.mustJob(w, serviceSafePoint, PhaseDone)
+}
+
+func (s *testGCWorkerSuite) TestResolveLocks() {
+	ctx := context.Background()
+	txn, err := tikv.StoreProbe{KVStore: s.store}.Begin()
+	s.Require().Nil(err)
+	s.Require().Nil(txn.Set([]byte("a"), []byte("a")))
+	committer, err := txn.NewCommitter(1)
+	s.Require().Nil(err)
+	s.Require().Nil(committer.PrewriteAllMutations(ctx))
+	locks, err := tikv.StoreProbe{KVStore: s.store}.ScanLocks(ctx, []byte("a"), []byte("z"), math.MaxUint64)
+	s.Require().Nil(err)
+	s.Len(locks, 1)
+
+	time.Sleep(10 * time.Millisecond)
+	w := NewGCWorker(s.store, Config{LifeTime: time.Millisecond})
+	s.Require().Nil(w.RunOnce(ctx))
+	locks, err = tikv.StoreProbe{KVStore: s.store}.ScanLocks(ctx, []byte("a"), []byte("z"), math.MaxUint64)
+	s.Require().Nil(err)
+	s.Len(locks, 0)
+}
+
+func (s *testGCWorkerSuite) TestDeleteRanges() {
+	ctx := context.Background()
+	s.mustPut("a", "b", "c", "d", "e")
+	w := NewGCWorker(s.store, Config{LifeTime: time.Millisecond})
+
+	ts := s.currentTS()
+	r, err := w.AddDeleteRange([]byte("b"), []byte("d"), ts)
+	s.Require().Nil(err)
+	s.Equal(DeleteRangePending, r.State)
+	_, err = w.AddDeleteRange([]byte("d"), []byte("e"), math.MaxUint64)
+	s.Require().Nil(err)
+	_, err = w.AddDeleteRange([]byte("e"), []byte("a"), ts)
+	s.Require().NotNil(err)
+	// Adding the same range again is a no-op.
+	_, err = w.AddDeleteRange([]byte("b"), []byte("d"), ts)
+	s.Require().Nil(err)
+	ranges, err := w.DeleteRanges()
+	s.Require().Nil(err)
+	s.Len(ranges, 2)
+
+	time.Sleep(10 * time.Millisecond)
+	s.Require().Nil(w.RunOnce(ctx))
+	for _, k := range []string{"a", "d", "e"} {
+		s.mustExist(k, true)
+	}
+	for _, k := range []string{"b", "c"} {
+		s.mustExist(k, false)
+	}
+	// The range whose TS is not passed by the safe point is kept.
+	ranges, err = w.DeleteRanges()
+	s.Require().Nil(err)
+	s.Len(ranges, 1)
+	s.Equal([]byte("d"), ranges[0].StartKey)
+	s.Equal(DeleteRangePending, ranges[0].State)
+}
+
+func (s *testGCWorkerSuite) TestResumeDeleteRanges() {
+	ctx := context.Background()
+	s.mustPut("a", "b", "c")
+	w := NewGCWorker(s.store, Config{LifeTime: time.Millisecond})
+	_, err := w.AddDeleteRange([]byte("b"), []byte("c"), s.currentTS())
+	s.Require().Nil(err)
+	time.Sleep(10 * time.Millisecond)
+
+	// The round stops at the delete ranges phase if UnsafeDestroyRange fails.
+	s.Require().Nil(failpoint.Enable("tikvclient/rpcUnsafeDestroyRangeError", `return("injected error")`))
+	s.Require().NotNil(w.RunOnce(ctx))
+	s.Require().Nil(failpoint.Disable("tikvclient/rpcUnsafeDestroyRangeError"))
+	job, err := w.Job()
+	s.Require().Nil(err)
+	s.Equal(PhaseDeleteRanges, job.Phase)
+	ranges, err := w.DeleteRanges()
+	s.Require().Nil(err)
+	s.Len(ranges, 1)
+	s.Equal(DeleteRangeDeleted, ranges[0].State)
+	s.mustExist("b", false)
+
+	// The next round resumes the last one with the same safe point.
+	time.Sleep(10 * time.Millisecond)
+	s.Require().Nil(w.RunOnce(ctx))
+	s.mustJob(w, job.SafePoint, PhaseDone)
+	ranges, err = w.DeleteRanges()
+	s.Require().Nil(err)
+	s.Len(ranges, 0)
+	s.mustExist("a", true)
+	s.mustExist("c", true)
+
+	// A new worker continues the progress recorded in SafePointKV.
+	time.Sleep(10 * time.Millisecond)
+	w = NewGCWorker(s.store, Config{LifeTime: time.Millisecond})
+	s.Require().Nil(w.RunOnce(ctx))
+	newJob, err := w.Job()
+	s.Require().Nil(err)
+	s.Greater(newJob.SafePoint, job.SafePoint)
+}
+
+func (s *testGCWorkerSuite) TestStart() {
+	w := NewGCWorker(s.store, Config{RunInterval: 10 * time.Millisecond, LifeTime: time.Millisecond})
+	w.Start()
+	defer w.Close()
+	s.Eventually(func() bool {
+		job, err := w.Job()
+		return err == nil && job != nil && job.Phase == PhaseDone
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -575,6 +575,12 @@ func (s *KVStore) GetRegionCache() *locate.RegionCache {
 	return s.regionCache
 }
 
+// SetLocalityPolicy sets the policy to route the follower reads and stale reads
+// to the replicas in the local zone, nil disables it.
+func (s *KVStore) SetLocalityPolicy(policy *locate.LocalityPolicy) {
+	s.regionCache.SetLocalityPolicy(policy)
+}
+
 // GetLockResolver returns the lock resolver instance.
 func (s *KVStore) GetLockResolver() *txnlock.LockResolver {
 	return s.lockResolver
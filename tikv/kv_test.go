@@ -22,9 +22,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pingcap/failpoint"
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/stretchr/testify/suite"
+	tikverr "github.com/tikv/client-go/v2/error"
 	"github.com/tikv/client-go/v2/internal/mockstore/mocktikv"
 	"github.com/tikv/client-go/v2/oracle"
 	"github.com/tikv/client-go/v2/testutils"
@@ -273,3 +275,112 @@ func (s *testKVSuite) TestMinSafeTsFromMixed2() {
 	s.Require().Equal(mockClient.tikvSafeTs, s.store.GetMinSafeTS("z1"))
 	s.Require().Equal(uint64(10), s.store.GetMinSafeTS("z2"))
 }
+
+func (s *testKVSuite) TestUnsafeDestroyRange() {
+	txn, err := s.store.Begin()
+	s.Require().Nil(err)
+	s.Require().Nil(txn.Set([]byte("a"), []byte("a")))
+	s.Require().Nil(txn.Set([]byte("c"), []byte("c")))
+	s.Require().Nil(txn.Commit(context.Background()))
+
+	s.Require().Nil(failpoint.Enable("tikvclient/rpcUnsafeDestroyRangeError", `return("injected")`))
+	err = s.store.UnsafeDestroyRange(context.Background(), []byte("b"), []byte("d"))
+	s.Require().Nil(failpoint.Disable("tikvclient/rpcUnsafeDestroyRangeError"))
+	s.Require().NotNil(err)
+	s.Contains(err.Error(), "injected")
+
+	s.Require().Nil(s.store.UnsafeDestroyRange(context.Background(), []byte("b"), []byte("d")))
+	ts, err := s.store.CurrentTimestamp(oracle.GlobalTxnScope)
+	s.Require().Nil(err)
+	snapshot := s.store.GetSnapshot(ts)
+	val, err := snapshot.Get(context.Background(), []byte("a"))
+	s.Require().Nil(err)
+	s.Equal([]byte("a"), val)
+	_, err = snapshot.Get(context.Background(), []byte("c"))
+	s.True(tikverr.IsErrNotFound(err))
+}
+
+func (s *testKVSuite) TestServiceSafePointKeeper() {
+	ctx := context.Background()
+	now, err := s.store.CurrentTimestamp(oracle.GlobalTxnScope)
+	s.Require().Nil(err)
+	tsBefore := func(d time.Duration) uint64 {
+		return oracle.GoTimeToTS(oracle.GetTimeFromTS(now).Add(-d))
+	}
+
+	br, err := s.store.NewServiceSafePointKeeper(ctx, "br", tsBefore(time.Hour), WithServiceSafePointTTL(time.Second))
+	s.Require().Nil(err)
+	s.Equal(tsBefore(time.Hour), br.MinServiceSafePoint())
+	s.GreaterOrEqual(br.GCBlockedDuration(), time.Hour)
+
+	// The safe point already passed by GC is rejected.
+	_, err = s.store.NewServiceSafePointKeeper(ctx, "cdc", tsBefore(2*time.Hour))
+	var gcTooEarly *tikverr.ErrGCTooEarly
+	s.ErrorAs(err, &gcTooEarly)
+
+	cdc, err := s.store.NewServiceSafePointKeeper(ctx, "cdc", tsBefore(30*time.Minute), WithServiceSafePointTTL(time.Second))
+	s.Require().Nil(err)
+	s.Equal(tsBefore(time.Hour), cdc.MinServiceSafePoint())
+
+	// The min service safe point is refreshed by the renewal after br is released.
+	s.Require().Nil(br.Close())
+	s.Require().Nil(br.Close())
+	s.Eventually(func() bool {
+		return cdc.MinServiceSafePoint() == tsBefore(30*time.Minute)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	s.Require().Nil(cdc.Advance(ctx, tsBefore(time.Minute)))
+	s.Equal(tsBefore(time.Minute), cdc.SafePoint())
+	s.Equal(tsBefore(time.Minute), cdc.MinServiceSafePoint())
+	s.Require().Nil(cdc.Advance(ctx, tsBefore(time.Hour)))
+	s.Equal(tsBefore(time.Minute), cdc.SafePoint())
+
+	// The safe point isn't advanced if PD fails to set it.
+	s.Require().Nil(failpoint.Enable("tikvclient/updateServiceSafePointError", "return"))
+	s.NotNil(cdc.Advance(ctx, now))
+	s.Require().Nil(failpoint.Disable("tikvclient/updateServiceSafePointError"))
+	s.Equal(tsBefore(time.Minute), cdc.SafePoint())
+
+	s.Require().Nil(cdc.Close())
+	s.NotNil(cdc.Advance(ctx, now))
+	<-cdc.Done()
+	s.Nil(cdc.Err())
+
+	// The renewal stops and reports the failure once GC passes the service safe
+	// point, e.g. it expires and another service sets a later one.
+	lost, err := s.store.NewServiceSafePointKeeper(ctx, "lost", tsBefore(time.Minute), WithServiceSafePointTTL(time.Second))
+	s.Require().Nil(err)
+	_, err = s.store.pdClient.UpdateServiceGCSafePoint(ctx, "lost", 0, 0)
+	s.Require().Nil(err)
+	_, err = s.store.pdClient.UpdateServiceGCSafePoint(ctx, "probe", 10, now)
+	s.Require().Nil(err)
+	select {
+	case <-lost.Done():
+	case <-time.After(5 * time.Second):
+		s.FailNow("the renewal of the lost service safe point doesn't stop")
+	}
+	s.ErrorAs(lost.Err(), &gcTooEarly)
+	s.ErrorAs(lost.Advance(ctx, now), &gcTooEarly)
+	s.Require().Nil(lost.Close())
+	_, err = s.store.pdClient.UpdateServiceGCSafePoint(ctx, "probe", 0, 0)
+	s.Require().Nil(err)
+
+	// Advance racing with Close never registers the service safe point again.
+	for i := 0; i < 20; i++ {
+		job, err := s.store.NewServiceSafePointKeeper(ctx, "job", tsBefore(time.Minute))
+		s.Require().Nil(err)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = job.Advance(ctx, now)
+		}()
+		s.Require().Nil(job.Close())
+		<-done
+		// The min service safe point is the probe itself if job is released.
+		minServiceSafePoint, err := s.store.pdClient.UpdateServiceGCSafePoint(ctx, "probe", 10, now+1)
+		s.Require().Nil(err)
+		s.Equal(now+1, minServiceSafePoint)
+		_, err = s.store.pdClient.UpdateServiceGCSafePoint(ctx, "probe", 0, 0)
+		s.Require().Nil(err)
+	}
+}
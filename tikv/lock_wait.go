@@ -0,0 +1,363 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"encoding/hex"
+	"sort"
+	"sync"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/client"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/util"
+	"go.uber.org/zap"
+)
+
+// LockWaitEntry is a pessimistic lock wait reported by a store: transaction
+// Txn is waiting for the lock of transaction WaitForTxn on the key.
+type LockWaitEntry struct {
+	StoreID          uint64 `json:"store_id"`
+	Txn              uint64 `json:"txn"`
+	WaitForTxn       uint64 `json:"wait_for_txn"`
+	KeyHash          uint64 `json:"key_hash"`
+	Key              []byte `json:"key,omitempty"`
+	ResourceGroupTag []byte `json:"resource_group_tag,omitempty"`
+	// WaitTimeMs is how long the transaction has been waiting.
+	WaitTimeMs uint64 `json:"wait_time_ms"`
+}
+
+// LockWaits is the cluster-wide pessimistic lock waits returned by
+// KVStore.GetLockWaits.
+type LockWaits struct {
+	Entries []LockWaitEntry `json:"entries"`
+	// FailedStores records the errors of the stores failed to report their lock
+	// waits, the waits on these stores are missing.
+	FailedStores map[uint64]string `json:"failed_stores,omitempty"`
+}
+
+// LockWaitSummary summarizes the wait-for graph of LockWaits.
+type LockWaitSummary struct {
+	WaitingTxns int `json:"waiting_txns"`
+	Waits       int `json:"waits"`
+	// Cycles are the wait-for cycles in the graph, each of them is a deadlock
+	// which is not resolved yet. A cycle starts with its smallest transaction.
+	Cycles [][]uint64 `json:"cycles"`
+	// HotKeys are the keys waited by the most transactions.
+	HotKeys      []HotLockWaitKey  `json:"hot_keys"`
+	FailedStores map[uint64]string `json:"failed_stores,omitempty"`
+}
+
+// HotLockWaitKey is a key waited by multiple transactions.
+type HotLockWaitKey struct {
+	// Key is hex encoded, it's empty if the stores do not report it.
+	Key     string `json:"key,omitempty"`
+	KeyHash uint64 `json:"key_hash"`
+	// Holders are the transactions being waited for on the key.
+	Holders       []uint64 `json:"holders"`
+	Waiters       int      `json:"waiters"`
+	MaxWaitTimeMs uint64   `json:"max_wait_time_ms"`
+}
+
+// GetLockWaits collects the pessimistic lock waits from all stores. The stores
+// failed to respond are recorded in LockWaits.FailedStores, an error is
+// returned only if no store responds.
+func (s *KVStore) GetLockWaits(ctx context.Context) (*LockWaits, error) {
+	stores := s.regionCache.GetAllStores()
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		lastErr  error
+		resps    = make(map[uint64][]LockWaitEntry, len(stores))
+		failures = make(map[uint64]string)
+	)
+	for _, store := range stores {
+		// Lock waits only happen on TiKV.
+		if store.IsTiFlash() {
+			continue
+		}
+		wg.Add(1)
+		go func(storeID uint64, storeAddr string) {
+			defer wg.Done()
+			entries, err := s.getStoreLockWaits(ctx, storeID, storeAddr)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				logutil.Logger(ctx).Warn("get lock wait info failed",
+					zap.Uint64("store-id", storeID), zap.String("store-addr", storeAddr), zap.Error(err))
+				failures[storeID] = err.Error()
+				lastErr = err
+				return
+			}
+			resps[storeID] = entries
+		}(store.StoreID(), store.GetAddr())
+	}
+	wg.Wait()
+
+	if len(resps) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	waits := &LockWaits{}
+	if len(failures) > 0 {
+		waits.FailedStores = failures
+	}
+	type waitKey struct {
+		txn, waitForTxn, keyHash uint64
+	}
+	seen := make(map[waitKey]int)
+	for _, entries := range resps {
+		for _, entry := range entries {
+			k := waitKey{entry.Txn, entry.WaitForTxn, entry.KeyHash}
+			if i, ok := seen[k]; ok {
+				// The same wait may be reported by multiple stores, e.g. during
+				// leader transfer. Keep the longest one.
+				if entry.WaitTimeMs > waits.Entries[i].WaitTimeMs {
+					waits.Entries[i] = entry
+				}
+				continue
+			}
+			seen[k] = len(waits.Entries)
+			waits.Entries = append(waits.Entries, entry)
+		}
+	}
+	sort.Slice(waits.Entries, func(i, j int) bool {
+		a, b := waits.Entries[i], waits.Entries[j]
+		if a.Txn != b.Txn {
+			return a.Txn < b.Txn
+		}
+		if a.WaitForTxn != b.WaitForTxn {
+			return a.WaitForTxn < b.WaitForTxn
+		}
+		return a.KeyHash < b.KeyHash
+	})
+	return waits, nil
+}
+
+func (s *KVStore) getStoreLockWaits(ctx context.Context, storeID uint64, storeAddr string) ([]LockWaitEntry, error) {
+	req := tikvrpc.NewRequest(tikvrpc.CmdLockWaitInfo, &kvrpcpb.GetLockWaitInfoRequest{}, kvrpcpb.Context{
+		RequestSource: util.RequestSourceFromCtx(ctx),
+	})
+	resp, err := s.GetTiKVClient().SendRequest(ctx, storeAddr, req, client.ReadTimeoutShort)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Resp == nil {
+		return nil, errors.WithStack(tikverr.ErrBodyMissing)
+	}
+	waitResp := resp.Resp.(*kvrpcpb.GetLockWaitInfoResponse)
+	if regionErr := waitResp.GetRegionError(); regionErr != nil {
+		return nil, errors.New(regionErr.String())
+	}
+	if waitResp.GetError() != "" {
+		return nil, errors.Errorf("unexpected lock wait info err: %s", waitResp.GetError())
+	}
+	entries := make([]LockWaitEntry, 0, len(waitResp.GetEntries()))
+	for _, entry := range waitResp.GetEntries() {
+		entries = append(entries, LockWaitEntry{
+			StoreID:          storeID,
+			Txn:              entry.GetTxn(),
+			WaitForTxn:       entry.GetWaitForTxn(),
+			KeyHash:          entry.GetKeyHash(),
+			Key:              entry.GetKey(),
+			ResourceGroupTag: entry.GetResourceGroupTag(),
+			WaitTimeMs:       entry.GetWaitTime(),
+		})
+	}
+	return entries, nil
+}
+
+// WaitForGraph returns the wait-for graph, which maps each waiting transaction
+// to the sorted transactions it is waiting for.
+func (w *LockWaits) WaitForGraph() map[uint64][]uint64 {
+	graph := make(map[uint64][]uint64)
+	for _, entry := range w.Entries {
+		graph[entry.Txn] = append(graph[entry.Txn], entry.WaitForTxn)
+	}
+	for txn, waitFor := range graph {
+		graph[txn] = sortAndDedupTxns(waitFor)
+	}
+	return graph
+}
+
+// Summary summarizes the wait-for cycles and the top `topN` hot keys. All hot
+// keys are returned if topN is not positive.
+func (w *LockWaits) Summary(topN int) *LockWaitSummary {
+	graph := w.WaitForGraph()
+	summary := &LockWaitSummary{
+		WaitingTxns:  len(graph),
+		Waits:        len(w.Entries),
+		Cycles:       findWaitForCycles(graph),
+		HotKeys:      []HotLockWaitKey{},
+		FailedStores: w.FailedStores,
+	}
+
+	type hotKey struct {
+		HotLockWaitKey
+		waiters map[uint64]struct{}
+	}
+	keys := make(map[uint64]*hotKey)
+	for _, entry := range w.Entries {
+		k, ok := keys[entry.KeyHash]
+		if !ok {
+			k = &hotKey{
+				HotLockWaitKey: HotLockWaitKey{KeyHash: entry.KeyHash},
+				waiters:        make(map[uint64]struct{}),
+			}
+			keys[entry.KeyHash] = k
+		}
+		if len(k.Key) == 0 && len(entry.Key) > 0 {
+			k.Key = hex.EncodeToString(entry.Key)
+		}
+		k.Holders = append(k.Holders, entry.WaitForTxn)
+		k.waiters[entry.Txn] = struct{}{}
+		if entry.WaitTimeMs > k.MaxWaitTimeMs {
+			k.MaxWaitTimeMs = entry.WaitTimeMs
+		}
+	}
+	for _, k := range keys {
+		k.Holders = sortAndDedupTxns(k.Holders)
+		k.Waiters = len(k.waiters)
+		summary.HotKeys = append(summary.HotKeys, k.HotLockWaitKey)
+	}
+	sort.Slice(summary.HotKeys, func(i, j int) bool {
+		a, b := summary.HotKeys[i], summary.HotKeys[j]
+		if a.Waiters != b.Waiters {
+			return a.Waiters > b.Waiters
+		}
+		if a.MaxWaitTimeMs != b.MaxWaitTimeMs {
+			return a.MaxWaitTimeMs > b.MaxWaitTimeMs
+		}
+		return a.KeyHash < b.KeyHash
+	})
+	if topN > 0 && len(summary.HotKeys) > topN {
+		summary.HotKeys = summary.HotKeys[:topN]
+	}
+	return summary
+}
+
+func sortAndDedupTxns(txns []uint64) []uint64 {
+	sort.Slice(txns, func(i, j int) bool { return txns[i] < txns[j] })
+	n := 0
+	for i, txn := range txns {
+		if i == 0 || txn != txns[n-1] {
+			txns[n] = txn
+			n++
+		}
+	}
+	return txns[:n]
+}
+
+// findWaitForCycles finds a cycle in each strongly connected component of the
+// graph which contains one.
+func findWaitForCycles(graph map[uint64][]uint64) [][]uint64 {
+	txns := make([]uint64, 0, len(graph))
+	for txn := range graph {
+		txns = append(txns, txn)
+	}
+	sortAndDedupTxns(txns)
+
+	// Tarjan's algorithm.
+	var (
+		index    = make(map[uint64]int)
+		lowLink  = make(map[uint64]int)
+		onStack  = make(map[uint64]bool)
+		stack    []uint64
+		sccs     [][]uint64
+		strongly func(uint64)
+	)
+	strongly = func(v uint64) {
+		index[v] = len(index)
+		lowLink[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range graph[v] {
+			if _, ok := index[w]; !ok {
+				strongly(w)
+				lowLink[v] = min(lowLink[v], lowLink[w])
+			} else if onStack[w] {
+				lowLink[v] = min(lowLink[v], index[w])
+			}
+		}
+		if lowLink[v] == index[v] {
+			var scc []uint64
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				scc = append(scc, w)
+				if w == v {
+					break
+				}
+			}
+			sccs = append(sccs, scc)
+		}
+	}
+	for _, txn := range txns {
+		if _, ok := index[txn]; !ok {
+			strongly(txn)
+		}
+	}
+
+	var cycles [][]uint64
+	for _, scc := range sccs {
+		members := make(map[uint64]struct{}, len(scc))
+		for _, txn := range scc {
+			members[txn] = struct{}{}
+		}
+		start := sortAndDedupTxns(scc)[0]
+		if cycle := shortestWaitForCycle(graph, members, start); cycle != nil {
+			cycles = append(cycles, cycle)
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+// shortestWaitForCycle finds the shortest cycle from start within members.
+func shortestWaitForCycle(graph map[uint64][]uint64, members map[uint64]struct{}, start uint64) []uint64 {
+	parent := map[uint64]uint64{}
+	queue := []uint64{start}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, w := range graph[v] {
+			if _, ok := members[w]; !ok {
+				continue
+			}
+			if w == start {
+				cycle := []uint64{v}
+				for v != start {
+					v = parent[v]
+					cycle = append(cycle, v)
+				}
+				// Reverse the path to start from `start`.
+				for i, j := 0, len(cycle)-1; i < j; i, j = i+1, j-1 {
+					cycle[i], cycle[j] = cycle[j], cycle[i]
+				}
+				return cycle
+			}
+			if _, ok := parent[w]; ok {
+				continue
+			}
+			parent[w] = v
+			queue = append(queue, w)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dgryski/go-farm"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/internal/mockstore/mocktikv"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/testutils"
+)
+
+func TestGetLockWaits(t *testing.T) {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	require.Nil(t, err)
+	mocktikv.BootstrapWithMultiStores(cluster, 2)
+	store, err := NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	require.Nil(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	waits, err := store.GetLockWaits(ctx)
+	require.Nil(t, err)
+	require.Empty(t, waits.Entries)
+
+	txn1, err := store.Begin()
+	require.Nil(t, err)
+	txn1.SetPessimistic(true)
+	require.Nil(t, txn1.LockKeysWithWaitTime(ctx, kv.LockAlwaysWait, []byte("k1")))
+	defer txn1.Rollback()
+
+	txn2, err := store.Begin()
+	require.Nil(t, err)
+	txn2.SetPessimistic(true)
+	require.NotNil(t, txn2.LockKeysWithWaitTime(ctx, kv.LockNoWait, []byte("k1")))
+	defer txn2.Rollback()
+
+	waits, err = store.GetLockWaits(ctx)
+	require.Nil(t, err)
+	require.Len(t, waits.Entries, 1)
+	entry := waits.Entries[0]
+	require.Equal(t, txn2.StartTS(), entry.Txn)
+	require.Equal(t, txn1.StartTS(), entry.WaitForTxn)
+	require.Equal(t, farm.Fingerprint64([]byte("k1")), entry.KeyHash)
+	require.Equal(t, map[uint64][]uint64{txn2.StartTS(): {txn1.StartTS()}}, waits.WaitForGraph())
+
+	summary := waits.Summary(0)
+	require.Empty(t, summary.Cycles)
+	require.Len(t, summary.HotKeys, 1)
+	require.Equal(t, 1, summary.HotKeys[0].Waiters)
+	require.Equal(t, []uint64{txn1.StartTS()}, summary.HotKeys[0].Holders)
+}
+
+func TestLockWaitSummary(t *testing.T) {
+	waits := &LockWaits{
+		Entries: []LockWaitEntry{
+			// Cycle 1 -> 2 -> 3 -> 1.
+			{Txn: 1, WaitForTxn: 2, KeyHash: 100, Key: []byte("a"), WaitTimeMs: 10},
+			{Txn: 2, WaitForTxn: 3, KeyHash: 200, WaitTimeMs: 20},
+			{Txn: 3, WaitForTxn: 1, KeyHash: 300, WaitTimeMs: 30},
+			// 4, 5 and 6 are waiting for 7 on the same key.
+			{Txn: 4, WaitForTxn: 7, KeyHash: 400, WaitTimeMs: 40},
+			{Txn: 5, WaitForTxn: 7, KeyHash: 400, WaitTimeMs: 50},
+			{Txn: 6, WaitForTxn: 7, KeyHash: 400, WaitTimeMs: 5},
+			// Cycle 8 <-> 9, and 9 also waits for 1.
+			{Txn: 8, WaitForTxn: 9, KeyHash: 500},
+			{Txn: 9, WaitForTxn: 8, KeyHash: 600},
+			{Txn: 9, WaitForTxn: 1, KeyHash: 100},
+		},
+		FailedStores: map[uint64]string{3: "unavailable"},
+	}
+	graph := waits.WaitForGraph()
+	require.Equal(t, []uint64{1, 8}, graph[9])
+	require.Len(t, graph, 8)
+
+	summary := waits.Summary(2)
+	require.Equal(t, 8, summary.WaitingTxns)
+	require.Equal(t, 9, summary.Waits)
+	require.Equal(t, [][]uint64{{1, 2, 3}, {8, 9}}, summary.Cycles)
+	require.Len(t, summary.HotKeys, 2)
+	require.Equal(t, HotLockWaitKey{KeyHash: 400, Holders: []uint64{7}, Waiters: 3, MaxWaitTimeMs: 50}, summary.HotKeys[0])
+	require.Equal(t, HotLockWaitKey{Key: "61", KeyHash: 100, Holders: []uint64{1, 2}, Waiters: 2, MaxWaitTimeMs: 10}, summary.HotKeys[1])
+
+	data, err := json.Marshal(summary)
+	require.Nil(t, err)
+	var decoded LockWaitSummary
+	require.Nil(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, *summary, decoded)
+	require.Contains(t, string(data), `"cycles":[[1,2,3],[8,9]]`)
+}
@@ -0,0 +1,137 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/stretchr/testify/suite"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/mockstore/mocktikv"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/testutils"
+	"github.com/tikv/client-go/v2/tikvrpc"
+)
+
+func TestPipelinedMockTiKV(t *testing.T) {
+	suite.Run(t, new(testPipelinedMockTiKVSuite))
+}
+
+type testPipelinedMockTiKVSuite struct {
+	suite.Suite
+	store *KVStore
+}
+
+func (s *testPipelinedMockTiKVSuite) SetupTest() {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	s.Require().Nil(err)
+	_, _, regionID := mocktikv.BootstrapWithSingleStore(cluster)
+	peerID := cluster.AllocID()
+	cluster.Split(regionID, cluster.AllocID(), []byte("k2"), []uint64{peerID}, peerID)
+
+	s.store, err = NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	s.Require().Nil(err)
+}
+
+func (s *testPipelinedMockTiKVSuite) TearDownTest() {
+	s.Require().Nil(s.store.Close())
+}
+
+func (s *testPipelinedMockTiKVSuite) scanLocks() []*kvrpcpb.LockInfo {
+	ts, err := s.store.CurrentTimestamp(oracle.GlobalTxnScope)
+	s.Require().Nil(err)
+	var locks []*kvrpcpb.LockInfo
+	for _, key := range []string{"k1", "k2"} {
+		bo := NewBackofferWithVars(context.Background(), 2000, nil)
+		loc, err := s.store.GetRegionCache().LocateKey(bo, []byte(key))
+		s.Require().Nil(err)
+		req := tikvrpc.NewRequest(tikvrpc.CmdScanLock, &kvrpcpb.ScanLockRequest{
+			MaxVersion: ts,
+			StartKey:   loc.StartKey,
+			EndKey:     loc.EndKey,
+		})
+		resp, err := s.store.SendReq(bo, req, loc.Region, ReadTimeoutShort)
+		s.Require().Nil(err)
+		locks = append(locks, resp.Resp.(*kvrpcpb.ScanLockResponse).Locks...)
+	}
+	return locks
+}
+
+func (s *testPipelinedMockTiKVSuite) TestCommit() {
+	ctx := context.Background()
+	txn, err := s.store.Begin(WithDefaultPipelinedTxn())
+	s.Require().Nil(err)
+	s.Require().True(txn.IsPipelined())
+
+	s.Require().Nil(txn.Set([]byte("k1"), []byte("v1")))
+	s.Require().Nil(txn.Set([]byte("k3"), []byte("v3")))
+	flushed, err := txn.GetMemBuffer().Flush(true)
+	s.Require().Nil(err)
+	s.Require().True(flushed)
+	// Overwrite the flushed keys with the next generation.
+	s.Require().Nil(txn.Set([]byte("k1"), []byte("v11")))
+	s.Require().Nil(txn.Delete([]byte("k3")))
+	flushed, err = txn.GetMemBuffer().Flush(true)
+	s.Require().Nil(err)
+	s.Require().True(flushed)
+	s.Require().Nil(txn.GetMemBuffer().FlushWait())
+
+	// The flushed data is read from the buffer.
+	val, err := txn.Get(ctx, []byte("k1"))
+	s.Require().Nil(err)
+	s.Equal([]byte("v11"), val)
+	_, err = txn.Get(ctx, []byte("k3"))
+	s.True(tikverr.IsErrNotFound(err))
+	s.Len(s.scanLocks(), 2)
+
+	s.Require().Nil(txn.Commit(ctx))
+	s.Eventually(func() bool { return len(s.scanLocks()) == 0 }, 5*time.Second, 50*time.Millisecond)
+
+	txn1, err := s.store.Begin()
+	s.Require().Nil(err)
+	val, err = txn1.Get(ctx, []byte("k1"))
+	s.Require().Nil(err)
+	s.Equal([]byte("v11"), val)
+	_, err = txn1.Get(ctx, []byte("k3"))
+	s.True(tikverr.IsErrNotFound(err))
+	s.Require().Nil(txn1.Rollback())
+}
+
+func (s *testPipelinedMockTiKVSuite) TestRollback() {
+	ctx := context.Background()
+	txn, err := s.store.Begin(WithDefaultPipelinedTxn())
+	s.Require().Nil(err)
+	s.Require().Nil(txn.Set([]byte("k1"), []byte("v1")))
+	s.Require().Nil(txn.Set([]byte("k3"), []byte("v3")))
+	flushed, err := txn.GetMemBuffer().Flush(true)
+	s.Require().Nil(err)
+	s.Require().True(flushed)
+	s.Require().Nil(txn.GetMemBuffer().FlushWait())
+	s.Len(s.scanLocks(), 2)
+
+	s.Require().Nil(txn.Rollback())
+	s.Eventually(func() bool { return len(s.scanLocks()) == 0 }, 5*time.Second, 50*time.Millisecond)
+
+	txn1, err := s.store.Begin()
+	s.Require().Nil(err)
+	_, err = txn1.Get(ctx, []byte("k1"))
+	s.True(tikverr.IsErrNotFound(err))
+	_, err = txn1.Get(ctx, []byte("k3"))
+	s.True(tikverr.IsErrNotFound(err))
+	s.Require().Nil(txn1.Rollback())
+}
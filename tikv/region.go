@@ -89,6 +89,43 @@ type StoreSelectorOption = locate.StoreSelectorOption
 // RegionRequestRuntimeStats records the runtime stats of send region requests.
 type RegionRequestRuntimeStats = locate.RegionRequestRuntimeStats
 
+// ReplicaSelectionStrategy decides the replica to send a read request to.
+type ReplicaSelectionStrategy = locate.ReplicaSelectionStrategy
+
+// ReplicaSelectionStrategyFunc is a function that implements ReplicaSelectionStrategy.
+type ReplicaSelectionStrategyFunc = locate.ReplicaSelectionStrategyFunc
+
+// ReplicaCandidate describes a replica which a read request can be sent to.
+type ReplicaCandidate = locate.ReplicaCandidate
+
+// HedgePolicy controls the hedged reads of RegionRequestSender.
+type HedgePolicy = locate.HedgePolicy
+
+// RegionEvent is a change of the region cache observed by the client.
+type RegionEvent = locate.RegionEvent
+
+// RegionEventType is the type of the RegionEvent.
+type RegionEventType = locate.RegionEventType
+
+// RegionEventSubscription receives the events of the region cache.
+type RegionEventSubscription = locate.RegionEventSubscription
+
+const (
+	// RegionSplit indicates a cached region is replaced by a region split from it.
+	RegionSplit = locate.RegionSplit
+	// RegionMerged indicates cached regions are replaced by a region merged from them.
+	RegionMerged = locate.RegionMerged
+	// LeaderChanged indicates the leader of a cached region is switched to another store.
+	LeaderChanged = locate.LeaderChanged
+	// RegionInvalidated indicates a cached region is invalidated.
+	RegionInvalidated = locate.RegionInvalidated
+	// StoreStateChanged indicates the resolve state or the liveness of a store is changed.
+	StoreStateChanged = locate.StoreStateChanged
+)
+
+// LocalityPolicy routes the follower reads and stale reads to the replicas in the local zone.
+type LocalityPolicy = locate.LocalityPolicy
+
 // RPCRuntimeStats indicates the RPC request count and consume time.
 type RPCRuntimeStats = locate.RPCRuntimeStats
 
@@ -187,6 +224,11 @@ func WithMatchStores(stores []uint64) StoreSelectorOption {
 	return locate.WithMatchStores(stores)
 }
 
+// WithReplicaSelectionStrategy indicates selecting the replica of read requests by the strategy.
+func WithReplicaSelectionStrategy(strategy ReplicaSelectionStrategy) StoreSelectorOption {
+	return locate.WithReplicaSelectionStrategy(strategy)
+}
+
 // NewRegionRequestRuntimeStats returns a new RegionRequestRuntimeStats.
 func NewRegionRequestRuntimeStats() *RegionRequestRuntimeStats {
 	return locate.NewRegionRequestRuntimeStats()
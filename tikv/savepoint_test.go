@@ -0,0 +1,235 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/mockstore/mocktikv"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/testutils"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+)
+
+func TestSavepoint(t *testing.T) {
+	suite.Run(t, new(testSavepointSuite))
+}
+
+type testSavepointSuite struct {
+	suite.Suite
+	store *KVStore
+}
+
+func (s *testSavepointSuite) SetupTest() {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	s.Require().Nil(err)
+	mocktikv.BootstrapWithSingleStore(cluster)
+	s.store, err = NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	s.Require().Nil(err)
+}
+
+func (s *testSavepointSuite) TearDownTest() {
+	s.Require().Nil(s.store.Close())
+}
+
+func (s *testSavepointSuite) mustGet(txn *KVTxn, key string, expected string) {
+	val, err := txn.Get(context.Background(), []byte(key))
+	if expected == "" {
+		s.True(tikverr.IsErrNotFound(err), key)
+		return
+	}
+	s.Require().Nil(err, key)
+	s.Equal(expected, string(val), key)
+}
+
+func (s *testSavepointSuite) lockKeys(txn *KVTxn, keys ...string) {
+	lockCtx := kv.NewLockCtx(txn.StartTS(), kv.LockAlwaysWait, time.Now())
+	for _, key := range keys {
+		s.Require().Nil(txn.LockKeys(context.Background(), lockCtx, []byte(key)))
+	}
+}
+
+func (s *testSavepointSuite) lockedKeys() []string {
+	locks, err := StoreProbe{KVStore: s.store}.ScanLocks(context.Background(), []byte("a"), []byte("z"), math.MaxUint64)
+	s.Require().Nil(err)
+	keys := make([]string, 0, len(locks))
+	for _, l := range locks {
+		keys = append(keys, string(l.Key))
+	}
+	return keys
+}
+
+func (s *testSavepointSuite) TestRollbackToSavepoint() {
+	txn, err := s.store.Begin()
+	s.Require().Nil(err)
+	s.Require().Nil(txn.Set([]byte("a"), []byte("a1")))
+	s.Require().Nil(txn.Savepoint("s1"))
+	s.Require().Nil(txn.Set([]byte("a"), []byte("a2")))
+	s.Require().Nil(txn.GetMemBuffer().SetWithFlags([]byte("b"), []byte("b1"), kv.SetPresumeKeyNotExists))
+	s.Require().Nil(txn.Savepoint("s2"))
+	s.Require().Nil(txn.Delete([]byte("a")))
+	s.mustGet(txn, "a", "")
+
+	s.Require().Nil(txn.RollbackToSavepoint("s2"))
+	s.mustGet(txn, "a", "a2")
+	// Rolling back to a savepoint keeps it.
+	s.Require().Nil(txn.Set([]byte("c"), []byte("c1")))
+	s.Require().Nil(txn.RollbackToSavepoint("s2"))
+	s.mustGet(txn, "c", "")
+
+	s.Require().Nil(txn.RollbackToSavepoint("s1"))
+	s.mustGet(txn, "a", "a1")
+	s.mustGet(txn, "b", "")
+	// The flags set along with the reverted write are discarded.
+	_, err = txn.GetMemBuffer().GetFlags([]byte("b"))
+	s.True(tikverr.IsErrNotFound(err))
+	// The savepoints set after the one rolled back to are removed.
+	s.ErrorIs(txn.RollbackToSavepoint("s2"), tikverr.ErrSavepointNotExist)
+
+	s.Require().Nil(txn.Commit(context.Background()))
+	txn, err = s.store.Begin()
+	s.Require().Nil(err)
+	s.mustGet(txn, "a", "a1")
+	s.mustGet(txn, "b", "")
+}
+
+func (s *testSavepointSuite) TestReleaseSavepoint() {
+	txn, err := s.store.Begin()
+	s.Require().Nil(err)
+	s.Require().Nil(txn.Savepoint("s1"))
+	s.Require().Nil(txn.Set([]byte("a"), []byte("a1")))
+	s.Require().Nil(txn.Savepoint("s2"))
+	s.Require().Nil(txn.Set([]byte("b"), []byte("b1")))
+	s.Require().Nil(txn.Savepoint("s3"))
+
+	s.Require().Nil(txn.ReleaseSavepoint("s2"))
+	s.mustGet(txn, "b", "b1")
+	s.ErrorIs(txn.RollbackToSavepoint("s2"), tikverr.ErrSavepointNotExist)
+	s.ErrorIs(txn.RollbackToSavepoint("s3"), tikverr.ErrSavepointNotExist)
+	s.ErrorIs(txn.ReleaseSavepoint("s2"), tikverr.ErrSavepointNotExist)
+
+	// Setting a savepoint with an existing name replaces it.
+	s.Require().Nil(txn.Savepoint("s1"))
+	s.Require().Nil(txn.Set([]byte("c"), []byte("c1")))
+	s.Require().Nil(txn.RollbackToSavepoint("s1"))
+	s.mustGet(txn, "a", "a1")
+	s.mustGet(txn, "b", "b1")
+	s.mustGet(txn, "c", "")
+	s.Require().Nil(txn.Rollback())
+	s.ErrorIs(txn.Savepoint("s1"), tikverr.ErrInvalidTxn)
+}
+
+func (s *testSavepointSuite) TestKeepLocks() {
+	txn, err := s.store.Begin()
+	s.Require().Nil(err)
+	txn.SetPessimistic(true)
+	s.lockKeys(txn, "a")
+	s.Require().Nil(txn.Savepoint("s1"))
+	s.lockKeys(txn, "b")
+	s.Require().Nil(txn.Set([]byte("b"), []byte("b1")))
+
+	s.Require().Nil(txn.RollbackToSavepoint("s1"))
+	s.mustGet(txn, "b", "")
+	flags, err := txn.GetMemBuffer().GetFlags([]byte("b"))
+	s.Require().Nil(err)
+	s.True(flags.HasLocked())
+	s.ElementsMatch([]string{"a", "b"}, s.lockedKeys())
+	s.Require().Nil(txn.Rollback())
+	s.Empty(s.lockedKeys())
+}
+
+func (s *testSavepointSuite) TestRollbackLocks() {
+	txn, err := s.store.Begin()
+	s.Require().Nil(err)
+	txn.SetPessimistic(true)
+	txn.SetSavepointLockPolicy(transaction.RollbackLocksPolicy)
+	s.Require().Nil(txn.Savepoint("s1"))
+	// The lock of the primary key is kept even if it's acquired after the savepoint.
+	s.lockKeys(txn, "a")
+	s.Require().Nil(txn.Savepoint("s2"))
+	s.lockKeys(txn, "b", "c")
+	s.Require().Nil(txn.Set([]byte("b"), []byte("b1")))
+	s.Require().Nil(txn.ReleaseSavepoint("s2"))
+	s.ElementsMatch([]string{"a", "b", "c"}, s.lockedKeys())
+
+	s.Require().Nil(txn.RollbackToSavepoint("s1"))
+	s.Eventually(func() bool {
+		return len(s.lockedKeys()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Equal([]string{"a"}, s.lockedKeys())
+	for _, key := range []string{"b", "c"} {
+		flags, err := txn.GetMemBuffer().GetFlags([]byte(key))
+		s.Require().Nil(err)
+		s.False(flags.HasLocked(), key)
+	}
+
+	// The rolled back keys can be locked and committed again.
+	s.lockKeys(txn, "c")
+	s.Require().Nil(txn.Set([]byte("c"), []byte("c1")))
+	s.Require().Nil(txn.Commit(context.Background()))
+	s.Empty(s.lockedKeys())
+	txn, err = s.store.Begin()
+	s.Require().Nil(err)
+	s.mustGet(txn, "b", "")
+	s.mustGet(txn, "c", "c1")
+}
+
+func (s *testSavepointSuite) TestRollbackLocksLockedWithConflict() {
+	txn, err := s.store.Begin()
+	s.Require().Nil(err)
+	txn.SetPessimistic(true)
+	txn.SetSavepointLockPolicy(transaction.RollbackLocksPolicy)
+	s.lockKeys(txn, "a")
+	s.Require().Nil(txn.Savepoint("s1"))
+
+	// The key "b" written after the transaction starts is locked with conflict.
+	other, err := s.store.Begin()
+	s.Require().Nil(err)
+	s.Require().Nil(other.Set([]byte("b"), []byte("b0")))
+	s.Require().Nil(other.Commit(context.Background()))
+	txn.StartAggressiveLocking()
+	s.lockKeys(txn, "b")
+	txn.DoneAggressiveLocking(context.Background())
+
+	// A later statement locks "c" at a greater for update ts.
+	forUpdateTS, err := s.store.CurrentTimestamp(oracle.GlobalTxnScope)
+	s.Require().Nil(err)
+	lockCtx := kv.NewLockCtx(forUpdateTS, kv.LockAlwaysWait, time.Now())
+	s.Require().Nil(txn.LockKeys(context.Background(), lockCtx, []byte("c")))
+	s.ElementsMatch([]string{"a", "b", "c"}, s.lockedKeys())
+
+	s.Require().Nil(txn.RollbackToSavepoint("s1"))
+	s.Eventually(func() bool {
+		return len(s.lockedKeys()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Equal([]string{"a"}, s.lockedKeys())
+	s.Require().Nil(txn.Commit(context.Background()))
+	s.Empty(s.lockedKeys())
+}
+
+func (s *testSavepointSuite) TestPipelined() {
+	txn, err := s.store.Begin(WithDefaultPipelinedTxn())
+	s.Require().Nil(err)
+	s.ErrorIs(txn.Savepoint("s1"), tikverr.ErrSavepointNotSupported)
+	s.ErrorIs(txn.RollbackToSavepoint("s1"), tikverr.ErrSavepointNotSupported)
+	s.ErrorIs(txn.ReleaseSavepoint("s1"), tikverr.ErrSavepointNotSupported)
+	s.Require().Nil(txn.Rollback())
+}
@@ -0,0 +1,276 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/util"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultServiceSafePointTTL       = 5 * time.Minute
+	defaultGCBlockedWarningThreshold = time.Hour
+)
+
+type serviceSafePointOption struct {
+	ttl                       time.Duration
+	gcBlockedWarningThreshold time.Duration
+}
+
+// ServiceSafePointOpt is the option of ServiceSafePointKeeper.
+type ServiceSafePointOpt func(*serviceSafePointOption)
+
+// WithServiceSafePointTTL sets the TTL of the service safe point, it's renewed
+// every TTL/3 in the background.
+func WithServiceSafePointTTL(ttl time.Duration) ServiceSafePointOpt {
+	return func(opt *serviceSafePointOption) {
+		opt.ttl = ttl
+	}
+}
+
+// WithGCBlockedWarningThreshold sets the threshold to warn when the min service
+// safe point falls behind the current time for longer than it.
+func WithGCBlockedWarningThreshold(threshold time.Duration) ServiceSafePointOpt {
+	return func(opt *serviceSafePointOption) {
+		opt.gcBlockedWarningThreshold = threshold
+	}
+}
+
+// ServiceSafePointKeeper keeps a service safe point in PD, which prevents GC
+// from removing the MVCC versions after it, for the long-running readers such
+// as backups. The service safe point is renewed in the background until Close
+// is called, or until GC passes it, e.g. after it expires while PD is
+// unreachable. The readers should watch Done and stop once it's closed.
+type ServiceSafePointKeeper struct {
+	store     *KVStore
+	serviceID string
+	opt       serviceSafePointOption
+
+	mu struct {
+		sync.Mutex
+		safePoint           uint64
+		minServiceSafePoint uint64
+		closed              bool
+		err                 error
+	}
+	// done is closed when the renewal stops.
+	done chan struct{}
+	// updateMu serializes the updates of the service safe point in PD, so that it
+	// can't be registered again by Advance or the renewal after Close releases it.
+	updateMu sync.Mutex
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewServiceSafePointKeeper registers the service safe point of serviceID in PD
+// and keeps it until the returned keeper is closed. It fails with ErrGCTooEarly
+// if GC has already passed safePoint.
+func (s *KVStore) NewServiceSafePointKeeper(ctx context.Context, serviceID string, safePoint uint64, opts ...ServiceSafePointOpt) (*ServiceSafePointKeeper, error) {
+	opt := serviceSafePointOption{
+		ttl:                       defaultServiceSafePointTTL,
+		gcBlockedWarningThreshold: defaultGCBlockedWarningThreshold,
+	}
+	for _, o := range opts {
+		o(&opt)
+	}
+	if opt.ttl < time.Second {
+		return nil, errors.Errorf("the TTL of service safe point should be at least 1s, but got %v", opt.ttl)
+	}
+	k := &ServiceSafePointKeeper{
+		store:     s,
+		serviceID: serviceID,
+		opt:       opt,
+		done:      make(chan struct{}),
+	}
+	if err := k.update(ctx, safePoint); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	k.cancel = cancel
+	k.wg.Add(1)
+	go k.run(ctx)
+	return k, nil
+}
+
+// ServiceID returns the service ID of the service safe point.
+func (k *ServiceSafePointKeeper) ServiceID() string {
+	return k.serviceID
+}
+
+// SafePoint returns the service safe point kept.
+func (k *ServiceSafePointKeeper) SafePoint() uint64 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.mu.safePoint
+}
+
+// MinServiceSafePoint returns the min service safe point of all the services
+// returned by PD on the last renewal, which is the effective bound of GC.
+func (k *ServiceSafePointKeeper) MinServiceSafePoint() uint64 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.mu.minServiceSafePoint
+}
+
+// GCBlockedDuration returns how long the min service safe point falls behind
+// the current time.
+func (k *ServiceSafePointKeeper) GCBlockedDuration() time.Duration {
+	minServiceSafePoint := k.MinServiceSafePoint()
+	if minServiceSafePoint == 0 {
+		return 0
+	}
+	return time.Since(oracle.GetTimeFromTS(minServiceSafePoint))
+}
+
+// Done returns a channel which is closed when the service safe point is no
+// longer kept, either because the keeper is closed or the renewal fails with
+// ErrGCTooEarly.
+func (k *ServiceSafePointKeeper) Done() <-chan struct{} {
+	return k.done
+}
+
+// Err returns the ErrGCTooEarly error if the renewal finds GC has passed the
+// service safe point, the versions read after it may be removed. It returns nil
+// if the service safe point is still kept or the keeper is closed normally.
+func (k *ServiceSafePointKeeper) Err() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.mu.err
+}
+
+// Advance moves the service safe point forward, e.g. when the job has finished
+// reading the older versions. It's a no-op if safePoint is not greater than the
+// current one. The kept safe point is unchanged if it fails to update PD.
+func (k *ServiceSafePointKeeper) Advance(ctx context.Context, safePoint uint64) error {
+	k.updateMu.Lock()
+	defer k.updateMu.Unlock()
+	k.mu.Lock()
+	closed, current, lost := k.mu.closed, k.mu.safePoint, k.mu.err
+	k.mu.Unlock()
+	if lost != nil {
+		return lost
+	}
+	if closed {
+		return errors.Errorf("service safe point %s is closed", k.serviceID)
+	}
+	if safePoint <= current {
+		return nil
+	}
+	return k.update(ctx, safePoint)
+}
+
+// Close stops renewing and releases the service safe point.
+func (k *ServiceSafePointKeeper) Close() error {
+	k.mu.Lock()
+	if k.mu.closed {
+		k.mu.Unlock()
+		return nil
+	}
+	k.mu.closed = true
+	k.mu.Unlock()
+	k.cancel()
+	k.wg.Wait()
+
+	// Wait for the running Advance, which has seen the keeper open.
+	k.updateMu.Lock()
+	defer k.updateMu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// A TTL of 0 removes the service safe point.
+	if _, err := k.store.pdClient.UpdateServiceGCSafePoint(ctx, k.serviceID, 0, 0); err != nil {
+		return errors.WithStack(err)
+	}
+	logutil.BgLogger().Info("service safe point released", zap.String("serviceID", k.serviceID))
+	return nil
+}
+
+func (k *ServiceSafePointKeeper) run(ctx context.Context) {
+	defer k.wg.Done()
+	defer close(k.done)
+	ticker := time.NewTicker(k.opt.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := k.renew(ctx)
+			if err == nil || ctx.Err() != nil {
+				continue
+			}
+			var gcTooEarly *tikverr.ErrGCTooEarly
+			if errors.As(err, &gcTooEarly) {
+				// The service safe point is lost, renewing it again can't
+				// bring back the versions removed by GC.
+				logutil.BgLogger().Error("service safe point is passed by GC, stop renewing it",
+					zap.String("serviceID", k.serviceID), zap.Error(err))
+				k.mu.Lock()
+				k.mu.err = err
+				k.mu.Unlock()
+				return
+			}
+			logutil.BgLogger().Warn("renew service safe point failed",
+				zap.String("serviceID", k.serviceID), zap.Error(err))
+		}
+	}
+}
+
+// renew sets the kept service safe point in PD again to extend its TTL.
+func (k *ServiceSafePointKeeper) renew(ctx context.Context) error {
+	k.updateMu.Lock()
+	defer k.updateMu.Unlock()
+	return k.update(ctx, k.SafePoint())
+}
+
+// update sets the service safe point in PD with the TTL, and keeps it once PD
+// accepts it. It's called with updateMu held after the keeper is created.
+func (k *ServiceSafePointKeeper) update(ctx context.Context, safePoint uint64) error {
+	if _, e := util.EvalFailpoint("updateServiceSafePointError"); e == nil {
+		return errors.New("injected error on updating service safe point")
+	}
+	minServiceSafePoint, err := k.store.pdClient.UpdateServiceGCSafePoint(ctx, k.serviceID, int64(k.opt.ttl/time.Second), safePoint)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// PD doesn't set the service safe point if it's behind the min one.
+	if minServiceSafePoint > safePoint {
+		return &tikverr.ErrGCTooEarly{
+			TxnStartTS:  oracle.GetTimeFromTS(safePoint),
+			GCSafePoint: oracle.GetTimeFromTS(minServiceSafePoint),
+		}
+	}
+	k.mu.Lock()
+	k.mu.safePoint = safePoint
+	k.mu.minServiceSafePoint = minServiceSafePoint
+	k.mu.Unlock()
+
+	if blocked := k.GCBlockedDuration(); k.opt.gcBlockedWarningThreshold > 0 && blocked > k.opt.gcBlockedWarningThreshold {
+		logutil.BgLogger().Warn("GC is blocked by service safe point",
+			zap.String("serviceID", k.serviceID),
+			zap.Uint64("safePoint", safePoint),
+			zap.Uint64("minServiceSafePoint", minServiceSafePoint),
+			zap.Bool("blockedBySelf", minServiceSafePoint == safePoint),
+			zap.Duration("blocked", blocked))
+	}
+	return nil
+}
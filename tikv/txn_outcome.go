@@ -0,0 +1,160 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/tikvrpc"
+	"github.com/tikv/client-go/v2/txnkv/txnlock"
+	"go.uber.org/zap"
+)
+
+const getTxnOutcomeMaxBackoff = 20000
+
+// TxnOutcomeState is the state of a transaction reported by GetTransactionOutcome.
+type TxnOutcomeState int
+
+// Transaction outcome states.
+const (
+	// TxnStillRunning means the primary lock is still alive, the transaction may be committed or rolled back later.
+	TxnStillRunning TxnOutcomeState = iota
+	// TxnCommitted means the transaction is committed.
+	TxnCommitted
+	// TxnRolledBack means the transaction is rolled back and can never be committed.
+	TxnRolledBack
+)
+
+func (s TxnOutcomeState) String() string {
+	switch s {
+	case TxnStillRunning:
+		return "StillRunning"
+	case TxnCommitted:
+		return "Committed"
+	case TxnRolledBack:
+		return "RolledBack"
+	default:
+		return "Unknown"
+	}
+}
+
+// TxnOutcome is the outcome of a transaction.
+type TxnOutcome struct {
+	State TxnOutcomeState
+	// CommitTS is the commit timestamp if the transaction is committed.
+	CommitTS uint64
+}
+
+func (o TxnOutcome) String() string {
+	if o.State == TxnCommitted {
+		return fmt.Sprintf("%s(%d)", o.State, o.CommitTS)
+	}
+	return o.State.String()
+}
+
+// GetTransactionOutcome finds out whether the transaction of startTS is committed by its primary key, e.g. after the
+// commit fails with ErrResultUndetermined. The write records of the primary key are checked first. If there is none,
+// the status is checked by CheckTxnStatus like lock resolution, which rolls back the expired primary lock, and writes a
+// rollback record if the lock does not exist, so that the transaction can not be committed afterwards. So the outcome
+// is final unless it's TxnStillRunning, in which case the caller should check it again after a while.
+//
+// If startTS is before the GC safe point, ErrGCTooEarly is returned, as the write records of the transaction may have
+// been garbage collected and a rollback record written for a committed transaction.
+func (s *KVStore) GetTransactionOutcome(ctx context.Context, startTS uint64, primaryKey []byte) (TxnOutcome, error) {
+	if err := s.CheckVisibility(startTS); err != nil {
+		return TxnOutcome{}, err
+	}
+	bo := retry.NewBackofferWithVars(ctx, getTxnOutcomeMaxBackoff, nil)
+	for resolved := false; ; resolved = true {
+		if outcome, ok, err := s.getTxnOutcomeFromWrites(bo, startTS, primaryKey); err != nil || ok {
+			return outcome, err
+		}
+
+		currentTS, err := s.GetTimestampWithRetry(bo, oracle.GlobalTxnScope)
+		if err != nil {
+			return TxnOutcome{}, err
+		}
+		status, primaryLock, err := s.lockResolver.CheckTxnStatus(bo, startTS, primaryKey, currentTS, true)
+		if err != nil {
+			return TxnOutcome{}, err
+		}
+		switch {
+		case status.IsCommitted():
+			return TxnOutcome{State: TxnCommitted, CommitTS: status.CommitTS()}, nil
+		case status.TTL() > 0:
+			return TxnOutcome{State: TxnStillRunning}, nil
+		case primaryLock != nil && primaryLock.UseAsyncCommit && resolved:
+			return TxnOutcome{State: TxnStillRunning}, nil
+		case primaryLock != nil && primaryLock.UseAsyncCommit:
+			// The expired async commit transaction is committed or rolled back according to the secondaries.
+			logutil.Logger(ctx).Info("resolve expired async commit lock for transaction outcome",
+				zap.Uint64("startTS", startTS))
+			if _, err = s.lockResolver.ResolveLocks(bo, 0, []*txnlock.Lock{txnlock.NewLock(primaryLock)}); err != nil {
+				return TxnOutcome{}, err
+			}
+		default:
+			return TxnOutcome{State: TxnRolledBack}, nil
+		}
+	}
+}
+
+// getTxnOutcomeFromWrites checks the write records of the primary key with MvccGetByKey.
+func (s *KVStore) getTxnOutcomeFromWrites(bo *Backoffer, startTS uint64, primaryKey []byte) (TxnOutcome, bool, error) {
+	req := tikvrpc.NewRequest(tikvrpc.CmdMvccGetByKey, &kvrpcpb.MvccGetByKeyRequest{Key: primaryKey})
+	for {
+		loc, err := s.GetRegionCache().LocateKey(bo, primaryKey)
+		if err != nil {
+			return TxnOutcome{}, false, err
+		}
+		resp, err := s.SendReq(bo, req, loc.Region, ReadTimeoutShort)
+		if err != nil {
+			return TxnOutcome{}, false, err
+		}
+		regionErr, err := resp.GetRegionError()
+		if err != nil {
+			return TxnOutcome{}, false, err
+		}
+		if regionErr != nil {
+			if err = bo.Backoff(retry.BoRegionMiss, errors.New(regionErr.String())); err != nil {
+				return TxnOutcome{}, false, err
+			}
+			continue
+		}
+		if resp.Resp == nil {
+			return TxnOutcome{}, false, errors.WithStack(tikverr.ErrBodyMissing)
+		}
+		mvccResp := resp.Resp.(*kvrpcpb.MvccGetByKeyResponse)
+		if mvccResp.Error != "" {
+			return TxnOutcome{}, false, errors.Errorf("unexpected %s err: %v", req.Type, mvccResp.Error)
+		}
+		for _, write := range mvccResp.GetInfo().GetWrites() {
+			if write.StartTs != startTS {
+				continue
+			}
+			if write.Type == kvrpcpb.Op_Rollback {
+				return TxnOutcome{State: TxnRolledBack}, true, nil
+			}
+			return TxnOutcome{State: TxnCommitted, CommitTS: write.CommitTs}, true, nil
+		}
+		return TxnOutcome{}, false, nil
+	}
+}
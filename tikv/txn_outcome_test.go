@@ -0,0 +1,143 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/mockstore/mocktikv"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/testutils"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+)
+
+func TestTxnOutcome(t *testing.T) {
+	suite.Run(t, new(testTxnOutcomeSuite))
+}
+
+type testTxnOutcomeSuite struct {
+	suite.Suite
+	store *KVStore
+}
+
+func (s *testTxnOutcomeSuite) SetupTest() {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	s.Require().Nil(err)
+	mocktikv.BootstrapWithMultiRegions(cluster, []byte("b"))
+	s.store, err = NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	s.Require().Nil(err)
+}
+
+func (s *testTxnOutcomeSuite) TearDownTest() {
+	s.Require().Nil(s.store.Close())
+}
+
+// prewrite prewrites a transaction writing "a" and "c" with the primary key "a".
+func (s *testTxnOutcomeSuite) prewrite(ttl uint64, asyncCommit bool) (transaction.TxnProbe, transaction.CommitterProbe) {
+	txn, err := StoreProbe{KVStore: s.store}.Begin()
+	s.Require().Nil(err)
+	s.Require().Nil(txn.Set([]byte("a"), []byte("a")))
+	s.Require().Nil(txn.Set([]byte("c"), []byte("c")))
+	committer, err := txn.NewCommitter(1)
+	s.Require().Nil(err)
+	s.Require().Nil(committer.InitKeysAndMutations())
+	committer.SetPrimaryKey([]byte("a"))
+	committer.SetLockTTL(ttl)
+	if asyncCommit {
+		committer.SetUseAsyncCommit()
+	}
+	s.Require().Nil(committer.PrewriteAllMutations(context.Background()))
+	return txn, committer
+}
+
+func (s *testTxnOutcomeSuite) mustOutcome(startTS uint64, expected TxnOutcome) {
+	outcome, err := s.store.GetTransactionOutcome(context.Background(), startTS, []byte("a"))
+	s.Require().Nil(err)
+	s.Equal(expected, outcome)
+}
+
+func (s *testTxnOutcomeSuite) TestCommitted() {
+	txn, err := s.store.Begin()
+	s.Require().Nil(err)
+	s.Require().Nil(txn.Set([]byte("a"), []byte("a")))
+	s.Require().Nil(txn.Commit(context.Background()))
+	outcome := TxnOutcome{State: TxnCommitted, CommitTS: txn.CommitTS()}
+	s.Equal(fmt.Sprintf("Committed(%d)", txn.CommitTS()), outcome.String())
+	s.mustOutcome(txn.StartTS(), outcome)
+}
+
+func (s *testTxnOutcomeSuite) TestStillRunning() {
+	txn, committer := s.prewrite(uint64(time.Minute.Milliseconds()), false)
+	s.mustOutcome(txn.StartTS(), TxnOutcome{State: TxnStillRunning})
+
+	commitTS, err := s.store.CurrentTimestamp(oracle.GlobalTxnScope)
+	s.Require().Nil(err)
+	committer.SetCommitTS(commitTS)
+	s.Require().Nil(committer.CommitMutations(context.Background()))
+	s.mustOutcome(txn.StartTS(), TxnOutcome{State: TxnCommitted, CommitTS: commitTS})
+}
+
+func (s *testTxnOutcomeSuite) TestRolledBack() {
+	// The expired primary lock is rolled back.
+	txn, _ := s.prewrite(1, false)
+	time.Sleep(10 * time.Millisecond)
+	s.mustOutcome(txn.StartTS(), TxnOutcome{State: TxnRolledBack})
+	// The rollback record is found by the later calls.
+	s.mustOutcome(txn.StartTS(), TxnOutcome{State: TxnRolledBack})
+
+	// A rollback record is written if the transaction has not prewritten the primary key, so it can't commit later.
+	startTS, err := s.store.CurrentTimestamp(oracle.GlobalTxnScope)
+	s.Require().Nil(err)
+	s.mustOutcome(startTS, TxnOutcome{State: TxnRolledBack})
+	txn, err = StoreProbe{KVStore: s.store}.Begin(WithStartTS(startTS))
+	s.Require().Nil(err)
+	s.Require().Nil(txn.Set([]byte("a"), []byte("a")))
+	committer, err := txn.NewCommitter(1)
+	s.Require().Nil(err)
+	s.Require().NotNil(committer.PrewriteAllMutations(context.Background()))
+}
+
+func (s *testTxnOutcomeSuite) TestAsyncCommit() {
+	// The expired async commit transaction is committed as all the locks are prewritten.
+	txn, committer := s.prewrite(1, true)
+	time.Sleep(10 * time.Millisecond)
+	outcome, err := s.store.GetTransactionOutcome(context.Background(), txn.StartTS(), []byte("a"))
+	s.Require().Nil(err)
+	s.Equal(TxnCommitted, outcome.State)
+	s.GreaterOrEqual(outcome.CommitTS, committer.GetMinCommitTS())
+}
+
+func (s *testTxnOutcomeSuite) TestGCTooEarly() {
+	txn, committer := s.prewrite(uint64(time.Minute.Milliseconds()), false)
+	safePoint, err := s.store.CurrentTimestamp(oracle.GlobalTxnScope)
+	s.Require().Nil(err)
+	s.store.UpdateSPCache(safePoint, time.Now())
+	_, err = s.store.GetTransactionOutcome(context.Background(), txn.StartTS(), []byte("a"))
+	var gcErr *tikverr.ErrGCTooEarly
+	s.ErrorAs(err, &gcErr)
+
+	// The transaction is not rolled back, so it can still be committed.
+	commitTS, err := s.store.CurrentTimestamp(oracle.GlobalTxnScope)
+	s.Require().Nil(err)
+	committer.SetCommitTS(commitTS)
+	s.Require().Nil(committer.CommitMutations(context.Background()))
+	s.store.UpdateSPCache(0, time.Now())
+	s.mustOutcome(txn.StartTS(), TxnOutcome{State: TxnCommitted, CommitTS: commitTS})
+}
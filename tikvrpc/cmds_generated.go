@@ -224,6 +224,15 @@ func patchCmdCtx(req *Request, cmd CmdType, ctx *kvrpcpb.Context) bool {
 			req.Req = &cmd
 		}
 		req.rev++
+	case CmdRawBatchScan:
+		if req.rev == 0 {
+			req.RawBatchScan().Context = ctx
+		} else {
+			cmd := *req.RawBatchScan()
+			cmd.Context = ctx
+			req.Req = &cmd
+		}
+		req.rev++
 	case CmdUnsafeDestroyRange:
 		if req.rev == 0 {
 			req.UnsafeDestroyRange().Context = ctx
@@ -433,6 +442,8 @@ func isValidReqType(cmd CmdType) bool {
 		return true
 	case CmdRawChecksum:
 		return true
+	case CmdRawBatchScan:
+		return true
 	case CmdUnsafeDestroyRange:
 		return true
 	case CmdRegisterLockObserver:
@@ -51,6 +51,7 @@ cmds=(
   RawGetKeyTTL
   RawCompareAndSwap
   RawChecksum
+  RawBatchScan
   UnsafeDestroyRange
   RegisterLockObserver
   CheckLockObserver
@@ -88,6 +88,7 @@ const (
 	CmdRawGetKeyTTL
 	CmdRawCompareAndSwap
 	CmdRawChecksum
+	CmdRawBatchScan
 
 	CmdUnsafeDestroyRange
 
@@ -172,6 +173,8 @@ func (t CmdType) String() string {
 		return "RawScan"
 	case CmdRawChecksum:
 		return "RawChecksum"
+	case CmdRawBatchScan:
+		return "RawBatchScan"
 	case CmdRawGetKeyTTL:
 		return "RawGetKeyTTL"
 	case CmdRawCompareAndSwap:
@@ -447,6 +450,11 @@ func (req *Request) RawChecksum() *kvrpcpb.RawChecksumRequest {
 	return req.Req.(*kvrpcpb.RawChecksumRequest)
 }
 
+// RawBatchScan returns RawBatchScanRequest in request.
+func (req *Request) RawBatchScan() *kvrpcpb.RawBatchScanRequest {
+	return req.Req.(*kvrpcpb.RawBatchScanRequest)
+}
+
 // RegisterLockObserver returns RegisterLockObserverRequest in request.
 func (req *Request) RegisterLockObserver() *kvrpcpb.RegisterLockObserverRequest {
 	return req.Req.(*kvrpcpb.RegisterLockObserverRequest)
@@ -637,6 +645,8 @@ func (req *Request) ToBatchCommandsRequest() *tikvpb.BatchCommandsRequest_Reques
 		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_RawDeleteRange{RawDeleteRange: req.RawDeleteRange()}}
 	case CmdRawScan:
 		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_RawScan{RawScan: req.RawScan()}}
+	case CmdRawBatchScan:
+		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_RawBatchScan{RawBatchScan: req.RawBatchScan()}}
 	case CmdCop:
 		return &tikvpb.BatchCommandsRequest_Request{Cmd: &tikvpb.BatchCommandsRequest_Request_Coprocessor{Coprocessor: req.Cop()}}
 	case CmdPessimisticLock:
@@ -720,6 +730,8 @@ func FromBatchCommandsResponse(res *tikvpb.BatchCommandsResponse_Response) (*Res
 		return &Response{Resp: res.RawDeleteRange}, nil
 	case *tikvpb.BatchCommandsResponse_Response_RawScan:
 		return &Response{Resp: res.RawScan}, nil
+	case *tikvpb.BatchCommandsResponse_Response_RawBatchScan:
+		return &Response{Resp: res.RawBatchScan}, nil
 	case *tikvpb.BatchCommandsResponse_Response_Coprocessor:
 		return &Response{Resp: res.Coprocessor}, nil
 	case *tikvpb.BatchCommandsResponse_Response_PessimisticLock:
@@ -939,6 +951,10 @@ func GenRegionErrorResp(req *Request, e *errorpb.Error) (*Response, error) {
 		p = &kvrpcpb.RawChecksumResponse{
 			RegionError: e,
 		}
+	case CmdRawBatchScan:
+		p = &kvrpcpb.RawBatchScanResponse{
+			RegionError: e,
+		}
 	case CmdCop:
 		p = &coprocessor.Response{
 			RegionError: e,
@@ -1103,6 +1119,8 @@ func CallRPC(ctx context.Context, client tikvpb.TikvClient, req *Request) (*Resp
 		resp.Resp, err = client.RawCompareAndSwap(ctx, req.RawCompareAndSwap())
 	case CmdRawChecksum:
 		resp.Resp, err = client.RawChecksum(ctx, req.RawChecksum())
+	case CmdRawBatchScan:
+		resp.Resp, err = client.RawBatchScan(ctx, req.RawBatchScan())
 	case CmdRegisterLockObserver:
 		resp.Resp, err = client.RegisterLockObserver(ctx, req.RegisterLockObserver())
 	case CmdCheckLockObserver:
@@ -37,6 +37,7 @@ package rangetask
 import (
 	"bytes"
 	"context"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -74,6 +75,25 @@ type Runner struct {
 
 	completedRegions int32
 	failedRegions    int32
+	completedRanges  completedRanges
+}
+
+// completedRanges collects the ranges of the finished tasks.
+type completedRanges struct {
+	sync.Mutex
+	ranges []kv.KeyRange
+}
+
+func (c *completedRanges) add(r kv.KeyRange) {
+	c.Lock()
+	c.ranges = append(c.ranges, r)
+	c.Unlock()
+}
+
+func (c *completedRanges) reset() {
+	c.Lock()
+	c.ranges = nil
+	c.Unlock()
 }
 
 // TaskStat is used to count Regions that completed or failed to do the task.
@@ -155,6 +175,7 @@ func NewLocateRegionBackoffer(ctx context.Context) *retry.Backoffer {
 // Empty startKey or endKey means unbounded.
 func (s *Runner) RunOnRange(ctx context.Context, startKey, endKey []byte) error {
 	s.completedRegions = 0
+	s.completedRanges.reset()
 	metrics.TiKVRangeTaskStats.WithLabelValues(s.name, lblCompletedRegions).Set(0)
 
 	if len(endKey) != 0 && bytes.Compare(startKey, endKey) >= 0 {
@@ -296,6 +317,7 @@ func (s *Runner) createWorker(taskCh chan *kv.KeyRange, wg *sync.WaitGroup) *ran
 
 		completedRegions: &s.completedRegions,
 		failedRegions:    &s.failedRegions,
+		completedRanges:  &s.completedRanges,
 	}
 }
 
@@ -304,6 +326,25 @@ func (s *Runner) CompletedRegions() int {
 	return int(atomic.LoadInt32(&s.completedRegions))
 }
 
+// CompletedRanges returns the ranges of the tasks finished without error by the
+// last RunOnRange, sorted by the start key with the adjacent ones merged.
+func (s *Runner) CompletedRanges() []kv.KeyRange {
+	s.completedRanges.Lock()
+	ranges := append([]kv.KeyRange(nil), s.completedRanges.ranges...)
+	s.completedRanges.Unlock()
+
+	sort.Slice(ranges, func(i, j int) bool { return bytes.Compare(ranges[i].StartKey, ranges[j].StartKey) < 0 })
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && len(merged[n-1].EndKey) > 0 && bytes.Equal(merged[n-1].EndKey, r.StartKey) {
+			merged[n-1].EndKey = r.EndKey
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
 // FailedRegions returns how many regions has failed to do the task.
 func (s *Runner) FailedRegions() int {
 	return int(atomic.LoadInt32(&s.failedRegions))
@@ -324,6 +365,7 @@ type rangeTaskWorker struct {
 
 	completedRegions *int32
 	failedRegions    *int32
+	completedRanges  *completedRanges
 }
 
 // run starts the worker. It collects all objects from `w.taskCh` and process them one by one.
@@ -354,5 +396,6 @@ func (w *rangeTaskWorker) run(ctx context.Context, cancel context.CancelFunc) {
 			cancel()
 			break
 		}
+		w.completedRanges.add(*r)
 	}
 }
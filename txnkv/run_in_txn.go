@@ -0,0 +1,226 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnkv
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tikv/client-go/v2/config/retry"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/metrics"
+	"github.com/tikv/client-go/v2/tikv"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultTxnMaxAttempts is the default max attempts of RunInTxn.
+	DefaultTxnMaxAttempts = 10
+	// DefaultTxnRetryBaseBackoff is the default base backoff between the attempts of RunInTxn.
+	DefaultTxnRetryBaseBackoff = 10 * time.Millisecond
+	// DefaultTxnRetryMaxBackoff is the default max backoff between the attempts of RunInTxn.
+	DefaultTxnRetryMaxBackoff = time.Second
+)
+
+// RunInTxnOptions is the options of RunInTxn. The zero value runs an optimistic transaction with the default retry
+// policy.
+type RunInTxnOptions struct {
+	// MaxAttempts is the max number of attempts including the first one. 0 means DefaultTxnMaxAttempts.
+	MaxAttempts int
+	// BaseBackoff and MaxBackoff bound the exponential backoff with jitter between the attempts. 0 means the default.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// MaxTotalBackoff limits the total backoff time of all the attempts. 0 means no limit other than the deadline of
+	// the context.
+	MaxTotalBackoff time.Duration
+	// Pessimistic runs the transaction in pessimistic mode. RunInTxn locks the keys written by fn before committing,
+	// so fn only needs to call LockKeys for the keys it reads but doesn't write.
+	Pessimistic bool
+	// FallbackToPessimistic switches the transaction to pessimistic mode after an optimistic attempt fails with a
+	// write conflict. The keys written by the failed attempt are locked at the start ts of the next attempt before
+	// calling fn, so that the concurrent writers can't commit them after fn reads them, which avoids failing
+	// repeatedly on hot keys.
+	FallbackToPessimistic bool
+	// TxnOptions are the options to begin the transaction of each attempt.
+	TxnOptions []tikv.TxnOption
+}
+
+// RunInTxnStats is the statistics of the attempts of RunInTxn.
+type RunInTxnStats struct {
+	// Attempts is the number of the transactions begun.
+	Attempts int
+	// WriteConflicts and Deadlocks are the numbers of the attempts failed by them.
+	WriteConflicts int
+	Deadlocks      int
+	// FellBackToPessimistic is set if the transaction switched to pessimistic mode after a write conflict.
+	FellBackToPessimistic bool
+	// Undetermined is set if the commit result of the last attempt is undetermined. The transaction may or may not
+	// be committed, so it's not retried.
+	Undetermined bool
+	// TotalBackoff is the total backoff time between the attempts.
+	TotalBackoff time.Duration
+	// StartTS and CommitTS are the timestamps of the last attempt. CommitTS is 0 if it's not committed.
+	StartTS  uint64
+	CommitTS uint64
+}
+
+// RunInTxn runs fn in a transaction and commits it. The transaction is rolled back if fn returns an error. The
+// attempt is retried with backoff if it fails with a retryable error, see IsRetryableTxnError. fn may be called
+// multiple times, so it should not have side effects other than the transaction. The statistics of the attempts are
+// returned even if it fails.
+func (c *Client) RunInTxn(ctx context.Context, opts RunInTxnOptions, fn func(txn *KVTxn) error) (RunInTxnStats, error) {
+	var stats RunInTxnStats
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultTxnMaxAttempts
+	}
+	baseBackoff, maxBackoff := opts.BaseBackoff, opts.MaxBackoff
+	if baseBackoff <= 0 {
+		baseBackoff = DefaultTxnRetryBaseBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultTxnRetryMaxBackoff
+	}
+	if maxBackoff < baseBackoff {
+		maxBackoff = baseBackoff
+	}
+	boCfg := retry.NewConfig("txnRetry", &metrics.BackoffHistogramEmpty,
+		retry.NewBackoffFnCfg(int(baseBackoff.Milliseconds()), int(maxBackoff.Milliseconds()), retry.EqualJitter), nil)
+	bo := retry.NewBackoffer(ctx, int(opts.MaxTotalBackoff.Milliseconds()))
+
+	pessimistic := opts.Pessimistic
+	var hotKeys [][]byte
+	for {
+		stats.Attempts++
+		writtenKeys, err := c.runTxnAttempt(ctx, pessimistic, opts.TxnOptions, hotKeys, fn, &stats)
+		if err == nil {
+			return stats, nil
+		}
+		if tikverr.IsErrorUndetermined(err) {
+			stats.Undetermined = true
+			return stats, err
+		}
+		if !IsRetryableTxnError(err) {
+			return stats, err
+		}
+		var deadlock *tikverr.ErrDeadlock
+		if errors.As(err, &deadlock) {
+			stats.Deadlocks++
+		} else if isWriteConflict(err) {
+			stats.WriteConflicts++
+			hotKeys = writtenKeys
+			if !pessimistic && opts.FallbackToPessimistic {
+				pessimistic = true
+				stats.FellBackToPessimistic = true
+			}
+		}
+		if stats.Attempts >= maxAttempts {
+			return stats, errors.WithMessagef(err, "transaction failed after %d attempts", stats.Attempts)
+		}
+		logutil.Logger(ctx).Debug("retry transaction",
+			zap.Int("attempts", stats.Attempts), zap.Uint64("startTS", stats.StartTS), zap.Error(err))
+		// The last error is returned if the backoff time is exceeded.
+		boCfg.SetErrors(err)
+		boErr := bo.Backoff(boCfg, err)
+		stats.TotalBackoff = time.Duration(bo.GetTotalSleep()) * time.Millisecond
+		if boErr != nil {
+			return stats, boErr
+		}
+	}
+}
+
+// runTxnAttempt runs fn in a new transaction and commits it. In pessimistic mode, lockKeys are locked before calling
+// fn and the keys written by fn are locked before committing, both at the start ts of the transaction, so that the
+// conflicts are either reported early or prevented. The keys written by fn are returned.
+func (c *Client) runTxnAttempt(ctx context.Context, pessimistic bool, txnOpts []tikv.TxnOption, lockKeys [][]byte, fn func(txn *KVTxn) error, stats *RunInTxnStats) (writtenKeys [][]byte, err error) {
+	txn, err := c.Begin(txnOpts...)
+	if err != nil {
+		return nil, err
+	}
+	stats.StartTS, stats.CommitTS = txn.StartTS(), 0
+	pessimistic = pessimistic && !txn.IsPipelined()
+	if pessimistic {
+		txn.SetPessimistic(true)
+	}
+	defer func() {
+		if err != nil && txn.Valid() {
+			if rollbackErr := txn.Rollback(); rollbackErr != nil {
+				logutil.Logger(ctx).Warn("rollback transaction failed",
+					zap.Uint64("startTS", txn.StartTS()), zap.Error(rollbackErr))
+			}
+		}
+	}()
+	lockAtStartTS := func(keys [][]byte) error {
+		if !pessimistic || len(keys) == 0 {
+			return nil
+		}
+		return txn.LockKeys(ctx, kv.NewLockCtx(txn.StartTS(), kv.LockAlwaysWait, time.Now()), keys...)
+	}
+	if err = lockAtStartTS(lockKeys); err != nil {
+		return nil, err
+	}
+	if err = fn(txn); err != nil {
+		return nil, err
+	}
+	writtenKeys = memBufferKeys(txn)
+	if err = lockAtStartTS(writtenKeys); err != nil {
+		return writtenKeys, err
+	}
+	if err = txn.Commit(ctx); err != nil {
+		return writtenKeys, err
+	}
+	stats.CommitTS = txn.CommitTS()
+	return writtenKeys, nil
+}
+
+// memBufferKeys returns the keys written in the transaction.
+func memBufferKeys(txn *KVTxn) [][]byte {
+	var keys [][]byte
+	it, err := txn.GetMemBuffer().Iter(nil, nil)
+	for err == nil && it.Valid() {
+		keys = append(keys, append([]byte(nil), it.Key()...))
+		err = it.Next()
+	}
+	if it != nil {
+		it.Close()
+	}
+	return keys
+}
+
+// IsRetryableTxnError returns whether the transaction failed with err can be retried from the beginning, which are
+// write conflicts and deadlocks. ErrTxnTooLarge and the undetermined commit results are never retryable.
+func IsRetryableTxnError(err error) bool {
+	if err == nil || tikverr.IsErrorUndetermined(err) {
+		return false
+	}
+	var tooLarge *tikverr.ErrTxnTooLarge
+	if errors.As(err, &tooLarge) {
+		return false
+	}
+	var deadlock *tikverr.ErrDeadlock
+	if errors.As(err, &deadlock) {
+		return true
+	}
+	var retryable *tikverr.ErrRetryable
+	return isWriteConflict(err) || errors.As(err, &retryable)
+}
+
+func isWriteConflict(err error) bool {
+	var latchConflict *tikverr.ErrWriteConflictInLatch
+	return tikverr.IsErrWriteConflict(err) || errors.As(err, &latchConflict)
+}
@@ -0,0 +1,216 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txnkv
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/testutils"
+	"github.com/tikv/client-go/v2/tikv"
+)
+
+func TestRunInTxn(t *testing.T) {
+	suite.Run(t, new(testRunInTxnSuite))
+}
+
+type testRunInTxnSuite struct {
+	suite.Suite
+	client *Client
+}
+
+func (s *testRunInTxnSuite) SetupTest() {
+	client, cluster, pdClient, err := testutils.NewMockTiKV("", nil)
+	s.Require().Nil(err)
+	testutils.BootstrapWithSingleStore(cluster)
+	store, err := tikv.NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	s.Require().Nil(err)
+	s.client = &Client{KVStore: store}
+}
+
+func (s *testRunInTxnSuite) TearDownTest() {
+	s.Require().Nil(s.client.Close())
+}
+
+func (s *testRunInTxnSuite) mustPut(key, value string) {
+	txn, err := s.client.Begin()
+	s.Require().Nil(err)
+	s.Require().Nil(txn.Set([]byte(key), []byte(value)))
+	s.Require().Nil(txn.Commit(context.Background()))
+}
+
+// tryPut writes the key in another transaction, which fails if the key is locked.
+func (s *testRunInTxnSuite) tryPut(key, value string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	txn, err := s.client.Begin()
+	s.Require().Nil(err)
+	s.Require().Nil(txn.Set([]byte(key), []byte(value)))
+	return txn.Commit(ctx)
+}
+
+func (s *testRunInTxnSuite) mustGetInt(key string) int {
+	txn, err := s.client.Begin()
+	s.Require().Nil(err)
+	val, err := txn.Get(context.Background(), []byte(key))
+	s.Require().Nil(err)
+	n, err := strconv.Atoi(string(val))
+	s.Require().Nil(err)
+	return n
+}
+
+// incr increases the value of key, and commits a conflicting write in the first conflicts attempts.
+func (s *testRunInTxnSuite) incr(key string, conflicts int, modes *[]bool) func(txn *KVTxn) error {
+	attempts := 0
+	return func(txn *KVTxn) error {
+		attempts++
+		if modes != nil {
+			*modes = append(*modes, txn.IsPessimistic())
+		}
+		val, err := txn.Get(context.Background(), []byte(key))
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(string(val))
+		if err != nil {
+			return err
+		}
+		if attempts <= conflicts {
+			s.mustPut(key, strconv.Itoa(n+100))
+		}
+		return txn.Set([]byte(key), []byte(strconv.Itoa(n+1)))
+	}
+}
+
+func (s *testRunInTxnSuite) TestRetryWriteConflict() {
+	s.mustPut("k", "0")
+	stats, err := s.client.RunInTxn(context.Background(), RunInTxnOptions{}, s.incr("k", 2, nil))
+	s.Require().Nil(err)
+	s.Equal(3, stats.Attempts)
+	s.Equal(2, stats.WriteConflicts)
+	s.False(stats.Undetermined)
+	s.NotZero(stats.StartTS)
+	s.Greater(stats.CommitTS, stats.StartTS)
+	s.Equal(201, s.mustGetInt("k"))
+}
+
+func (s *testRunInTxnSuite) TestMaxAttempts() {
+	s.mustPut("k", "0")
+	stats, err := s.client.RunInTxn(context.Background(), RunInTxnOptions{MaxAttempts: 2}, s.incr("k", 10, nil))
+	s.Require().NotNil(err)
+	s.True(tikverr.IsErrWriteConflict(err))
+	s.Equal(2, stats.Attempts)
+	s.Equal(2, stats.WriteConflicts)
+	s.Zero(stats.CommitTS)
+	s.Equal(200, s.mustGetInt("k"))
+}
+
+func (s *testRunInTxnSuite) TestFallbackToPessimistic() {
+	s.mustPut("k", "0")
+	var (
+		modes        []bool
+		concurrentOK int
+	)
+	// incr increases the key while a concurrent writer keeps writing it in every attempt.
+	incr := func(txn *KVTxn) error {
+		modes = append(modes, txn.IsPessimistic())
+		val, err := txn.Get(context.Background(), []byte("k"))
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(string(val))
+		if err != nil {
+			return err
+		}
+		if s.tryPut("k", strconv.Itoa(n+100)) == nil {
+			concurrentOK++
+		}
+		return txn.Set([]byte("k"), []byte(strconv.Itoa(n+1)))
+	}
+
+	// Every optimistic attempt conflicts with the concurrent writer.
+	stats, err := s.client.RunInTxn(context.Background(), RunInTxnOptions{MaxAttempts: 3}, incr)
+	s.Require().NotNil(err)
+	s.True(tikverr.IsErrWriteConflict(err))
+	s.Equal(3, stats.WriteConflicts)
+	s.Equal(3, concurrentOK)
+	s.Equal(300, s.mustGetInt("k"))
+
+	// The key written by the failed attempt is locked before the pessimistic attempt reads it, so the concurrent
+	// writer fails instead.
+	modes, concurrentOK = nil, 0
+	stats, err = s.client.RunInTxn(context.Background(), RunInTxnOptions{MaxAttempts: 3, FallbackToPessimistic: true}, incr)
+	s.Require().Nil(err)
+	s.Equal(2, stats.Attempts)
+	s.Equal(1, stats.WriteConflicts)
+	s.True(stats.FellBackToPessimistic)
+	s.Equal([]bool{false, true}, modes)
+	s.Equal(1, concurrentOK)
+	s.Equal(401, s.mustGetInt("k"))
+
+	// The keys written in pessimistic mode are locked at the start ts before committing even if fn doesn't lock them,
+	// so the conflict is detected by locking.
+	modes = nil
+	stats, err = s.client.RunInTxn(context.Background(), RunInTxnOptions{Pessimistic: true}, s.incr("k", 1, &modes))
+	s.Require().Nil(err)
+	s.Equal(2, stats.Attempts)
+	s.Equal(1, stats.WriteConflicts)
+	s.False(stats.FellBackToPessimistic)
+	s.Equal([]bool{true, true}, modes)
+	s.Equal(502, s.mustGetInt("k"))
+}
+
+func (s *testRunInTxnSuite) TestNonRetryableError() {
+	injected := errors.New("injected")
+	var txns []*KVTxn
+	stats, err := s.client.RunInTxn(context.Background(), RunInTxnOptions{}, func(txn *KVTxn) error {
+		txns = append(txns, txn)
+		s.Require().Nil(txn.Set([]byte("k"), []byte("v")))
+		return injected
+	})
+	s.ErrorIs(err, injected)
+	s.Equal(1, stats.Attempts)
+	s.Len(txns, 1)
+	// The transaction is rolled back.
+	s.False(txns[0].Valid())
+}
+
+func (s *testRunInTxnSuite) TestUndetermined() {
+	// The undetermined result is not retried, as the transaction may have been committed.
+	stats, err := s.client.RunInTxn(context.Background(), RunInTxnOptions{}, func(txn *KVTxn) error {
+		s.Require().Nil(txn.Set([]byte("k"), []byte("v")))
+		return errors.WithMessage(tikverr.ErrResultUndetermined, "commit primary")
+	})
+	s.True(tikverr.IsErrorUndetermined(err))
+	s.True(stats.Undetermined)
+	s.Equal(1, stats.Attempts)
+}
+
+func (s *testRunInTxnSuite) TestIsRetryableTxnError() {
+	s.False(IsRetryableTxnError(nil))
+	s.False(IsRetryableTxnError(errors.New("other")))
+	s.True(IsRetryableTxnError(errors.WithStack(tikverr.NewErrWriteConflictWithArgs(1, 2, 3, []byte("k"), kvrpcpb.WriteConflict_Optimistic))))
+	s.True(IsRetryableTxnError(&tikverr.ErrWriteConflictInLatch{StartTS: 1}))
+	s.True(IsRetryableTxnError(errors.WithStack(&tikverr.ErrDeadlock{Deadlock: &kvrpcpb.Deadlock{}})))
+	s.True(IsRetryableTxnError(&tikverr.ErrRetryable{Retryable: "retry"}))
+	s.False(IsRetryableTxnError(errors.WithStack(&tikverr.ErrTxnTooLarge{Size: 1})))
+	s.False(IsRetryableTxnError(errors.WithMessage(tikverr.ErrResultUndetermined, "commit primary")))
+}
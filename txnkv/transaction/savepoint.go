@@ -0,0 +1,212 @@
+// Copyright 2025 TiKV Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/pkg/errors"
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/internal/logutil"
+	"github.com/tikv/client-go/v2/kv"
+	"github.com/tikv/client-go/v2/util"
+	"go.uber.org/zap"
+)
+
+// SavepointLockPolicy specifies what to do with the pessimistic locks acquired after a savepoint when rolling back
+// to it.
+type SavepointLockPolicy int
+
+const (
+	// KeepLocksPolicy is the default one: the locks are kept until the transaction ends, which is the same as MySQL.
+	KeepLocksPolicy SavepointLockPolicy = iota
+	// RollbackLocksPolicy means the locks are rolled back asynchronously. The lock of the primary key is always kept,
+	// because the other locks of the transaction point to it.
+	RollbackLocksPolicy
+)
+
+func (p SavepointLockPolicy) String() string {
+	switch p {
+	case KeepLocksPolicy:
+		return "KeepLocksPolicy"
+	case RollbackLocksPolicy:
+		return "RollbackLocksPolicy"
+	default:
+		return "Unknown"
+	}
+}
+
+// savepoint is backed by a staging buffer of the MemBuffer, which prevents the values written before it from being
+// overwritten in place.
+type savepoint struct {
+	name   string
+	handle int
+	// replaced is set when a newer savepoint with the same name is set. The staging buffer is kept until the
+	// savepoints before it are released or rolled back to, as the staging buffers can only be released in order.
+	replaced bool
+	// lockedKeys are the keys pessimistically locked after this savepoint and before the next one.
+	lockedKeys [][]byte
+}
+
+// SetSavepointLockPolicy specifies the behavior of the pessimistic locks acquired after the savepoint when rolling
+// back to it.
+func (txn *KVTxn) SetSavepointLockPolicy(policy SavepointLockPolicy) {
+	txn.savepointLockPolicy = policy
+}
+
+// Savepoint sets a savepoint with the name. The existing savepoint with the same name is replaced, the same as
+// MySQL. Savepoints are implemented by the staging buffers of the MemBuffer, so the caller should not interleave them
+// with its own staging handles. The remaining savepoints are released on commit.
+func (txn *KVTxn) Savepoint(name string) error {
+	if err := txn.checkSavepointAvailable(); err != nil {
+		return err
+	}
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	if idx := txn.findSavepoint(name); idx >= 0 {
+		txn.savepoints[idx].replaced = true
+	}
+	txn.savepoints = append(txn.savepoints, savepoint{
+		name:   name,
+		handle: txn.GetMemBuffer().Staging(),
+	})
+	return nil
+}
+
+// RollbackToSavepoint reverts the changes made after the savepoint, the savepoints set after it are removed while
+// the savepoint itself is kept. The pessimistic locks acquired after the savepoint are handled according to the
+// SavepointLockPolicy. The key flags set along with the reverted writes are discarded together with them, but the
+// locked flags are only removed if the locks are rolled back.
+func (txn *KVTxn) RollbackToSavepoint(name string) error {
+	if err := txn.checkSavepointAvailable(); err != nil {
+		return err
+	}
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	idx := txn.findSavepoint(name)
+	if idx < 0 {
+		return errors.WithMessage(tikverr.ErrSavepointNotExist, name)
+	}
+	var lockedKeys [][]byte
+	for _, sp := range txn.savepoints[idx:] {
+		lockedKeys = append(lockedKeys, sp.lockedKeys...)
+	}
+	memBuf := txn.GetMemBuffer()
+	for i := len(txn.savepoints) - 1; i >= idx; i-- {
+		memBuf.Cleanup(txn.savepoints[i].handle)
+	}
+	txn.savepoints = append(txn.savepoints[:idx], savepoint{
+		name:   name,
+		handle: memBuf.Staging(),
+	})
+
+	if txn.savepointLockPolicy == RollbackLocksPolicy && len(lockedKeys) > 0 {
+		txn.rollbackSavepointLocks(lockedKeys)
+	}
+	return nil
+}
+
+// ReleaseSavepoint removes the savepoint and the ones set after it without reverting any changes.
+func (txn *KVTxn) ReleaseSavepoint(name string) error {
+	if err := txn.checkSavepointAvailable(); err != nil {
+		return err
+	}
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	idx := txn.findSavepoint(name)
+	if idx < 0 {
+		return errors.WithMessage(tikverr.ErrSavepointNotExist, name)
+	}
+	txn.releaseSavepoints(idx)
+	return nil
+}
+
+func (txn *KVTxn) checkSavepointAvailable() error {
+	if !txn.valid {
+		return tikverr.ErrInvalidTxn
+	}
+	if txn.IsPipelined() {
+		return tikverr.ErrSavepointNotSupported
+	}
+	if txn.IsInAggressiveLockingMode() {
+		return errors.New("savepoint is not supported in aggressive locking mode")
+	}
+	return nil
+}
+
+func (txn *KVTxn) findSavepoint(name string) int {
+	for i := len(txn.savepoints) - 1; i >= 0; i-- {
+		if txn.savepoints[i].name == name && !txn.savepoints[i].replaced {
+			return i
+		}
+	}
+	return -1
+}
+
+// releaseSavepoints releases the savepoints from idx on, the changes made after them are kept. The keys locked after
+// them are moved to the previous savepoint, so that they are still handled if the transaction rolls back to it.
+func (txn *KVTxn) releaseSavepoints(idx int) {
+	memBuf := txn.GetMemBuffer()
+	for i := len(txn.savepoints) - 1; i >= idx; i-- {
+		memBuf.Release(txn.savepoints[i].handle)
+		if idx > 0 {
+			txn.savepoints[idx-1].lockedKeys = append(txn.savepoints[idx-1].lockedKeys, txn.savepoints[i].lockedKeys...)
+		}
+	}
+	txn.savepoints = txn.savepoints[:idx]
+}
+
+// trackSavepointLockedKey records the key locked after the last savepoint.
+func (txn *KVTxn) trackSavepointLockedKey(key []byte) {
+	if len(txn.savepoints) == 0 {
+		return
+	}
+	last := &txn.savepoints[len(txn.savepoints)-1]
+	last.lockedKeys = append(last.lockedKeys, append([]byte(nil), key...))
+}
+
+// rollbackSavepointLocks rolls back the pessimistic locks acquired after the savepoint asynchronously, except the
+// one of the primary key.
+func (txn *KVTxn) rollbackSavepointLocks(lockedKeys [][]byte) {
+	memBuf := txn.GetMemBuffer()
+	keys := make([][]byte, 0, len(lockedKeys))
+	for _, key := range lockedKeys {
+		if bytes.Equal(key, txn.committer.primaryKey) {
+			continue
+		}
+		flags, err := memBuf.GetFlags(key)
+		if err != nil || !flags.HasLocked() {
+			continue
+		}
+		memBuf.UpdateFlags(key, kv.DelKeyLocked, kv.SetKeyLockedValueNotExists)
+		delete(txn.forUpdateTSChecks, string(key))
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return
+	}
+	txn.lockedCnt -= len(keys)
+	logutil.BgLogger().Debug("[kv] rollback pessimistic locks to savepoint",
+		zap.Uint64("txnStartTS", txn.startTS), zap.Int("keys", len(keys)))
+	// The keys may be locked with conflict at a ts greater than the for update ts, and the later statements may
+	// lock them at a greater for update ts, so the greater one covers all the locks.
+	forUpdateTS := max(txn.committer.forUpdateTS, txn.committer.maxLockedWithConflictTS)
+	ctx := context.WithValue(context.Background(), util.RequestSourceKey, *txn.RequestSource)
+	txn.asyncPessimisticRollback(ctx, keys, forUpdateTS)
+}
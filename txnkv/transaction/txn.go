@@ -211,6 +211,9 @@ type KVTxn struct {
 	flushBatchDurationEWMA ewma.MovingAverage
 
 	prewriteEncounterLockPolicy PrewriteEncounterLockPolicy
+
+	savepoints          []savepoint
+	savepointLockPolicy SavepointLockPolicy
 }
 
 // NewTiKVTxn creates a new KVTxn.
@@ -756,6 +759,9 @@ func (txn *KVTxn) Commit(ctx context.Context) error {
 	}
 	defer txn.close()
 
+	// The staging buffers of the savepoints must be released to publish the changes made after them.
+	txn.releaseSavepoints(0)
+
 	ctx = context.WithValue(ctx, util.RequestSourceKey, *txn.RequestSource)
 
 	if txn.IsInAggressiveLockingMode() {
@@ -1191,6 +1197,7 @@ func (txn *KVTxn) DoneAggressiveLocking(ctx context.Context) {
 			setValExists = tikv.SetKeyLockedValueNotExists
 		}
 		memBuffer.UpdateFlags([]byte(key), tikv.SetKeyLocked, tikv.DelNeedCheckExists, setValExists)
+		txn.trackSavepointLockedKey([]byte(key))
 
 		if _, ok := txn.forUpdateTSChecks[key]; !ok {
 			txn.forUpdateTSChecks[key] = entry.ActualLockForUpdateTS
@@ -1638,6 +1645,7 @@ func (txn *KVTxn) lockKeys(ctx context.Context, lockCtx *tikv.LockCtx, fn func()
 				setValExists = tikv.SetKeyLockedValueNotExists
 			}
 			memBuf.UpdateFlags(key, tikv.SetKeyLocked, tikv.DelNeedCheckExists, setValExists)
+			txn.trackSavepointLockedKey(key)
 		}
 	}
 	if err != nil {
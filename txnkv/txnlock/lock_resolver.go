@@ -646,6 +646,17 @@ func (lr *LockResolver) GetTxnStatus(txnID uint64, callerStartTS uint64, primary
 	return lr.getTxnStatus(bo, txnID, primary, callerStartTS, currentTS, true, false, nil)
 }
 
+// CheckTxnStatus queries tikv-server for the status of the txn by its primary key. The primary lock is rolled back
+// if it's expired at currentTS, and a rollback record is written if the lock does not exist and rollbackIfNotExist is
+// set, which makes the status final. The primary lock is returned along with the status if it still exists.
+func (lr *LockResolver) CheckTxnStatus(bo *retry.Backoffer, txnID uint64, primary []byte, currentTS uint64, rollbackIfNotExist bool) (TxnStatus, *kvrpcpb.LockInfo, error) {
+	status, err := lr.getTxnStatus(bo, txnID, primary, 0, currentTS, rollbackIfNotExist, false, nil)
+	if err != nil {
+		return status, nil, err
+	}
+	return status, status.primaryLock, nil
+}
+
 func (lr *LockResolver) getTxnStatusFromLock(bo *retry.Backoffer, l *Lock, callerStartTS uint64, forceSyncCommit bool, detail *util.ResolveLockDetail) (TxnStatus, error) {
 	var currentTS uint64
 	var err error
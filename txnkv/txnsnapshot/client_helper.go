@@ -65,6 +65,7 @@ type ClientHelper struct {
 	resolveLite    bool
 	oracle         oracle.Oracle
 	Stats          *locate.RegionRequestRuntimeStats
+	HedgePolicy    *locate.HedgePolicy
 }
 
 // NewClientHelper creates a helper instance.
@@ -144,6 +145,7 @@ func (ch *ClientHelper) SendReqCtx(bo *retry.Backoffer, req *tikvrpc.Request, re
 		sender.SetStoreAddr(directStoreAddr)
 	}
 	sender.Stats = ch.Stats
+	sender.SetHedgePolicy(ch.HedgePolicy)
 	req.Context.ResolvedLocks = ch.resolvedLocks.GetAll()
 	req.Context.CommittedLocks = ch.committedLocks.GetAll()
 	resp, ctx, _, err := sender.SendReqCtx(bo, req, regionID, timeout, et, opts...)
@@ -242,6 +242,7 @@ func (s *Scanner) getData(bo *retry.Backoffer) error {
 			sreq.Reverse = true
 		}
 		s.snapshot.mu.RLock()
+		sender.SetHedgePolicy(s.snapshot.mu.hedgePolicy)
 		req := tikvrpc.NewReplicaReadRequest(tikvrpc.CmdScan, sreq, s.snapshot.mu.replicaRead, &s.snapshot.replicaReadSeed, kvrpcpb.Context{
 			Priority:         s.snapshot.priority.ToPB(),
 			NotFillCache:     s.snapshot.notFillCache,
@@ -261,8 +262,12 @@ func (s *Scanner) getData(bo *retry.Backoffer) error {
 		if s.snapshot.mu.resourceGroupTag == nil && s.snapshot.mu.resourceGroupTagger != nil {
 			s.snapshot.mu.resourceGroupTagger(req)
 		}
+		var ops []locate.StoreSelectorOption
+		if s.snapshot.mu.replicaSelectionStrategy != nil {
+			ops = append(ops, locate.WithReplicaSelectionStrategy(s.snapshot.mu.replicaSelectionStrategy))
+		}
 		s.snapshot.mu.RUnlock()
-		resp, _, err := sender.SendReq(bo, req, loc.Region, client.ReadTimeoutMedium)
+		resp, _, _, err := sender.SendReqCtx(bo, req, loc.Region, client.ReadTimeoutMedium, tikvrpc.TiKV, ops...)
 		if err != nil {
 			return err
 		}
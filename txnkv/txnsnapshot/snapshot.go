@@ -152,6 +152,10 @@ type KVSnapshot struct {
 		interceptor interceptor.RPCInterceptor
 		// resourceGroupName is used to bind the request to specified resource group.
 		resourceGroupName string
+		// hedgePolicy enables hedged reads of Get, BatchGet and Scan if it's not nil.
+		hedgePolicy *locate.HedgePolicy
+		// replicaSelectionStrategy decides the replica to read if it's not nil.
+		replicaSelectionStrategy locate.ReplicaSelectionStrategy
 	}
 	sampleStep uint32
 	*util.RequestSource
@@ -436,6 +440,7 @@ func (s *KVSnapshot) batchGetSingleRegion(bo *retry.Backoffer, batch batchKeys,
 	}
 	isStaleness := s.mu.isStaleness
 	busyThresholdMs := s.mu.busyThreshold.Milliseconds()
+	cli.HedgePolicy = s.mu.hedgePolicy
 	s.mu.RUnlock()
 
 	pending := batch.keys
@@ -461,6 +466,7 @@ func (s *KVSnapshot) batchGetSingleRegion(bo *retry.Backoffer, batch batchKeys,
 		scope := s.mu.readReplicaScope
 		matchStoreLabels := s.mu.matchStoreLabels
 		replicaAdjuster := s.mu.replicaReadAdjuster
+		strategy := s.mu.replicaSelectionStrategy
 		s.mu.RUnlock()
 		req.TxnScope = scope
 		req.ReadReplicaScope = scope
@@ -473,10 +479,13 @@ func (s *KVSnapshot) batchGetSingleRegion(bo *retry.Backoffer, batch batchKeys,
 			timeout = s.readTimeout
 		}
 		req.MaxExecutionDurationMs = uint64(timeout.Milliseconds())
-		ops := make([]locate.StoreSelectorOption, 0, 2)
+		ops := make([]locate.StoreSelectorOption, 0, 3)
 		if len(matchStoreLabels) > 0 {
 			ops = append(ops, locate.WithMatchLabels(matchStoreLabels))
 		}
+		if strategy != nil {
+			ops = append(ops, locate.WithReplicaSelectionStrategy(strategy))
+		}
 		if req.ReplicaReadType.IsFollowerRead() && replicaAdjuster != nil {
 			op, readType := replicaAdjuster(len(pending))
 			if op != nil {
@@ -684,6 +693,7 @@ func (s *KVSnapshot) get(ctx context.Context, bo *retry.Backoffer, k []byte) ([]
 			s.mergeRegionRequestStats(cli.Stats)
 		}()
 	}
+	cli.HedgePolicy = s.mu.hedgePolicy
 	req := tikvrpc.NewReplicaReadRequest(tikvrpc.CmdGet,
 		&kvrpcpb.GetRequest{
 			Key:     k,
@@ -707,6 +717,7 @@ func (s *KVSnapshot) get(ctx context.Context, bo *retry.Backoffer, k []byte) ([]
 	matchStoreLabels := s.mu.matchStoreLabels
 	scope := s.mu.readReplicaScope
 	replicaAdjuster := s.mu.replicaReadAdjuster
+	strategy := s.mu.replicaSelectionStrategy
 	s.mu.RUnlock()
 	req.TxnScope = scope
 	req.ReadReplicaScope = scope
@@ -717,6 +728,9 @@ func (s *KVSnapshot) get(ctx context.Context, bo *retry.Backoffer, k []byte) ([]
 	if len(matchStoreLabels) > 0 {
 		ops = append(ops, locate.WithMatchLabels(matchStoreLabels))
 	}
+	if strategy != nil {
+		ops = append(ops, locate.WithReplicaSelectionStrategy(strategy))
+	}
 	if req.ReplicaReadType.IsFollowerRead() && replicaAdjuster != nil {
 		op, readType := replicaAdjuster(1)
 		if op != nil {
@@ -936,6 +950,22 @@ func (s *KVSnapshot) SetLoadBasedReplicaReadThreshold(busyThreshold time.Duratio
 	}
 }
 
+// SetHedgePolicy enables hedged reads of Get, BatchGet and Scan with the
+// policy, nil disables it.
+func (s *KVSnapshot) SetHedgePolicy(policy *locate.HedgePolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mu.hedgePolicy = policy
+}
+
+// SetReplicaSelectionStrategy sets the strategy to decide the replica of Get,
+// BatchGet and Scan requests, nil uses the built-in selection.
+func (s *KVSnapshot) SetReplicaSelectionStrategy(strategy locate.ReplicaSelectionStrategy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mu.replicaSelectionStrategy = strategy
+}
+
 // SetIsStalenessReadOnly indicates whether the transaction is staleness read only transaction
 func (s *KVSnapshot) SetIsStalenessReadOnly(b bool) {
 	s.mu.Lock()
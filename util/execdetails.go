@@ -350,6 +350,11 @@ type TrafficDetails struct {
 	UnpackedBytesReceivedMPPTotal     int64
 	UnpackedBytesSentMPPCrossZone     int64
 	UnpackedBytesReceivedMPPCrossZone int64
+	// UncompressedBytesSent and CompressedBytesSent are the bytes of the
+	// requests sent before and after gRPC compression. A batched request
+	// takes its share of the compressed batch message by its size.
+	UncompressedBytesSent int64
+	CompressedBytesSent   int64
 }
 
 // FormatDuration uses to format duration, this function will prune precision before format duration.